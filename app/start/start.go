@@ -3,11 +3,13 @@ package start
 import (
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"swiflow/config"
+	"swiflow/support"
 
 	"github.com/wailsapp/wails/v3/pkg/application"
 	"github.com/wailsapp/wails/v3/pkg/events"
@@ -50,6 +52,15 @@ func GetMainView(app *application.App) *application.WebviewWindow {
 		app.Event.Emit("app:Uploaded", detail)
 	})
 
+	// MCP OAuth 授权需要打开系统浏览器
+	support.Listen("open-browser", func(uuid string, data any) {
+		if link, ok := data.(string); ok && link != "" {
+			if err := app.Browser.OpenURL(link); err != nil {
+				log.Println("open browser failed:", uuid, err)
+			}
+		}
+	})
+
 	closing := events.Common.WindowClosing
 	window.RegisterHook(closing, func(e *application.WindowEvent) {
 		docker.HideAppIcon()
@@ -202,11 +202,15 @@ export const getDisplayActDesc = (item: MsgAct): string => {
     }
     case "query-subtask": {
       const act = (item as QuerySubtask)
-      return `查询子任务: ${act['sub-agent']}`
+      return `查询子任务: ${act['subtask-id'] || act['sub-agent']}`
     }
     case "abort-subtask": {
       const act = (item as AbortSubtask)
-      return `终止子任务: ${act['sub-agent']}`
+      return `终止子任务: ${act['subtask-id'] || act['sub-agent']}`
+    }
+    case "join-subtasks": {
+      const act = (item as JoinSubtasks)
+      return `等待子任务: ${act['subtask-ids'] || '全部'}`
     }
     case 'path-list-files': {
       const act = (item as PathListFiles)
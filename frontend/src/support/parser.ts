@@ -12,6 +12,7 @@ export class XMLParser {
   private static readonly START_SUBTASK = "start-subtask"
   private static readonly QUERY_SUBTASK = "query-subtask"
   private static readonly ABORT_SUBTASK = "abort-subtask"
+  private static readonly JOIN_SUBTASKS = "join-subtasks"
   private static readonly EXECUTE_COMMAND = 'execute-command';
   private static readonly START_ASYNC_CMD = "start-async-cmd"
   private static readonly QUERY_ASYNC_CMD = "query-async-cmd"
@@ -150,6 +151,9 @@ export class XMLParser {
         }
         return result as MsgAct
       }
+      case XMLParser.JOIN_SUBTASKS: {
+        return result as MsgAct
+      }
       case XMLParser.USE_MCP_TOOL: {
         if (!result['tool']) { return null }
         if (!result['name']) { return null }
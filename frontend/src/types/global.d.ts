@@ -87,10 +87,17 @@ declare type StartSubtask = {
 
 declare type QuerySubtask = {
   "sub-agent": string;
+  "subtask-id"?: string;
 }
 
 declare type AbortSubtask = {
   "sub-agent": string;
+  "subtask-id"?: string;
+}
+
+declare type JoinSubtasks = {
+  "subtask-ids": string;
+  mode?: string;
 }
 
 declare type UseMcpTool = {
@@ -133,7 +140,7 @@ declare type MsgAct = (
    Annotate | Thinking | UserInput | BotReply
   | DefaultAction | MakeAsk | Complete
   | ExecuteCommand | UseMcpTool | GetMcpResource | UseBuiltinTool
-  | StartAsyncCmd | StartSubtask | QuerySubtask | AbortSubtask
+  | StartAsyncCmd | StartSubtask | QuerySubtask | AbortSubtask | JoinSubtasks
   | FileGetContent | FilePutContent | FileReplaceText | PathListFiles
 ) & DefaultResult & DefaultProps
 
@@ -222,8 +229,12 @@ declare type MemEntity = {
   id: string
   bot: string
   type: string
+  scope?: 'global' | 'bot' | 'project' | 'task'
+  target?: string
   subject: string
   content: string
+  importance?: number
+  expireAt?: string
 }
 
 declare type McpServer = {
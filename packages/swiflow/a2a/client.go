@@ -0,0 +1,194 @@
+package a2a
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// Client 通过 JSON-RPC 调用远程 agent
+type Client struct {
+	Url     string
+	Token   string
+	Headers map[string]string
+
+	client *http.Client
+	nextId atomic.Int64
+}
+
+func NewClient(url, token string, headers map[string]string) *Client {
+	return &Client{
+		Url: url, Token: token, Headers: headers,
+		client: &http.Client{},
+	}
+}
+
+// Card 读取远程 agent 的描述，用于校验地址与认证
+func (c *Client) Card(ctx context.Context) (*AgentCard, error) {
+	url := strings.TrimRight(c.Url, "/") + AGENT_CARD
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	card := new(AgentCard)
+	if err = json.NewDecoder(resp.Body).Decode(card); err != nil {
+		return nil, fmt.Errorf("decode agent card: %w", err)
+	}
+	return card, nil
+}
+
+// Send 发送消息并等待结果
+func (c *Client) Send(ctx context.Context, msg *Message) (*Event, error) {
+	event := new(Event)
+	params := map[string]any{"message": msg}
+	if err := c.call(ctx, METHOD_SEND, params, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// Get 查询远程任务状态
+func (c *Client) Get(ctx context.Context, taskId string) (*Event, error) {
+	event := new(Event)
+	params := map[string]any{"id": taskId}
+	if err := c.call(ctx, METHOD_GET, params, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// Cancel 取消远程任务
+func (c *Client) Cancel(ctx context.Context, taskId string) error {
+	params := map[string]any{"id": taskId}
+	return c.call(ctx, METHOD_CANCEL, params, new(Event))
+}
+
+// Stream 发送消息并以 SSE 接收事件，直到任务结束
+// 远程不支持流式时按普通 JSON 结果处理
+func (c *Client) Stream(ctx context.Context, msg *Message, handle func(*Event)) error {
+	params := map[string]any{"message": msg}
+	req, err := c.newRequest(ctx, METHOD_STREAM, params)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		event := new(Event)
+		if err := decodeResult(resp.Body, event); err != nil {
+			return err
+		}
+		handle(event)
+		return nil
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	var data strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimSpace(line[5:]))
+		case line == "" && data.Len() > 0:
+			event := new(Event)
+			if err := decodeResult(strings.NewReader(data.String()), event); err != nil {
+				return err
+			}
+			data.Reset()
+			handle(event)
+			if event.IsFinal() {
+				return nil
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("read stream: %w", err)
+		}
+	}
+}
+
+func (c *Client) call(ctx context.Context, method string, params any, result any) error {
+	req, err := c.newRequest(ctx, method, params)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResult(resp.Body, result)
+}
+
+func (c *Client) newRequest(ctx context.Context, method string, params any) (*http.Request, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	body, _ := json.Marshal(&Request{
+		JsonRPC: "2.0", Id: c.nextId.Add(1),
+		Method: method, Params: data,
+	})
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, c.Url, bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	for key, val := range c.Headers {
+		req.Header.Set(key, val)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request remote agent: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("remote agent status %d: %s", resp.StatusCode, body)
+	}
+	return resp, nil
+}
+
+func decodeResult(body io.Reader, result any) error {
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *RpcError       `json:"error"`
+	}
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if len(resp.Result) == 0 {
+		return fmt.Errorf("empty result")
+	}
+	return json.Unmarshal(resp.Result, result)
+}
@@ -0,0 +1,89 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_Stream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Method != METHOD_STREAM {
+			t.Errorf("方法应为 %s，实际 %s", METHOD_STREAM, req.Method)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"kind":"task","id":"t-1","status":{"state":"submitted"}}`,
+			`{"kind":"status-update","taskId":"t-1","status":{"state":"working","message":{"role":"agent","parts":[{"kind":"text","text":"thinking"}]}}}`,
+			`{"kind":"artifact-update","taskId":"t-1","artifact":{"artifactId":"a","parts":[{"kind":"text","text":"done"}]}}`,
+			`{"kind":"status-update","taskId":"t-1","status":{"state":"completed"},"final":true}`,
+			`{"kind":"status-update","taskId":"t-1","status":{"state":"working"}}`,
+		}
+		for _, event := range events {
+			fmt.Fprintf(w, "data: {\"jsonrpc\":\"2.0\",\"id\":1,\"result\":%s}\n\n", event)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "secret", nil)
+	texts, states := []string{}, []string{}
+	err := client.Stream(context.Background(), NewMessage("hi"), func(event *Event) {
+		if event.GetTaskId() != "t-1" {
+			t.Errorf("taskId 解析错误: %+v", event)
+		}
+		states = append(states, event.State())
+		if text := event.Text(); text != "" {
+			texts = append(texts, text)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Stream 失败: %v", err)
+	}
+	if len(states) != 4 || states[3] != STATE_COMPLETED {
+		t.Errorf("应在 final 事件后停止: %v", states)
+	}
+	if len(texts) != 2 || texts[1] != "done" {
+		t.Errorf("文本解析错误: %v", texts)
+	}
+
+	client.Token = "wrong"
+	if err = client.Stream(context.Background(), NewMessage("hi"), func(*Event) {}); err == nil {
+		t.Errorf("认证失败应返回错误")
+	}
+}
+
+func TestClient_Fallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		switch req.Method {
+		case METHOD_CANCEL:
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"error":{"code":-32002,"message":"not cancelable"}}`)
+		default:
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":{"kind":"message","role":"agent","parts":[{"kind":"text","text":"pong"}]}}`)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "", nil)
+	var got *Event
+	err := client.Stream(context.Background(), NewMessage("ping"), func(event *Event) {
+		got = event
+	})
+	if err != nil || got == nil || got.Text() != "pong" || !got.IsFinal() {
+		t.Errorf("非流式结果处理错误: %v %+v", err, got)
+	}
+	if err = client.Cancel(context.Background(), "t-1"); err == nil || err.Error() != "not cancelable" {
+		t.Errorf("应返回 JSON-RPC 错误: %v", err)
+	}
+}
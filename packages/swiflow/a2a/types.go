@@ -0,0 +1,202 @@
+package a2a
+
+import (
+	"encoding/json"
+	"strings"
+	"swiflow/support"
+)
+
+// A2A(Agent2Agent) 协议的 JSON-RPC 方法
+const (
+	METHOD_SEND   = "message/send"
+	METHOD_STREAM = "message/stream"
+	METHOD_GET    = "tasks/get"
+	METHOD_CANCEL = "tasks/cancel"
+)
+
+// 远程任务状态
+const (
+	STATE_SUBMITTED      = "submitted"
+	STATE_WORKING        = "working"
+	STATE_INPUT_REQUIRED = "input-required"
+	STATE_AUTH_REQUIRED  = "auth-required"
+	STATE_COMPLETED      = "completed"
+	STATE_CANCELED       = "canceled"
+	STATE_FAILED         = "failed"
+	STATE_REJECTED       = "rejected"
+	STATE_UNKNOWN        = "unknown"
+)
+
+// 事件类型
+const (
+	KIND_TASK     = "task"
+	KIND_MESSAGE  = "message"
+	KIND_STATUS   = "status-update"
+	KIND_ARTIFACT = "artifact-update"
+)
+
+// AGENT_CARD 远程 agent 的描述文件
+const AGENT_CARD = "/.well-known/agent.json"
+
+type Part struct {
+	Kind string `json:"kind"`
+	Text string `json:"text,omitempty"`
+	Data any    `json:"data,omitempty"`
+}
+
+type Message struct {
+	Kind      string `json:"kind"`
+	Role      string `json:"role"`
+	Parts     []Part `json:"parts"`
+	MessageId string `json:"messageId"`
+	TaskId    string `json:"taskId,omitempty"`
+	ContextId string `json:"contextId,omitempty"`
+}
+
+type TaskStatus struct {
+	State     string   `json:"state"`
+	Message   *Message `json:"message,omitempty"`
+	Timestamp string   `json:"timestamp,omitempty"`
+}
+
+type Artifact struct {
+	ArtifactId string `json:"artifactId"`
+	Name       string `json:"name,omitempty"`
+	Parts      []Part `json:"parts"`
+}
+
+// Event 是 message/send 与 message/stream 返回的结果
+// kind 为 task/message/status-update/artifact-update，字段按需填充
+type Event struct {
+	Kind      string `json:"kind"`
+	Id        string `json:"id,omitempty"`
+	TaskId    string `json:"taskId,omitempty"`
+	ContextId string `json:"contextId,omitempty"`
+
+	Role  string `json:"role,omitempty"`
+	Parts []Part `json:"parts,omitempty"`
+
+	Status    *TaskStatus `json:"status,omitempty"`
+	Artifact  *Artifact   `json:"artifact,omitempty"`
+	Artifacts []Artifact  `json:"artifacts,omitempty"`
+
+	Final bool `json:"final,omitempty"`
+}
+
+type AgentCard struct {
+	Name         string           `json:"name"`
+	Description  string           `json:"description"`
+	Url          string           `json:"url"`
+	Version      string           `json:"version"`
+	Capabilities map[string]any   `json:"capabilities"`
+	Skills       []map[string]any `json:"skills,omitempty"`
+}
+
+type Request struct {
+	JsonRPC string          `json:"jsonrpc"`
+	Id      any             `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type Response struct {
+	JsonRPC string    `json:"jsonrpc"`
+	Id      any       `json:"id"`
+	Result  any       `json:"result,omitempty"`
+	Error   *RpcError `json:"error,omitempty"`
+}
+
+type RpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RpcError) Error() string {
+	return e.Message
+}
+
+// NewMessage 构造用户发送的文本消息
+func NewMessage(text string) *Message {
+	uuid, _ := support.UniqueID()
+	return &Message{
+		Kind: KIND_MESSAGE, Role: "user", MessageId: uuid,
+		Parts: []Part{{Kind: "text", Text: text}},
+	}
+}
+
+// Text 合并消息中的文本
+func (m *Message) Text() string {
+	if m == nil {
+		return ""
+	}
+	return partsText(m.Parts)
+}
+
+func (e *Event) GetTaskId() string {
+	if e.Kind == KIND_TASK {
+		return e.Id
+	}
+	return e.TaskId
+}
+
+func (e *Event) State() string {
+	if e.Status != nil {
+		return e.Status.State
+	}
+	return ""
+}
+
+// Text 事件中可展示的文本
+func (e *Event) Text() string {
+	switch e.Kind {
+	case KIND_MESSAGE:
+		return partsText(e.Parts)
+	case KIND_ARTIFACT:
+		if e.Artifact != nil {
+			return partsText(e.Artifact.Parts)
+		}
+	case KIND_TASK:
+		texts := []string{}
+		for _, item := range e.Artifacts {
+			texts = append(texts, partsText(item.Parts))
+		}
+		if len(texts) > 0 {
+			return strings.Join(texts, "\n")
+		}
+	}
+	if e.Status != nil {
+		return e.Status.Message.Text()
+	}
+	return ""
+}
+
+// IsFinal 流是否已结束
+func (e *Event) IsFinal() bool {
+	if e.Final || e.Kind == KIND_MESSAGE {
+		return true
+	}
+	return IsTerminal(e.State())
+}
+
+// IsTerminal 任务不会再继续执行的状态
+func IsTerminal(state string) bool {
+	switch state {
+	case STATE_COMPLETED, STATE_CANCELED, STATE_FAILED,
+		STATE_REJECTED, STATE_INPUT_REQUIRED, STATE_AUTH_REQUIRED:
+		return true
+	}
+	return false
+}
+
+func partsText(parts []Part) string {
+	texts := []string{}
+	for _, part := range parts {
+		switch {
+		case part.Text != "":
+			texts = append(texts, part.Text)
+		case part.Data != nil:
+			texts = append(texts, support.ToJson(part.Data))
+		}
+	}
+	return strings.Join(texts, "\n")
+}
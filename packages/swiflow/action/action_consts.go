@@ -32,6 +32,7 @@ const (
 	START_SUBTASK = "start-subtask"
 	QUERY_SUBTASK = "query-subtask"
 	ABORT_SUBTASK = "abort-subtask"
+	JOIN_SUBTASKS = "join-subtasks"
 
 	// MCP工具
 	USE_MCP_TOOL     = "use-mcp-tool"
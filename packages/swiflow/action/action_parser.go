@@ -199,6 +199,21 @@ func Parse(data string) *SuperAction {
 	return msg
 }
 
+func (act *SuperAction) TaskID() string {
+	if act.Payload == nil {
+		return ""
+	}
+	return act.Payload.UUID
+}
+
+// 任务目录，用于区分 MCP 服务的隔离实例
+func (act *SuperAction) home() string {
+	if act.Payload == nil {
+		return ""
+	}
+	return act.Payload.Home
+}
+
 func (act *SuperAction) Hash() []string {
 	result := []string{}
 	for idx, act := range act.UseTools {
@@ -231,12 +246,16 @@ func (act *SuperAction) Hash() []string {
 		case *AbortAsyncCmd:
 			identifier = act.XMLName.Local + ":" + act.Session
 		// Subtask
+		// 同一 worker 可并行多个子任务，按任务内容区分
 		case *StartSubtask:
-			identifier = act.XMLName.Local + ":" + act.SubAgent
+			identifier = act.XMLName.Local + ":" + act.SubAgent + ":" +
+				cryptor.Sha1(act.TaskDesc+act.Context+act.Require)
 		case *QuerySubtask:
-			identifier = act.XMLName.Local + ":" + act.SubAgent
+			identifier = act.XMLName.Local + ":" + support.Or(act.SubtaskId, act.SubAgent)
 		case *AbortSubtask:
-			identifier = act.XMLName.Local + ":" + act.SubAgent
+			identifier = act.XMLName.Local + ":" + support.Or(act.SubtaskId, act.SubAgent)
+		case *JoinSubtasks:
+			identifier = act.XMLName.Local + ":" + act.SubtaskIds
 			// MCP工具
 		case *UseMcpTool:
 			identifier = act.XMLName.Local + ":" + act.Desc + ":" + act.Tool
@@ -285,6 +304,8 @@ func (msg *SuperAction) Merge(act *SuperAction) {
 			result[hash] = res.Result
 		case *AbortSubtask:
 			result[hash] = res.Result
+		case *JoinSubtasks:
+			result[hash] = res.Result
 		// MCP工具
 		case *UseMcpTool:
 			result[hash] = res.Result
@@ -323,6 +344,8 @@ func (msg *SuperAction) Merge(act *SuperAction) {
 			res.Result, _ = result[hash]
 		case *AbortSubtask:
 			res.Result, _ = result[hash]
+		case *JoinSubtasks:
+			res.Result, _ = result[hash]
 		// MCP工具
 		case *UseMcpTool:
 			res.Result, _ = result[hash]
@@ -438,6 +461,8 @@ func parse(text string) any {
 		detail = new(QuerySubtask)
 	case ABORT_SUBTASK:
 		detail = new(AbortSubtask)
+	case JOIN_SUBTASKS:
+		detail = new(JoinSubtasks)
 	// MCP工具
 	case USE_MCP_TOOL:
 		detail = new(UseMcpTool)
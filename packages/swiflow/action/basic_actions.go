@@ -77,6 +77,12 @@ type Memorize struct {
 	Subject  string   `xml:"subject" json:"subject"`
 	Content  string   `xml:"content" json:"content"`
 	Datetime string   `xml:"datetime" json:"datetime"`
+	// global/bot/project/task，默认 bot
+	Scope string `xml:"scope" json:"scope"`
+	// low/normal/high，默认 normal
+	Importance string `xml:"importance" json:"importance"`
+	// 有效期，如 7d、72h 或 2025-12-31，为空表示长期有效
+	Expire string `xml:"expire" json:"expire"`
 }
 
 // alias Summarise
@@ -1,9 +1,10 @@
 package action
 
 import (
-	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"log"
+	"strings"
 	"swiflow/amcp"
 )
 
@@ -20,25 +21,41 @@ type UseMcpTool struct {
 }
 
 func (act *UseMcpTool) Handle(super *SuperAction) any {
-	var client *amcp.McpClient
-	var server = &amcp.McpServer{UUID: act.Name}
-	if client = amcp.NewMcpClient(server); client == nil {
-		act.Result = fmt.Errorf(
-			"mcp server[%s][%s] not in service, err: %s",
-			act.Name, act.Tool, server.Status.ErrMsg,
-		)
+	client, err := amcp.GetInstance(act.Name, super.home())
+	if err != nil {
+		act.Result = fmt.Errorf("%v, tool: %s", err, act.Tool)
 		return act.Result
 	}
-	var args = map[string]any{}
-	var data = []byte(act.Args)
-	json.Unmarshal(data, &args)
+	audit := amcp.NewAudit(amcp.AUDIT_TOOL, act.Name, act.Tool, act.Args)
+	audit.Task, audit.Bot = super.TaskID(), super.WorkerID
+	defer func() { amcp.RecordAudit(audit, act.Result) }()
+	// 参数不合法时直接把错误返回给模型修正，同样记录审计
+	args, err := client.PrepareArgs(act.Tool, act.Args)
+	if err != nil {
+		act.Result = fmt.Errorf("error: %s", err)
+		return act.Result
+	}
+	audit.Args = amcp.HashArgs(args)
 	resp, err := client.Execute(
 		act.Tool, args,
 	)
-	if err == nil && resp != "" {
-		act.Result = resp
-	} else {
+	if err != nil || resp == nil {
 		act.Result = fmt.Errorf("error: %s", err)
+		return act.Result
+	}
+	// 图片等二进制内容保存到任务目录，以文件路径告知模型
+	if super.Payload != nil {
+		prefix := strings.ReplaceAll(act.Tool, "/", "-")
+		if err := resp.Save(super.Payload.Home, prefix); err != nil {
+			log.Println("[MCP] save output error:", err)
+		}
+	}
+	if text := resp.String(); resp.IsError {
+		act.Result = fmt.Errorf("error: %s", text)
+	} else if text != "" {
+		act.Result = text
+	} else {
+		act.Result = fmt.Errorf("error: empty result")
 	}
 	return act.Result
 }
@@ -55,20 +72,19 @@ type GetMcpResource struct {
 }
 
 func (act *GetMcpResource) Handle(super *SuperAction) any {
-	var client *amcp.McpClient
-	var server = &amcp.McpServer{UUID: act.Name}
-	if client = amcp.NewMcpClient(server); client == nil {
-		act.Result = fmt.Errorf(
-			"mcp server[%s][%s] not in service, err: %s",
-			act.Name, act.Uri, server.Status.ErrMsg,
-		)
+	client, err := amcp.GetInstance(act.Name, super.home())
+	if err != nil {
+		act.Result = fmt.Errorf("%v, uri: %s", err, act.Uri)
 		return act.Result
 	}
+	audit := amcp.NewAudit(amcp.AUDIT_RESOURCE, act.Name, act.Uri, nil)
+	audit.Task, audit.Bot = super.TaskID(), super.WorkerID
 	resp, err := client.Resource(act.Uri)
 	if err == nil && resp != "" {
 		act.Result = resp
 	} else {
 		act.Result = fmt.Errorf("error: %s", err)
 	}
+	amcp.RecordAudit(audit, act.Result)
 	return act.Result
 }
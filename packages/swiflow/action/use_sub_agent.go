@@ -2,6 +2,7 @@ package action
 
 import (
 	"encoding/xml"
+	"strings"
 )
 
 // StartSubtask 用于调用其他agent作为工具来执行特定任务
@@ -12,6 +13,10 @@ type StartSubtask struct {
 	TaskDesc string `xml:"task-desc" json:"task-desc"`
 	Context  string `xml:"context" json:"context"`
 	Require  string `xml:"require" json:"require"`
+	// 超时时间，如 10m，为空时使用默认值
+	Timeout string `xml:"timeout" json:"timeout"`
+	// 启动后返回，用于 query/abort/join
+	SubtaskId string `xml:"subtask-id" json:"subtask-id"`
 
 	Result any `xml:"result" json:"result"`
 }
@@ -28,7 +33,8 @@ func (action *StartSubtask) ToSubtask() *Subtask {
 type QuerySubtask struct {
 	XMLName xml.Name `xml:"query-subtask"`
 
-	SubAgent string `xml:"sub-agent" json:"sub-agent"`
+	SubAgent  string `xml:"sub-agent" json:"sub-agent"`
+	SubtaskId string `xml:"subtask-id" json:"subtask-id"`
 
 	Result any `xml:"result" json:"result"`
 }
@@ -37,7 +43,25 @@ type QuerySubtask struct {
 type AbortSubtask struct {
 	XMLName xml.Name `xml:"abort-subtask"`
 
-	SubAgent string `xml:"sub-agent" json:"sub-agent"`
+	SubAgent  string `xml:"sub-agent" json:"sub-agent"`
+	SubtaskId string `xml:"subtask-id" json:"subtask-id"`
 
 	Result any `xml:"result" json:"result"`
 }
+
+// JoinSubtasks 等待多个subtask完成后再继续
+type JoinSubtasks struct {
+	XMLName xml.Name `xml:"join-subtasks"`
+
+	SubtaskIds string `xml:"subtask-ids" json:"subtask-ids"`
+	// all: 全部完成，any: 任一完成
+	Mode string `xml:"mode" json:"mode"`
+
+	Result any `xml:"result" json:"result"`
+}
+
+func (action *JoinSubtasks) IDs() []string {
+	return strings.FieldsFunc(action.SubtaskIds, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\t'
+	})
+}
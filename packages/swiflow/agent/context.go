@@ -21,6 +21,10 @@ import (
 type Context struct {
 	usePrompt string
 	useMemory string
+	// 检索记忆使用的最新输入
+	query    string
+	scoped   []*entity.MemEntity
+	embedder model.Embedder
 
 	worker *Worker
 	mytask *MyTask
@@ -179,15 +183,6 @@ func (c *Context) DebugCall(op string, msgs []*model.Message) {
 	fileutil.WriteStringToFile(prompt, sysPrompt, false)
 }
 
-func (c *Context) Memorize(act *action.Memorize) error {
-	mem := &entity.MemEntity{
-		Bot: c.worker.UUID, Type: "chat",
-		Subject: act.Subject,
-		Content: act.Content,
-	}
-	return c.store.SaveMem(mem)
-}
-
 func (c *Context) Annotate(act *action.Annotate) error {
 	if act.Subject != "" {
 		c.mytask.Name = act.Subject
@@ -257,13 +252,19 @@ func (c *Context) GetMemory() string {
 		return c.useMemory
 	}
 	var memory strings.Builder
-	for _, mem := range c.worker.Memories {
+	recalled := c.RecallMemory()
+	c.touchMemory(recalled)
+	for _, item := range recalled {
+		mem := item.Mem
 		memory.WriteString("\n")
 		memorize := &action.Memorize{
 			Content:  strings.TrimSpace(mem.Content),
 			Subject:  strings.TrimSpace(mem.Subject),
 			Datetime: mem.CreatedAt.String(),
 		}
+		if mem.GetScope() != entity.SCOPE_BOT {
+			memorize.Scope = mem.GetScope()
+		}
 		memory.WriteString(support.ToXML(memorize, nil))
 	}
 	c.useMemory = memory.String()
@@ -284,14 +285,28 @@ func (c *Context) GetPrompt() string {
 		prompt, "${{SUBAGENTS}}",
 		c.getSubAgents(c.worker),
 	)
+	if c.worker.Leader != "" && len(c.worker.OutputSchema) > 0 {
+		prompt += OutputPrompt(c.worker.OutputSchema)
+	}
 	return c.getSystemInfo(prompt)
 }
 
+// 有下级的 worker 作为中层，同时拥有分派能力
+func (c *Context) promptKind() string {
+	if c.worker.Type != AGENT_WORKER || c.store == nil {
+		return c.worker.Type
+	}
+	if list, _ := c.store.LoadBot("leader = ?", c.worker.UUID); len(list) > 0 {
+		return AGENT_MANAGER
+	}
+	return c.worker.Type
+}
+
 func (c *Context) UsePrompt() *string {
 	if c.usePrompt != "" {
 		return &c.usePrompt
 	}
-	var prompt = initial.UsePrompt(c.worker.Type)
+	var prompt = initial.UsePrompt(c.promptKind())
 	prompt = strings.ReplaceAll(
 		prompt, "${{USER_PROMPT}}", c.worker.UsePrompt,
 	)
@@ -335,6 +350,12 @@ func (c *Context) getSubAgents(leader *Worker) string {
 			"- **%s** (id: %s): %s\n",
 			worker.Name, worker.UUID, worker.Desc,
 		))
+		if len(worker.OutputSchema) > 0 {
+			result.WriteString(fmt.Sprintf(
+				"  - 返回结构(JSON Schema): `%s`\n",
+				support.ToJson(worker.OutputSchema),
+			))
+		}
 	}
 	if result.Len() == 0 {
 		return "empty list"
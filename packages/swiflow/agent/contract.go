@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"swiflow/amcp"
+)
+
+// 子任务输出不符合约定时，最多退回 worker 修正的次数
+var MAX_OUTPUT_RETRY = 2
+
+var fencedOutput = regexp.MustCompile("(?s)^```[a-zA-Z]*\\s*\n?(.*?)\\s*```$")
+
+// ParseOutput 解析 complete 的内容并按 outputSchema 校验
+func ParseOutput(content string, schema map[string]any) (any, error) {
+	text := strings.TrimSpace(content)
+	if match := fencedOutput.FindStringSubmatch(text); match != nil {
+		text = strings.TrimSpace(match[1])
+	}
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return nil, fmt.Errorf("content is not valid JSON: %v", err)
+	}
+	resolved, err := amcp.AnyToSchema(schema).Resolve(nil)
+	if err != nil {
+		// schema 本身有误时不阻断，交由上级判断
+		return value, nil
+	}
+	if err = resolved.Validate(value); err != nil {
+		return value, fmt.Errorf("content does not match outputSchema: %v", err)
+	}
+	return value, nil
+}
+
+// FormatOutput 以 json 代码块交给上级，便于按类型读取
+func FormatOutput(value any) string {
+	data, _ := json.MarshalIndent(value, "", "  ")
+	return "```json\n" + string(data) + "\n```"
+}
+
+// OutputPrompt 告知 worker 完成任务时的返回格式
+func OutputPrompt(schema map[string]any) string {
+	data, _ := json.MarshalIndent(schema, "", "  ")
+	return "\n\n## 输出约定\n" +
+		"完成任务时 `complete` 的 `content` 必须是符合以下 JSON Schema 的 JSON，不要附加其他说明：\n" +
+		"```json\n" + string(data) + "\n```\n"
+}
@@ -72,6 +72,17 @@ func DiscoverWorkers(scanDir string) ([]*Worker, error) {
 			if len(scan.Tools) > 0 {
 				entity.Tools = scan.Tools
 			}
+			// 指定上级时可组成多级团队
+			if scan.Leader != "" {
+				entity.Leader = scan.Leader
+			}
+			if len(scan.OutputSchema) > 0 {
+				entity.OutputSchema = scan.OutputSchema
+			}
+			// 远程 worker 通过 A2A 协议调用
+			if scan.Remote != nil && scan.Remote.Url != "" {
+				entity.Type, entity.Remote = AGENT_REMOTE, scan.Remote
+			}
 			if len(scan.McpServers) > 0 {
 				entity.McpServers = scan.McpServers
 				for uuid := range scan.McpServers {
@@ -86,9 +97,37 @@ func DiscoverWorkers(scanDir string) ([]*Worker, error) {
 	}
 
 	for _, worker := range workers {
-		if worker.Type == AGENT_WORKER {
+		if worker.Type != AGENT_LEADER && worker.Leader == "" {
 			worker.Leader = leaderId
 		}
 	}
+	fixHierarchy(workers, leaderId)
 	return workers, nil
 }
+
+// fixHierarchy 上级不存在或形成环时挂回顶层 leader
+func fixHierarchy(workers []*Worker, leaderId string) {
+	index := map[string]*Worker{}
+	for _, worker := range workers {
+		index[worker.UUID] = worker
+	}
+	for _, worker := range workers {
+		if worker.Type == AGENT_LEADER || worker.Leader == "" {
+			continue
+		}
+		if _, ok := index[worker.Leader]; !ok {
+			log.Printf("[AGENT] leader of %s not found: %s", worker.UUID, worker.Leader)
+			worker.Leader = leaderId
+			continue
+		}
+		seen := map[string]bool{worker.UUID: true}
+		for curr := index[worker.Leader]; curr != nil; curr = index[curr.Leader] {
+			if seen[curr.UUID] {
+				log.Printf("[AGENT] leader cycle found at %s", worker.UUID)
+				worker.Leader = leaderId
+				break
+			}
+			seen[curr.UUID] = true
+		}
+	}
+}
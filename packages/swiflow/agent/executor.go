@@ -9,6 +9,7 @@ import (
 	"swiflow/config"
 	"swiflow/errors"
 	"swiflow/model"
+	"swiflow/storage"
 	"swiflow/support"
 	"time"
 
@@ -29,6 +30,7 @@ type Executor struct {
 	isTerminated bool   // 终止任务
 	currentTurns int    // 当前轮次
 	currentState string // 当前状态
+	lastError    string // 最近一次错误
 
 	modelClient model.LLMClient
 	fileWatcher *support.FileWatcher
@@ -50,6 +52,10 @@ const (
 
 	AGENT_LEADER = "leader"
 	AGENT_WORKER = "worker"
+	// 有下级的 worker，仅用于选择提示词
+	AGENT_MANAGER = "manager"
+	// 通过 A2A 协议调用的远程 worker
+	AGENT_REMOTE = "remote"
 )
 
 func (r *Executor) Resume() error {
@@ -68,6 +74,53 @@ func (r *Executor) IsRunning() bool {
 	return r.currentTurns > 0
 }
 
+// LastError 最近一次执行错误，子任务失败时交给上级
+func (r *Executor) LastError() string {
+	return r.lastError
+}
+
+func (r *Executor) emitError(err any) {
+	r.lastError = fmt.Sprint(err)
+	support.Emit("errors", r.UUID, err)
+}
+
+// Progress 返回执行进度，供上级 query-subtask 查询
+func (r *Executor) Progress() map[string]any {
+	return map[string]any{
+		"state":   support.Or(r.currentState, r.context.mytask.State),
+		"turns":   r.currentTurns,
+		"error":   r.lastError,
+		"running": r.IsRunning(),
+		"subject": r.context.GetSubject(),
+		"context": r.context.TaskContext(),
+	}
+}
+
+// UseModel 替换执行器使用的模型，评测时用于指定脚本模型
+func (r *Executor) UseModel(client model.LLMClient) {
+	r.modelClient = client
+}
+
+// UseStore 替换执行器上下文使用的存储，评测时用于隔离记忆与待办
+func (r *Executor) UseStore(store storage.MyStore) {
+	r.context.store = store
+}
+
+// Model 返回执行器当前使用的模型
+func (r *Executor) Model() model.LLMClient {
+	return r.modelClient
+}
+
+// Run 同步执行一次输入，直到任务结束后返回最终状态
+func (r *Executor) Run(input action.Input) string {
+	r.queueLock.Lock()
+	r.isTerminated = false
+	r.msgsQueue = append(r.msgsQueue, input)
+	r.queueLock.Unlock()
+	r.Handle()
+	return r.context.mytask.State
+}
+
 func (r *Executor) Enqueue(input action.Input) {
 	r.queueLock.Lock()
 	defer r.queueLock.Unlock()
@@ -90,6 +143,8 @@ func (r *Executor) Handle() *action.SuperAction {
 
 	r.startFileWatcher()
 	r.currentState = STATE_RUNNING
+	// 记录执行时 bot 的版本
+	r.context.mytask.BotVer = r.context.worker.Version
 	r.context.SetState(STATE_RUNNING)
 	for {
 		if len(r.msgsQueue) == 0 {
@@ -100,31 +155,31 @@ func (r *Executor) Handle() *action.SuperAction {
 		if maxTurns > 0 && r.currentTurns > maxTurns {
 			r.currentState = STATE_WAITING
 			log.Println("[EXEC] task", r.UUID, errors.ErrExceededMaximumTurns)
-			support.Emit("errors", r.UUID, errors.ErrExceededMaximumTurns)
+			r.emitError(errors.ErrExceededMaximumTurns)
 			break
 		}
 		if r.isTerminated {
 			r.currentState = STATE_CANCELED
 			log.Println("[EXEC] task", r.UUID, errors.ErrTaskTerminatedByUser)
-			support.Emit("errors", r.UUID, errors.ErrTaskTerminatedByUser)
+			r.emitError(errors.ErrTaskTerminatedByUser)
 			break
 		}
 		if r.context.HasMcpError() {
 			r.currentState = STATE_FAILED
 			log.Println("[EXEC] task", r.UUID, errors.ErrListMcpToolsError)
-			support.Emit("errors", r.UUID, errors.ErrListMcpToolsError)
+			r.emitError(errors.ErrListMcpToolsError)
 			break
 		}
 
 		currMsgId, _ := support.UniqueID()
-		messages := r.context.GetContext()
 
 		var lastOp, currOp = "", ""
 		var merged, content = "", ""
+		var inputs []*model.Message
 		for _, queued := range r.msgsQueue {
 			content, currOp = queued.Input()
 			role := r.context.GetMsgRole(currOp)
-			messages = append(messages, &model.Message{
+			inputs = append(inputs, &model.Message{
 				Content: content, Role: role,
 			})
 			if merged != "" {
@@ -134,6 +189,11 @@ func (r *Executor) Handle() *action.SuperAction {
 			lastOp = currOp
 		}
 		r.msgsQueue = nil
+		// 工具结果不作为检索条件，沿用上一次选出的记忆
+		if lastOp != "tool-result" {
+			r.context.Recall(merged)
+		}
+		messages := append(r.context.GetContext(), inputs...)
 		if merged != "" {
 			r.context.WriteMsg(&MyMsg{
 				IsSend: true, Request: merged,
@@ -159,14 +219,14 @@ func (r *Executor) Handle() *action.SuperAction {
 		if resp != nil && resp.ErrMsg != nil {
 			r.currentState = STATE_FAILED
 			log.Println("[EXEC] task", r.UUID, resp.ErrMsg)
-			support.Emit("errors", r.UUID, resp.ErrMsg)
+			r.emitError(resp.ErrMsg)
 			continue
 		}
 		// step 3. handle empty response
 		if resp != nil && resp.Origin == "" {
 			r.currentState = STATE_FAILED
 			log.Println("[EXEC] task", r.UUID, errors.ErrEmptyLlmResponse)
-			support.Emit("errors", r.UUID, errors.ErrEmptyLlmResponse)
+			r.emitError(errors.ErrEmptyLlmResponse)
 			break
 		}
 
@@ -183,7 +243,7 @@ func (r *Executor) Handle() *action.SuperAction {
 		if r.isTerminated {
 			r.currentState = STATE_CANCELED
 			log.Println("[EXEC] task", r.UUID, errors.ErrTaskTerminatedByUser)
-			support.Emit("errors", r.UUID, errors.ErrTaskTerminatedByUser)
+			r.emitError(errors.ErrTaskTerminatedByUser)
 			break
 		}
 
@@ -293,6 +353,8 @@ func (r *Executor) PlayAction(super *action.SuperAction) string {
 			support.Emit("subtask", r.UUID, act)
 		case *action.AbortSubtask:
 			support.Emit("subtask", r.UUID, act)
+		case *action.JoinSubtasks:
+			support.Emit("subtask", r.UUID, act)
 		}
 	}
 	if len(replyMsgs) == 0 {
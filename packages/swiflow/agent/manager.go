@@ -11,6 +11,7 @@ import (
 	"swiflow/config"
 	"swiflow/entity"
 	"swiflow/model"
+	"swiflow/secret"
 	"swiflow/storage"
 	"swiflow/support"
 	"sync"
@@ -32,7 +33,11 @@ type Manager struct {
 	configs map[string]any
 
 	executors map[string]*Executor
+	// key: subtask id，即子任务的 task uuid
 	subagents map[string]*SubAgent
+	// key: 上级任务 uuid
+	joins   map[string]*joinWait
+	subLock sync.Mutex
 	// ensure event listeners registered only once
 	initOnce sync.Once
 }
@@ -43,6 +48,7 @@ func NewManager() *Manager {
 	m.configs = map[string]any{}
 	m.executors = map[string]*Executor{}
 	m.subagents = map[string]*SubAgent{}
+	m.joins = map[string]*joinWait{}
 	return m
 }
 
@@ -85,20 +91,29 @@ func (m *Manager) onSubtask(tid string, data any) {
 	switch act := data.(type) {
 	case *action.StartSubtask:
 		log.Println("[AGENT] start subtask", tid, act.SubAgent)
-		subagent := m.GetSubAgent(act.SubAgent, leader, task)
-		if subagent.target != nil && subagent.target.UUID != tid {
-			return
+		subagent := &SubAgent{
+			parent: m, leader: leader, target: task,
 		}
 		subagent.OnStart(act)
-		log.Println("[AGENT] booted subtask", tid, act.SubAgent)
+		log.Println("[AGENT] booted subtask", tid, act.SubtaskId)
+	case *action.QuerySubtask:
+		log.Println("[AGENT] query subtask", tid, act.SubtaskId)
+		if sa := m.FindSubAgent(task, act.SubtaskId, act.SubAgent); sa != nil {
+			sa.OnQuery(act)
+		} else {
+			m.replyTo(task, leader, support.ToXML(act, "subtask not found"))
+		}
 	case *action.AbortSubtask:
-		log.Println("[AGENT] abort subtask", tid, act.SubAgent)
-		subagent := m.GetSubAgent(act.SubAgent, leader, task)
-		if subagent.target != nil && subagent.target.UUID != tid {
-			return
+		log.Println("[AGENT] abort subtask", tid, act.SubtaskId)
+		if sa := m.FindSubAgent(task, act.SubtaskId, act.SubAgent); sa != nil {
+			sa.OnAbort(act)
+		} else {
+			m.replyTo(task, leader, support.ToXML(act, "subtask not found"))
 		}
-		subagent.OnAbort(act)
-		log.Println("[AGENT] leave subtask", tid, act.SubAgent)
+		log.Println("[AGENT] leave subtask", tid, act.SubtaskId)
+	case *action.JoinSubtasks:
+		log.Println("[AGENT] join subtasks", tid, act.SubtaskIds)
+		m.JoinSubtasks(act, leader, task)
 	}
 }
 
@@ -107,16 +122,9 @@ func (m *Manager) onComplete(tid string, data any) {
 	if tid == "" {
 		return
 	}
-	var subagent *SubAgent
-	for _, item := range m.subagents {
-		if item.mytask == nil {
-			continue
-		}
-		if item.mytask.UUID == tid {
-			subagent = item
-			break
-		}
-	}
+	m.subLock.Lock()
+	subagent := m.subagents[tid]
+	m.subLock.Unlock()
 	log.Println("[AGENT] task complete", tid)
 	act, _ := data.(*action.Complete)
 	if subagent != nil && act != nil {
@@ -126,6 +134,26 @@ func (m *Manager) onComplete(tid string, data any) {
 	}
 }
 
+// onControl handles "control" events
+// 子任务失败或被取消时通知上级，避免上级一直等待
+func (m *Manager) onControl(tid string, data any) {
+	state, _ := data.(string)
+	if state != STATE_FAILED && state != STATE_CANCELED {
+		return
+	}
+	m.subLock.Lock()
+	subagent := m.subagents[tid]
+	m.subLock.Unlock()
+	if subagent == nil {
+		return
+	}
+	lastErr := ""
+	if executor, _ := m.FindExecutor(tid); executor != nil {
+		lastErr = executor.LastError()
+	}
+	subagent.OnFailed(state, lastErr)
+}
+
 func (m *Manager) Start(input action.Input, task *MyTask, leader *Worker) {
 	// debug mode, it's worker
 	if leader.Leader != "" {
@@ -137,6 +165,7 @@ func (m *Manager) Start(input action.Input, task *MyTask, leader *Worker) {
 	m.initOnce.Do(func() {
 		support.Once("subtask", m.onSubtask)
 		support.Once("complete", m.onComplete)
+		support.Once("control", m.onControl)
 	})
 
 	task.Group = task.UUID
@@ -309,12 +338,33 @@ func (m *Manager) GetLLMConfig(name string) *model.LLMConfig {
 	if provider != "" && cfg.Provider == "" {
 		cfg.Provider = provider
 	}
+	// apiKey 可引用命名密钥 secret://NAME
+	if key, err := secret.Resolve(cfg.ApiKey); err == nil {
+		cfg.ApiKey = key
+	} else {
+		log.Println("[AGENT] resolve api key error", err)
+		cfg.ApiKey = ""
+	}
 	if cfg.ApiKey == "" && provider != "" {
 		return m.GetLLMConfig("")
 	}
 	return cfg
 }
 
+// GetEmbedder 使用 EMBED_PROVIDER 对应的模型配置生成向量，未配置时返回 nil
+func (m *Manager) GetEmbedder() model.Embedder {
+	name := config.Get("EMBED_PROVIDER")
+	if name == "" {
+		return nil
+	}
+	cfg := m.GetLLMConfig(name)
+	if cfg == nil {
+		return nil
+	}
+	cfg.UseModel = config.GetStr("EMBED_MODEL", "text-embedding-3-small")
+	return model.NewEmbedModel(*cfg)
+}
+
 func (m *Manager) GetExecutor(task *MyTask, worker *Worker) *Executor {
 	payload := &Payload{
 		UUID: task.UUID,
@@ -325,6 +375,8 @@ func (m *Manager) GetExecutor(task *MyTask, worker *Worker) *Executor {
 		mytask: task,
 		worker: worker,
 		store:  m.store,
+
+		embedder: m.GetEmbedder(),
 	}
 	executor := &Executor{
 		UUID:    task.UUID,
@@ -339,7 +391,7 @@ func (m *Manager) GetExecutor(task *MyTask, worker *Worker) *Executor {
 
 	switch worker.Type {
 	case AGENT_DEBUG, AGENT_BASIC:
-	case AGENT_LEADER, AGENT_WORKER:
+	case AGENT_LEADER, AGENT_WORKER, AGENT_REMOTE:
 	default:
 		if worker.Leader != "" {
 			worker.Type = AGENT_WORKER
@@ -372,21 +424,6 @@ func (m *Manager) FindExecutor(tid string) (*Executor, error) {
 	return nil, fmt.Errorf("executor not found")
 }
 
-// GetSubAgent creates or retrieves a SubAgent instance for the given task and leader
-func (m *Manager) GetSubAgent(key string, leader *Worker, task *MyTask) *SubAgent {
-	if subagent, ok := m.subagents[key]; ok {
-		return subagent
-	}
-
-	subagent := &SubAgent{
-		leader: leader,
-		target: task,
-		parent: m,
-	}
-	m.subagents[key] = subagent
-	return subagent
-}
-
 func (m *Manager) GetMemory(worker *Worker) string {
 	var memory strings.Builder
 	for _, mem := range worker.Memories {
@@ -447,13 +484,8 @@ func (h *Manager) UpdateEnv(cfg *entity.CfgEntity) error {
 				continue
 			}
 
-			origin := config.GetStr("CURRENT_HOME", "")
-			if origin != "" && origin != fmt.Sprint(val) {
-				config.Set("CURRENT_HOME", fmt.Sprint(val))
-				support.Emit("mcp-reboot", "UpdateEnv", nil)
-			} else {
-				config.Set("CURRENT_HOME", fmt.Sprint(val))
-			}
+			// 依赖 $CURRENT_HOME 的 MCP 服务按目录区分实例，切换目录无需重启
+			config.Set("CURRENT_HOME", fmt.Sprint(val))
 		case "ctxMsgSize":
 			err = config.Set("CTX_MSG_SIZE", fmt.Sprint(val))
 		case "maxCallTurns":
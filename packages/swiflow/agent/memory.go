@@ -0,0 +1,381 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"swiflow/action"
+	"swiflow/config"
+	"swiflow/entity"
+	"swiflow/model"
+	"swiflow/support"
+	"time"
+)
+
+const (
+	// 判定为重复记忆的相似度
+	DUP_COSINE  = 0.92
+	DUP_JACCARD = 0.8
+	// 整理时判定为相关记忆的相似度
+	MERGE_COSINE  = 0.8
+	MERGE_JACCARD = 0.4
+)
+
+// Memorize 写入记忆，与已有记忆高度相似时更新已有记忆而不是新增
+func (c *Context) Memorize(act *action.Memorize) error {
+	mem := &entity.MemEntity{
+		Bot: c.worker.UUID, Type: "chat",
+		Subject: act.Subject, Content: act.Content,
+		Importance: entity.ParseImportance(act.Importance),
+		ExpireAt:   ParseExpire(act.Expire),
+	}
+	switch mem.Scope = entity.ParseScope(act.Scope); mem.Scope {
+	case entity.SCOPE_PROJECT:
+		mem.Target = c.mytask.Home
+	case entity.SCOPE_TASK:
+		mem.Target = c.mytask.UUID
+	}
+	// 没有工作目录时无法区分项目
+	if mem.Scope == entity.SCOPE_PROJECT && mem.Target == "" {
+		mem.Scope = entity.SCOPE_BOT
+	}
+	// 向量在锁外生成，避免阻塞其他任务检索记忆
+	if c.embedder != nil {
+		if vectors, err := c.embedder.Embed([]string{mem.EmbedText()}); err == nil {
+			mem.Vector, mem.Embedder = vectors[0], c.embedder.Name()
+		}
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	if dup := c.findDuplicate(mem); dup != nil {
+		log.Println("[MEMORY] merge into", dup.ID, dup.Subject)
+		mergeMemory(dup, mem)
+		mem = dup
+	}
+	if err := c.store.SaveMem(mem); err != nil {
+		return err
+	}
+	c.syncMemory(mem)
+	return nil
+}
+
+// findDuplicate 在同一作用范围内查找与新记忆最相似且超过阈值的已有记忆
+func (c *Context) findDuplicate(mem *entity.MemEntity) *entity.MemEntity {
+	query := []any{"scope = ? AND target = ?", mem.Scope, mem.Target}
+	switch mem.Scope {
+	case entity.SCOPE_BOT:
+		query = []any{"bot = ?", mem.Bot}
+	case entity.SCOPE_GLOBAL:
+		query = []any{"scope = ?", mem.Scope}
+	}
+	list, err := c.store.LoadMem(query...)
+	if err != nil {
+		return nil
+	}
+	var found *entity.MemEntity
+	var best float64
+	for _, item := range list {
+		if !item.SameScope(mem) || item.IsExpired() {
+			continue
+		}
+		score, semantic := similarity(mem, item)
+		limit := support.If(semantic, DUP_COSINE, DUP_JACCARD)
+		if score >= limit && score > best {
+			found, best = item, score
+		}
+	}
+	return found
+}
+
+// syncMemory 同步 worker 上缓存的记忆，新记忆下一次检索即可使用
+func (c *Context) syncMemory(mem *entity.MemEntity) {
+	if mem.GetScope() != entity.SCOPE_BOT {
+		if c.scoped != nil && c.applies(mem) {
+			for i, item := range c.scoped {
+				if item.ID == mem.ID {
+					c.scoped[i] = mem
+					return
+				}
+			}
+			c.scoped = append(c.scoped, mem)
+		}
+		return
+	}
+	for i := range c.worker.Memories {
+		if c.worker.Memories[i].ID == mem.ID {
+			c.worker.Memories[i] = *mem
+			return
+		}
+	}
+	c.worker.Memories = append(c.worker.Memories, *mem)
+}
+
+// touchMemory 记录记忆被使用的时间和次数
+func (c *Context) touchMemory(result []*Recalled) {
+	now := time.Now()
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	for _, item := range result {
+		c.saveCached(item.Mem, func(mem *entity.MemEntity) bool {
+			mem.UsedAt = &now
+			mem.UsedCount += 1
+			return true
+		})
+	}
+}
+
+// saveCached 检索结果是 worker 记忆的副本，修改并保存缓存中的同一条记忆，
+// fn 返回 false 时不保存；需持有 memoryLock
+func (c *Context) saveCached(mem *entity.MemEntity, fn func(*entity.MemEntity) bool) {
+	target := mem
+	if mem.GetScope() == entity.SCOPE_BOT {
+		target = nil
+		for i := range c.worker.Memories {
+			if c.worker.Memories[i].ID == mem.ID {
+				target = &c.worker.Memories[i]
+				break
+			}
+		}
+	}
+	// 已被整理删除的记忆不再保存，避免重新写回
+	if target == nil || !fn(target) {
+		return
+	}
+	if c.store != nil && target.ID != 0 {
+		c.store.SaveMem(target)
+	}
+}
+
+// similarity 两条记忆的相似度，都有同一模型生成的向量时使用余弦相似度
+func similarity(a, b *entity.MemEntity) (float64, bool) {
+	if len(a.Vector) > 0 && a.Embedder != "" && a.Embedder == b.Embedder {
+		return support.Cosine(a.Vector, b.Vector), true
+	}
+	return support.Jaccard(a.EmbedText(), b.EmbedText()), false
+}
+
+// isDuplicate 两条记忆内容基本相同
+func isDuplicate(a, b *entity.MemEntity) bool {
+	score, semantic := similarity(a, b)
+	return score >= support.If(semantic, DUP_COSINE, DUP_JACCARD)
+}
+
+// mergeMemory 用新内容更新已有记忆，保留较高的重要程度和较晚的有效期
+func mergeMemory(dst, src *entity.MemEntity) {
+	dst.Content = src.Content
+	if src.Subject != "" {
+		dst.Subject = src.Subject
+	}
+	dst.Importance = max(dst.Importance, src.Importance)
+	dst.ExpireAt = laterExpire(dst.ExpireAt, src.ExpireAt)
+	dst.Vector, dst.Embedder = src.Vector, src.Embedder
+}
+
+// laterExpire 为空表示长期有效
+func laterExpire(a, b *time.Time) *time.Time {
+	if a == nil || b == nil {
+		return nil
+	}
+	if a.After(*b) {
+		return a
+	}
+	return b
+}
+
+// ParseExpire 解析有效期：7d、72h 或 2006-01-02，为空表示长期有效
+func ParseExpire(value string) *time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	now := time.Now()
+	if days, found := strings.CutSuffix(value, "d"); found {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			expire := now.AddDate(0, 0, n)
+			return &expire
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		expire := now.Add(d)
+		return &expire
+	}
+	for _, layout := range []string{time.DateTime, time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
+
+// ConsolidateMemory 删除过期记忆，并请模型合并每个 bot 下相关的记忆
+func (m *Manager) ConsolidateMemory() {
+	for _, worker := range m.workers {
+		var client model.LLMClient
+		if cfg := m.GetLLMConfig(worker.Provider); cfg != nil {
+			cfg.TaskId = "memory-" + worker.UUID
+			client = model.GetClient(cfg)
+		}
+		if err := m.consolidate(worker, client); err != nil {
+			log.Println("[MEMORY] consolidate", worker.UUID, err)
+		}
+	}
+}
+
+func (m *Manager) consolidate(worker *Worker, client model.LLMClient) error {
+	list, err := m.store.LoadMem("bot = ?", worker.UUID)
+	if err != nil {
+		return err
+	}
+
+	mems := []*entity.MemEntity{}
+	for _, mem := range list {
+		if mem.Bot != worker.UUID {
+			continue
+		}
+		if mem.IsExpired() {
+			log.Println("[MEMORY] remove expired", mem.ID, mem.Subject)
+			mem.DeletedAt.Time = time.Now()
+			m.store.SaveMem(mem)
+			continue
+		}
+		mems = append(mems, mem)
+	}
+
+	// 调用模型时不持有锁，写入前确认这组记忆在此期间没有变化
+	limit := config.GetInt("MEMORY_MERGE_GROUPS", 10)
+	for _, group := range relatedGroups(mems, limit) {
+		if client == nil {
+			break
+		}
+		merged, err := mergeByModel(client, group)
+		if err != nil {
+			log.Println("[MEMORY] merge error", err)
+			continue
+		}
+		memoryLock.Lock()
+		if m.memChanged(group) {
+			memoryLock.Unlock()
+			log.Println("[MEMORY] skip merge, memory changed", group[0].ID)
+			continue
+		}
+		keep := group[0]
+		keep.Subject, keep.Content = merged.Subject, merged.Content
+		keep.Vector, keep.Embedder = nil, ""
+		for _, mem := range group[1:] {
+			keep.Importance = max(keep.Importance, mem.Importance)
+			keep.ExpireAt = laterExpire(keep.ExpireAt, mem.ExpireAt)
+			keep.UsedCount += mem.UsedCount
+			if mem.UsedAt != nil && (keep.UsedAt == nil || mem.UsedAt.After(*keep.UsedAt)) {
+				keep.UsedAt = mem.UsedAt
+			}
+			mem.DeletedAt.Time = time.Now()
+			m.store.SaveMem(mem)
+		}
+		m.store.SaveMem(keep)
+		memoryLock.Unlock()
+		log.Println("[MEMORY] merged", len(group), "into", keep.ID)
+	}
+	// 按存储重新加载，整理期间新写入的记忆不会丢失
+	return m.reloadMemory(worker)
+}
+
+// memChanged 组内记忆已被删除或更新；需持有 memoryLock
+func (m *Manager) memChanged(group []*entity.MemEntity) bool {
+	for _, mem := range group {
+		curr := &entity.MemEntity{ID: mem.ID}
+		if err := m.store.FindMem(curr); err != nil {
+			return true
+		}
+		if !curr.UpdatedAt.Equal(mem.UpdatedAt) || curr.Content != mem.Content {
+			return true
+		}
+	}
+	return false
+}
+
+// relatedGroups 把相关的记忆聚成组，只返回需要合并的组
+func relatedGroups(mems []*entity.MemEntity, limit int) [][]*entity.MemEntity {
+	groups := [][]*entity.MemEntity{}
+	used := make([]bool, len(mems))
+	for i := range mems {
+		if used[i] || len(groups) >= limit {
+			continue
+		}
+		group := []*entity.MemEntity{mems[i]}
+		for j := i + 1; j < len(mems); j++ {
+			if used[j] || !mems[i].SameScope(mems[j]) {
+				continue
+			}
+			score, semantic := similarity(mems[i], mems[j])
+			if score >= support.If(semantic, MERGE_COSINE, MERGE_JACCARD) {
+				group, used[j] = append(group, mems[j]), true
+			}
+		}
+		if len(group) > 1 {
+			used[i] = true
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// mergeByModel 请模型把一组相关记忆合并为一条
+func mergeByModel(client model.LLMClient, group []*entity.MemEntity) (*action.Memorize, error) {
+	var memory strings.Builder
+	for _, mem := range group {
+		memory.WriteString(support.ToXML(&action.Memorize{
+			Subject: mem.Subject, Content: mem.Content,
+		}, nil))
+		memory.WriteString("\n")
+	}
+	msgs := []model.Message{
+		{Role: "system", Content: MERGE_MEMORY_PROMPT},
+		{Role: "user", Content: memory.String()},
+	}
+	choices, err := client.Respond("memory", msgs)
+	if err != nil {
+		return nil, err
+	}
+	if len(choices) == 0 {
+		return nil, fmt.Errorf("empty response of llm")
+	}
+	for _, tool := range action.Parse(choices[0].Message.Content).UseTools {
+		if act, ok := tool.(*action.Memorize); ok && act.Content != "" {
+			return act, nil
+		}
+	}
+	return nil, fmt.Errorf("no memorize in response")
+}
+
+const MERGE_MEMORY_PROMPT = `你负责整理记忆。下面是同一个助手保存的多条相关记忆，请合并为一条：
+- 保留所有仍然有效的事实、经验、偏好和要求；
+- 去掉重复内容，存在冲突时以较新的记录为准；
+- 只输出一个 <memorize> 标签，包含 <subject> 和 <content>。`
+
+// ReloadMemory 从存储重新加载 bot 的记忆，导入记忆后使用
+func (m *Manager) ReloadMemory(uuid string) error {
+	worker, err := m.GetWorker(uuid)
+	if err != nil {
+		return err
+	}
+	return m.reloadMemory(worker)
+}
+
+// reloadMemory 加载与替换在同一次加锁内完成，避免覆盖并发写入的记忆
+func (m *Manager) reloadMemory(worker *Worker) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	list, err := m.store.LoadMem("bot = ?", worker.UUID)
+	if err != nil {
+		return err
+	}
+	memories := []entity.MemEntity{}
+	for _, mem := range list {
+		if mem.Bot == worker.UUID {
+			memories = append(memories, *mem)
+		}
+	}
+	worker.Memories = memories
+	return nil
+}
@@ -0,0 +1,217 @@
+package agent
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"swiflow/action"
+	"swiflow/entity"
+	"swiflow/model"
+	"swiflow/storage"
+	"testing"
+	"time"
+)
+
+// mergeClient 返回固定的合并结果，hook 在模型调用期间执行
+type mergeClient struct {
+	reply string
+	hook  func()
+}
+
+func (c *mergeClient) Cancel(string) error { return nil }
+
+func (c *mergeClient) Stream(string, []model.Message, model.Handle) error { return nil }
+
+func (c *mergeClient) Respond(string, []model.Message) ([]model.Choice, error) {
+	if c.hook != nil {
+		c.hook()
+	}
+	choice := model.Choice{}
+	choice.Message.Content = c.reply
+	return []model.Choice{choice}, nil
+}
+
+func memoryStore(t *testing.T) storage.MyStore {
+	path := filepath.Join(t.TempDir(), "test.db")
+	store, err := storage.NewSQLiteStorage(map[string]any{"path": path})
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	if err = store.AutoMigrate(); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	return store
+}
+
+func TestContext_MemorizeDedupe(t *testing.T) {
+	store := memoryStore(t)
+	worker := &Worker{UUID: "tester"}
+	ctx := &Context{worker: worker, mytask: &MyTask{UUID: "task"}, store: store}
+
+	ctx.Memorize(&action.Memorize{Subject: "代码风格", Content: "Go 代码提交前需要执行 gofmt 格式化"})
+	ctx.Memorize(&action.Memorize{Subject: "代码风格", Content: "Go 代码提交前需要执行 gofmt 格式化。", Importance: "high"})
+	ctx.Memorize(&action.Memorize{Subject: "周报", Content: "每周五下午提交周报", Expire: "7d"})
+
+	list, _ := store.LoadMem("bot = ?", "tester")
+	if len(list) != 2 {
+		t.Fatalf("重复记忆应合并，实际 %d 条", len(list))
+	}
+	for _, mem := range list {
+		switch mem.Subject {
+		case "代码风格":
+			if mem.Importance != entity.MEM_HIGH || !strings.HasSuffix(mem.Content, "。") {
+				t.Errorf("合并后未更新内容或重要程度: %+v", mem)
+			}
+		case "周报":
+			if mem.ExpireAt == nil || mem.ExpireAt.Before(time.Now().AddDate(0, 0, 6)) {
+				t.Errorf("有效期错误: %v", mem.ExpireAt)
+			}
+		}
+	}
+	if len(worker.Memories) != 2 {
+		t.Errorf("worker 缓存的记忆未同步: %d", len(worker.Memories))
+	}
+	// 注入上下文后记录使用次数
+	ctx.GetMemory()
+	if list, _ = store.LoadMem("bot = ?", "tester"); list[0].UsedCount != 1 || list[0].UsedAt == nil {
+		t.Errorf("未记录使用情况: %+v", list[0])
+	}
+}
+
+func TestManager_ConsolidateMemory(t *testing.T) {
+	store := memoryStore(t)
+	past := time.Now().Add(-time.Hour)
+	for _, mem := range []*entity.MemEntity{
+		{Bot: "tester", Subject: "部署", Content: "服务通过 docker compose 部署到测试环境"},
+		{Bot: "tester", Subject: "部署", Content: "服务通过 docker compose 部署，端口为 8080"},
+		{Bot: "tester", Subject: "周报", Content: "每周五下午提交周报"},
+		{Bot: "tester", Subject: "临时", Content: "今天下午停电", ExpireAt: &past},
+	} {
+		store.SaveMem(mem)
+	}
+	manager := NewManager()
+	manager.store = store
+	worker := &Worker{UUID: "tester"}
+	client := &mergeClient{reply: "<memorize>\n<subject>部署</subject>\n<content>服务通过 docker compose 部署到测试环境，端口为 8080</content>\n</memorize>"}
+	if err := manager.consolidate(worker, client); err != nil {
+		t.Fatalf("整理失败: %v", err)
+	}
+
+	list, _ := store.LoadMem("bot = ?", "tester")
+	if len(list) != 2 {
+		t.Fatalf("应剩余 2 条记忆，实际 %d 条", len(list))
+	}
+	for _, mem := range list {
+		if mem.Subject == "临时" {
+			t.Errorf("过期记忆未删除")
+		}
+		if mem.Subject == "部署" && !strings.Contains(mem.Content, "8080") {
+			t.Errorf("相关记忆未合并: %s", mem.Content)
+		}
+	}
+	if len(worker.Memories) != 2 {
+		t.Errorf("worker 缓存的记忆未刷新: %d", len(worker.Memories))
+	}
+}
+
+func TestManager_ConsolidateConcurrent(t *testing.T) {
+	store := memoryStore(t)
+	for _, mem := range []*entity.MemEntity{
+		{Bot: "tester", Subject: "部署", Content: "服务通过 docker compose 部署到测试环境"},
+		{Bot: "tester", Subject: "部署", Content: "服务通过 docker compose 部署，端口为 8080"},
+	} {
+		store.SaveMem(mem)
+	}
+	manager := NewManager()
+	manager.store = store
+	worker := &Worker{UUID: "tester"}
+	ctx := &Context{worker: worker, mytask: &MyTask{UUID: "task"}, store: store}
+	// 模型合并期间写入新记忆并检索，不应阻塞，也不应在整理后丢失
+	client := &mergeClient{
+		reply: "<memorize>\n<subject>部署</subject>\n<content>docker compose 部署，端口 8080</content>\n</memorize>",
+		hook: func() {
+			ctx.Memorize(&action.Memorize{Subject: "周报", Content: "每周五下午提交周报"})
+			ctx.RecallMemory()
+		},
+	}
+	done := make(chan error, 1)
+	go func() { done <- manager.consolidate(worker, client) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("整理失败: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("模型调用期间写入记忆被阻塞")
+	}
+	subjects := []string{}
+	for _, mem := range worker.Memories {
+		subjects = append(subjects, mem.Subject)
+	}
+	if len(subjects) != 2 || !slices.Contains(subjects, "周报") {
+		t.Errorf("整理期间写入的记忆丢失: %v", subjects)
+	}
+}
+
+func TestParseExpire(t *testing.T) {
+	now := time.Now()
+	cases := map[string]time.Duration{
+		"7d": 7 * 24 * time.Hour, "72h": 72 * time.Hour,
+	}
+	for value, want := range cases {
+		got := ParseExpire(value)
+		if got == nil || got.Sub(now).Round(time.Hour) != want {
+			t.Errorf("%s 解析错误: %v", value, got)
+		}
+	}
+	if got := ParseExpire("2030-01-02"); got == nil || got.Year() != 2030 {
+		t.Errorf("日期解析错误: %v", got)
+	}
+	if ParseExpire("") != nil || ParseExpire("someday") != nil {
+		t.Errorf("无效有效期应返回 nil")
+	}
+}
+
+func TestContext_MemoryScope(t *testing.T) {
+	store := memoryStore(t)
+	for _, mem := range []*entity.MemEntity{
+		{Bot: "other", Scope: entity.SCOPE_GLOBAL, Subject: "语言", Content: "回答时使用中文"},
+		{Bot: "other", Scope: entity.SCOPE_BOT, Subject: "私有", Content: "其他 bot 的记忆"},
+		{Bot: "other", Scope: entity.SCOPE_PROJECT, Target: "/proj", Subject: "构建", Content: "使用 make build 构建项目"},
+		{Bot: "other", Scope: entity.SCOPE_PROJECT, Target: "/else", Subject: "构建", Content: "使用 npm run build 构建项目"},
+		{Bot: "tester", Scope: entity.SCOPE_TASK, Target: "task-2", Subject: "任务", Content: "其他任务的记忆"},
+		// 与项目记忆重复，只保留范围小的
+		{Bot: "other", Scope: entity.SCOPE_GLOBAL, Subject: "构建", Content: "使用 make build 构建项目"},
+	} {
+		store.SaveMem(mem)
+	}
+	worker := &Worker{UUID: "tester"}
+	task := &MyTask{UUID: "task-1", Home: "/proj"}
+	ctx := &Context{worker: worker, mytask: task, store: store}
+	ctx.Memorize(&action.Memorize{Subject: "风格", Content: "提交前执行 gofmt"})
+	ctx.Memorize(&action.Memorize{Subject: "进度", Content: "已完成接口设计", Scope: "task"})
+
+	scopes := map[string]string{}
+	for _, item := range ctx.RecallMemory() {
+		scopes[item.Mem.Content] = item.Mem.GetScope()
+	}
+	expect := map[string]string{
+		"回答时使用中文":            entity.SCOPE_GLOBAL,
+		"使用 make build 构建项目": entity.SCOPE_PROJECT,
+		"提交前执行 gofmt":        entity.SCOPE_BOT,
+		"已完成接口设计":            entity.SCOPE_TASK,
+	}
+	if len(scopes) != len(expect) {
+		t.Errorf("记忆范围合并错误: %v", scopes)
+	}
+	for content, scope := range expect {
+		if scopes[content] != scope {
+			t.Errorf("%s 的范围应为 %s，实际 %q", content, scope, scopes[content])
+		}
+	}
+
+	list, _ := store.LoadMem("scope = ? AND target = ?", entity.SCOPE_TASK, "task-1")
+	if len(list) != 1 || list[0].Bot != "tester" {
+		t.Errorf("task 记忆未保存: %d", len(list))
+	}
+}
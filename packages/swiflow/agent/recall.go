@@ -0,0 +1,235 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"swiflow/config"
+	"swiflow/entity"
+	"swiflow/support"
+	"sync"
+
+	"github.com/duke-git/lancet/v2/fileutil"
+)
+
+// Recalled 检索到的记忆及其相关度
+type Recalled struct {
+	Mem   *entity.MemEntity
+	Score float64
+}
+
+// worker 的记忆在多个任务间共享，读写时需加锁
+var memoryLock sync.Mutex
+
+// 不同重要程度的分数权重
+var importance = map[int]float64{
+	entity.MEM_LOW: 0.8, entity.MEM_NORMAL: 1, entity.MEM_HIGH: 1.2,
+}
+
+// 作用范围的优先级，范围越小越优先
+var scopeRank = map[string]int{
+	entity.SCOPE_GLOBAL: 0, entity.SCOPE_BOT: 1,
+	entity.SCOPE_PROJECT: 2, entity.SCOPE_TASK: 3,
+}
+
+var xmlTag = regexp.MustCompile(`</?[a-zA-Z][\w-]*>`)
+
+// Recall 以最新输入作为检索条件，下一轮重新选择注入的记忆
+func (c *Context) Recall(input string) {
+	input = strings.TrimSpace(xmlTag.ReplaceAllString(input, " "))
+	c.query, c.useMemory, c.scoped = input, "", nil
+}
+
+// scopedMemory 加载适用于当前任务的 global/project/task 记忆
+func (c *Context) scopedMemory() []*entity.MemEntity {
+	if c.scoped != nil || c.store == nil {
+		return c.scoped
+	}
+	c.scoped = []*entity.MemEntity{}
+	list, err := c.store.LoadMem(
+		"scope = ? OR (scope = ? AND target = ?) OR (scope = ? AND target = ?)",
+		entity.SCOPE_GLOBAL, entity.SCOPE_PROJECT, c.mytask.Home,
+		entity.SCOPE_TASK, c.mytask.UUID,
+	)
+	if err != nil {
+		log.Println("[MEMORY] load scoped memory error", err)
+		return c.scoped
+	}
+	for _, mem := range list {
+		if mem.GetScope() != entity.SCOPE_BOT && c.applies(mem) {
+			c.scoped = append(c.scoped, mem)
+		}
+	}
+	return c.scoped
+}
+
+func (c *Context) applies(mem *entity.MemEntity) bool {
+	return mem.Applies(c.worker.UUID, c.mytask.Home, c.mytask.UUID)
+}
+
+// RecallMemory 选出与当前任务和最新输入最相关的 MEMORY_TOP_K 条记忆
+// 配置了向量模型时按余弦相似度排序，否则使用 BM25
+// 合并 bot 与适用的 global/project/task 记忆，范围越小、越重要越优先，已过期的不再注入
+func (c *Context) RecallMemory() []*Recalled {
+	// 复制 worker 共享的记忆，评分和生成向量时不持有锁
+	result := []*Recalled{}
+	memoryLock.Lock()
+	for _, mem := range c.worker.Memories {
+		if mem.GetScope() == entity.SCOPE_BOT && !mem.IsExpired() {
+			copied := mem
+			result = append(result, &Recalled{Mem: &copied})
+		}
+	}
+	memoryLock.Unlock()
+	for _, mem := range c.scopedMemory() {
+		if !mem.IsExpired() {
+			result = append(result, &Recalled{Mem: mem})
+		}
+	}
+	topK := config.GetInt("MEMORY_TOP_K", 5)
+	if topK <= 0 || len(result) <= topK {
+		return c.selectMemory(result, 0)
+	}
+
+	query := strings.Join([]string{
+		c.mytask.Name, c.mytask.Context, c.query,
+	}, "\n")
+	method := "bm25"
+	if err := c.scoreByVector(query, result); err == nil {
+		method = c.embedder.Name()
+	} else {
+		if c.embedder != nil {
+			log.Println("[MEMORY] embed error, fallback to bm25:", err)
+		}
+		c.scoreByBM25(query, result)
+	}
+	for _, item := range result {
+		if weight, ok := importance[item.Mem.Importance]; ok {
+			item.Score *= weight
+		}
+		item.Score *= 1 + 0.1*float64(scopeRank[item.Mem.GetScope()])
+	}
+	// 分数相同时优先范围小、重要、较新的记忆
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i].Mem, result[j].Mem
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		if scopeRank[a.GetScope()] != scopeRank[b.GetScope()] {
+			return scopeRank[a.GetScope()] > scopeRank[b.GetScope()]
+		}
+		if a.Importance != b.Importance {
+			return a.Importance > b.Importance
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	})
+	result = c.selectMemory(result, topK)
+	c.debugRecall(method, result)
+	return result
+}
+
+// selectMemory 按顺序选出至多 topK 条记忆，不同范围中重复的记忆只保留范围小的
+func (c *Context) selectMemory(result []*Recalled, topK int) []*Recalled {
+	selected := []*Recalled{}
+	for _, item := range result {
+		if topK > 0 && len(selected) >= topK {
+			break
+		}
+		dup := -1
+		for j, curr := range selected {
+			if !item.Mem.SameScope(curr.Mem) && isDuplicate(item.Mem, curr.Mem) {
+				dup = j
+				break
+			}
+		}
+		if dup < 0 {
+			selected = append(selected, item)
+		} else if scopeRank[item.Mem.GetScope()] > scopeRank[selected[dup].Mem.GetScope()] {
+			selected[dup] = item
+		}
+	}
+	return selected
+}
+
+func (c *Context) scoreByBM25(query string, result []*Recalled) {
+	docs := make([]string, len(result))
+	for i, item := range result {
+		docs[i] = item.Mem.EmbedText()
+	}
+	for i, score := range support.BM25(query, docs) {
+		result[i].Score = score
+	}
+}
+
+func (c *Context) scoreByVector(query string, result []*Recalled) error {
+	if c.embedder == nil {
+		return fmt.Errorf("no embedder")
+	}
+	if err := c.embedMemory(result); err != nil {
+		return err
+	}
+	vectors, err := c.embedder.Embed([]string{query})
+	if err != nil {
+		return err
+	}
+	for _, item := range result {
+		item.Score = support.Cosine(vectors[0], item.Mem.Vector)
+	}
+	return nil
+}
+
+// embedMemory 为没有向量或模型已更换的记忆生成向量并保存
+func (c *Context) embedMemory(result []*Recalled) error {
+	name := c.embedder.Name()
+	texts, missing := []string{}, []*entity.MemEntity{}
+	for _, item := range result {
+		if len(item.Mem.Vector) == 0 || item.Mem.Embedder != name {
+			texts = append(texts, item.Mem.EmbedText())
+			missing = append(missing, item.Mem)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	vectors, err := c.embedder.Embed(texts)
+	if err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	for i, mem := range missing {
+		mem.Vector, mem.Embedder = vectors[i], name
+		c.saveCached(mem, func(cached *entity.MemEntity) bool {
+			// 生成向量期间内容已更新的，不保存旧内容的向量
+			if cached.EmbedText() != texts[i] {
+				return false
+			}
+			cached.Vector, cached.Embedder = vectors[i], name
+			return true
+		})
+	}
+	return nil
+}
+
+// debugRecall 记录检索结果，调试模式下写入 .msgs 目录
+func (c *Context) debugRecall(method string, result []*Recalled) {
+	var s strings.Builder
+	s.WriteString(fmt.Sprintf("query(%s): %s\n\n", method, c.query))
+	for _, item := range result {
+		scope := item.Mem.GetScope()
+		log.Printf("[MEMORY] %s recall %d(%s) score %.4f", c.mytask.UUID, item.Mem.ID, scope, item.Score)
+		s.WriteString(fmt.Sprintf("- [%.4f] #%d(%s) %s\n", item.Score, item.Mem.ID, scope, item.Mem.Subject))
+	}
+	if !c.mytask.IsDebug {
+		return
+	}
+	path := support.Or(c.GetWorkHome(), config.GetWorkHome())
+	if fileutil.CreateDir(filepath.Join(path, ".msgs")) != nil {
+		return
+	}
+	recall := filepath.Join(path, ".msgs", c.worker.UUID+".memory.md")
+	fileutil.WriteStringToFile(recall, s.String(), false)
+}
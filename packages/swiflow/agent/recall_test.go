@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"swiflow/entity"
+	"swiflow/storage"
+	"testing"
+)
+
+// fakeEmbedder 按关键词生成向量，便于验证排序
+type fakeEmbedder struct {
+	words []string
+	calls int
+	fail  bool
+}
+
+func (e *fakeEmbedder) Name() string { return "fake/embed" }
+
+func (e *fakeEmbedder) Embed(texts []string) ([][]float32, error) {
+	e.calls += 1
+	if e.fail {
+		return nil, fmt.Errorf("embed failed")
+	}
+	result := [][]float32{}
+	for _, text := range texts {
+		vector := make([]float32, len(e.words))
+		for i, word := range e.words {
+			if strings.Contains(text, word) {
+				vector[i] = 1
+			}
+		}
+		result = append(result, vector)
+	}
+	return result, nil
+}
+
+func recallContext(embedder *fakeEmbedder) *Context {
+	worker := &Worker{UUID: "tester", Memories: []entity.MemEntity{
+		{ID: 1, Subject: "部署", Content: "服务通过 docker compose 部署到测试环境"},
+		{ID: 2, Subject: "代码风格", Content: "Go 代码提交前需要执行 gofmt"},
+		{ID: 3, Subject: "数据库", Content: "mysql 密码保存在 vault 中"},
+		{ID: 4, Subject: "周报", Content: "每周五下午提交周报"},
+	}}
+	ctx := &Context{
+		worker: worker, mytask: &MyTask{UUID: "task"},
+		store: storage.NewMockStore(),
+	}
+	if embedder != nil {
+		ctx.embedder = embedder
+	}
+	return ctx
+}
+
+func TestContext_RecallMemory(t *testing.T) {
+	t.Setenv("MEMORY_TOP_K", "2")
+
+	ctx := recallContext(nil)
+	ctx.Recall("<user-input>\n<content>帮我把服务部署到 docker</content>\n</user-input>")
+	result := ctx.RecallMemory()
+	if len(result) != 2 || result[0].Mem.ID != 1 || result[0].Score <= 0 {
+		t.Fatalf("BM25 未选出部署相关记忆: %+v", result)
+	}
+	if memory := ctx.GetMemory(); strings.Contains(memory, "周报") {
+		t.Errorf("不相关的记忆不应注入: %s", memory)
+	}
+
+	embedder := &fakeEmbedder{words: []string{"mysql", "gofmt", "docker"}}
+	ctx = recallContext(embedder)
+	ctx.Recall("mysql 连接失败")
+	if result := ctx.RecallMemory(); result[0].Mem.ID != 3 {
+		t.Errorf("向量检索排序错误: %+v", result[0].Mem)
+	}
+	if mem := ctx.worker.Memories[0]; len(mem.Vector) == 0 || mem.Embedder != "fake/embed" {
+		t.Errorf("记忆向量未保存")
+	}
+	// 已有向量的记忆不再重复生成
+	calls := embedder.calls
+	ctx.RecallMemory()
+	if embedder.calls != calls+1 {
+		t.Errorf("重复生成记忆向量: %d", embedder.calls-calls)
+	}
+
+	// 向量模型不可用时回退到 BM25
+	ctx = recallContext(&fakeEmbedder{fail: true})
+	ctx.Recall("gofmt")
+	if result := ctx.RecallMemory(); result[0].Mem.ID != 2 {
+		t.Errorf("未回退到 BM25: %+v", result[0].Mem)
+	}
+}
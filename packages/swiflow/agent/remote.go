@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"swiflow/a2a"
+	"swiflow/action"
+	"swiflow/support"
+	"time"
+)
+
+// IsRemote worker 是否为通过 A2A 协议调用的远程 agent
+func IsRemote(worker *Worker) bool {
+	return worker.Type == AGENT_REMOTE && worker.Remote != nil
+}
+
+func remoteClient(worker *Worker) *a2a.Client {
+	remote := worker.Remote
+	return a2a.NewClient(remote.Url, remote.Token, remote.Headers)
+}
+
+// runRemote 把子任务交给远程 agent，远程输出转发到上级任务的 stream 事件
+// ctx 在启动前创建并记录到 sa.cancel，保证启动后立即 abort 也能取消
+func (sa *SubAgent) runRemote(ctx context.Context, subtask *action.Subtask) {
+	defer sa.cancel()
+
+	var stream struct {
+		Idx uint32 `json:"idx"`
+		Str string `json:"str"`
+	}
+	msgid, _ := support.UniqueID()
+	parts := []string{"data", sa.worker.UUID, msgid}
+	stream.Str = strings.Join(parts, ":")
+	support.Emit("stream", sa.target.UUID, stream)
+
+	content, _ := subtask.Input()
+	var state, lastText string
+	var artifacts []string
+	err := remoteClient(sa.worker).Stream(ctx, a2a.NewMessage(content), func(event *a2a.Event) {
+		// remoteId 与 remoteState 会被 abort 和 query 在其他 goroutine 读取
+		sa.parent.subLock.Lock()
+		if id := event.GetTaskId(); id != "" {
+			sa.remoteId = id
+		}
+		if s := event.State(); s != "" {
+			state, sa.remoteState = s, s
+		}
+		sa.parent.subLock.Unlock()
+		text := event.Text()
+		if text == "" {
+			return
+		}
+		stream.Idx, stream.Str = stream.Idx+1, text
+		support.Emit("stream", sa.target.UUID, stream)
+		switch event.Kind {
+		case a2a.KIND_ARTIFACT, a2a.KIND_TASK:
+			artifacts = append(artifacts, text)
+		case a2a.KIND_MESSAGE:
+			// 直接回复消息，不创建远程任务
+			state, lastText = a2a.STATE_COMPLETED, text
+		default:
+			lastText = text
+		}
+	})
+	if ctx.Err() != nil {
+		return // 已被 abort 或超时处理
+	}
+	result := support.Or(strings.Join(artifacts, "\n"), lastText)
+	log.Println("[SUBTASK] remote done", sa.worker.UUID, state, err)
+	switch {
+	case err != nil:
+		sa.OnFailed(STATE_FAILED, err.Error())
+	case state == a2a.STATE_COMPLETED:
+		sa.OnComplete(&action.Complete{Content: result})
+	case state == a2a.STATE_CANCELED:
+		sa.OnFailed(STATE_CANCELED, result)
+	case state == a2a.STATE_INPUT_REQUIRED, state == a2a.STATE_AUTH_REQUIRED:
+		sa.OnFailed(STATE_FAILED, fmt.Sprintf("remote agent %s: %s", state, result))
+	default:
+		sa.OnFailed(STATE_FAILED, support.Or(result, "remote task "+support.Or(state, "closed")))
+	}
+	sa.parent.subLock.Lock()
+	sa.mytask.State = sa.state
+	sa.parent.subLock.Unlock()
+	if err := sa.parent.store.SaveTask(sa.mytask); err != nil {
+		log.Println("[SUBTASK] save remote task error", err)
+	}
+}
+
+// stopRemote 取消本地流并通知远程取消任务
+func (sa *SubAgent) stopRemote() {
+	sa.parent.subLock.Lock()
+	cancel, remoteId := sa.cancel, sa.remoteId
+	sa.parent.subLock.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	if remoteId == "" {
+		return
+	}
+	go func(taskId string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := remoteClient(sa.worker).Cancel(ctx, taskId); err != nil {
+			log.Println("[SUBTASK] cancel remote error", taskId, err)
+		}
+	}(remoteId)
+}
@@ -1,33 +1,68 @@
 package agent
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"swiflow/action"
+	"swiflow/config"
 	"swiflow/support"
+	"time"
 )
 
+// SubAgent 对应一次 start-subtask，同一 worker 可并发执行多个
 type SubAgent struct {
 	parent *Manager
 	worker *Worker // current worker
 	mytask *MyTask // current task
 	leader *Worker // leader worker
 	target *MyTask // leader task
+
+	retries int       // 输出不符合约定的退回次数
+	state   string    // 子任务状态：running/completed/...
+	result  string    // 交给上级的结果
+	started time.Time // 启动时间
+	settled bool      // 结果是否已交给上级
+
+	timeout time.Duration // 超时时间，0 表示不限制
+	timer   *time.Timer
+
+	// 远程 worker 的任务，由 parent.subLock 保护
+	cancel      context.CancelFunc
+	remoteId    string
+	remoteState string
+}
+
+// SubtaskTimeout 解析 start-subtask 的 timeout，支持 10m 或秒数
+// 未指定时使用 SUBTASK_TIMEOUT(秒)，默认 30 分钟
+func SubtaskTimeout(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d
+	}
+	if sec, err := strconv.Atoi(value); err == nil && sec > 0 {
+		return time.Duration(sec) * time.Second
+	}
+	sec := config.GetInt("SUBTASK_TIMEOUT", 1800)
+	return time.Duration(max(sec, 0)) * time.Second
 }
 
 // OnStart handles the start of a subtask by selecting a worker bot and initializing the subtask
+// 启动后立即把 subtask-id 返回给上级，上级可继续分派其他任务
 func (sa *SubAgent) OnStart(act *action.StartSubtask) {
 	worker, err := sa.parent.GetWorker(act.SubAgent)
 	if err != nil || worker == nil {
-		act.Result = fmt.Sprintf(
+		sa.reply(act, fmt.Sprintf(
 			"agent(%s) not found %v",
 			act.SubAgent, err,
-		)
-		toolResult := support.ToXML(act, act.Result)
-		input := &action.ToolResult{
-			Content: action.TOOL_RESULT_TAG + "\n" + toolResult,
-		}
-		sa.parent.Handle(input, sa.target, sa.leader)
+		))
+		return
+	}
+	// 不能分派给自己或上级，避免多级团队中循环委派
+	if sa.isAncestor(worker) {
+		sa.reply(act, fmt.Sprintf("agent(%s) is not a subagent", act.SubAgent))
 		return
 	}
 	log.Println("[SUBTASK] bot", worker.UUID)
@@ -36,60 +71,178 @@ func (sa *SubAgent) OnStart(act *action.StartSubtask) {
 	newtask, err := sa.parent.InitSubtask(
 		worker.UUID, sa.target.Group,
 	)
-	if err == nil && newtask != nil {
-		log.Println("[SUBTASK] OnStart", worker.UUID)
-		// ensure work in same dir
-		newtask.Home = sa.target.Home
-		newtask.IsDebug = sa.target.IsDebug
-		sa.worker, sa.mytask = worker, newtask
-		sa.parent.Handle(act.ToSubtask(), newtask, worker)
+	if err != nil || newtask == nil {
+		sa.reply(act, fmt.Sprintf("start subtask error %v", err))
+		return
+	}
+	log.Println("[SUBTASK] OnStart", worker.UUID, newtask.UUID)
+	// ensure work in same dir
+	newtask.Home = sa.target.Home
+	newtask.IsDebug = sa.target.IsDebug
+	sa.worker, sa.mytask = worker, newtask
+	sa.state, sa.started = STATE_RUNNING, time.Now()
+	var ctx context.Context
+	if IsRemote(worker) {
+		ctx, sa.cancel = context.WithCancel(context.Background())
+	}
+	sa.parent.addSubAgent(sa)
+	if sa.timeout = SubtaskTimeout(act.Timeout); sa.timeout > 0 {
+		sa.timer = time.AfterFunc(sa.timeout, sa.OnTimeout)
+	}
+
+	act.SubtaskId = newtask.UUID
+	sa.reply(act, fmt.Sprintf(
+		"started, use subtask-id(%s) to query, abort or join it",
+		newtask.UUID,
+	))
+	if IsRemote(worker) {
+		go sa.runRemote(ctx, act.ToSubtask())
+		return
+	}
+	sa.parent.Handle(act.ToSubtask(), newtask, worker)
+}
+
+// OnQuery 返回子任务当前的执行进度
+func (sa *SubAgent) OnQuery(act *action.QuerySubtask) {
+	sa.parent.subLock.Lock()
+	state, result := sa.state, sa.result
+	remoteId, remoteState := sa.remoteId, sa.remoteState
+	sa.parent.subLock.Unlock()
+	// 不改写 act 的 subtask-id，保持与模型输出的 hash 一致
+	progress := map[string]any{
+		"subtask": sa.mytask.UUID,
+		"state":   state,
+		"elapsed": time.Since(sa.started).Round(time.Second).String(),
+	}
+	if IsRemote(sa.worker) {
+		progress["remote"] = remoteId
+		progress["remoteState"] = remoteState
+	} else if executor := sa.parent.LoadExecutor(sa.mytask, sa.worker); executor != nil {
+		for key, val := range executor.Progress() {
+			progress[key] = val
+		}
+		// 执行器状态只在运行中可信
+		if state != STATE_RUNNING {
+			progress["state"] = state
+		}
+	}
+	if sa.timeout > 0 {
+		progress["deadline"] = sa.started.Add(sa.timeout).Format(time.DateTime)
+	}
+	if result != "" {
+		progress["result"] = result
 	}
+	sa.reply(act, support.ToJson(progress))
 }
 
 // OnAbort handles the abortion of a subtask by terminating the active executor
 func (sa *SubAgent) OnAbort(act *action.AbortSubtask) {
-	toolResult := &action.ToolResult{}
-	if sa.worker == nil || sa.worker.UUID != act.SubAgent {
-		result := fmt.Sprintf("agent(%s) not found", act.SubAgent)
-		toolResult.Content = support.ToXML(act, result)
-		sa.parent.Handle(toolResult, sa.target, sa.leader)
+	sa.parent.subLock.Lock()
+	state := sa.state
+	if state == STATE_RUNNING {
+		sa.settled = true
+	}
+	sa.parent.subLock.Unlock()
+	if state != STATE_RUNNING {
+		sa.reply(act, fmt.Sprintf("subtask is %s", state))
 		return
 	}
 
-	// terminate subtask
-	executor := sa.parent.LoadExecutor(sa.target, sa.leader)
-	if executor != nil && executor.IsRunning() {
-		executor.Terminate()
-		toolResult.Content = support.ToXML(act, "success")
-		sa.parent.Handle(toolResult, sa.target, sa.leader)
-	} else {
-		toolResult.Content = support.ToXML(act, "no subtask found")
-		sa.parent.Handle(toolResult, sa.mytask, sa.worker)
-	}
+	sa.terminate()
+	sa.reply(act, fmt.Sprintf("subtask(%s) aborted", sa.mytask.UUID))
+	sa.parent.settle(sa, STATE_CANCELED, "aborted by leader")
 }
 
 // OnComplete handles the completion of a subtask by updating the leader task context
+// worker 声明了 outputSchema 时，校验通过的结果以 JSON 交给上级，否则退回修正
 func (sa *SubAgent) OnComplete(act *action.Complete) {
-	// task of worker context update
-	// need push context to leader
-	toolResult := &action.ToolResult{}
-	subtask := &action.StartSubtask{
-		SubAgent: sa.worker.UUID,
+	result := act.Content
+	if schema := sa.worker.OutputSchema; len(schema) > 0 {
+		value, err := ParseOutput(act.Content, schema)
+		// 远程 worker 无法退回修正
+		if err != nil && sa.retries < MAX_OUTPUT_RETRY && !IsRemote(sa.worker) {
+			sa.retries += 1
+			log.Println("[SUBTASK] invalid output", sa.worker.UUID, err)
+			toolResult := &action.ToolResult{
+				Content: action.TOOL_RESULT_TAG + "\n" + support.ToXML(
+					act, fmt.Sprintf("error: %v, fix it and complete again", err),
+				),
+			}
+			sa.parent.Handle(toolResult, sa.mytask, sa.worker)
+			return
+		}
+		if err != nil {
+			result = fmt.Sprintf("warning: %v\n%s", err, act.Content)
+		} else {
+			result = FormatOutput(value)
+		}
 	}
-	toolResult.Content = support.ToXML(subtask, act.Content)
-	log.Println("[SUBTASK] OnComplete", sa.worker.UUID)
-	sa.parent.Handle(toolResult, sa.target, sa.worker)
+	log.Println("[SUBTASK] OnComplete", sa.worker.UUID, sa.mytask.UUID)
+	sa.parent.settle(sa, STATE_COMPLETED, result)
 }
 
 // OnTimeout handles the timeout of a subtask by updating the leader task context
-func (sa *SubAgent) OnTimeout(act *action.Complete) {
-	// task of worker context update
-	// need push context to leader
-	toolResult := &action.ToolResult{}
+// 到达截止时间后终止子任务，以 failed 状态通知上级
+func (sa *SubAgent) OnTimeout() {
+	// 计时器在独立的 goroutine 中触发，与 settle 同锁读取状态
+	sa.parent.subLock.Lock()
+	running := sa.state == STATE_RUNNING
+	sa.parent.subLock.Unlock()
+	if !running {
+		return
+	}
+	log.Println("[SUBTASK] OnTimeout", sa.worker.UUID, sa.mytask.UUID)
+	sa.terminate()
+	sa.parent.settle(sa, STATE_FAILED, fmt.Sprintf(
+		"subtask timeout after %s", sa.timeout,
+	))
+}
+
+// OnFailed 子任务执行失败或被取消时，把最近一次错误交给上级
+func (sa *SubAgent) OnFailed(state string, lastErr string) {
+	log.Println("[SUBTASK] OnFailed", sa.worker.UUID, sa.mytask.UUID, state)
+	sa.parent.settle(sa, state, support.Or(lastErr, "unknown error"))
+}
+
+// terminate 终止 worker 上正在执行的子任务
+func (sa *SubAgent) terminate() {
+	if IsRemote(sa.worker) {
+		sa.stopRemote()
+	} else if executor := sa.parent.LoadExecutor(sa.mytask, sa.worker); executor != nil {
+		executor.Terminate()
+	}
+}
+
+// reply 把 act 的执行结果作为工具结果返回给上级
+func (sa *SubAgent) reply(act any, result string) {
+	sa.parent.replyTo(sa.target, sa.leader, support.ToXML(act, result))
+}
+
+// toXML 子任务结果，交给上级时使用
+func (sa *SubAgent) toXML() string {
 	subtask := &action.StartSubtask{
-		SubAgent: sa.worker.UUID,
+		SubAgent: sa.worker.UUID, SubtaskId: sa.mytask.UUID,
+	}
+	if sa.state == STATE_COMPLETED {
+		return support.ToXML(subtask, sa.result)
+	}
+	return support.ToXML(subtask, fmt.Sprintf(
+		"%s: %s\nyou can retry or start it with another sub-agent",
+		sa.state, sa.result,
+	))
+}
+
+func (sa *SubAgent) isAncestor(worker *Worker) bool {
+	seen := map[string]bool{}
+	for curr := sa.leader; curr != nil && !seen[curr.UUID]; {
+		if curr.UUID == worker.UUID {
+			return true
+		}
+		seen[curr.UUID] = true
+		if curr.Leader == "" {
+			break
+		}
+		curr, _ = sa.parent.GetWorker(curr.Leader)
 	}
-	toolResult.Content = support.ToXML(subtask, act.Content)
-	log.Println("[SUBTASK] OnTimeout", sa.worker.UUID)
-	sa.parent.Handle(toolResult, sa.target, sa.worker)
+	return false
 }
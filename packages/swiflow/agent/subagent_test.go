@@ -0,0 +1,218 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"swiflow/action"
+	"testing"
+	"time"
+)
+
+func TestDiscoverWorkers_Nested(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"leader-boss.md":   "boss",
+		"manager.md":       "manager",
+		"manager.json":     `{"desc": "中层"}`,
+		"coder.md":         "coder",
+		"coder.json":       `{"leader": "manager", "outputSchema": {"type": "object", "required": ["files"]}}`,
+		"loop-a.md":        "a",
+		"loop-a.json":      `{"leader": "loop-b"}`,
+		"loop-b.md":        "b",
+		"loop-b.json":      `{"leader": "loop-a"}`,
+		"orphan.md":        "orphan",
+		"orphan.json":      `{"leader": "missing"}`,
+		"remote.md":        "remote",
+		"remote.json":      `{"leader": "manager", "remote": {"url": "http://127.0.0.1:11235/api/a2a/coder"}}`,
+		"not-an-agent.txt": "skip",
+	}
+	for name, content := range files {
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+	workers, err := DiscoverWorkers(dir)
+	if err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+	leaders := map[string]string{}
+	for _, worker := range workers {
+		leaders[worker.UUID] = worker.Leader
+		if worker.UUID == "coder" && len(worker.OutputSchema) == 0 {
+			t.Errorf("未读取 outputSchema")
+		}
+		if worker.UUID == "remote" && !IsRemote(worker) {
+			t.Errorf("未识别远程 worker: %s", worker.Type)
+		}
+	}
+	expect := map[string]string{
+		"leader-boss": "", "manager": "leader-boss", "coder": "manager",
+		"orphan": "leader-boss", "remote": "manager",
+	}
+	for uuid, want := range expect {
+		if leaders[uuid] != want {
+			t.Errorf("%s 的上级应为%q，实际%q", uuid, want, leaders[uuid])
+		}
+	}
+	// 环中至少有一个被挂回顶层
+	if leaders["loop-a"] != "leader-boss" && leaders["loop-b"] != "leader-boss" {
+		t.Errorf("循环上级未修正: %v", leaders)
+	}
+}
+
+func TestParseOutput(t *testing.T) {
+	schema := map[string]any{
+		"type":       "object",
+		"required":   []any{"amount"},
+		"properties": map[string]any{"amount": map[string]any{"type": "number"}},
+	}
+	value, err := ParseOutput("```json\n{\"amount\": 1990}\n```", schema)
+	if err != nil {
+		t.Fatalf("合法输出校验失败: %v", err)
+	}
+	if out := FormatOutput(value); !strings.Contains(out, `"amount": 1990`) {
+		t.Errorf("格式化输出错误: %s", out)
+	}
+	if _, err = ParseOutput(`{"amount": "many"}`, schema); err == nil {
+		t.Errorf("类型不符应校验失败")
+	}
+	if _, err = ParseOutput("done, amount is 1990", schema); err == nil {
+		t.Errorf("非 JSON 内容应校验失败")
+	}
+}
+
+func TestManager_TryJoin(t *testing.T) {
+	m := NewManager()
+	leader := &Worker{UUID: "boss"}
+	task := &MyTask{UUID: "task-1"}
+	for _, id := range []string{"sub-a", "sub-b", "sub-c"} {
+		m.subagents[id] = &SubAgent{
+			parent: m, leader: leader, target: task,
+			worker: &Worker{UUID: "coder"}, mytask: &MyTask{UUID: id},
+			state: STATE_RUNNING,
+		}
+	}
+	act := &action.JoinSubtasks{SubtaskIds: "sub-a, sub-b"}
+	join := &joinWait{
+		ids: act.IDs(), mode: JOIN_ALL, act: act,
+		task: task, leader: leader,
+	}
+	m.joins[task.UUID] = join
+	if _, ok := m.tryJoin(join); ok {
+		t.Fatalf("子任务未结束不应返回")
+	}
+	m.subagents["sub-a"].state, m.subagents["sub-a"].result = STATE_COMPLETED, "result-a"
+	m.subagents["sub-c"].state, m.subagents["sub-c"].result = STATE_FAILED, "result-c"
+	if _, ok := m.tryJoin(join); ok {
+		t.Fatalf("all 模式下 sub-b 未结束不应返回")
+	}
+	m.subagents["sub-b"].state, m.subagents["sub-b"].result = STATE_COMPLETED, "result-b"
+	content, ok := m.tryJoin(join)
+	if !ok {
+		t.Fatalf("全部结束后应返回")
+	}
+	for _, want := range []string{"result-a", "result-b", "failed: result-c"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("合并结果缺少 %s: %s", want, content)
+		}
+	}
+	if len(m.joins) != 0 {
+		t.Errorf("join 完成后应移除")
+	}
+	if len(m.subagents) != 0 {
+		t.Errorf("已交付的子任务应移除: %v", m.subagents)
+	}
+
+	// any 模式任一结束即返回
+	for id, state := range map[string]string{"sub-d": STATE_RUNNING, "sub-e": STATE_COMPLETED} {
+		m.subagents[id] = &SubAgent{
+			parent: m, leader: leader, target: task,
+			worker: &Worker{UUID: "coder"}, mytask: &MyTask{UUID: id},
+			state: state,
+		}
+	}
+	act = &action.JoinSubtasks{SubtaskIds: "sub-e,sub-d", Mode: JOIN_ANY}
+	join = &joinWait{
+		ids: act.IDs(), mode: JOIN_ANY, act: act,
+		task: task, leader: leader,
+	}
+	if _, ok := m.tryJoin(join); !ok {
+		t.Errorf("any 模式下 sub-e 已结束应立即返回")
+	}
+	if _, ok := m.subagents["sub-e"]; ok || m.subagents["sub-d"] == nil {
+		t.Errorf("只应移除已交付的子任务: %v", m.subagents)
+	}
+}
+
+func TestSubtaskTimeout(t *testing.T) {
+	cases := map[string]time.Duration{
+		"10m": 10 * time.Minute, "90": 90 * time.Second,
+		"": 30 * time.Minute, "abc": 30 * time.Minute,
+	}
+	for value, want := range cases {
+		if got := SubtaskTimeout(value); got != want {
+			t.Errorf("timeout(%q) 应为 %s，实际 %s", value, want, got)
+		}
+	}
+}
+
+func TestSubAgent_Failure(t *testing.T) {
+	m := NewManager()
+	leader := &Worker{UUID: "boss"}
+	task := &MyTask{UUID: "task-2"}
+	newSub := func(id string) *SubAgent {
+		sa := &SubAgent{
+			parent: m, leader: leader, target: task,
+			worker: &Worker{UUID: "coder"}, mytask: &MyTask{UUID: id},
+			state: STATE_RUNNING, started: time.Now(), timeout: time.Minute,
+		}
+		m.subagents[id] = sa
+		return sa
+	}
+	a, b := newSub("sub-a"), newSub("sub-b")
+	act := &action.JoinSubtasks{SubtaskIds: "sub-a,sub-b"}
+	m.joins[task.UUID] = &joinWait{
+		ids: act.IDs(), mode: JOIN_ALL, act: act,
+		task: task, leader: leader,
+	}
+
+	a.OnFailed(STATE_FAILED, "llm error")
+	if a.state != STATE_FAILED || a.result != "llm error" {
+		t.Errorf("失败状态未记录: %s %s", a.state, a.result)
+	}
+	// 已结束的子任务不受后续状态影响
+	a.OnFailed(STATE_CANCELED, "")
+	if a.state != STATE_FAILED {
+		t.Errorf("已结束的子任务状态被覆盖: %s", a.state)
+	}
+	b.OnTimeout()
+	if b.state != STATE_FAILED || !strings.Contains(b.result, "timeout") {
+		t.Errorf("超时未以失败通知: %s %s", b.state, b.result)
+	}
+	if len(m.joins) != 0 || !a.settled || !b.settled {
+		t.Errorf("全部失败后 join 应完成")
+	}
+	if len(m.subagents) != 0 {
+		t.Errorf("已交付的子任务应移除: %v", m.subagents)
+	}
+}
+
+func TestSubAgent_TimeoutRace(t *testing.T) {
+	m := NewManager()
+	sa := &SubAgent{
+		parent: m, leader: &Worker{UUID: "boss"}, target: &MyTask{UUID: "task-3"},
+		worker: &Worker{UUID: "coder"}, mytask: &MyTask{UUID: "sub-r"},
+		state: STATE_RUNNING, started: time.Now(), timeout: time.Millisecond,
+	}
+	m.subagents["sub-r"] = sa
+	// 超时与子任务结束同时发生，go test -race 下不应报告竞争
+	done := make(chan struct{})
+	go func() {
+		sa.OnTimeout()
+		close(done)
+	}()
+	sa.OnFailed(STATE_FAILED, "llm error")
+	<-done
+	if sa.state != STATE_FAILED || !sa.settled {
+		t.Errorf("子任务应只结束一次: %s %v", sa.state, sa.settled)
+	}
+}
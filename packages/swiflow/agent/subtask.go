@@ -0,0 +1,193 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"swiflow/action"
+	"swiflow/support"
+)
+
+const (
+	JOIN_ALL = "all"
+	JOIN_ANY = "any"
+)
+
+// joinWait 上级任务正在等待的一组子任务
+type joinWait struct {
+	ids    []string
+	mode   string
+	act    *action.JoinSubtasks
+	task   *MyTask
+	leader *Worker
+}
+
+func (m *Manager) addSubAgent(sa *SubAgent) {
+	m.subLock.Lock()
+	defer m.subLock.Unlock()
+	m.subagents[sa.mytask.UUID] = sa
+}
+
+// FindSubAgent 按 subtask-id 查找，未指定时取该 agent 最近一次启动的子任务
+func (m *Manager) FindSubAgent(task *MyTask, id, agent string) *SubAgent {
+	m.subLock.Lock()
+	defer m.subLock.Unlock()
+	if id != "" {
+		if sa, ok := m.subagents[id]; ok && sa.target.UUID == task.UUID {
+			return sa
+		}
+		return nil
+	}
+	var found *SubAgent
+	for _, sa := range m.subagents {
+		if sa.target.UUID != task.UUID || sa.worker.UUID != agent {
+			continue
+		}
+		if found == nil || sa.started.After(found.started) {
+			found = sa
+		}
+	}
+	return found
+}
+
+// settle 记录子任务结束状态，无等待中的 join 时直接交给上级
+// 已交付的(如被上级 abort-subtask 终止)不再重复通知
+func (m *Manager) settle(sa *SubAgent, state, result string) {
+	m.subLock.Lock()
+	if sa.state != STATE_RUNNING {
+		m.subLock.Unlock()
+		return
+	}
+	sa.state, sa.result = state, result
+	if sa.timer != nil {
+		sa.timer.Stop()
+	}
+	join := m.joins[sa.target.UUID]
+	if join == nil {
+		notify := !sa.settled
+		sa.settled = true
+		m.release(sa)
+		m.subLock.Unlock()
+		if notify {
+			m.replyTo(sa.target, sa.leader, sa.toXML())
+		}
+		return
+	}
+	content, ok := m.tryJoin(join)
+	m.release(sa)
+	m.subLock.Unlock()
+	if ok {
+		m.replyTo(join.task, join.leader, content)
+	}
+}
+
+// release 结果已交给上级且不在等待中的 join 里时移除子任务，需持有 subLock
+func (m *Manager) release(sa *SubAgent) {
+	if sa.state == STATE_RUNNING || !sa.settled {
+		return
+	}
+	join := m.joins[sa.target.UUID]
+	if join != nil && slices.Contains(join.ids, sa.mytask.UUID) {
+		return
+	}
+	delete(m.subagents, sa.mytask.UUID)
+}
+
+// JoinSubtasks 阻塞上级直到子任务全部(all)或任一(any)结束
+// 未指定 subtask-ids 时等待当前任务下所有未交付的子任务
+func (m *Manager) JoinSubtasks(act *action.JoinSubtasks, leader *Worker, task *MyTask) {
+	mode := strings.ToLower(strings.TrimSpace(act.Mode))
+	if mode != JOIN_ANY {
+		mode = JOIN_ALL
+	}
+	ids, unknown := act.IDs(), []string{}
+
+	m.subLock.Lock()
+	if len(ids) == 0 {
+		for id, sa := range m.subagents {
+			if sa.target.UUID == task.UUID && !sa.settled {
+				ids = append(ids, id)
+			}
+		}
+		slices.Sort(ids)
+	}
+	for _, id := range ids {
+		sa, ok := m.subagents[id]
+		if !ok || sa.target.UUID != task.UUID {
+			unknown = append(unknown, id)
+		}
+	}
+	if len(ids) == 0 || len(unknown) > 0 {
+		m.subLock.Unlock()
+		result := "no subtask to join"
+		if len(unknown) > 0 {
+			result = fmt.Sprintf("subtask(%s) not found", strings.Join(unknown, ","))
+		}
+		m.replyTo(task, leader, support.ToXML(act, result))
+		return
+	}
+	join := &joinWait{
+		ids: ids, mode: mode, act: act,
+		task: task, leader: leader,
+	}
+	m.joins[task.UUID] = join
+	content, ok := m.tryJoin(join)
+	m.subLock.Unlock()
+	if ok {
+		m.replyTo(task, leader, content)
+	} else {
+		log.Println("[SUBTASK] join waiting", task.UUID, ids, mode)
+	}
+}
+
+// tryJoin 检查 join 条件，满足时返回合并后的结果，需持有 subLock
+func (m *Manager) tryJoin(join *joinWait) (string, bool) {
+	done, listed := []*SubAgent{}, map[string]bool{}
+	for _, id := range join.ids {
+		listed[id] = true
+		if sa := m.subagents[id]; sa != nil && sa.state != STATE_RUNNING {
+			done = append(done, sa)
+		}
+	}
+	switch join.mode {
+	case JOIN_ANY:
+		if len(done) == 0 {
+			return "", false
+		}
+	default:
+		if len(done) < len(join.ids) {
+			return "", false
+		}
+	}
+	delete(m.joins, join.task.UUID)
+
+	// 等待期间结束的其他子任务一并返回
+	others := []*SubAgent{}
+	for id, sa := range m.subagents {
+		if listed[id] || sa.target.UUID != join.task.UUID {
+			continue
+		}
+		if sa.state != STATE_RUNNING && !sa.settled {
+			others = append(others, sa)
+		}
+	}
+	slices.SortFunc(others, func(a, b *SubAgent) int {
+		return a.started.Compare(b.started)
+	})
+
+	results := []string{}
+	for _, sa := range append(done, others...) {
+		sa.settled = true
+		results = append(results, sa.toXML())
+		m.release(sa)
+	}
+	return support.ToXML(join.act, strings.Join(results, "\n")), true
+}
+
+func (m *Manager) replyTo(task *MyTask, worker *Worker, content string) {
+	toolResult := &action.ToolResult{
+		Content: action.TOOL_RESULT_TAG + "\n" + content,
+	}
+	m.Handle(toolResult, task, worker)
+}
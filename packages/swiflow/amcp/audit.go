@@ -0,0 +1,53 @@
+package amcp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"swiflow/entity"
+	"swiflow/storage"
+	"time"
+)
+
+const (
+	AUDIT_TOOL     = "tool"
+	AUDIT_RESOURCE = "resource"
+)
+
+// HashArgs 对参数做 sha256，map 序列化时 key 有序，相同参数得到相同摘要
+func HashArgs(args any) string {
+	data, _ := json.Marshal(args)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// NewAudit 创建审计记录，调用结束后由 RecordAudit 填充耗时与结果
+func NewAudit(kind, server, tool string, args any) *entity.AuditEntity {
+	audit := &entity.AuditEntity{
+		Kind: kind, Server: server, Tool: tool,
+		Args: HashArgs(args),
+	}
+	audit.CreatedAt = time.Now()
+	return audit
+}
+
+// RecordAudit 异步写入，result 为返回给模型的内容或错误；审计失败不影响工具调用
+func RecordAudit(audit *entity.AuditEntity, result any) {
+	audit.Latency = time.Since(audit.CreatedAt).Milliseconds()
+	switch result := result.(type) {
+	case error:
+		audit.Error = result.Error()
+	case string:
+		audit.Size = len(result)
+	}
+	go func() {
+		store, err := storage.GetStorage()
+		if store == nil || err != nil {
+			return
+		}
+		if err = store.SaveAudit(audit); err != nil {
+			log.Println("[MCP] save audit error:", err)
+		}
+	}()
+}
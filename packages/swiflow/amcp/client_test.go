@@ -0,0 +1,68 @@
+//go:build !windows
+// +build !windows
+
+package amcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func newTestSSEServer(t *testing.T) *httptest.Server {
+	server := mcp.NewServer(&mcp.Implementation{
+		Name: "echo", Version: "0.0.1",
+	}, nil)
+	server.AddTool(&mcp.Tool{
+		Name: "echo", InputSchema: map[string]any{"type": "object"},
+	}, func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return &mcp.CallToolResult{Content: []mcp.Content{
+			&mcp.TextContent{Text: "pong"},
+		}}, nil
+	})
+	handler := mcp.NewSSEHandler(func(*http.Request) *mcp.Server {
+		return server
+	}, nil)
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMcpClient_AutoFallbackSSE(t *testing.T) {
+	srv := newTestSSEServer(t)
+	server := &McpServer{
+		UUID: "sse-echo", Type: "auto",
+		Url: srv.URL, ConnectTimeout: 5,
+	}
+	client := &McpClient{server: server}
+	if err := client.Initialize(); err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer client.Close()
+	if client.detected != "sse" {
+		t.Errorf("期望回退到sse，实际%v", client.detected)
+	}
+	res, err := client.Execute("echo", map[string]any{})
+	if err != nil || res.String() != "pong" {
+		t.Errorf("调用结果不符: %v %v", res, err)
+	}
+}
+
+func TestMcpServer_Timeouts(t *testing.T) {
+	server := &McpServer{}
+	server.FromMap(map[string]any{
+		"type": "sse", "connectTimeout": float64(5), "timeout": "120",
+	})
+	if got := server.GetConnectTimeout().Seconds(); got != 5 {
+		t.Errorf("connectTimeout不符: %v", got)
+	}
+	if got := server.GetExecuteTimeout().Seconds(); got != 120 {
+		t.Errorf("executeTimeout不符: %v", got)
+	}
+	if got := (&McpServer{}).GetExecuteTimeout().Seconds(); got != float64(EXECUTE_TIMEOUT) {
+		t.Errorf("默认executeTimeout不符: %v", got)
+	}
+}
@@ -17,40 +17,38 @@ import (
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
-var clients = make(map[string]*McpClient)
-
-func NewMcpClient(server *McpServer) *McpClient {
-	if get, ok := clients[server.UUID]; ok {
-		return get
-	}
-	c := &McpClient{server: server}
-	if err := c.Initialize(); err != nil {
-		server.Status.ErrMsg = err
-		log.Println("[MCP] init fail:", err)
-		return nil
-	}
-	// debug mode not cache
-	if server.Type != "debug" {
-		clients[server.UUID] = c
-	}
-	return c
-}
-
 type McpClient struct {
 	server    *McpServer
 	session   *mcp.ClientSession
 	client    *mcp.Client
 	transport mcp.Transport
+	// auto 模式下探测到的传输方式
+	detected string
+	cancel   context.CancelFunc
+	// 实例缓存的 key、最近使用时间与进行中的调用数
+	key      string
+	lastUsed time.Time
+	inflight int
 }
 
-func (a *McpClient) buildTransport() (mcp.Transport, error) {
-	switch a.server.Type {
+func (a *McpClient) buildTransport(kind string) (mcp.Transport, error) {
+	switch kind {
 	case "streamable", "stream":
-		headers := a.server.GetHeaders()
-		client := support.NewHttpClient(headers)
+		client, err := a.server.HttpClient()
+		if err != nil {
+			return nil, err
+		}
 		return &mcp.StreamableClientTransport{
 			HTTPClient: client, Endpoint: a.server.Url,
 		}, nil
+	case "sse":
+		client, err := a.server.HttpClient()
+		if err != nil {
+			return nil, err
+		}
+		return &mcp.SSEClientTransport{
+			HTTPClient: client, Endpoint: a.server.Url,
+		}, nil
 	case "memory":
 		memTransport, _ := mcp.NewInMemoryTransports()
 		return memTransport, nil
@@ -74,36 +72,57 @@ func (a *McpClient) buildTransport() (mcp.Transport, error) {
 	}
 }
 
+// auto/http 模式先尝试 streamable，失败后回退到旧版 sse
+func (a *McpClient) transportKinds() []string {
+	switch a.server.Type {
+	case "auto", "http":
+		if a.detected != "" {
+			return []string{a.detected}
+		}
+		return []string{"streamable", "sse"}
+	}
+	return []string{a.server.Type}
+}
+
 func (a *McpClient) Initialize() error {
 	log.Println("[MCP] Start Init Mcp Server:", a.server.UUID)
-	if transport, err := a.buildTransport(); err != nil {
-		return fmt.Errorf("创建MCP客户端失败: %v", err)
-	} else {
-		a.transport = transport
+	if a.cancel != nil {
+		a.cancel()
 	}
-
 	a.client = mcp.NewClient(&mcp.Implementation{
 		Name: "swiflow", Version: config.GetVersion(),
 	}, nil)
 
-	duration := time.Duration(CONNECT_TIMEOUT)
-	ctx, cancel := context.WithTimeout(
-		context.Background(), duration*time.Second,
-	)
-	defer cancel()
-
-	session, err := a.client.Connect(ctx, a.transport, nil)
-	if err != nil {
-		return fmt.Errorf("启动MCP客户端失败: %v", err)
-	}
-	a.session = session
+	var lastErr error
+	for _, kind := range a.transportKinds() {
+		transport, err := a.buildTransport(kind)
+		if err != nil {
+			return fmt.Errorf("创建MCP客户端失败: %v", err)
+		}
+		// sse 的事件流绑定在连接的 ctx 上，只在超时前取消
+		ctx, cancel := context.WithCancel(context.Background())
+		timer := time.AfterFunc(a.server.GetConnectTimeout(), cancel)
+		session, err := a.client.Connect(ctx, transport, nil)
+		if !timer.Stop() && err == nil {
+			session.Close()
+			err = context.DeadlineExceeded
+		}
+		if err != nil {
+			cancel()
+			log.Printf("[MCP] connect %s via %s: %v", a.server.UUID, kind, err)
+			lastErr = err
+			continue
+		}
+		a.detected, a.cancel = kind, cancel
+		a.session, a.transport = session, transport
 
-	// InitializeResult
-	if resp := session.InitializeResult(); resp != nil {
-		log.Println("[MCP] SUCCESS: %w", resp.ServerInfo)
+		// InitializeResult
+		if resp := session.InitializeResult(); resp != nil {
+			log.Println("[MCP] SUCCESS: %w", resp.ServerInfo)
+		}
+		return nil
 	}
-
-	return nil
+	return fmt.Errorf("启动MCP客户端失败: %v", lastErr)
 }
 
 func (a *McpClient) ListTools() ([]*McpTool, error) {
@@ -125,7 +144,9 @@ func (a *McpClient) ListTools() ([]*McpTool, error) {
 	for _, tool := range result.Tools {
 		tools = append(tools, &McpTool{
 			Name: tool.Name, Meta: tool.Meta,
-			Description: tool.Description,
+			Title: tool.Title, Description: tool.Description,
+			InputSchema:  AnyToSchema(tool.InputSchema),
+			OutputSchema: AnyToSchema(tool.OutputSchema),
 		})
 	}
 	return tools, nil
@@ -138,21 +159,33 @@ func (a *McpClient) Close() error {
 			log.Println("[MCP] mcp close error:", err)
 		}
 	}
+	if a.cancel != nil {
+		a.cancel()
+	}
 	log.Println("[MCP] mcp closed:", a.server.UUID)
-	delete(clients, a.server.UUID)
+	clientsMu.Lock()
+	if clients[a.key] == a {
+		delete(clients, a.key)
+	}
+	clientsMu.Unlock()
 	return nil
 }
 
-func (a *McpClient) Execute(toolName string, args map[string]any) (string, error) {
+func (a *McpClient) Execute(toolName string, args map[string]any) (*McpResult, error) {
 	log.Println("[MCP] Start Execute:", toolName, support.ToJson(args))
-	duration := time.Duration(EXECUTE_TIMEOUT)
+	defer a.use()()
 	ctx, cancel := context.WithTimeout(
-		context.Background(), duration*time.Second,
+		context.Background(), a.server.GetExecuteTimeout(),
 	)
 	defer cancel()
+	release, err := a.server.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	if a.session == nil {
 		if err := a.Initialize(); err != nil {
-			return "", err
+			return nil, err
 		}
 	}
 	params := &mcp.CallToolParams{
@@ -162,27 +195,63 @@ func (a *McpClient) Execute(toolName string, args map[string]any) (string, error
 	if errors.Is(err, mcp.ErrConnectionClosed) {
 		log.Println("[MCP] Closed & Retry:", toolName)
 		if err = a.Initialize(); err != nil {
-			return "", err
+			return nil, err
 		}
 		res, err = a.session.CallTool(ctx, params)
 	}
 	if err != nil || res == nil {
-		return "", fmt.Errorf("[MCP] 工具调用失败: %v", err)
+		return nil, fmt.Errorf("[MCP] 工具调用失败: %v", err)
 	}
-	if len(res.Content) > 0 {
-		switch v := res.Content[0].(type) {
-		case *mcp.TextContent:
-			return v.Text, nil
-		default:
-			b, _ := json.Marshal(v)
-			return string(b), nil
+	result := &McpResult{
+		IsError:    res.IsError,
+		Structured: res.StructuredContent,
+	}
+	for _, item := range res.Content {
+		result.Content = append(result.Content, toMcpContent(item))
+	}
+	if tool := a.server.FindTool(toolName); tool != nil && !res.IsError {
+		if err := result.Validate(tool.OutputSchema); err != nil {
+			log.Println("[MCP] Invalid Output:", toolName, err)
 		}
 	}
-	return "", nil
+	return result, nil
+}
+
+func toMcpContent(content mcp.Content) *McpContent {
+	switch v := content.(type) {
+	case *mcp.TextContent:
+		return &McpContent{Type: "text", Text: v.Text}
+	case *mcp.ImageContent:
+		return &McpContent{
+			Type: "image", Data: v.Data, MIMEType: v.MIMEType,
+		}
+	case *mcp.AudioContent:
+		return &McpContent{
+			Type: "audio", Data: v.Data, MIMEType: v.MIMEType,
+		}
+	case *mcp.ResourceLink:
+		return &McpContent{
+			Type: "resource_link", URI: v.URI,
+			Name: v.Name, MIMEType: v.MIMEType,
+		}
+	case *mcp.EmbeddedResource:
+		if v.Resource == nil {
+			return &McpContent{Type: "resource"}
+		}
+		return &McpContent{
+			Type: "resource", URI: v.Resource.URI,
+			Text: v.Resource.Text, Data: v.Resource.Blob,
+			MIMEType: v.Resource.MIMEType,
+		}
+	default:
+		data, _ := json.Marshal(v)
+		return &McpContent{Type: "text", Text: string(data)}
+	}
 }
 
 func (a *McpClient) Resources() ([]*Resource, error) {
 	log.Println("[MCP] List Resources:", a.server.UUID)
+	defer a.use()()
 	if a.session == nil {
 		if err := a.Initialize(); err != nil {
 			return nil, err
@@ -218,11 +287,16 @@ func (a *McpClient) Resources() ([]*Resource, error) {
 
 func (a *McpClient) Resource(uri string) (string, error) {
 	log.Println("[MCP] Get Resource:", a.server.Name, uri)
-	duration := time.Duration(EXECUTE_TIMEOUT)
+	defer a.use()()
 	ctx, cancel := context.WithTimeout(
-		context.Background(), duration*time.Second,
+		context.Background(), a.server.GetExecuteTimeout(),
 	)
 	defer cancel()
+	release, err := a.server.Acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
 	if a.session == nil {
 		if err := a.Initialize(); err != nil {
 			return "", err
@@ -5,6 +5,7 @@ package amcp
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -19,53 +20,43 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
-var clients = make(map[string]*McpClient)
-
-func NewMcpClient(server *McpServer) *McpClient {
-	if get, ok := clients[server.UUID]; ok {
-		return get
-	}
-	c := &McpClient{server: server}
-	if err := c.Initialize(); err != nil {
-		server.Status.ErrMsg = err
-		log.Println("[MCP] init fail:", err)
-		return nil
-	}
-	// debug mode not cache
-	if server.Type != "debug" {
-		clients[server.UUID] = c
-	}
-	return c
-}
-
 type McpClient struct {
 	server *McpServer
 	client *client.Client
+	// auto 模式下探测到的传输方式
+	detected string
+	// 实例缓存的 key、最近使用时间与进行中的调用数
+	key      string
+	lastUsed time.Time
+	inflight int
 }
 
-func (a *McpClient) Initialize() error {
-	log.Println("[MCP] Start Init Mcp Server:", a.server.UUID)
-
-	// Create client based on server type
-	var mcpClient *client.Client
-	switch a.server.Type {
+func (a *McpClient) newClient(kind string) (*client.Client, error) {
+	switch kind {
 	case "streamable", "stream":
-		var err error
-		headers := a.server.GetHeaders()
-		mcpClient, err = client.NewStreamableHttpClient(
-			a.server.Url, transport.WithHTTPHeaders(headers),
+		httpClient, err := a.server.HttpClient()
+		if err != nil {
+			return nil, err
+		}
+		return client.NewStreamableHttpClient(
+			a.server.Url, transport.WithHTTPBasicClient(httpClient),
 		)
+	case "sse":
+		httpClient, err := a.server.HttpClient()
 		if err != nil {
-			return fmt.Errorf("启动MCP客户端失败: %v", err)
+			return nil, err
 		}
+		return client.NewSSEMCPClient(
+			a.server.Url, transport.WithHTTPClient(httpClient),
+		)
 	case "debug":
 		cmdPath, err := config.GetMcpEnv(a.server.Cmd)
-		mcpClient, err = client.NewStdioMCPClient(
-			cmdPath, a.server.GetEnv(), a.server.Args...,
-		)
 		if err != nil {
-			return fmt.Errorf("启动MCP客户端失败: %v", err)
+			return nil, err
 		}
+		return client.NewStdioMCPClient(
+			cmdPath, a.server.GetEnv(), a.server.Args...,
+		)
 	default:
 		opt := transport.WithCommandFunc(func(ctx context.Context, cmd string, env, args []string) (*exec.Cmd, error) {
 			command := exec.CommandContext(ctx, cmd, args...)
@@ -74,24 +65,66 @@ func (a *McpClient) Initialize() error {
 			return command, nil
 		})
 		cmdPath, err := config.GetMcpEnv(a.server.Cmd)
-		mcpClient, err = client.NewStdioMCPClientWithOptions(
+		if err != nil {
+			return nil, err
+		}
+		return client.NewStdioMCPClientWithOptions(
 			cmdPath, a.server.GetEnv(), a.server.Args, opt,
 		)
-		if err != nil {
-			return fmt.Errorf("启动MCP客户端失败: %v", err)
+	}
+}
+
+// auto/http 模式先尝试 streamable，失败后回退到旧版 sse
+func (a *McpClient) transportKinds() []string {
+	switch a.server.Type {
+	case "auto", "http":
+		if a.detected != "" {
+			return []string{a.detected}
 		}
+		return []string{"streamable", "sse"}
 	}
+	return []string{a.server.Type}
+}
 
-	// Additional safety check to ensure client is not nil
-	if mcpClient == nil {
-		return fmt.Errorf("MCP客户端创建失败: client is nil")
+func (a *McpClient) Initialize() error {
+	log.Println("[MCP] Start Init Mcp Server:", a.server.UUID)
+
+	var lastErr error
+	for _, kind := range a.transportKinds() {
+		mcpClient, err := a.newClient(kind)
+		if err != nil {
+			return fmt.Errorf("启动MCP客户端失败: %v", err)
+		}
+		// Additional safety check to ensure client is not nil
+		if mcpClient == nil {
+			return fmt.Errorf("MCP客户端创建失败: client is nil")
+		}
+		if err = a.connect(kind, mcpClient); err != nil {
+			log.Printf("[MCP] connect %s via %s: %v", a.server.UUID, kind, err)
+			_ = mcpClient.Close()
+			lastErr = err
+			continue
+		}
+		a.client, a.detected = mcpClient, kind
+		return nil
 	}
+	return lastErr
+}
 
+func (a *McpClient) connect(kind string, mcpClient *client.Client) error {
 	// Initialize the client
-	duration := time.Duration(CONNECT_TIMEOUT)
-	ctx, cancel := context.WithTimeout(context.Background(), duration*time.Second)
+	ctx, cancel := context.WithTimeout(
+		context.Background(), a.server.GetConnectTimeout(),
+	)
 	defer cancel()
 
+	// sse 需要先建立事件流才能发送请求，流的生命周期不受超时限制
+	if kind == "sse" {
+		if err := mcpClient.Start(context.Background()); err != nil {
+			return fmt.Errorf("启动MCP客户端失败: %v", err)
+		}
+	}
+
 	initReq := mcp.InitializeRequest{}
 	initReq.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
 	initReq.Params.Capabilities = mcp.ClientCapabilities{}
@@ -103,7 +136,6 @@ func (a *McpClient) Initialize() error {
 	} else if err = mcpClient.Start(ctx); err != nil {
 		return fmt.Errorf("启动MCP客户端失败: %v", err)
 	} else {
-		a.client = mcpClient
 		log.Println("[MCP] SUCCESS: %w", res.Result)
 	}
 	return nil
@@ -125,10 +157,16 @@ func (a *McpClient) ListTools() ([]*McpTool, error) {
 
 	tools := make([]*McpTool, 0)
 	for _, tool := range result.Tools {
-		tools = append(tools, &McpTool{
+		item := &McpTool{
 			Name:        tool.Name,
 			Description: tool.Description,
-		})
+			InputSchema: AnyToSchema(tool.InputSchema),
+		}
+		// 未声明 outputSchema 时 Type 为空
+		if tool.OutputSchema.Type != "" {
+			item.OutputSchema = AnyToSchema(tool.OutputSchema)
+		}
+		tools = append(tools, item)
 	}
 	return tools, nil
 }
@@ -140,19 +178,30 @@ func (a *McpClient) Close() error {
 			log.Println("[MCP] mcp close error:", err)
 		}
 	}
-	delete(clients, a.server.UUID)
+	clientsMu.Lock()
+	if clients[a.key] == a {
+		delete(clients, a.key)
+	}
+	clientsMu.Unlock()
 	return nil
 }
 
-func (a *McpClient) Execute(toolName string, args map[string]any) (string, error) {
+func (a *McpClient) Execute(toolName string, args map[string]any) (*McpResult, error) {
 	log.Println("[MCP] Start Execute:", toolName, support.ToJson(args))
-	duration := time.Duration(EXECUTE_TIMEOUT)
-	ctx, cancel := context.WithTimeout(context.Background(), duration*time.Second)
+	defer a.use()()
+	ctx, cancel := context.WithTimeout(
+		context.Background(), a.server.GetExecuteTimeout(),
+	)
 	defer cancel()
+	release, err := a.server.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	if a.client == nil {
 		if err := a.Initialize(); err != nil {
-			return "", err
+			return nil, err
 		}
 	}
 
@@ -165,23 +214,67 @@ func (a *McpClient) Execute(toolName string, args map[string]any) (string, error
 
 	res, err := a.client.CallTool(ctx, request)
 	if err != nil || res == nil {
-		return "", fmt.Errorf("MCP工具调用失败: %v", err)
+		return nil, fmt.Errorf("MCP工具调用失败: %v", err)
 	}
 
-	if len(res.Content) > 0 {
-		switch v := res.Content[0].(type) {
-		case *mcp.TextContent:
-			return v.Text, nil
-		default:
-			b, _ := json.Marshal(v)
-			return string(b), nil
+	result := &McpResult{
+		IsError:    res.IsError,
+		Structured: res.StructuredContent,
+	}
+	for _, item := range res.Content {
+		result.Content = append(result.Content, toMcpContent(item))
+	}
+	if tool := a.server.FindTool(toolName); tool != nil && !res.IsError {
+		if err := result.Validate(tool.OutputSchema); err != nil {
+			log.Println("[MCP] Invalid Output:", toolName, err)
 		}
 	}
-	return "", nil
+	return result, nil
+}
+
+func toMcpContent(content mcp.Content) *McpContent {
+	switch v := content.(type) {
+	case mcp.TextContent:
+		return &McpContent{Type: "text", Text: v.Text}
+	case mcp.ImageContent:
+		data, _ := base64.StdEncoding.DecodeString(v.Data)
+		return &McpContent{
+			Type: "image", Data: data, MIMEType: v.MIMEType,
+		}
+	case mcp.AudioContent:
+		data, _ := base64.StdEncoding.DecodeString(v.Data)
+		return &McpContent{
+			Type: "audio", Data: data, MIMEType: v.MIMEType,
+		}
+	case mcp.ResourceLink:
+		return &McpContent{
+			Type: "resource_link", URI: v.URI,
+			Name: v.Name, MIMEType: v.MIMEType,
+		}
+	case mcp.EmbeddedResource:
+		switch r := v.Resource.(type) {
+		case mcp.TextResourceContents:
+			return &McpContent{
+				Type: "resource", URI: r.URI,
+				Text: r.Text, MIMEType: r.MIMEType,
+			}
+		case mcp.BlobResourceContents:
+			data, _ := base64.StdEncoding.DecodeString(r.Blob)
+			return &McpContent{
+				Type: "resource", URI: r.URI,
+				Data: data, MIMEType: r.MIMEType,
+			}
+		}
+		return &McpContent{Type: "resource"}
+	default:
+		data, _ := json.Marshal(v)
+		return &McpContent{Type: "text", Text: string(data)}
+	}
 }
 
 func (a *McpClient) Resources() ([]*Resource, error) {
 	log.Println("[MCP] List Resources:", a.server.UUID)
+	defer a.use()()
 	if a.client == nil {
 		if err := a.Initialize(); err != nil {
 			return nil, err
@@ -206,9 +299,16 @@ func (a *McpClient) Resources() ([]*Resource, error) {
 
 func (a *McpClient) Resource(uri string) (string, error) {
 	log.Println("[MCP] Get Resource:", uri)
-	duration := time.Duration(EXECUTE_TIMEOUT)
-	ctx, cancel := context.WithTimeout(context.Background(), duration*time.Second)
+	defer a.use()()
+	ctx, cancel := context.WithTimeout(
+		context.Background(), a.server.GetExecuteTimeout(),
+	)
 	defer cancel()
+	release, err := a.server.Acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
 	if a.client == nil {
 		if err := a.Initialize(); err != nil {
 			return "", err
@@ -0,0 +1,183 @@
+package amcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"swiflow/secret"
+)
+
+// 兼容 Claude Desktop / Cursor 的 mcpServers 与 VS Code 的 servers/inputs 格式
+type importConfig struct {
+	McpServers map[string]map[string]any `json:"mcpServers"`
+	Servers    map[string]map[string]any `json:"servers"`
+	Inputs     []importInput             `json:"inputs"`
+	Mcp        *importConfig             `json:"mcp"`
+}
+
+type importInput struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Password    bool   `json:"password"`
+}
+
+type ImportReport struct {
+	Imported  []string `json:"imported"`
+	Conflicts []string `json:"conflicts"`
+	Errors    []string `json:"errors"`
+}
+
+var (
+	varPattern  = regexp.MustCompile(`\$\{([a-zA-Z]+)(?::([^}]*))?\}`)
+	uuidPattern = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+	keyPattern  = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+)
+
+// ParseImport 解析外部客户端的 MCP 配置，返回按名称排序的服务列表
+func ParseImport(data []byte) ([]*McpServer, error) {
+	cfg := &importConfig{}
+	if err := json.Unmarshal(stripJsonc(data), cfg); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	if cfg.Mcp != nil { // VS Code settings.json
+		cfg = cfg.Mcp
+	}
+	entries := cfg.McpServers
+	if len(entries) == 0 {
+		entries = cfg.Servers
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no mcpServers found")
+	}
+	inputs := map[string]importInput{}
+	for _, input := range cfg.Inputs {
+		inputs[input.ID] = input
+	}
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := []*McpServer{}
+	for _, name := range names {
+		entry := entries[name]
+		uuid := strings.Trim(uuidPattern.ReplaceAllString(name, "-"), "-")
+		server := &McpServer{UUID: uuid, Name: name}
+		_ = server.FromMap(entry)
+		server.Name = name
+		server.Type = importType(server)
+		server.Cmd = expandVars(server.Cmd, inputs)
+		server.Url = expandVars(server.Url, inputs)
+		for i, arg := range server.Args {
+			server.Args[i] = expandVars(arg, inputs)
+		}
+		for key, val := range server.Env {
+			server.Env[key] = expandSecret(val, inputs)
+		}
+		for key, val := range server.Headers {
+			server.Headers[key] = expandSecret(val, inputs)
+		}
+		result = append(result, server)
+	}
+	return result, nil
+}
+
+func importType(server *McpServer) string {
+	switch server.Type {
+	case "stdio", "":
+		if server.Url != "" && server.Cmd == "" {
+			return "auto"
+		}
+		return ""
+	case "http", "streamableHttp", "streamable-http":
+		return "auto"
+	}
+	return server.Type
+}
+
+// expandSecret env 与 header 的值整个为 ${env:X} 时转为 secret://X 引用，
+// 由同名密钥提供，避免把当前环境中的值作为明文保存
+func expandSecret(val string, inputs map[string]importInput) string {
+	parts := varPattern.FindStringSubmatch(val)
+	if len(parts) > 0 && parts[0] == val && parts[1] == "env" && secret.ValidName(parts[2]) {
+		return secret.Ref(parts[2])
+	}
+	return expandVars(val, inputs)
+}
+
+// 变量替换：${input:x} 与 ${env:X} 转为待填写的占位符，不读取当前环境
+func expandVars(val string, inputs map[string]importInput) string {
+	return varPattern.ReplaceAllStringFunc(val, func(match string) string {
+		parts := varPattern.FindStringSubmatch(match)
+		switch parts[1] {
+		case "input":
+			input := inputs[parts[2]]
+			key := strings.ToUpper(keyPattern.ReplaceAllString(parts[2], "_"))
+			desc := input.Description
+			if desc == "" {
+				desc = parts[2]
+			}
+			return fmt.Sprintf("{{%s@string::%s}}", key, desc)
+		case "env":
+			return fmt.Sprintf("{{%s@string::env %s}}", parts[2], parts[2])
+		case "workspaceFolder":
+			return "$CURRENT_HOME"
+		case "userHome":
+			if home, err := os.UserHomeDir(); err == nil {
+				return home
+			}
+		}
+		return match
+	})
+}
+
+// 去掉 JSONC 中的注释与尾逗号
+func stripJsonc(data []byte) []byte {
+	var out []byte
+	inStr, escaped := false, false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inStr {
+			out = append(out, c)
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == '"' {
+				inStr = false
+			}
+			continue
+		}
+		switch {
+		case c == '"':
+			inStr = true
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			for i += 2; i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/'); i++ {
+			}
+			i++
+			continue
+		case c == ']' || c == '}':
+			// 回退尾逗号
+			j := len(out) - 1
+			for j >= 0 && strings.ContainsRune(" \t\r\n", rune(out[j])) {
+				j--
+			}
+			if j >= 0 && out[j] == ',' {
+				out = append(out[:j], out[j+1:]...)
+			}
+		}
+		if i < len(data) {
+			out = append(out, data[i])
+		}
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+package amcp
+
+import (
+	"testing"
+
+	"swiflow/entity"
+)
+
+func TestParseImport_Claude(t *testing.T) {
+	data := []byte(`{
+		"mcpServers": {
+			"filesystem": {
+				"command": "npx",
+				"args": ["-y", "@modelcontextprotocol/server-filesystem", "${workspaceFolder}"],
+			},
+			"remote api": {"url": "https://example.com/mcp", "headers": {"X-Token": "abc"}}
+		}
+	}`)
+	servers, err := ParseImport(data)
+	if err != nil || len(servers) != 2 {
+		t.Fatalf("期望2个server，实际%v %v", len(servers), err)
+	}
+	if servers[0].UUID != "filesystem" || servers[0].Cmd != "npx" || servers[0].Type != "" {
+		t.Errorf("stdio server不符: %+v", servers[0])
+	}
+	if servers[0].Args[2] != "$CURRENT_HOME" {
+		t.Errorf("workspaceFolder未替换: %v", servers[0].Args)
+	}
+	if servers[1].UUID != "remote-api" || servers[1].Type != "auto" {
+		t.Errorf("remote server不符: %+v", servers[1])
+	}
+	if servers[1].GetHeaders()["X-Token"] != "abc" {
+		t.Errorf("headers未导入: %v", servers[1].Headers)
+	}
+}
+
+func TestParseImport_VSCode(t *testing.T) {
+	t.Setenv("GH_TOKEN", "ghp-plain")
+	data := []byte(`{
+		// VS Code settings.json
+		"mcp": {
+			"inputs": [{"type": "promptString", "id": "api-key", "description": "GitHub Token", "password": true}],
+			"servers": {
+				"github": {
+					"type": "sse", "url": "https://api.example.com/sse",
+					"env": {"TOKEN": "${input:api-key}", "GH": "${env:GH_TOKEN}"},
+					"headers": {"Authorization": "Bearer ${env:GH_TOKEN}"}
+				}
+			}
+		}
+	}`)
+	servers, err := ParseImport(data)
+	if err != nil || len(servers) != 1 {
+		t.Fatalf("期望1个server，实际%v %v", len(servers), err)
+	}
+	if servers[0].Type != "sse" {
+		t.Errorf("type不符: %v", servers[0].Type)
+	}
+	if servers[0].Env["TOKEN"] != "{{API_KEY@string::GitHub Token}}" {
+		t.Errorf("input变量未转换: %v", servers[0].Env)
+	}
+	// 环境变量不以明文导入
+	if servers[0].Env["GH"] != "secret://GH_TOKEN" {
+		t.Errorf("env变量应转为密钥引用: %v", servers[0].Env)
+	}
+	if auth := servers[0].Headers["Authorization"]; auth != "Bearer {{GH_TOKEN@string::env GH_TOKEN}}" {
+		t.Errorf("env变量应转为占位符: %v", auth)
+	}
+}
+
+func TestMcpService_ImportServers(t *testing.T) {
+	store := newTestMcpStorage(map[string]any{"servers": map[string]any{
+		"memory": map[string]any{"command": "npx"},
+	}})
+	service := &McpService{storage: store, servers: map[string]*McpServer{}}
+	data := []byte(`{"mcpServers": {"memory": {"command": "uvx"}, "git": {"command": "uvx"}}}`)
+	report, err := service.ImportServers(data, false)
+	if err != nil {
+		t.Fatalf("导入失败: %v", err)
+	}
+	if len(report.Imported) != 1 || report.Imported[0] != "git" {
+		t.Errorf("导入结果不符: %+v", report)
+	}
+	if len(report.Conflicts) != 1 || report.Conflicts[0] != "memory" {
+		t.Errorf("冲突结果不符: %+v", report)
+	}
+	cfg := &entity.CfgEntity{Type: entity.KEY_MCP_SERVER, Name: entity.KEY_MCP_SERVER}
+	store.store.FindCfg(cfg)
+	servers := cfg.Data["servers"].(map[string]any)
+	if memory, _ := servers["memory"].(map[string]any); memory["command"] != "npx" {
+		t.Errorf("未覆盖时不应修改已有server: %v", memory)
+	}
+}
@@ -0,0 +1,191 @@
+package amcp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"swiflow/config"
+	"sync"
+	"time"
+)
+
+// 空闲超过该时长（秒）的隔离实例会被自动关闭
+var IDLE_TIMEOUT = 600
+
+var (
+	clients   = make(map[string]*McpClient)
+	clientsMu sync.Mutex
+	reapOnce  sync.Once
+	// 每个实例 key 一把锁，避免并发调用各自启动进程
+	initLocks sync.Map
+)
+
+// 实例按（服务, 解析后的 env）区分：env 引用 $CURRENT_HOME 的服务，
+// 不同任务目录各自启动独立进程，互不影响
+func NewMcpClient(server *McpServer) *McpClient {
+	key := server.InstanceKey()
+	if get := cachedClient(key); get != nil {
+		return get
+	}
+	// 同一 key 的初始化串行执行，拿到锁后再检查一次
+	lock, _ := initLocks.LoadOrStore(key, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+	if get := cachedClient(key); get != nil {
+		return get
+	}
+
+	c := &McpClient{server: server, key: key, lastUsed: time.Now()}
+	if err := c.Initialize(); err != nil {
+		server.Status.ErrMsg = err
+		log.Println("[MCP] init fail:", err)
+		return nil
+	}
+	// debug mode not cache
+	if server.Type != "debug" {
+		clientsMu.Lock()
+		clients[key] = c
+		clientsMu.Unlock()
+	}
+	reapOnce.Do(func() { go reapIdleClients() })
+	return c
+}
+
+func cachedClient(key string) *McpClient {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if get, ok := clients[key]; ok {
+		get.lastUsed = time.Now()
+		return get
+	}
+	return nil
+}
+
+// use 标记一次调用开始，返回的函数在调用结束时执行；调用中的实例不会被回收
+func (a *McpClient) use() func() {
+	clientsMu.Lock()
+	a.inflight++
+	a.lastUsed = time.Now()
+	clientsMu.Unlock()
+	return func() {
+		clientsMu.Lock()
+		a.inflight--
+		a.lastUsed = time.Now()
+		clientsMu.Unlock()
+	}
+}
+
+// GetInstance 获取服务在指定任务目录下的实例，home 为空时使用当前目录
+func GetInstance(uuid, home string) (*McpClient, error) {
+	server := findServer(uuid)
+	if home != "" && server.Isolated() {
+		server = server.ForHome(home)
+	}
+	if client := NewMcpClient(server); client != nil {
+		return client, nil
+	}
+	return nil, fmt.Errorf(
+		"mcp server[%s] not in service, err: %s",
+		uuid, server.Status.ErrMsg,
+	)
+}
+
+// 优先使用服务列表中的完整配置
+func findServer(uuid string) *McpServer {
+	if service != nil {
+		service.mu.RLock()
+		server := service.servers[uuid]
+		service.mu.RUnlock()
+		if server != nil {
+			return server
+		}
+	}
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	for _, client := range clients {
+		if client.server.UUID == uuid {
+			return client.server
+		}
+	}
+	return &McpServer{UUID: uuid}
+}
+
+// Isolated 判断 env 是否依赖当前任务目录
+func (s *McpServer) Isolated() bool {
+	for _, val := range s.Env {
+		if val == "$CURRENT_HOME" {
+			return true
+		}
+	}
+	return false
+}
+
+// ForHome 返回绑定到指定目录的副本
+func (s *McpServer) ForHome(home string) *McpServer {
+	server := *s
+	server.home = home
+	return &server
+}
+
+func (s *McpServer) InstanceKey() string {
+	if !s.Isolated() {
+		return s.UUID
+	}
+	env := s.GetEnv()
+	slices.Sort(env)
+	sum := sha256.Sum256([]byte(strings.Join(env, "\n")))
+	return s.UUID + "@" + hex.EncodeToString(sum[:4])
+}
+
+// Instances 列出服务当前运行的全部实例
+func Instances(uuid string) []*McpClient {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	result := []*McpClient{}
+	for _, client := range clients {
+		if client.server.UUID == uuid {
+			result = append(result, client)
+		}
+	}
+	return result
+}
+
+// CloseInstances 关闭服务的全部实例
+func CloseInstances(uuid string) {
+	for _, client := range Instances(uuid) {
+		if err := client.Close(); err != nil {
+			log.Println("[MCP] close instance error:", err)
+		}
+	}
+}
+
+func reapIdleClients() {
+	idle := time.Duration(config.GetInt("MCP_IDLE_TIMEOUT", IDLE_TIMEOUT)) * time.Second
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, client := range idleClients(idle) {
+			log.Println("[MCP] reap idle instance:", client.key)
+			_ = client.Close()
+		}
+	}
+}
+
+// 只回收没有进行中调用的隔离实例，共享实例仍随服务启停
+func idleClients(idle time.Duration) []*McpClient {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	result := []*McpClient{}
+	for _, client := range clients {
+		if !client.server.Isolated() {
+			continue
+		}
+		if client.inflight == 0 && time.Since(client.lastUsed) > idle {
+			result = append(result, client)
+		}
+	}
+	return result
+}
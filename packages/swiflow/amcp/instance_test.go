@@ -0,0 +1,82 @@
+package amcp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMcpServer_InstanceKey(t *testing.T) {
+	shared := &McpServer{UUID: "fetch", Env: map[string]string{"A": "1"}}
+	if shared.Isolated() || shared.ForHome("/tmp/a").InstanceKey() != "fetch" {
+		t.Errorf("未引用 $CURRENT_HOME 的服务应共用实例")
+	}
+
+	server := &McpServer{UUID: "fs", Env: map[string]string{"ROOT": "$CURRENT_HOME"}}
+	a, b := server.ForHome("/tmp/a"), server.ForHome("/tmp/b")
+	if !server.Isolated() || a.InstanceKey() == b.InstanceKey() {
+		t.Errorf("不同目录应使用不同实例: %s %s", a.InstanceKey(), b.InstanceKey())
+	}
+	if a.InstanceKey() != server.ForHome("/tmp/a").InstanceKey() {
+		t.Errorf("相同目录应复用实例")
+	}
+	if env := a.GetEnv(); len(env) != 1 || env[0] != "ROOT=/tmp/a" {
+		t.Errorf("env 未替换为任务目录: %v", env)
+	}
+	if server.home != "" {
+		t.Errorf("ForHome 不应修改原配置")
+	}
+}
+
+func TestIdleClients(t *testing.T) {
+	server := &McpServer{UUID: "fs-idle", Env: map[string]string{"ROOT": "$CURRENT_HOME"}}
+	idle := &McpClient{server: server.ForHome("/tmp/a"), lastUsed: time.Now().Add(-time.Hour)}
+	busy := &McpClient{server: server.ForHome("/tmp/b"), lastUsed: time.Now()}
+	shared := &McpClient{server: &McpServer{UUID: "fetch-idle"}, lastUsed: time.Now().Add(-time.Hour)}
+	clientsMu.Lock()
+	for _, client := range []*McpClient{idle, busy, shared} {
+		client.key = client.server.InstanceKey()
+		clients[client.key] = client
+	}
+	clientsMu.Unlock()
+	defer func() {
+		clientsMu.Lock()
+		for _, client := range []*McpClient{idle, busy, shared} {
+			delete(clients, client.key)
+		}
+		clientsMu.Unlock()
+	}()
+
+	list := idleClients(10 * time.Minute)
+	if len(list) != 1 || list[0] != idle {
+		t.Errorf("只应回收空闲的隔离实例: %v", list)
+	}
+	// 调用进行中的实例即使超时也不回收，调用结束后重新计时
+	done := idle.use()
+	idle.lastUsed = time.Now().Add(-time.Hour)
+	if list := idleClients(10 * time.Minute); len(list) != 0 {
+		t.Errorf("调用中的实例不应回收: %v", list)
+	}
+	done()
+	if list := idleClients(10 * time.Minute); len(list) != 0 {
+		t.Errorf("调用结束应刷新使用时间: %v", list)
+	}
+	if n := len(Instances("fs-idle")); n != 2 {
+		t.Errorf("实例数量错误: %d", n)
+	}
+}
+
+func TestMcpClient_CloseLoser(t *testing.T) {
+	server := &McpServer{UUID: "fs-race"}
+	winner := &McpClient{server: server, key: "fs-race"}
+	loser := &McpClient{server: server, key: "fs-race"}
+	clientsMu.Lock()
+	clients[winner.key] = winner
+	clientsMu.Unlock()
+	defer winner.Close()
+
+	// 关闭未缓存的实例不应移除已缓存的实例
+	loser.Close()
+	if get := cachedClient("fs-race"); get != winner {
+		t.Errorf("已缓存的实例被移除: %v", get)
+	}
+}
@@ -0,0 +1,80 @@
+package amcp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 按服务限制调用频率与并发，保护按量计费的上游接口
+type serverLimiter struct {
+	rate  int
+	slots chan struct{}
+	calls []time.Time
+	mu    sync.Mutex
+}
+
+var (
+	limiters   = map[string]*serverLimiter{}
+	limitMutex sync.Mutex
+)
+
+func getLimiter(server *McpServer) *serverLimiter {
+	limitMutex.Lock()
+	defer limitMutex.Unlock()
+	limiter := limiters[server.UUID]
+	// 配置变更后重建
+	if limiter == nil || limiter.rate != server.RateLimit ||
+		cap(limiter.slots) != server.MaxConcurrent {
+		limiter = &serverLimiter{rate: server.RateLimit}
+		if server.MaxConcurrent > 0 {
+			limiter.slots = make(chan struct{}, server.MaxConcurrent)
+		}
+		limiters[server.UUID] = limiter
+	}
+	return limiter
+}
+
+// Acquire 占用一次调用额度，超出频率直接报错，并发已满时等待至 ctx 结束
+func (s *McpServer) Acquire(ctx context.Context) (func(), error) {
+	if s.RateLimit <= 0 && s.MaxConcurrent <= 0 {
+		return func() {}, nil
+	}
+	limiter := getLimiter(s)
+	if err := limiter.allow(time.Now()); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.UUID, err)
+	}
+	if limiter.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case limiter.slots <- struct{}{}:
+		return func() { <-limiter.slots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s: too many concurrent calls (max %d)",
+			s.UUID, s.MaxConcurrent)
+	}
+}
+
+func (l *serverLimiter) allow(now time.Time) error {
+	if l.rate <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	start, calls := now.Add(-time.Minute), l.calls[:0]
+	for _, t := range l.calls {
+		if t.After(start) {
+			calls = append(calls, t)
+		}
+	}
+	l.calls = calls
+	if len(l.calls) >= l.rate {
+		wait := l.calls[0].Add(time.Minute).Sub(now)
+		return fmt.Errorf("rate limit exceeded (%d calls/min), retry after %ds",
+			l.rate, int(wait.Seconds())+1)
+	}
+	l.calls = append(l.calls, now)
+	return nil
+}
@@ -0,0 +1,54 @@
+package amcp
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMcpServer_RateLimit(t *testing.T) {
+	server := &McpServer{UUID: "limit-rate", RateLimit: 2}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		release, err := server.Acquire(ctx)
+		if err != nil {
+			t.Fatalf("第%d次调用不应被限制: %v", i+1, err)
+		}
+		release()
+	}
+	if _, err := server.Acquire(ctx); err == nil {
+		t.Errorf("超出每分钟次数应报错")
+	}
+
+	// 窗口滑过后恢复
+	limiter := getLimiter(server)
+	if err := limiter.allow(time.Now().Add(61 * time.Second)); err != nil {
+		t.Errorf("一分钟后应恢复: %v", err)
+	}
+}
+
+func TestMcpServer_MaxConcurrent(t *testing.T) {
+	server := &McpServer{UUID: "limit-concurrent", MaxConcurrent: 1}
+	release, err := server.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("首次调用失败: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err = server.Acquire(ctx); err == nil {
+		t.Errorf("并发已满应等待超时")
+	}
+	release()
+	if release, err = server.Acquire(context.Background()); err != nil {
+		t.Errorf("释放后应可继续调用: %v", err)
+	}
+	release()
+}
+
+func TestHashArgs(t *testing.T) {
+	a := HashArgs(map[string]any{"b": 1, "a": "x"})
+	b := HashArgs(map[string]any{"a": "x", "b": 1})
+	if a != b || len(a) != 64 {
+		t.Errorf("相同参数摘要应一致: %s %s", a, b)
+	}
+}
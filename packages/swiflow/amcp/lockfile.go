@@ -0,0 +1,107 @@
+package amcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"swiflow/config"
+)
+
+const LOCK_FILE = "mcp-lock.json"
+
+var lockMutex sync.Mutex
+
+// LockEntry 记录某个服务实际安装的包版本
+type LockEntry struct {
+	Name    string `json:"name"`
+	Manager string `json:"manager"`
+	Version string `json:"version"`
+	// docker 镜像的 sha256 摘要
+	Digest string `json:"digest,omitempty"`
+	// 固定版本后不随 upgrade 自动升级
+	Pinned    bool  `json:"pinned,omitempty"`
+	UpdatedAt int64 `json:"updatedAt"`
+}
+
+type McpLock struct {
+	path    string
+	Servers map[string]*LockEntry `json:"servers"`
+}
+
+func LoadLock() *McpLock {
+	return LoadLockFile(config.GetWorkPath(LOCK_FILE))
+}
+
+func LoadLockFile(path string) *McpLock {
+	lockMutex.Lock()
+	defer lockMutex.Unlock()
+	lock := &McpLock{path: path}
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, lock)
+	}
+	if lock.Servers == nil {
+		lock.Servers = map[string]*LockEntry{}
+	}
+	lock.path = path
+	return lock
+}
+
+func (l *McpLock) Get(uuid string) *LockEntry {
+	return l.Servers[uuid]
+}
+
+// Record 查询本地安装的版本并写入锁文件，保留原有的固定标记
+func (l *McpLock) Record(uuid string, pkg *PackageInfo) (*LockEntry, error) {
+	version, digest, err := pkg.InstalledVersion()
+	if err != nil {
+		return nil, err
+	}
+	entry := &LockEntry{
+		Name: pkg.Name, Manager: pkg.Manager,
+		Version: version, Digest: digest,
+		UpdatedAt: time.Now().Unix(),
+	}
+	if old := l.Get(uuid); old != nil {
+		entry.Pinned = old.Pinned
+	}
+	l.Servers[uuid] = entry
+	return entry, l.Save()
+}
+
+func (l *McpLock) Remove(uuid string) error {
+	delete(l.Servers, uuid)
+	return l.Save()
+}
+
+func (l *McpLock) Save() error {
+	lockMutex.Lock()
+	defer lockMutex.Unlock()
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(l.path, data, 0644); err != nil {
+		return fmt.Errorf("save lock: %w", err)
+	}
+	return nil
+}
+
+// Check 校验本地安装与锁定记录是否一致
+func (e *LockEntry) Check(pkg *PackageInfo) error {
+	version, digest, err := pkg.InstalledVersion()
+	if err != nil {
+		return err
+	}
+	if e.Digest != "" && digest != "" && e.Digest != digest {
+		return fmt.Errorf("%s digest mismatch: locked %s, got %s",
+			pkg.Name, e.Digest, digest)
+	}
+	if e.Pinned && e.Version != "" && version != e.Version {
+		return fmt.Errorf("%s version mismatch: pinned %s, got %s",
+			pkg.Name, e.Version, version)
+	}
+	return nil
+}
@@ -0,0 +1,558 @@
+package amcp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"swiflow/entity"
+	"swiflow/storage"
+	"swiflow/support"
+	"sync"
+	"time"
+)
+
+// 等待用户在浏览器完成授权的时间（秒）
+var OAUTH_TIMEOUT = 300
+
+// 授权回调的固定端口，redirect_uri 不变时可复用已注册的 client_id
+var OAUTH_PORT = 11236
+
+const OAUTH_CALLBACK = "/callback"
+
+type OAuthMeta struct {
+	Issuer        string   `json:"issuer,omitempty"`
+	Resource      string   `json:"resource,omitempty"`
+	AuthorizeUrl  string   `json:"authorization_endpoint"`
+	TokenUrl      string   `json:"token_endpoint"`
+	RegisterUrl   string   `json:"registration_endpoint,omitempty"`
+	ScopeSupports []string `json:"scopes_supported,omitempty"`
+}
+
+type OAuthClient struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret,omitempty"`
+	RedirectUri  string `json:"redirect_uri,omitempty"`
+}
+
+type OAuthToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	ExpiresAt    int64  `json:"expires_at,omitempty"`
+}
+
+// 提前 30s 视为过期，避免请求途中失效
+func (t *OAuthToken) Valid() bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	if t.ExpiresAt == 0 {
+		return true
+	}
+	return time.Now().Unix() < t.ExpiresAt-30
+}
+
+type OAuthState struct {
+	Meta   *OAuthMeta   `json:"meta,omitempty"`
+	Client *OAuthClient `json:"client,omitempty"`
+	Token  *OAuthToken  `json:"token,omitempty"`
+}
+
+type McpOAuth struct {
+	server *McpServer
+	store  storage.MyStore
+	client *http.Client
+	state  *OAuthState
+	mu     sync.Mutex
+}
+
+func NewMcpOAuth(server *McpServer, store storage.MyStore) *McpOAuth {
+	return &McpOAuth{
+		server: server, store: store,
+		client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+var (
+	oauthMu sync.Mutex
+	oauths  = map[string]*McpOAuth{}
+)
+
+// OAuth 授权状态按 server uuid 存储在 CfgEntity
+// 同一服务共用一个实例，避免并发请求各自刷新使对方的 refresh token 失效
+func (s *McpServer) OAuth() *McpOAuth {
+	oauthMu.Lock()
+	defer oauthMu.Unlock()
+	if auth, ok := oauths[s.UUID]; ok && auth.server.Url == s.Url {
+		return auth
+	}
+	store, _ := storage.GetStorage()
+	auth := NewMcpOAuth(s, store)
+	oauths[s.UUID] = auth
+	return auth
+}
+
+func (o *McpOAuth) load() *OAuthState {
+	if o.state != nil {
+		return o.state
+	}
+	o.state = &OAuthState{}
+	if o.store == nil {
+		return o.state
+	}
+	cfg := &entity.CfgEntity{
+		Type: entity.KEY_MCP_OAUTH,
+		Name: o.server.UUID,
+	}
+	if err := o.store.FindCfg(cfg); err != nil || len(cfg.Data) == 0 {
+		return o.state
+	}
+	if data, err := json.Marshal(cfg.Data); err == nil {
+		_ = json.Unmarshal(data, o.state)
+	}
+	return o.state
+}
+
+func (o *McpOAuth) save() error {
+	if o.store == nil {
+		return nil
+	}
+	cfg := &entity.CfgEntity{
+		Type: entity.KEY_MCP_OAUTH,
+		Name: o.server.UUID,
+	}
+	_ = o.store.FindCfg(cfg)
+	cfg.Data = map[string]any{}
+	if data, err := json.Marshal(o.state); err == nil {
+		_ = json.Unmarshal(data, &cfg.Data)
+	}
+	if err := o.store.SaveCfg(cfg); err != nil {
+		log.Printf("[OAUTH] failed to save %s: %v", o.server.UUID, err)
+		return err
+	}
+	return nil
+}
+
+// Token 返回可用的 access token，过期则刷新，没有则发起授权
+func (o *McpOAuth) Token(ctx context.Context) (*OAuthToken, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	state := o.load()
+	if state.Token.Valid() {
+		return state.Token, nil
+	}
+	if state.Token != nil && state.Token.RefreshToken != "" {
+		if token, err := o.refresh(ctx); err == nil {
+			return token, nil
+		} else {
+			log.Printf("[OAUTH] refresh %s failed: %v", o.server.UUID, err)
+		}
+	}
+	return o.authorize(ctx)
+}
+
+// Cached 仅返回已存储且未过期的 token，不触发任何网络请求
+func (o *McpOAuth) Cached() *OAuthToken {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if token := o.load().Token; token.Valid() {
+		return token
+	}
+	return nil
+}
+
+// Revoke 清除本地保存的 token，下次连接重新授权
+func (o *McpOAuth) Revoke() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.load().Token = nil
+	return o.save()
+}
+
+// Discover 按 RFC9728/RFC8414 查找授权服务器元数据
+func (o *McpOAuth) Discover(ctx context.Context) (*OAuthMeta, error) {
+	target, err := url.Parse(o.server.Url)
+	if err != nil || target.Host == "" {
+		return nil, fmt.Errorf("invalid server url: %s", o.server.Url)
+	}
+	origin := target.Scheme + "://" + target.Host
+	path := strings.TrimSuffix(target.Path, "/")
+
+	issuer, resource := origin, o.server.Url
+	candidates := []string{}
+	if found := o.probeResourceMeta(ctx); found != "" {
+		candidates = append(candidates, found)
+	}
+	if path != "" {
+		candidates = append(candidates, origin+"/.well-known/oauth-protected-resource"+path)
+	}
+	candidates = append(candidates, origin+"/.well-known/oauth-protected-resource")
+	for _, link := range candidates {
+		prm := struct {
+			Resource string   `json:"resource"`
+			Servers  []string `json:"authorization_servers"`
+		}{}
+		if o.getJson(ctx, link, &prm) != nil {
+			continue
+		}
+		if len(prm.Servers) > 0 {
+			issuer = strings.TrimSuffix(prm.Servers[0], "/")
+		}
+		if prm.Resource != "" {
+			resource = prm.Resource
+		}
+		break
+	}
+
+	meta := &OAuthMeta{}
+	issuerUrl, _ := url.Parse(issuer)
+	base := issuerUrl.Scheme + "://" + issuerUrl.Host
+	suffix := strings.TrimSuffix(issuerUrl.Path, "/")
+	links := []string{
+		base + "/.well-known/oauth-authorization-server" + suffix,
+		base + "/.well-known/openid-configuration" + suffix,
+	}
+	if suffix != "" {
+		links = append(links, issuer+"/.well-known/openid-configuration")
+	}
+	for _, link := range links {
+		if err = o.getJson(ctx, link, meta); err == nil && meta.TokenUrl != "" {
+			break
+		}
+	}
+	// 没有元数据时按 MCP 规范回退到默认端点
+	if meta.AuthorizeUrl == "" || meta.TokenUrl == "" {
+		meta.AuthorizeUrl = base + "/authorize"
+		meta.TokenUrl = base + "/token"
+		meta.RegisterUrl = base + "/register"
+	}
+	meta.Issuer = support.Or(meta.Issuer, issuer)
+	meta.Resource = resource
+	return meta, nil
+}
+
+// 未授权请求会返回 401，从 WWW-Authenticate 中读取 resource_metadata
+func (o *McpOAuth) probeResourceMeta(ctx context.Context) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.server.Url, nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		return ""
+	}
+	for _, header := range resp.Header.Values("WWW-Authenticate") {
+		for _, part := range strings.Split(header, ",") {
+			part = strings.TrimSpace(part)
+			if idx := strings.Index(part, "resource_metadata="); idx >= 0 {
+				val := part[idx+len("resource_metadata="):]
+				return strings.Trim(val, `"`)
+			}
+		}
+	}
+	return ""
+}
+
+// register 动态注册客户端（RFC7591）
+func (o *McpOAuth) register(ctx context.Context, redirect string) (*OAuthClient, error) {
+	meta := o.state.Meta
+	if meta == nil || meta.RegisterUrl == "" {
+		return nil, fmt.Errorf("server not support dynamic client registration")
+	}
+	body, _ := json.Marshal(map[string]any{
+		"client_name":   "Swiflow",
+		"redirect_uris": []string{redirect},
+		"grant_types": []string{
+			"authorization_code", "refresh_token",
+		},
+		"response_types":             []string{"code"},
+		"token_endpoint_auth_method": "none",
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		meta.RegisterUrl, strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("register client: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("register client: %s %s", resp.Status, data)
+	}
+	client := &OAuthClient{RedirectUri: redirect}
+	if err := json.Unmarshal(data, client); err != nil {
+		return nil, fmt.Errorf("register client: %w", err)
+	}
+	if client.ClientID == "" {
+		return nil, fmt.Errorf("register client: empty client_id")
+	}
+	return client, nil
+}
+
+func (o *McpOAuth) authorize(ctx context.Context) (*OAuthToken, error) {
+	state := o.load()
+	if meta, err := o.Discover(ctx); err != nil {
+		return nil, err
+	} else {
+		state.Meta = meta
+	}
+
+	listener, err := listenCallback(state.Client)
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+	redirect := fmt.Sprintf("http://127.0.0.1:%d%s", port, OAUTH_CALLBACK)
+
+	// 端口被占用而改用其他端口时才需要重新注册
+	if state.Client == nil || state.Client.RedirectUri != redirect {
+		client, err := o.register(ctx, redirect)
+		if err != nil {
+			return nil, err
+		}
+		state.Client = client
+		_ = o.save()
+	}
+
+	verifier := randomString(32)
+	challenge := sha256.Sum256([]byte(verifier))
+	nonce := randomString(16)
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {state.Client.ClientID},
+		"redirect_uri":          {redirect},
+		"state":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+		"resource":              {state.Meta.Resource},
+	}
+	if len(state.Meta.ScopeSupports) > 0 {
+		query.Set("scope", strings.Join(state.Meta.ScopeSupports, " "))
+	}
+	link := state.Meta.AuthorizeUrl
+	if strings.Contains(link, "?") {
+		link += "&" + query.Encode()
+	} else {
+		link += "?" + query.Encode()
+	}
+
+	codes, errs := make(chan string, 1), make(chan error, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc(OAUTH_CALLBACK, func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		if e := params.Get("error"); e != "" {
+			errs <- fmt.Errorf("authorize: %s %s", e, params.Get("error_description"))
+			w.Write([]byte("授权失败，请关闭此页面"))
+			return
+		}
+		if params.Get("state") != nonce {
+			http.Error(w, "state mismatch", http.StatusBadRequest)
+			return
+		}
+		codes <- params.Get("code")
+		w.Write([]byte("授权成功，请关闭此页面返回 Swiflow"))
+	})
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	// 由桌面端监听 open-browser 打开系统浏览器
+	log.Println("[OAUTH] open url:", link)
+	support.Emit("open-browser", o.server.UUID, link)
+
+	duration := time.Duration(OAUTH_TIMEOUT) * time.Second
+	waitCtx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	var code string
+	select {
+	case code = <-codes:
+	case err := <-errs:
+		o.dropClient(err)
+		return nil, err
+	case <-waitCtx.Done():
+		return nil, fmt.Errorf("authorize timeout: %w", waitCtx.Err())
+	}
+
+	token, err := o.exchange(ctx, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirect},
+		"code_verifier": {verifier},
+	})
+	if err != nil {
+		o.dropClient(err)
+		return nil, err
+	}
+	state.Token = token
+	return token, o.save()
+}
+
+// listenCallback 优先使用已注册的回调端口，其次固定端口，都被占用时使用随机端口
+func listenCallback(client *OAuthClient) (net.Listener, error) {
+	ports := []string{}
+	if client != nil {
+		if link, err := url.Parse(client.RedirectUri); err == nil && link.Port() != "" {
+			ports = append(ports, link.Port())
+		}
+	}
+	ports = append(ports, strconv.Itoa(OAUTH_PORT), "0")
+	var err error
+	for _, port := range ports {
+		var listener net.Listener
+		if listener, err = net.Listen("tcp", "127.0.0.1:"+port); err == nil {
+			return listener, nil
+		}
+	}
+	return nil, fmt.Errorf("oauth callback listen: %w", err)
+}
+
+// dropClient 服务端不再认可已注册的客户端时清除，下次授权重新注册
+func (o *McpOAuth) dropClient(err error) {
+	msg := err.Error()
+	if strings.Contains(msg, "invalid_client") || strings.Contains(msg, "unauthorized_client") {
+		log.Printf("[OAUTH] client rejected %s: %v", o.server.UUID, err)
+		o.state.Client = nil
+		_ = o.save()
+	}
+}
+
+func (o *McpOAuth) refresh(ctx context.Context) (*OAuthToken, error) {
+	state := o.load()
+	if state.Meta == nil || state.Client == nil {
+		return nil, fmt.Errorf("oauth not initialized")
+	}
+	token, err := o.exchange(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {state.Token.RefreshToken},
+	})
+	if err != nil {
+		o.dropClient(err)
+		return nil, err
+	}
+	// 部分服务刷新时不返回新的 refresh token
+	if token.RefreshToken == "" {
+		token.RefreshToken = state.Token.RefreshToken
+	}
+	state.Token = token
+	return token, o.save()
+}
+
+func (o *McpOAuth) exchange(ctx context.Context, form url.Values) (*OAuthToken, error) {
+	state := o.state
+	form.Set("client_id", state.Client.ClientID)
+	if state.Meta.Resource != "" {
+		form.Set("resource", state.Meta.Resource)
+	}
+	if state.Client.ClientSecret != "" {
+		form.Set("client_secret", state.Client.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		state.Meta.TokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("token request: %s %s", resp.Status, data)
+	}
+	token := &OAuthToken{}
+	if err := json.Unmarshal(data, token); err != nil {
+		return nil, fmt.Errorf("token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("token response: empty access_token")
+	}
+	if token.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Unix() + token.ExpiresIn
+	}
+	return token, nil
+}
+
+func (o *McpOAuth) getJson(ctx context.Context, link string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", link, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Expire 标记 access token 失效，保留 refresh token 用于刷新
+func (o *McpOAuth) Expire() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if token := o.load().Token; token != nil {
+		token.ExpiresAt = 1
+	}
+}
+
+// Transport 为每个请求注入 Bearer token，token 过期时自动刷新
+func (o *McpOAuth) Transport(base http.RoundTripper) http.RoundTripper {
+	return &oauthRoundTripper{rt: base, auth: o}
+}
+
+type oauthRoundTripper struct {
+	rt   http.RoundTripper
+	auth *McpOAuth
+}
+
+func (h *oauthRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	token := h.auth.Cached()
+	if token == nil {
+		var err error
+		if token, err = h.auth.Token(req.Context()); err != nil {
+			return nil, fmt.Errorf("oauth: %w", err)
+		}
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	resp, err := h.rt.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		log.Println("[OAUTH] token rejected:", h.auth.server.UUID)
+		h.auth.Expire()
+	}
+	return resp, err
+}
+
+func randomString(size int) string {
+	buf := make([]byte, size)
+	_, _ = rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,150 @@
+package amcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"swiflow/entity"
+	"swiflow/storage"
+)
+
+func newTestOAuthServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("/mcp", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer new-token" {
+			w.Header().Set("WWW-Authenticate", `Bearer resource_metadata="`+
+				srv.URL+`/.well-known/oauth-protected-resource/mcp"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/.well-known/oauth-protected-resource/mcp", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"resource":              srv.URL + "/mcp",
+			"authorization_servers": []string{srv.URL + "/auth"},
+		})
+	})
+	mux.HandleFunc("/.well-known/oauth-authorization-server/auth", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                 srv.URL + "/auth",
+			"authorization_endpoint": srv.URL + "/auth/authorize",
+			"token_endpoint":         srv.URL + "/auth/token",
+			"registration_endpoint":  srv.URL + "/auth/register",
+		})
+	})
+	mux.HandleFunc("/auth/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "refresh_token" ||
+			r.Form.Get("refresh_token") != "old-refresh" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "new-token", "expires_in": 3600,
+		})
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMcpOAuth_Discover(t *testing.T) {
+	srv := newTestOAuthServer(t)
+	server := &McpServer{UUID: "remote", Url: srv.URL + "/mcp"}
+	meta, err := NewMcpOAuth(server, nil).Discover(context.Background())
+	if err != nil {
+		t.Fatalf("发现授权元数据失败: %v", err)
+	}
+	if meta.TokenUrl != srv.URL+"/auth/token" {
+		t.Errorf("token_endpoint不符: %s", meta.TokenUrl)
+	}
+	if meta.Resource != srv.URL+"/mcp" {
+		t.Errorf("resource不符: %s", meta.Resource)
+	}
+}
+
+func TestMcpOAuth_Refresh(t *testing.T) {
+	srv := newTestOAuthServer(t)
+	server := &McpServer{UUID: "remote", Url: srv.URL + "/mcp"}
+	mock := storage.NewMockStore()
+	mock.SetCfgs([]*entity.CfgEntity{{
+		Type: entity.KEY_MCP_OAUTH, Name: server.UUID,
+		Data: map[string]any{
+			"meta": map[string]any{
+				"authorization_endpoint": srv.URL + "/auth/authorize",
+				"token_endpoint":         srv.URL + "/auth/token",
+			},
+			"client": map[string]any{"client_id": "swiflow"},
+			"token": map[string]any{
+				"access_token": "expired", "refresh_token": "old-refresh",
+				"expires_at": time.Now().Unix() - 10,
+			},
+		},
+	}})
+
+	auth := NewMcpOAuth(server, mock)
+	client := &http.Client{Transport: auth.Transport(http.DefaultTransport)}
+	resp, err := client.Get(server.Url)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("期望200，实际%v", resp.StatusCode)
+	}
+
+	// 刷新后的 token 应写回存储
+	reload := NewMcpOAuth(server, mock).Cached()
+	if reload == nil || reload.AccessToken != "new-token" {
+		t.Errorf("token未保存: %+v", reload)
+	}
+	if reload != nil && reload.RefreshToken != "old-refresh" {
+		t.Errorf("refresh token丢失: %+v", reload)
+	}
+}
+
+func TestMcpServer_OAuthShared(t *testing.T) {
+	server := &McpServer{UUID: "shared-oauth", Url: "https://a.example.com/mcp"}
+	auth := server.OAuth()
+	copied := *server
+	if copied.OAuth() != auth {
+		t.Errorf("同一服务应共用授权实例")
+	}
+	copied.Url = "https://b.example.com/mcp"
+	if copied.OAuth() == auth {
+		t.Errorf("地址变化后应使用新的授权实例")
+	}
+}
+
+func TestListenCallback(t *testing.T) {
+	first, err := listenCallback(nil)
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	port := first.Addr().(*net.TCPAddr).Port
+	first.Close()
+	// 已注册的回调端口可用时复用，保持 redirect_uri 不变
+	client := &OAuthClient{RedirectUri: fmt.Sprintf("http://127.0.0.1:%d%s", port, OAUTH_CALLBACK)}
+	again, err := listenCallback(client)
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	defer again.Close()
+	if again.Addr().(*net.TCPAddr).Port != port {
+		t.Errorf("应复用已注册的端口 %d", port)
+	}
+	// 端口被占用时改用其他端口
+	other, err := listenCallback(client)
+	if err != nil {
+		t.Fatalf("端口占用时应改用其他端口: %v", err)
+	}
+	other.Close()
+}
@@ -1,23 +1,31 @@
 package amcp
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	"swiflow/ability"
 	"swiflow/config"
+	"swiflow/support"
 )
 
 // PackageInfo represents information about a package to be preloaded
 type PackageInfo struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
-	Manager string `json:"manager"` // "uvx" or "npx"
+	Manager string `json:"manager"` // "uvx", "npx" or "docker"
 	Status  string `json:"status"`  // "checking", "installing", "completed", "failed"
+
+	// 命令参数中原始的包描述，用于改写版本
+	spec string
 }
 
 // GetInstallCommand returns the command to install this package
@@ -27,6 +35,8 @@ func (pkg *PackageInfo) GetInstallCommand() (string, []string, error) {
 		return pkg.getUvxInstallCmd()
 	case "npx":
 		return pkg.getNpxInstallCmd()
+	case "docker":
+		return "docker", []string{"pull", pkg.Spec()}, nil
 	default:
 		return "", nil, fmt.Errorf("unsupported package manager: %s", pkg.Manager)
 	}
@@ -88,6 +98,9 @@ func (pkg *PackageInfo) IsInstalled() (bool, error) {
 		return pkg.isUvxPackageInstalled()
 	case "npx":
 		return pkg.isNpxPackageInstalled()
+	case "docker":
+		command := exec.Command("docker", "image", "inspect", pkg.Spec())
+		return command.Run() == nil, nil
 	default:
 		return false, fmt.Errorf("unsupported package manager: %s", pkg.Manager)
 	}
@@ -110,25 +123,14 @@ func (pkg *PackageInfo) isUvxPackageInstalled() (bool, error) {
 }
 
 // isNpxPackageInstalled checks if this npx package is installed
+// 与 InstalledVersion 一样以 npm ls -g 为准，指定版本时需版本一致
 func (pkg *PackageInfo) isNpxPackageInstalled() (bool, error) {
-	npmPath, err := config.GetMcpEnv("npm")
+	version, _, err := pkg.InstalledVersion()
 	if err != nil {
-		npmPath = "npm"
-	}
-
-	packageSpec := pkg.Name
-	if pkg.Version != "" {
-		packageSpec = fmt.Sprintf("%s@%s", pkg.Name, pkg.Version)
-	}
-	command := exec.Command(npmPath, "cache", "ls", packageSpec)
-	if output, err := command.CombinedOutput(); err != nil {
-		log.Printf("[MCP] Missing Cache %s: %v", pkg.Name, err)
-		return false, fmt.Errorf("failed to check cache: %v", err)
-	} else if string(output) == "" || len(string(output)) < len(pkg.Name) {
-		log.Printf("[MCP] Missing Cache %s: %s", pkg.Name, string(output))
-		return false, fmt.Errorf("failed to check cache: %v", err)
+		log.Printf("[MCP] Missing Package %s: %v", pkg.Name, err)
+		return false, nil
 	}
-	return true, nil
+	return pkg.Version == "" || pkg.Version == version, nil
 }
 
 // Install starts async installation of this package
@@ -246,6 +248,8 @@ func (pkg *PackageInfo) ParseCommand(cmd string, args []string) error {
 		return pkg.parseUvxCommand(args)
 	} else if strings.Contains(cmd, "npx") {
 		return pkg.parseNpxCommand(args)
+	} else if isDockerCmd(cmd) {
+		return pkg.parseDockerCommand(args)
 	} else if strings.HasPrefix(cmd, "uv") {
 		pkg.Manager, pkg.Name = "uv", "local"
 		return nil
@@ -350,6 +354,7 @@ func (pkg *PackageInfo) parsePackageSpec(spec, manager string) error {
 	if strings.HasPrefix(spec, "@") {
 		// For scoped packages like @larksuiteoapi/lark-mcp
 		parts := strings.Split(spec, "@")
+		pkg.spec = spec
 		if len(parts) > 2 { // Has version: @scope/name@version
 			pkg.Name = "@" + parts[1]
 			pkg.Version = parts[2]
@@ -365,6 +370,7 @@ func (pkg *PackageInfo) parsePackageSpec(spec, manager string) error {
 	re := regexp.MustCompile(`^([^@]+)(?:@(.+))?$`)
 	matches := re.FindStringSubmatch(spec)
 
+	pkg.spec = spec
 	if len(matches) < 2 {
 		return fmt.Errorf("unformat")
 	}
@@ -377,3 +383,207 @@ func (pkg *PackageInfo) parsePackageSpec(spec, manager string) error {
 
 	return nil
 }
+
+// Spec returns the package specification with version, e.g. name@1.0.0 or image:tag
+func (pkg *PackageInfo) Spec() string {
+	switch {
+	case pkg.Version == "":
+		return pkg.Name
+	case pkg.Manager != "docker":
+		return pkg.Name + "@" + pkg.Version
+	case strings.HasPrefix(pkg.Version, "sha256:"):
+		return pkg.Name + "@" + pkg.Version
+	default:
+		return pkg.Name + ":" + pkg.Version
+	}
+}
+
+// Rewrite replaces the package spec in command args with the current version
+func (pkg *PackageInfo) Rewrite(args []string) []string {
+	result := make([]string, len(args))
+	copy(result, args)
+	if pkg.spec == "" {
+		return result
+	}
+	for i, arg := range result {
+		if arg == pkg.spec {
+			result[i] = pkg.Spec()
+			break
+		}
+		if strings.HasSuffix(arg, "="+pkg.spec) {
+			result[i] = strings.TrimSuffix(arg, pkg.spec) + pkg.Spec()
+			break
+		}
+	}
+	return result
+}
+
+func isDockerCmd(cmd string) bool {
+	name := strings.TrimSuffix(filepath.Base(cmd), ".exe")
+	return name == "docker" || name == "podman"
+}
+
+// docker run 中带值的参数，image 之前需要跳过
+var dockerValueFlags = []string{
+	"-e", "--env", "--env-file", "-v", "--volume", "--mount",
+	"--name", "-p", "--publish", "--network", "--entrypoint",
+	"-w", "--workdir", "-u", "--user", "-l", "--label", "--platform",
+}
+
+// parseDockerCommand parses docker run arguments to extract the image
+// docker usage: docker run [OPTIONS] IMAGE[:TAG|@DIGEST] [COMMAND] [ARG...]
+func (pkg *PackageInfo) parseDockerCommand(args []string) error {
+	start := slices.Index(args, "run")
+	if start < 0 {
+		return fmt.Errorf("unsupported docker command")
+	}
+	for i := start + 1; i < len(args); i++ {
+		arg := args[i]
+		if slices.Contains(dockerValueFlags, arg) {
+			i++
+			continue
+		}
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		pkg.spec, pkg.Manager = arg, "docker"
+		if name, digest, ok := strings.Cut(arg, "@"); ok {
+			pkg.Name, pkg.Version = name, digest
+			return nil
+		}
+		// 冒号在最后一个 / 之后才是 tag，避免误判 registry 端口
+		idx := strings.LastIndex(arg, ":")
+		if idx > strings.LastIndex(arg, "/") {
+			pkg.Name, pkg.Version = arg[:idx], arg[idx+1:]
+		} else {
+			pkg.Name = arg
+		}
+		return nil
+	}
+	return fmt.Errorf("docker image not found")
+}
+
+// GetUpgradeCommand returns the command to upgrade to pkg.Version, or latest if empty
+func (pkg *PackageInfo) GetUpgradeCommand() (string, []string, error) {
+	switch pkg.Manager {
+	case "uvx":
+		command, args, _ := pkg.getUvxInstallCmd()
+		if pkg.Version == "" {
+			return command, []string{"tool", "upgrade", pkg.Name}, nil
+		}
+		return command, append(args, "--force"), nil
+	case "npx":
+		command, _, _ := pkg.getNpxInstallCmd()
+		return command, []string{"install", "-g", pkg.Name + "@" + support.Or(pkg.Version, "latest")}, nil
+	case "docker":
+		return "docker", []string{"pull", pkg.Spec()}, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported package manager: %s", pkg.Manager)
+	}
+}
+
+// GetUninstallCommand returns the command to remove this package
+func (pkg *PackageInfo) GetUninstallCommand() (string, []string, error) {
+	switch pkg.Manager {
+	case "uvx":
+		command, _, _ := pkg.getUvxInstallCmd()
+		return command, []string{"tool", "uninstall", pkg.Name}, nil
+	case "npx":
+		command, _, _ := pkg.getNpxInstallCmd()
+		return command, []string{"uninstall", "-g", pkg.Name}, nil
+	case "docker":
+		return "docker", []string{"rmi", pkg.Spec()}, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported package manager: %s", pkg.Manager)
+	}
+}
+
+// InstalledVersion queries the version (and image digest for docker) installed locally
+func (pkg *PackageInfo) InstalledVersion() (version string, digest string, err error) {
+	switch pkg.Manager {
+	case "uvx":
+		command, _, _ := pkg.getUvxInstallCmd()
+		output, err := runPackageCmd(command, "tool", "list")
+		if err != nil {
+			return "", "", err
+		}
+		// 输出格式：name v1.2.3
+		for _, line := range strings.Split(output, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 && fields[0] == pkg.Name {
+				return strings.TrimPrefix(fields[1], "v"), "", nil
+			}
+		}
+	case "npx":
+		command, _, _ := pkg.getNpxInstallCmd()
+		output, err := runPackageCmd(command, "ls", "-g", pkg.Name, "--json", "--depth=0")
+		if err != nil {
+			return "", "", err
+		}
+		result := struct {
+			Dependencies map[string]struct {
+				Version string `json:"version"`
+			} `json:"dependencies"`
+		}{}
+		if err = json.Unmarshal([]byte(output), &result); err != nil {
+			return "", "", fmt.Errorf("parse npm ls: %v", err)
+		}
+		if dep, ok := result.Dependencies[pkg.Name]; ok {
+			return dep.Version, "", nil
+		}
+	case "docker":
+		output, err := runPackageCmd("docker", "image", "inspect",
+			"--format", "{{json .RepoDigests}}", pkg.Spec())
+		if err != nil {
+			return "", "", err
+		}
+		digests := []string{}
+		_ = json.Unmarshal([]byte(output), &digests)
+		for _, item := range digests {
+			if _, sum, ok := strings.Cut(item, "@"); ok {
+				digest = sum
+				break
+			}
+		}
+		return support.Or(pkg.Version, "latest"), digest, nil
+	default:
+		return "", "", fmt.Errorf("unsupported package manager: %s", pkg.Manager)
+	}
+	return "", "", fmt.Errorf("package %s not installed", pkg.Name)
+}
+
+// Upgrade installs pkg.Version (or latest) synchronously
+func (pkg *PackageInfo) Upgrade() error {
+	cmd, args, err := pkg.GetUpgradeCommand()
+	if err != nil {
+		return err
+	}
+	log.Printf("[MCP] Upgrade %s: %s %s", pkg.Name, cmd, strings.Join(args, " "))
+	if _, err = runPackageCmd(cmd, args...); err != nil {
+		return fmt.Errorf("upgrade %s: %v", pkg.Name, err)
+	}
+	return nil
+}
+
+// Uninstall removes this package synchronously
+func (pkg *PackageInfo) Uninstall() error {
+	cmd, args, err := pkg.GetUninstallCommand()
+	if err != nil {
+		return err
+	}
+	log.Printf("[MCP] Uninstall %s: %s %s", pkg.Name, cmd, strings.Join(args, " "))
+	if _, err = runPackageCmd(cmd, args...); err != nil {
+		return fmt.Errorf("uninstall %s: %v", pkg.Name, err)
+	}
+	return nil
+}
+
+func runPackageCmd(cmd string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, cmd, args...).CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
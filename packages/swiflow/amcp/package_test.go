@@ -0,0 +1,66 @@
+package amcp
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPackageInfo_ParseDocker(t *testing.T) {
+	cases := []struct {
+		args    []string
+		name    string
+		version string
+	}{
+		{[]string{"run", "-i", "--rm", "-e", "TOKEN", "mcp/github"}, "mcp/github", ""},
+		{[]string{"run", "-i", "ghcr.io/a/b:1.2", "serve"}, "ghcr.io/a/b", "1.2"},
+		{[]string{"run", "localhost:5000/mcp/fetch"}, "localhost:5000/mcp/fetch", ""},
+		{[]string{"run", "mcp/time@sha256:abc"}, "mcp/time", "sha256:abc"},
+	}
+	for _, c := range cases {
+		pkg := &PackageInfo{}
+		if err := pkg.ParseCommand("docker", c.args); err != nil {
+			t.Fatalf("解析失败 %v: %v", c.args, err)
+		}
+		if pkg.Manager != "docker" || pkg.Name != c.name || pkg.Version != c.version {
+			t.Errorf("解析结果错误 %v: %+v", c.args, pkg)
+		}
+	}
+}
+
+func TestPackageInfo_Rewrite(t *testing.T) {
+	pkg := &PackageInfo{}
+	args := []string{"-y", "@scope/server@1.0.0", "--port", "80"}
+	_ = pkg.ParseCommand("npx", args)
+	pkg.Version = "1.1.0"
+	got := pkg.Rewrite(args)
+	if got[1] != "@scope/server@1.1.0" || args[1] != "@scope/server@1.0.0" {
+		t.Errorf("改写版本错误: %v", got)
+	}
+
+	pkg = &PackageInfo{}
+	args = []string{"run", "-i", "mcp/github"}
+	_ = pkg.ParseCommand("/usr/local/bin/docker", args)
+	pkg.Version = "sha256:abc"
+	if got := pkg.Rewrite(args); got[2] != "mcp/github@sha256:abc" {
+		t.Errorf("改写镜像摘要错误: %v", got)
+	}
+}
+
+func TestMcpLock_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LOCK_FILE)
+	lock := LoadLockFile(path)
+	lock.Servers["fetch"] = &LockEntry{
+		Name: "mcp-server-fetch", Manager: "uvx",
+		Version: "1.0.0", Pinned: true,
+	}
+	if err := lock.Save(); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	entry := LoadLockFile(path).Get("fetch")
+	if entry == nil || !entry.Pinned || entry.Version != "1.0.0" {
+		t.Errorf("读取锁文件错误: %+v", entry)
+	}
+	if err := lock.Remove("fetch"); err != nil || LoadLockFile(path).Get("fetch") != nil {
+		t.Errorf("删除记录失败: %v", err)
+	}
+}
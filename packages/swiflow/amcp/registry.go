@@ -0,0 +1,306 @@
+package amcp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"swiflow/config"
+	"swiflow/support"
+	"time"
+)
+
+const (
+	CATALOG_NEW       = "new"
+	CATALOG_INSTALLED = "installed"
+	CATALOG_UPGRADE   = "upgrade"
+	CATALOG_CHANGED   = "changed"
+	CATALOG_REMOVED   = "removed"
+)
+
+type CatalogEntry struct {
+	Version     string `json:"version,omitempty"`
+	Description string `json:"description,omitempty"`
+	// sha256:<hex>，对 server 的 json 计算
+	Checksum string         `json:"checksum,omitempty"`
+	Server   map[string]any `json:"server"`
+}
+
+// Digest 按 json 序列化（map key 有序）计算摘要
+func (e *CatalogEntry) Digest() string {
+	data, _ := json.Marshal(e.Server)
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+type Catalog struct {
+	Version   string                   `json:"version"`
+	Entries   map[string]*CatalogEntry `json:"entries"`
+	Signature string                   `json:"signature,omitempty"`
+}
+
+// 签名内容为 version 与 entries 的 json
+func (c *Catalog) Payload() []byte {
+	data, _ := json.Marshal(map[string]any{
+		"version": c.Version, "entries": c.Entries,
+	})
+	return data
+}
+
+type CatalogDiff struct {
+	UUID    string `json:"uuid"`
+	Status  string `json:"status"`
+	Current string `json:"current,omitempty"`
+	Latest  string `json:"latest,omitempty"`
+}
+
+// ParseCatalog 解析目录，兼容旧版 {"mcpServers": {...}} 与数组格式
+func ParseCatalog(data []byte) (*Catalog, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		return parseCatalogList(trimmed)
+	}
+	raw := struct {
+		Catalog
+		McpServers map[string]map[string]any `json:"mcpServers"`
+	}{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse catalog: %w", err)
+	}
+	catalog := &raw.Catalog
+	if catalog.Entries == nil {
+		catalog.Entries = map[string]*CatalogEntry{}
+	}
+	for uuid, server := range raw.McpServers {
+		if _, ok := catalog.Entries[uuid]; !ok {
+			catalog.Entries[uuid] = &CatalogEntry{Server: server}
+		}
+	}
+	if len(catalog.Entries) == 0 {
+		return nil, fmt.Errorf("parse catalog: empty entries")
+	}
+	return catalog, nil
+}
+
+// parseCatalogList 线上 servers.json 为数组，每项以 uuid（缺省为 name）为键
+func parseCatalogList(data []byte) (*Catalog, error) {
+	list := []map[string]any{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parse catalog: %w", err)
+	}
+	catalog := &Catalog{Entries: map[string]*CatalogEntry{}}
+	for _, item := range list {
+		uuid, _ := item["uuid"].(string)
+		if uuid == "" {
+			uuid, _ = item["name"].(string)
+		}
+		if uuid == "" {
+			continue
+		}
+		entry := &CatalogEntry{Server: item}
+		entry.Version, _ = item["version"].(string)
+		entry.Description, _ = item["description"].(string)
+		catalog.Entries[uuid] = entry
+	}
+	if len(catalog.Entries) == 0 {
+		return nil, fmt.Errorf("parse catalog: empty entries")
+	}
+	return catalog, nil
+}
+
+type McpRegistry struct {
+	source string
+	pubkey ed25519.PublicKey
+	path   string
+	// MCP_REGISTRY_KEY=no 时允许未签名的目录
+	unsigned bool
+}
+
+func NewMcpRegistry() *McpRegistry {
+	r := &McpRegistry{
+		source: config.GetMcpRegistry(),
+		path:   config.GetWorkPath("servers.json"),
+	}
+	// 公钥无效时 pubkey 为空，Verify 拒绝所有目录而不是跳过校验
+	if key := config.GetRegistryKey(); key == "no" {
+		r.unsigned = true
+	} else if data, err := base64.StdEncoding.DecodeString(key); err == nil &&
+		len(data) == ed25519.PublicKeySize {
+		r.pubkey = ed25519.PublicKey(data)
+	} else {
+		log.Println("[REGISTRY] invalid MCP_REGISTRY_KEY")
+	}
+	return r
+}
+
+// Fetch 从 http(s) 镜像或本地文件读取目录
+func (r *McpRegistry) Fetch() ([]byte, error) {
+	source := r.source
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Get(source)
+		if err != nil {
+			return nil, fmt.Errorf("fetch catalog: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetch catalog: %s", resp.Status)
+		}
+		return io.ReadAll(resp.Body)
+	}
+	source = strings.TrimPrefix(source, "file://")
+	return os.ReadFile(source)
+}
+
+// Verify 校验每个条目的摘要，目录必须带有效签名；
+// 摘要与目录来自同一来源，只有签名能防篡改，MCP_REGISTRY_KEY=no 时才跳过
+func (r *McpRegistry) Verify(c *Catalog) error {
+	for uuid, entry := range c.Entries {
+		if entry.Checksum == "" {
+			continue
+		}
+		if entry.Checksum != entry.Digest() {
+			return fmt.Errorf("checksum mismatch: %s", uuid)
+		}
+	}
+	if r.unsigned {
+		return nil
+	}
+	if r.pubkey == nil {
+		return fmt.Errorf("invalid registry key")
+	}
+	if c.Signature == "" {
+		return fmt.Errorf("catalog is not signed")
+	}
+	sign, err := base64.StdEncoding.DecodeString(c.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	if !ed25519.Verify(r.pubkey, c.Payload(), sign) {
+		return fmt.Errorf("signature verify failed")
+	}
+	return nil
+}
+
+// Local 读取本地已保存的目录
+func (r *McpRegistry) Local() (*Catalog, error) {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCatalog(data)
+}
+
+// Sync 拉取并校验新目录，版本不低于本地时才覆盖；
+// 本地目录有版本时，不带版本的目录（如数组格式）视为降级
+func (r *McpRegistry) Sync() (*Catalog, error) {
+	data, err := r.Fetch()
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := ParseCatalog(data)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.Verify(catalog); err != nil {
+		return nil, err
+	}
+	if local, err := r.Local(); err == nil && local.Version != "" {
+		if catalog.Version == "" || support.IsNewVer(local.Version, catalog.Version) {
+			return local, fmt.Errorf("catalog %s older than local %s",
+				catalog.Version, local.Version)
+		}
+	}
+	if err = os.WriteFile(r.path, data, 0644); err != nil {
+		return nil, fmt.Errorf("save catalog: %w", err)
+	}
+	return catalog, nil
+}
+
+// Diff 对比目录与已安装的服务
+func (c *Catalog) Diff(installed []*McpServer) []*CatalogDiff {
+	result := []*CatalogDiff{}
+	exist := map[string]*McpServer{}
+	for _, server := range installed {
+		exist[server.UUID] = server
+	}
+	for uuid, entry := range c.Entries {
+		diff := &CatalogDiff{UUID: uuid, Latest: entry.Version}
+		server, ok := exist[uuid]
+		switch {
+		case !ok:
+			diff.Status = CATALOG_NEW
+		case entry.Version != "" && server.Version != "" &&
+			support.IsNewVer(entry.Version, server.Version):
+			diff.Status = CATALOG_UPGRADE
+		case !sameServer(server, entry):
+			diff.Status = CATALOG_CHANGED
+		default:
+			diff.Status = CATALOG_INSTALLED
+		}
+		if ok {
+			diff.Current = server.Version
+		}
+		result = append(result, diff)
+	}
+	// 曾经来自目录（带版本）但已下架的服务
+	for uuid, server := range exist {
+		if _, ok := c.Entries[uuid]; !ok && server.Version != "" {
+			result = append(result, &CatalogDiff{
+				UUID: uuid, Status: CATALOG_REMOVED,
+				Current: server.Version,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].UUID < result[j].UUID
+	})
+	return result
+}
+
+func sameServer(server *McpServer, entry *CatalogEntry) bool {
+	latest := &McpServer{UUID: server.UUID}
+	if latest.FromMap(entry.Server) != nil {
+		return false
+	}
+	return latest.Cmd == server.Cmd && latest.Url == server.Url &&
+		slices.Equal(latest.Args, server.Args)
+}
+
+// ToList 输出与旧版 servers.json 数组一致的列表，并附带版本与升级标记；
+// 已下架的服务不在列表中
+func (c *Catalog) ToList(installed []*McpServer) []map[string]any {
+	result := []map[string]any{}
+	for _, diff := range c.Diff(installed) {
+		if diff.Status == CATALOG_REMOVED {
+			continue
+		}
+		entry := c.Entries[diff.UUID]
+		item := map[string]any{}
+		for key, val := range entry.Server {
+			item[key] = val
+		}
+		item["uuid"] = diff.UUID
+		if name, _ := item["name"].(string); name == "" {
+			item["name"] = diff.UUID
+		}
+		if entry.Description != "" {
+			item["description"] = entry.Description
+		}
+		item["version"] = entry.Version
+		item["status"] = diff.Status
+		item["current"] = diff.Current
+		item["upgrade"] = diff.Status == CATALOG_UPGRADE
+		result = append(result, item)
+	}
+	return result
+}
@@ -0,0 +1,168 @@
+package amcp
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestCatalog(version string, priv ed25519.PrivateKey) []byte {
+	entry := &CatalogEntry{Version: "1.2.0", Server: map[string]any{
+		"command": "npx", "args": []any{"-y", "@modelcontextprotocol/server-memory"},
+	}}
+	entry.Checksum = entry.Digest()
+	catalog := &Catalog{
+		Version: version,
+		Entries: map[string]*CatalogEntry{"memory": entry},
+	}
+	if priv != nil {
+		sign := ed25519.Sign(priv, catalog.Payload())
+		catalog.Signature = base64.StdEncoding.EncodeToString(sign)
+	}
+	data, _ := json.Marshal(catalog)
+	return data
+}
+
+func TestMcpRegistry_Sync(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	dir := t.TempDir()
+	source := filepath.Join(dir, "catalog.json")
+	registry := &McpRegistry{
+		source: "file://" + source, pubkey: pub,
+		path: filepath.Join(dir, "servers.json"),
+	}
+
+	os.WriteFile(source, newTestCatalog("2.0.0", nil), 0644)
+	if _, err := registry.Sync(); err == nil {
+		t.Errorf("未签名目录应拒绝")
+	}
+	os.WriteFile(source, newTestCatalog("2.0.0", priv), 0644)
+	catalog, err := registry.Sync()
+	if err != nil || catalog.Version != "2.0.0" {
+		t.Fatalf("同步失败: %v", err)
+	}
+
+	// 篡改条目后摘要不一致
+	tampered := &Catalog{}
+	json.Unmarshal(newTestCatalog("2.0.1", priv), tampered)
+	tampered.Entries["memory"].Server["command"] = "curl"
+	if err := registry.Verify(tampered); err == nil {
+		t.Errorf("篡改的条目应校验失败")
+	}
+
+	// 旧版本目录不覆盖本地
+	os.WriteFile(source, newTestCatalog("1.0.0", priv), 0644)
+	if _, err := registry.Sync(); err == nil {
+		t.Errorf("旧版本目录不应覆盖本地")
+	}
+	if local, _ := registry.Local(); local == nil || local.Version != "2.0.0" {
+		t.Errorf("本地目录被覆盖: %+v", local)
+	}
+	// 不带版本的数组格式视为降级
+	registry.unsigned = true
+	os.WriteFile(source, []byte(`[{"name": "memory", "command": "npx"}]`), 0644)
+	if _, err := registry.Sync(); err == nil {
+		t.Errorf("无版本目录不应覆盖本地")
+	}
+}
+
+func TestNewMcpRegistry_Key(t *testing.T) {
+	t.Setenv("MCP_REGISTRY_KEY", "")
+	catalog := &Catalog{}
+	json.Unmarshal(newTestCatalog("1.0.0", nil), catalog)
+	if registry := NewMcpRegistry(); registry.pubkey == nil || registry.Verify(catalog) == nil {
+		t.Errorf("默认应使用内置公钥并拒绝未签名目录")
+	}
+	t.Setenv("MCP_REGISTRY_KEY", "invalid")
+	if err := NewMcpRegistry().Verify(catalog); err == nil {
+		t.Errorf("公钥无效时不应跳过校验")
+	}
+	t.Setenv("MCP_REGISTRY_KEY", "no")
+	if err := NewMcpRegistry().Verify(catalog); err != nil {
+		t.Errorf("MCP_REGISTRY_KEY=no 时应允许未签名目录: %v", err)
+	}
+}
+
+func TestCatalog_Diff(t *testing.T) {
+	catalog, err := ParseCatalog(newTestCatalog("2.0.0", nil))
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	catalog.Entries["git"] = &CatalogEntry{Server: map[string]any{"command": "uvx"}}
+	installed := []*McpServer{
+		{UUID: "memory", Cmd: "npx", Version: "1.0.0"},
+		{UUID: "legacy", Cmd: "npx", Version: "0.1.0"},
+	}
+	status := map[string]string{}
+	for _, diff := range catalog.Diff(installed) {
+		status[diff.UUID] = diff.Status
+	}
+	expect := map[string]string{
+		"memory": CATALOG_UPGRADE, "git": CATALOG_NEW, "legacy": CATALOG_REMOVED,
+	}
+	for uuid, want := range expect {
+		if status[uuid] != want {
+			t.Errorf("%s 期望%s，实际%s", uuid, want, status[uuid])
+		}
+	}
+
+	legacy, err := ParseCatalog([]byte(`{"mcpServers": {"memory": {"command": "npx"}}}`))
+	if err != nil || legacy.Entries["memory"] == nil {
+		t.Errorf("旧版格式解析失败: %v", err)
+	}
+}
+
+func TestParseCatalog_List(t *testing.T) {
+	// 线上 servers.json 的数组格式
+	data := []byte(`[
+  {
+    "name": "memory",
+    "description": "Knowledge graph-based persistent memory system",
+    "command": "npx",
+    "args": ["-y", "@modelcontextprotocol/server-memory"],
+    "tags": ["memory"],
+    "homepage": "https://github.com/modelcontextprotocol/servers"
+  },
+  {
+    "name": "mcp-midscene",
+    "uuid": "midscene",
+    "command": "npx",
+    "args": ["-y", "@midscene/mcp"],
+    "env": {"OPENAI_API_KEY": "{{API_KEY@string::Your LLM Api Access Key}}"},
+    "tags": ["browser"]
+  }
+]`)
+	catalog, err := ParseCatalog(data)
+	if err != nil {
+		t.Fatalf("数组格式解析失败: %v", err)
+	}
+	memory := catalog.Entries["memory"]
+	if memory == nil || memory.Description == "" || memory.Server["command"] != "npx" {
+		t.Fatalf("条目解析错误: %+v", memory)
+	}
+	if catalog.Entries["midscene"] == nil {
+		t.Errorf("应优先以 uuid 为键: %v", catalog.Entries)
+	}
+
+	installed := []*McpServer{{UUID: "memory", Cmd: "npx",
+		Args: []string{"-y", "@modelcontextprotocol/server-memory"}}}
+	list := catalog.ToList(installed)
+	if len(list) != 2 {
+		t.Fatalf("列表长度错误: %d", len(list))
+	}
+	for _, item := range list {
+		if item["name"] == "" || item["command"] == nil || item["tags"] == nil {
+			t.Errorf("列表项缺少市场页字段: %v", item)
+		}
+		if item["uuid"] == "memory" && item["status"] != CATALOG_INSTALLED {
+			t.Errorf("memory 应为已安装: %v", item["status"])
+		}
+	}
+
+	if _, err := ParseCatalog([]byte(`[]`)); err == nil {
+		t.Errorf("空数组应返回错误")
+	}
+}
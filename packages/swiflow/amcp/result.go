@@ -0,0 +1,148 @@
+package amcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"swiflow/support"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// 工具返回的二进制内容保存到任务目录下
+const OUTPUT_DIR = "mcp-output"
+
+type McpContent struct {
+	// text, image, audio, resource, resource_link
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	URI      string `json:"uri,omitempty"`
+	Name     string `json:"name,omitempty"`
+	MIMEType string `json:"mimeType,omitempty"`
+	// 保存后相对任务目录的路径
+	Path string `json:"path,omitempty"`
+	Data []byte `json:"-"`
+}
+
+type McpResult struct {
+	Content    []*McpContent `json:"content"`
+	Structured any           `json:"structuredContent,omitempty"`
+	IsError    bool          `json:"isError,omitempty"`
+	// structuredContent 未通过 outputSchema 校验
+	SchemaErr error `json:"-"`
+}
+
+// Save 把图片、音频、二进制资源写入 home/mcp-output
+func (r *McpResult) Save(home, prefix string) error {
+	if home == "" {
+		return nil
+	}
+	for i, item := range r.Content {
+		if len(item.Data) == 0 || item.Path != "" {
+			continue
+		}
+		dir := filepath.Join(home, OUTPUT_DIR)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("create output dir: %w", err)
+		}
+		name := item.fileName(prefix, i)
+		if err := os.WriteFile(filepath.Join(dir, name), item.Data, 0644); err != nil {
+			return fmt.Errorf("save %s: %w", name, err)
+		}
+		item.Path = path.Join(OUTPUT_DIR, name)
+	}
+	return nil
+}
+
+func (c *McpContent) fileName(prefix string, idx int) string {
+	ext := ""
+	if c.URI != "" {
+		base := path.Base(strings.SplitN(c.URI, "?", 2)[0])
+		if base != "." && base != "/" && path.Ext(base) != "" {
+			return fmt.Sprintf("%s-%d-%s", prefix, idx, base)
+		}
+	}
+	if exts, _ := mime.ExtensionsByType(c.MIMEType); len(exts) > 0 {
+		ext = exts[len(exts)-1]
+	}
+	uniq, _ := support.UniqueID(6)
+	return fmt.Sprintf("%s-%d-%s%s", prefix, idx, uniq, ext)
+}
+
+// Validate 按 outputSchema 校验 structuredContent
+func (r *McpResult) Validate(schema *jsonschema.Schema) error {
+	if schema == nil {
+		return nil
+	}
+	if r.Structured == nil {
+		r.SchemaErr = fmt.Errorf("missing structuredContent")
+		return r.SchemaErr
+	}
+	resolved, err := schema.Resolve(nil)
+	if err != nil {
+		return fmt.Errorf("resolve output schema: %w", err)
+	}
+	// 统一转为 json 原始类型再校验
+	var value any
+	data, _ := json.Marshal(r.Structured)
+	if err = json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	if err = resolved.Validate(value); err != nil {
+		r.SchemaErr = err
+	}
+	return r.SchemaErr
+}
+
+// String 渲染给模型阅读，二进制内容以文件引用给出
+func (r *McpResult) String() string {
+	var text strings.Builder
+	if r.SchemaErr != nil {
+		text.WriteString(fmt.Sprintf(
+			"warning: structuredContent does not match outputSchema: %v\n",
+			r.SchemaErr,
+		))
+	}
+	for _, item := range r.Content {
+		if text.Len() > 0 {
+			text.WriteString("\n")
+		}
+		switch {
+		case item.Type == "text":
+			text.WriteString(item.Text)
+		case item.Type == "resource_link":
+			text.WriteString(fmt.Sprintf("[resource link] %s %s", item.Name, item.URI))
+		case item.Path != "":
+			text.WriteString(fmt.Sprintf("[%s saved] %s (%s)", item.Type, item.Path, item.MIMEType))
+		case item.Text != "":
+			text.WriteString(fmt.Sprintf("[resource] %s\n%s", item.URI, item.Text))
+		default:
+			text.WriteString(fmt.Sprintf(
+				"[%s] %s %d bytes, not saved", item.Type,
+				support.Or(item.MIMEType, "unknown"), len(item.Data),
+			))
+		}
+	}
+	// 没有文本时才输出结构化内容，避免重复
+	if r.Structured != nil && !r.hasText() {
+		if text.Len() > 0 {
+			text.WriteString("\n")
+		}
+		data, _ := json.MarshalIndent(r.Structured, "", "  ")
+		text.WriteString("```json\n" + string(data) + "\n```")
+	}
+	return text.String()
+}
+
+func (r *McpResult) hasText() bool {
+	for _, item := range r.Content {
+		if item.Type == "text" && item.Text != "" {
+			return true
+		}
+	}
+	return false
+}
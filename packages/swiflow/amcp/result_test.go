@@ -0,0 +1,54 @@
+package amcp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMcpResult_Save(t *testing.T) {
+	home := t.TempDir()
+	result := &McpResult{Content: []*McpContent{
+		{Type: "text", Text: "截图如下"},
+		{Type: "image", MIMEType: "image/png", Data: []byte("png")},
+	}}
+	if err := result.Save(home, "screenshot"); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	saved := result.Content[1].Path
+	if !strings.HasPrefix(saved, OUTPUT_DIR+"/screenshot-1-") || !strings.HasSuffix(saved, ".png") {
+		t.Errorf("文件路径不符: %s", saved)
+	}
+	if data, err := os.ReadFile(filepath.Join(home, saved)); err != nil || string(data) != "png" {
+		t.Errorf("文件内容不符: %s %v", data, err)
+	}
+	if text := result.String(); !strings.Contains(text, saved) {
+		t.Errorf("渲染结果缺少文件引用: %s", text)
+	}
+}
+
+func TestMcpResult_Validate(t *testing.T) {
+	schema, _ := MapToSchema(map[string]any{
+		"type":     "object",
+		"required": []string{"count"},
+		"properties": map[string]any{
+			"count": map[string]any{"type": "integer"},
+		},
+	})
+	valid := &McpResult{Structured: map[string]any{"count": 3}}
+	if err := valid.Validate(schema); err != nil {
+		t.Errorf("期望校验通过: %v", err)
+	}
+	if text := valid.String(); !strings.Contains(text, `"count": 3`) {
+		t.Errorf("无文本时应输出结构化内容: %s", text)
+	}
+
+	invalid := &McpResult{Structured: map[string]any{"count": "3"}}
+	if err := invalid.Validate(schema); err == nil {
+		t.Errorf("期望校验失败")
+	}
+	if text := invalid.String(); !strings.HasPrefix(text, "warning:") {
+		t.Errorf("校验失败应提示模型: %s", text)
+	}
+}
@@ -27,3 +27,22 @@ func ToJsonSchema(s string) (*jsonschema.Schema, error) {
 	}
 	return schema, nil
 }
+
+// AnyToSchema 将 sdk 中任意形式的 schema 转为 *jsonschema.Schema
+func AnyToSchema(v any) *jsonschema.Schema {
+	switch s := v.(type) {
+	case nil:
+		return nil
+	case *jsonschema.Schema:
+		return s
+	}
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return nil
+	}
+	schema := new(jsonschema.Schema)
+	if err := schema.UnmarshalJSON(data); err != nil {
+		return nil
+	}
+	return schema
+}
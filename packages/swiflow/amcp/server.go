@@ -1,15 +1,20 @@
 package amcp
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"swiflow/ability"
 	"swiflow/config"
 	"swiflow/entity"
+	"swiflow/secret"
 	"swiflow/support"
+	"time"
 
 	"github.com/duke-git/lancet/v2/slice"
 	"github.com/duke-git/lancet/v2/structs"
@@ -107,10 +112,26 @@ type McpServer struct {
 	Cmd  string   `json:"command,omitempty"`
 	Url  string   `json:"url,omitempty"`
 	Args []string `json:"args,omitempty"`
+	// 从目录安装时记录的版本
+	Version string `json:"version,omitempty"`
+	// 远程服务鉴权方式，目前支持 oauth
+	Auth string `json:"auth,omitempty"`
+
+	// 单位秒，为 0 时使用全局 CONNECT_TIMEOUT/EXECUTE_TIMEOUT
+	ConnectTimeout int `json:"connectTimeout,omitempty"`
+	ExecuteTimeout int `json:"executeTimeout,omitempty"`
+	// 每分钟最多调用次数与最大并发，为 0 时不限制
+	RateLimit     int `json:"rateLimit,omitempty"`
+	MaxConcurrent int `json:"maxConcurrent,omitempty"`
 
 	Env map[string]string `json:"env,omitempty"`
+	// 远程服务额外的请求头
+	Headers map[string]string `json:"headers,omitempty"`
 
 	Status McpStatus `json:"status,omitempty"`
+
+	// 隔离实例绑定的任务目录，替代 $CURRENT_HOME
+	home string
 }
 
 func (s *McpServer) FromCfg(v any) error {
@@ -157,6 +178,18 @@ func (s *McpServer) FromMap(v any) error {
 			s.Type, _ = val.(string)
 		case "url":
 			s.Url, _ = val.(string)
+		case "auth":
+			s.Auth, _ = val.(string)
+		case "version":
+			s.Version, _ = val.(string)
+		case "connectTimeout", "connect_timeout":
+			s.ConnectTimeout = toSeconds(val)
+		case "executeTimeout", "execute_timeout", "timeout":
+			s.ExecuteTimeout = toSeconds(val)
+		case "rateLimit", "rate_limit":
+			s.RateLimit = toSeconds(val)
+		case "maxConcurrent", "max_concurrent":
+			s.MaxConcurrent = toSeconds(val)
 		case "env":
 			if val, ok := val.(map[string]any); ok {
 				s.Env = map[string]string{}
@@ -164,6 +197,13 @@ func (s *McpServer) FromMap(v any) error {
 					s.Env[k], _ = v.(string)
 				}
 			}
+		case "headers":
+			if val, ok := val.(map[string]any); ok {
+				s.Headers = map[string]string{}
+				for k, v := range val {
+					s.Headers[k], _ = v.(string)
+				}
+			}
 		case "args":
 			if val, ok := val.([]any); ok {
 				s.Args = []string{}
@@ -179,17 +219,46 @@ func (s *McpServer) FromMap(v any) error {
 	return nil
 }
 
+func (s *McpServer) GetConnectTimeout() time.Duration {
+	if s.ConnectTimeout > 0 {
+		return time.Duration(s.ConnectTimeout) * time.Second
+	}
+	return time.Duration(CONNECT_TIMEOUT) * time.Second
+}
+
+func (s *McpServer) GetExecuteTimeout() time.Duration {
+	if s.ExecuteTimeout > 0 {
+		return time.Duration(s.ExecuteTimeout) * time.Second
+	}
+	return time.Duration(EXECUTE_TIMEOUT) * time.Second
+}
+
+// 远程服务的 http client，附带静态 header 与 oauth token
+func (s *McpServer) HttpClient() (*http.Client, error) {
+	client := support.NewHttpClient(s.GetHeaders())
+	if s.Auth == "oauth" {
+		// 授权需等待用户操作，不受连接超时限制
+		auth := s.OAuth()
+		if _, err := auth.Token(context.Background()); err != nil {
+			return nil, fmt.Errorf("oauth: %w", err)
+		}
+		client.Transport = auth.Transport(client.Transport)
+	}
+	return client, nil
+}
+
 func (s *McpServer) GetEnv() []string {
 	if len(s.Env) == 0 {
 		return nil
 	}
 	result := make([]string, 0)
 	for key, val := range s.Env {
+		val = resolveVal(key, val)
 		if val == "$SWIFLOW_HOME" {
 			val = config.GetWorkHome()
 		}
 		if val == "$CURRENT_HOME" {
-			val = config.CurrentHome()
+			val = support.Or(s.home, config.CurrentHome())
 		}
 		env := fmt.Sprintf("%s=%v", key, val)
 		result = append(result, env)
@@ -247,6 +316,15 @@ func (s *McpServer) Checked(bot *entity.BotEntity) []*McpTool {
 	return result
 }
 
+func (s *McpServer) FindTool(name string) *McpTool {
+	for _, tool := range s.Status.McpTools {
+		if tool.Name == name {
+			return tool
+		}
+	}
+	return nil
+}
+
 func (s *McpServer) Preload() error {
 	if s.Cmd == "" {
 		return nil // No command to preload
@@ -259,11 +337,25 @@ func (s *McpServer) Preload() error {
 		return nil
 	}
 
+	lock := LoadLock()
+	entry := lock.Get(s.UUID)
+	if entry != nil && entry.Pinned && pkg.Version == "" {
+		pkg.Version = entry.Version
+	}
+
 	log.Printf("[MCP] Install %s for %s", pkg.Name, s.Name)
 	if installed, err := pkg.IsInstalled(); err != nil {
 		log.Printf("[MCP] Check package %s is failed: %v", pkg.Name, err)
 		return err
 	} else if installed {
+		if pkg.Manager == "uv" {
+			return nil
+		}
+		if entry == nil {
+			_, _ = lock.Record(s.UUID, pkg)
+		} else if entry.Pinned || entry.Digest != "" {
+			return entry.Check(pkg)
+		}
 		return nil
 	}
 
@@ -274,10 +366,109 @@ func (s *McpServer) Preload() error {
 	return nil
 }
 
+// Package 解析命令对应的 uvx/npx/docker 包
+func (s *McpServer) Package() (*PackageInfo, error) {
+	var pkg = &PackageInfo{}
+	if err := pkg.ParseCommand(s.Cmd, s.Args); err != nil {
+		return nil, err
+	}
+	if pkg.Name == "" || pkg.Manager == "uv" {
+		return nil, fmt.Errorf("no package found: %s", s.UUID)
+	}
+	return pkg, nil
+}
+
+// Upgrade 升级到指定版本，为空时升级到最新；固定版本的服务必须显式给出版本
+// 命令参数里写明了版本号时会改写为新版本，调用方需保存服务配置
+func (s *McpServer) Upgrade(version string) (*LockEntry, error) {
+	pkg, err := s.Package()
+	if err != nil {
+		return nil, err
+	}
+	lock := LoadLock()
+	entry := lock.Get(s.UUID)
+	if entry != nil && entry.Pinned && version == "" {
+		return nil, fmt.Errorf("%s is pinned to %s", s.UUID, entry.Version)
+	}
+	versioned := pkg.Version != ""
+	pkg.Version = version
+	if err = pkg.Upgrade(); err != nil {
+		return nil, err
+	}
+	if entry, err = lock.Record(s.UUID, pkg); err != nil {
+		return nil, err
+	}
+	// 固定版本的服务升级后固定在新版本
+	if entry.Pinned || versioned {
+		pkg.Version = entry.Version
+		s.Args = pkg.Rewrite(s.Args)
+	}
+	return entry, nil
+}
+
+// Pin 固定版本，为空时固定为当前安装的版本
+// 指定的版本与已安装的不同时先安装该版本，否则下次启动校验会失败
+func (s *McpServer) Pin(version string) (*LockEntry, error) {
+	pkg, err := s.Package()
+	if err != nil {
+		return nil, err
+	}
+	lock := LoadLock()
+	entry := lock.Get(s.UUID)
+	if entry == nil || (version != "" && entry.Version != version) {
+		if version != "" {
+			pkg.Version = version
+			if err = pkg.Upgrade(); err != nil {
+				return nil, err
+			}
+		}
+		if entry, err = lock.Record(s.UUID, pkg); err != nil {
+			return nil, err
+		}
+	}
+	if version != "" && entry.Version != version {
+		return nil, fmt.Errorf("%s: installed %s, want %s",
+			pkg.Name, entry.Version, version)
+	}
+	entry.Pinned = true
+	entry.UpdatedAt = time.Now().Unix()
+
+	pkg.Version = entry.Version
+	s.Args = pkg.Rewrite(s.Args)
+	return entry, lock.Save()
+}
+
+// Unpin 取消固定，命令参数中的版本号保留到下次 upgrade
+func (s *McpServer) Unpin() error {
+	lock := LoadLock()
+	if entry := lock.Get(s.UUID); entry != nil {
+		entry.Pinned = false
+		return lock.Save()
+	}
+	return nil
+}
+
+// Uninstall 卸载服务使用的包并移除锁定记录
+func (s *McpServer) Uninstall() error {
+	pkg, err := s.Package()
+	if err != nil {
+		return err
+	}
+	lock := LoadLock()
+	if entry := lock.Get(s.UUID); entry != nil && pkg.Version == "" {
+		pkg.Version = entry.Version
+	}
+	if err = pkg.Uninstall(); err != nil {
+		return err
+	}
+	return lock.Remove(s.UUID)
+}
+
 func (s *McpServer) GetHeaders() map[string]string {
 	headers := map[string]string{}
 	token := ""
 	for key, val := range s.Env {
+		val = resolveVal(key, val)
 		switch strings.ToUpper(key) {
 		case "API_TOKEN", "API_KEY",
 			"TOKEN", "BEARER_TOKEN",
@@ -294,9 +485,76 @@ func (s *McpServer) GetHeaders() map[string]string {
 		headers["Authorization"] = fmt.Sprintf("Bearer %s", token)
 		headers["X-API-KEY"] = token
 	}
+	for key, val := range s.Headers {
+		headers[key] = resolveVal(key, val)
+	}
 	return headers
 }
 
+// Redacted 返回敏感 env 与 header 替换为掩码的副本，用于接口返回
+func (s *McpServer) Redacted() *McpServer {
+	server := *s
+	server.Env = redactMap(s.Env)
+	server.Headers = redactMap(s.Headers)
+	return &server
+}
+
+// Restore 将仍为掩码的 env 与 header 还原为已保存的值
+func (s *McpServer) Restore(old *McpServer) {
+	restoreMap(s.Env, old.Env)
+	restoreMap(s.Headers, old.Headers)
+}
+
+// 解析 secret://NAME 引用，失败时记录日志并置空
+func resolveVal(key, val string) string {
+	value, err := secret.Resolve(val)
+	if err != nil {
+		log.Printf("[MCP] resolve %s: %v", key, err)
+	}
+	return value
+}
+
+func redactMap(data map[string]string) map[string]string {
+	if data == nil {
+		return nil
+	}
+	result := make(map[string]string, len(data))
+	for key, val := range data {
+		if secret.IsSecret(key) {
+			val = secret.Mask(val)
+		}
+		result[key] = val
+	}
+	return result
+}
+
+func restoreMap(data, old map[string]string) {
+	for key, val := range data {
+		if val != secret.MASK {
+			continue
+		}
+		if prev, ok := old[key]; ok {
+			data[key] = prev
+		} else {
+			delete(data, key)
+		}
+	}
+}
+
+// 兼容 json 数字与字符串形式的秒数
+func toSeconds(val any) int {
+	switch v := val.(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case string:
+		n, _ := strconv.Atoi(strings.TrimSpace(v))
+		return n
+	}
+	return 0
+}
+
 func (s *McpServer) GetCmd() (*exec.Cmd, error) {
 	cmdPath, err := config.GetMcpEnv(s.Cmd)
 	if err != nil {
@@ -38,11 +38,8 @@ func (m *McpService) GetMcpClient(server *McpServer) *McpClient {
 }
 
 func (m *McpService) ServerClose(server *McpServer) error {
-	client := NewMcpClient(server)
-	if client == nil {
-		return nil
-	}
-	return client.Close()
+	CloseInstances(server.UUID)
+	return nil
 }
 
 func (m *McpService) ServerStatus(server *McpServer) error {
@@ -249,3 +246,35 @@ func (m *McpService) LoadMcpServer(mcps map[string]any) {
 		}
 	}
 }
+
+// ImportServers 导入外部客户端配置，已存在的 uuid 记为冲突，overwrite 时覆盖
+func (m *McpService) ImportServers(data []byte, overwrite bool) (*ImportReport, error) {
+	servers, err := ParseImport(data)
+	if err != nil {
+		return nil, err
+	}
+	exist := map[string]*McpServer{}
+	for _, s := range m.ListServers() {
+		exist[s.UUID] = s
+	}
+	report := &ImportReport{
+		Imported: []string{}, Conflicts: []string{}, Errors: []string{},
+	}
+	for _, server := range servers {
+		if _, ok := exist[server.UUID]; ok {
+			report.Conflicts = append(report.Conflicts, server.UUID)
+			if !overwrite {
+				continue
+			}
+			// 只关闭已启动的实例，避免为覆盖而启动旧服务
+			CloseInstances(server.UUID)
+		}
+		if err := m.UpsertServer(server); err != nil {
+			msg := fmt.Sprintf("%s: %v", server.UUID, err)
+			report.Errors = append(report.Errors, msg)
+			continue
+		}
+		report.Imported = append(report.Imported, server.UUID)
+	}
+	return report, nil
+}
@@ -0,0 +1,114 @@
+package amcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+var fencedJson = regexp.MustCompile("(?s)^```[a-zA-Z]*\\s*\n?(.*?)\\s*```$")
+
+// PrepareArgs 解析模型给出的参数，并按工具的 inputSchema 校验
+func (a *McpClient) PrepareArgs(toolName string, raw string) (map[string]any, error) {
+	tool := a.server.FindTool(toolName)
+	if tool == nil {
+		return ParseArgs(raw, nil)
+	}
+	return ParseArgs(raw, tool.InputSchema)
+}
+
+// ParseArgs 宽松解析参数：去除代码块包裹、字符串形式的数字/布尔/JSON 自动转换
+func ParseArgs(raw string, schema *jsonschema.Schema) (map[string]any, error) {
+	raw = strings.TrimSpace(raw)
+	if match := fencedJson.FindStringSubmatch(raw); match != nil {
+		raw = strings.TrimSpace(match[1])
+	}
+	args := map[string]any{}
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &args); err != nil {
+			return nil, fmt.Errorf("args must be a JSON object: %v", err)
+		}
+	}
+	if schema == nil {
+		return args, nil
+	}
+	if value, ok := coerceValue(args, schema).(map[string]any); ok {
+		args = value
+	}
+	resolved, err := schema.Resolve(nil)
+	if err != nil {
+		// schema 本身有误时不阻断调用，交由服务端判断
+		return args, nil
+	}
+	if err = resolved.Validate(args); err != nil {
+		return args, fmt.Errorf("invalid args: %v", err)
+	}
+	return args, nil
+}
+
+func schemaTypes(schema *jsonschema.Schema) []string {
+	if schema.Type != "" {
+		return []string{schema.Type}
+	}
+	return schema.Types
+}
+
+func coerceValue(value any, schema *jsonschema.Schema) any {
+	if schema == nil || value == nil {
+		return value
+	}
+	if str, ok := value.(string); ok {
+		value = coerceString(str, schemaTypes(schema))
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		for key, prop := range schema.Properties {
+			if item, ok := v[key]; ok {
+				v[key] = coerceValue(item, prop)
+			}
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = coerceValue(item, schema.Items)
+		}
+		return v
+	}
+	return value
+}
+
+func coerceString(str string, types []string) any {
+	text := strings.TrimSpace(str)
+	for _, kind := range types {
+		switch kind {
+		case "string":
+			return str
+		case "integer":
+			// 与 json 解码保持一致，数字统一为 float64
+			if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+				return float64(n)
+			}
+		case "number":
+			if n, err := strconv.ParseFloat(text, 64); err == nil {
+				return n
+			}
+		case "boolean":
+			if b, err := strconv.ParseBool(text); err == nil {
+				return b
+			}
+		case "object", "array":
+			if match := fencedJson.FindStringSubmatch(text); match != nil {
+				text = strings.TrimSpace(match[1])
+			}
+			var parsed any
+			if json.Unmarshal([]byte(text), &parsed) == nil {
+				return parsed
+			}
+		}
+	}
+	return str
+}
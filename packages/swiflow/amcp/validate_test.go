@@ -0,0 +1,46 @@
+package amcp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	schema, _ := MapToSchema(map[string]any{
+		"type":     "object",
+		"required": []string{"path", "limit"},
+		"properties": map[string]any{
+			"path":    map[string]any{"type": "string"},
+			"limit":   map[string]any{"type": "integer"},
+			"recurse": map[string]any{"type": "boolean"},
+			"filter":  map[string]any{"type": "object"},
+		},
+	})
+
+	raw := "```json\n" + `{"path": "/tmp", "limit": "10", "recurse": "true", "filter": "{\"ext\": \"go\"}"}` + "\n```"
+	args, err := ParseArgs(raw, schema)
+	if err != nil {
+		t.Fatalf("期望宽松解析成功: %v", err)
+	}
+	if args["limit"] != float64(10) || args["recurse"] != true {
+		t.Errorf("字符串未转换: %+v", args)
+	}
+	if filter, ok := args["filter"].(map[string]any); !ok || filter["ext"] != "go" {
+		t.Errorf("JSON字符串未转换: %+v", args["filter"])
+	}
+
+	_, err = ParseArgs(`{"path": "/tmp", "limit": "ten"}`, schema)
+	if err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Errorf("期望返回limit校验错误: %v", err)
+	}
+	_, err = ParseArgs(`{"path": "/tmp"}`, schema)
+	if err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Errorf("期望返回缺少limit错误: %v", err)
+	}
+	if _, err = ParseArgs(`path=/tmp`, schema); err == nil {
+		t.Errorf("期望返回JSON格式错误")
+	}
+	if args, err = ParseArgs("", nil); err != nil || len(args) != 0 {
+		t.Errorf("空参数应返回空对象: %v %v", args, err)
+	}
+}
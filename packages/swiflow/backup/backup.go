@@ -0,0 +1,273 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"swiflow/config"
+	"swiflow/entity"
+	"swiflow/secret"
+	"swiflow/storage"
+	"time"
+)
+
+// 归档格式版本，结构不兼容时递增
+const FORMAT = 1
+
+const (
+	MANIFEST = "manifest.json"
+	DB_DIR   = "db/"
+	FILE_DIR = "files/"
+)
+
+// 不含工作区时也需备份的工作目录文件
+var baseFiles = []string{"mcp-lock.json", "agents"}
+
+type Manifest struct {
+	Format    int            `json:"format"`
+	Time      string         `json:"time"`
+	Dialect   string         `json:"dialect"`
+	Schema    int            `json:"schema"`
+	Home      string         `json:"home"`
+	Workspace bool           `json:"workspace"`
+	Tables    map[string]int `json:"tables"`
+	Files     int            `json:"files"`
+	Secret    *SecretInfo    `json:"secret,omitempty"`
+}
+
+// SecretInfo 备份中密文使用的密钥，Salt 非空时由备份口令派生
+type SecretInfo struct {
+	Fingerprint string `json:"fingerprint"`
+	Salt        string `json:"salt,omitempty"`
+}
+
+type Options struct {
+	// 同时备份工作目录下的任务与 bot 工作区
+	Workspace bool
+	// 设置后密文改用口令派生的密钥加密，可在其他机器恢复
+	Passphrase string
+}
+
+// Create 将数据库与工作目录打包为 tar.gz 写入 w
+func Create(store storage.MyStore, w io.Writer, opts Options) (*Manifest, error) {
+	b, ok := store.(storage.Backupable)
+	if !ok {
+		return nil, fmt.Errorf("storage not support backup")
+	}
+	manifest := &Manifest{
+		Format: FORMAT, Time: time.Now().Format(time.RFC3339),
+		Dialect: b.Backup().Dialect(), Home: config.GetWorkHome(),
+		Workspace: opts.Workspace, Tables: map[string]int{},
+	}
+	if m, ok := store.(storage.Migratable); ok {
+		current, err := m.Migrator().Current()
+		if err != nil {
+			return nil, err
+		}
+		manifest.Schema = current
+	}
+
+	var key []byte
+	if opts.Passphrase != "" {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		derived, err := secret.Derive(opts.Passphrase, salt)
+		if err != nil {
+			return nil, err
+		}
+		key = derived
+		manifest.Secret = &SecretInfo{
+			Salt: base64.StdEncoding.EncodeToString(salt),
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "swiflow-backup")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	sealed, files := false, map[string]*os.File{}
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	err = b.Backup().Dump(func(table string, row map[string]any) error {
+		if data, ok := row["data"].(map[string]any); ok && table == cfgTable && key != nil {
+			rekeyed, err := secret.Rekey(data, nil, key)
+			if err != nil {
+				return fmt.Errorf("rekey %s: %w", table, err)
+			}
+			row["data"] = rekeyed
+		}
+		line, err := json.Marshal(row)
+		if err != nil {
+			return fmt.Errorf("encode %s: %w", table, err)
+		}
+		if bytes.Contains(line, []byte(secret.PREFIX)) {
+			sealed = true
+		}
+		file := files[table]
+		if file == nil {
+			path := filepath.Join(dir, table+".jsonl")
+			if file, err = os.Create(path); err != nil {
+				return err
+			}
+			files[table] = file
+		}
+		manifest.Tables[table]++
+		_, err = file.Write(append(line, '\n'))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Secret != nil {
+		manifest.Secret.Fingerprint, _ = secret.Fingerprint(key)
+	} else if sealed {
+		fingerprint, err := secret.Fingerprint(nil)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Secret = &SecretInfo{Fingerprint: fingerprint}
+	}
+
+	entries, err := workFiles(manifest.Home, opts.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	manifest.Files = len(entries)
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	data, _ := json.MarshalIndent(manifest, "", "  ")
+	if err = writeBytes(tw, MANIFEST, data); err != nil {
+		return nil, err
+	}
+	for _, table := range b.Backup().Tables() {
+		if files[table] == nil {
+			continue
+		}
+		name := DB_DIR + table + ".jsonl"
+		if err = writeFile(tw, name, files[table].Name()); err != nil {
+			return nil, err
+		}
+	}
+	for _, rel := range entries {
+		name := FILE_DIR + filepath.ToSlash(rel)
+		if err = writeFile(tw, name, filepath.Join(manifest.Home, rel)); err != nil {
+			return nil, err
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return nil, err
+	}
+	if err = gz.Close(); err != nil {
+		return nil, err
+	}
+	log.Printf("[BACKUP] created: %d tables, %d files", len(manifest.Tables), manifest.Files)
+	return manifest, nil
+}
+
+// workFiles 列出需备份的工作目录文件（相对路径），跳过链接等非普通文件
+func workFiles(home string, workspace bool) ([]string, error) {
+	roots := []string{"."}
+	if !workspace {
+		roots = baseFiles
+	}
+	result := []string{}
+	for _, root := range roots {
+		start := filepath.Join(home, root)
+		if _, err := os.Stat(start); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.WalkDir(start, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(home, path)
+			if err == nil {
+				result = append(result, rel)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("walk %s: %w", root, err)
+		}
+	}
+	return result, nil
+}
+
+func writeBytes(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{
+		Name: name, Mode: 0644, Size: int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func writeFile(tw *tar.Writer, name, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{
+		Name: name, Mode: int64(info.Mode().Perm()),
+		Size: info.Size(), ModTime: info.ModTime(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	// 文件在备份期间变大时只写入 Stat 时的长度
+	_, err = io.CopyN(tw, file, info.Size())
+	return err
+}
+
+// rebase 将源机器工作目录下的路径改写到当前工作目录
+func rebase(home, from, to string) string {
+	if home == "" || from == "" {
+		return home
+	}
+	norm := func(p string) string {
+		return strings.TrimSuffix(strings.ReplaceAll(p, "\\", "/"), "/")
+	}
+	src, base := norm(home), norm(from)
+	if src != base && !strings.HasPrefix(src, base+"/") {
+		return home
+	}
+	rel := strings.TrimPrefix(src, base)
+	return filepath.Join(to, filepath.FromSlash(path.Clean("/"+rel)))
+}
+
+var cfgTable = new(entity.CfgEntity).TableName()
+
+// homeTables 含 home 路径的表
+var homeTables = []string{
+	new(entity.TaskEntity).TableName(),
+	new(entity.BotEntity).TableName(),
+}
@@ -0,0 +1,189 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"swiflow/entity"
+	"swiflow/storage"
+	"testing"
+)
+
+func setupEnv(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("SECRET_PASSPHRASE", "backup-test")
+	t.Setenv("SECRET_KEYRING", "no")
+}
+
+func newStore(t *testing.T) storage.MyStore {
+	path := filepath.Join(t.TempDir(), "test.db")
+	store, err := storage.NewStorage("sqlite", map[string]any{"path": path})
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	if err = store.AutoMigrate(); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	return store
+}
+
+// createBackup 在独立工作目录中准备数据并生成备份文件
+func createBackup(t *testing.T, opts Options) (string, string) {
+	home := t.TempDir()
+	t.Setenv("SWIFLOW_HOME", home)
+	store := newStore(t)
+	store.SaveTask(&storage.TaskEntity{
+		UUID: "task-1", Name: "demo",
+		Home: filepath.Join(home, "task-1"),
+	})
+	store.SaveCfg(&storage.CfgEntity{
+		Type: entity.KEY_PROVIDER, Name: "openai",
+		Data: map[string]any{"provider": "openai", "apiKey": "sk-backup"},
+	})
+	os.WriteFile(filepath.Join(home, "mcp-lock.json"), []byte("{}"), 0644)
+	os.MkdirAll(filepath.Join(home, "task-1"), 0755)
+	os.WriteFile(filepath.Join(home, "task-1", "note.md"), []byte("hi"), 0644)
+
+	file := filepath.Join(t.TempDir(), "backup.tar.gz")
+	out, err := os.Create(file)
+	if err != nil {
+		t.Fatalf("创建文件失败: %v", err)
+	}
+	defer out.Close()
+	manifest, err := Create(store, out, opts)
+	if err != nil {
+		t.Fatalf("备份失败: %v", err)
+	}
+	if manifest.Tables["llm_task"] != 1 || manifest.Secret == nil {
+		t.Fatalf("备份清单错误: %+v", manifest)
+	}
+	return file, home
+}
+
+func TestBackupRestore(t *testing.T) {
+	setupEnv(t)
+	file, _ := createBackup(t, Options{Workspace: true})
+
+	home := t.TempDir()
+	t.Setenv("SWIFLOW_HOME", home)
+	store := newStore(t)
+
+	report, err := Restore(store, file, RestoreOptions{DryRun: true})
+	if err != nil || !report.DryRun || report.Files.Insert != 2 {
+		t.Fatalf("dry-run 报告错误: %+v, %v", report, err)
+	}
+	if list, _ := store.LoadTask(); len(list) != 0 {
+		t.Fatalf("dry-run 不应写入数据库: %d", len(list))
+	}
+	if _, err = os.Stat(filepath.Join(home, "mcp-lock.json")); err == nil {
+		t.Fatalf("dry-run 不应写入文件")
+	}
+
+	if _, err = Restore(store, file, RestoreOptions{}); err != nil {
+		t.Fatalf("恢复失败: %v", err)
+	}
+	task := &storage.TaskEntity{UUID: "task-1"}
+	if err = store.FindTask(task); err != nil {
+		t.Fatalf("恢复后读取任务失败: %v", err)
+	}
+	if task.Home != filepath.Join(home, "task-1") {
+		t.Errorf("任务目录应改写到当前工作目录: %s", task.Home)
+	}
+	cfg := &storage.CfgEntity{Type: entity.KEY_PROVIDER, Name: "openai"}
+	if err = store.FindCfg(cfg); err != nil || cfg.Data["apiKey"] != "sk-backup" {
+		t.Errorf("恢复后密钥应可解密: %v, %v", cfg.Data, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(home, "task-1", "note.md")); string(data) != "hi" {
+		t.Errorf("工作区文件未恢复: %q", data)
+	}
+
+	// 再次恢复：skip 只统计冲突，fail 中止，overwrite 覆盖
+	report, err = Restore(store, file, RestoreOptions{Mode: "skip"})
+	if err != nil || report.Conflicts() == 0 {
+		t.Errorf("skip 应报告冲突: %+v, %v", report, err)
+	}
+	if _, err = Restore(store, file, RestoreOptions{Mode: "fail"}); err == nil {
+		t.Errorf("fail 模式存在冲突时应返回错误")
+	}
+	report, err = Restore(store, file, RestoreOptions{Mode: "overwrite"})
+	if err != nil || report.Files.Overwrite != 2 {
+		t.Errorf("overwrite 报告错误: %+v, %v", report, err)
+	}
+	if list, _ := store.LoadTask(); len(list) != 1 {
+		t.Errorf("覆盖后不应产生重复记录: %d", len(list))
+	}
+	if _, err = Restore(store, file, RestoreOptions{Mode: "merge"}); err == nil {
+		t.Errorf("未知模式应返回错误")
+	}
+}
+
+func TestBackupPassphrase(t *testing.T) {
+	setupEnv(t)
+	file, _ := createBackup(t, Options{Passphrase: "moving"})
+
+	t.Setenv("SWIFLOW_HOME", t.TempDir())
+	store := newStore(t)
+	if _, err := Restore(store, file, RestoreOptions{}); err == nil {
+		t.Errorf("缺少口令应返回错误")
+	}
+	_, err := Restore(store, file, RestoreOptions{Passphrase: "wrong"})
+	if err == nil || !strings.Contains(err.Error(), "passphrase") {
+		t.Errorf("口令错误应返回错误: %v", err)
+	}
+	if _, err = Restore(store, file, RestoreOptions{Passphrase: "moving"}); err != nil {
+		t.Fatalf("使用口令恢复失败: %v", err)
+	}
+	cfg := &storage.CfgEntity{Type: entity.KEY_PROVIDER, Name: "openai"}
+	if err = store.FindCfg(cfg); err != nil || cfg.Data["apiKey"] != "sk-backup" {
+		t.Errorf("口令备份恢复后密钥应可解密: %v, %v", cfg.Data, err)
+	}
+}
+
+func TestRebase(t *testing.T) {
+	cases := []struct{ home, from, want string }{
+		{"/old/home/task-1", "/old/home", filepath.Join("/new", "task-1")},
+		{"/old/home", "/old/home", filepath.Clean("/new")},
+		{"C:\\Users\\a\\.swiflow\\t1", "C:\\Users\\a\\.swiflow", filepath.Join("/new", "t1")},
+		{"/other/dir", "/old/home", "/other/dir"},
+		{"/old/homework", "/old/home", "/old/homework"},
+		{"", "/old/home", ""},
+	}
+	for _, c := range cases {
+		if got := rebase(c.home, c.from, "/new"); got != c.want {
+			t.Errorf("rebase(%q) = %q, 期望 %q", c.home, got, c.want)
+		}
+	}
+}
+
+// 同一主题的多条记忆不应与本次恢复刚插入的记录冲突
+func TestRestoreSameKey(t *testing.T) {
+	setupEnv(t)
+	t.Setenv("SWIFLOW_HOME", t.TempDir())
+	store := newStore(t)
+	for _, content := range []string{"简洁", "使用中文"} {
+		store.SaveMem(&storage.MemEntity{
+			Bot: "bot-1", Scope: entity.SCOPE_BOT,
+			Subject: "style", Content: content,
+		})
+	}
+	file := filepath.Join(t.TempDir(), "backup.tar.gz")
+	out, err := os.Create(file)
+	if err != nil {
+		t.Fatalf("创建文件失败: %v", err)
+	}
+	if _, err = Create(store, out, Options{}); err != nil {
+		t.Fatalf("备份失败: %v", err)
+	}
+	out.Close()
+
+	for _, mode := range []string{"skip", "fail"} {
+		t.Setenv("SWIFLOW_HOME", t.TempDir())
+		target := newStore(t)
+		if _, err = Restore(target, file, RestoreOptions{Mode: mode}); err != nil {
+			t.Fatalf("%s 模式恢复到空库失败: %v", mode, err)
+		}
+		if list, _ := target.LoadMem("bot = ?", "bot-1"); len(list) != 2 {
+			t.Errorf("%s 模式应恢复全部记忆: %d", mode, len(list))
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"swiflow/config"
+	"swiflow/secret"
+	"swiflow/storage"
+)
+
+type RestoreOptions struct {
+	// 冲突处理：skip、overwrite、fail，默认 skip
+	Mode string
+	// 只生成报告，不写入任何数据
+	DryRun bool
+	// 备份时设置的口令
+	Passphrase string
+}
+
+type Report struct {
+	DryRun   bool                   `json:"dryRun"`
+	Mode     string                 `json:"mode"`
+	Manifest *Manifest              `json:"manifest"`
+	Tables   []*storage.TableReport `json:"tables"`
+	Files    *FileReport            `json:"files"`
+	Warnings []string               `json:"warnings,omitempty"`
+}
+
+type FileReport struct {
+	Total     int      `json:"total"`
+	Insert    int      `json:"insert"`
+	Overwrite int      `json:"overwrite"`
+	Skip      int      `json:"skip"`
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// Conflicts 记录与文件的冲突总数
+func (r *Report) Conflicts() int {
+	count := r.Files.Skip + r.Files.Overwrite
+	for _, table := range r.Tables {
+		count += table.Skip + table.Overwrite
+	}
+	return count
+}
+
+// restoring 一次读取归档的状态
+type restoring struct {
+	opts  RestoreOptions
+	home  string
+	apply bool
+
+	key   []byte
+	strip bool
+
+	report   *Report
+	restorer *storage.Restorer
+}
+
+// Restore 从 tar.gz 归档恢复，先完整演练一遍生成报告，
+// 非 dry-run 且无需中止时再实际写入；fail 模式下存在冲突则不写入
+func Restore(store storage.MyStore, file string, opts RestoreOptions) (*Report, error) {
+	b, ok := store.(storage.Backupable)
+	if !ok {
+		return nil, fmt.Errorf("storage not support backup")
+	}
+	opts.Mode = strings.ToLower(opts.Mode)
+	if opts.Mode == "" {
+		opts.Mode = storage.CONFLICT_SKIP
+	}
+	modes := []string{storage.CONFLICT_SKIP, storage.CONFLICT_OVERWRITE, storage.CONFLICT_FAIL}
+	if !slices.Contains(modes, opts.Mode) {
+		return nil, fmt.Errorf("unknown conflict mode: %s", opts.Mode)
+	}
+
+	// 演练时 fail 按 skip 统计冲突
+	mode := opts.Mode
+	if mode == storage.CONFLICT_FAIL {
+		mode = storage.CONFLICT_SKIP
+	}
+	report, err := readArchive(store, b, file, opts, mode, false)
+	if err != nil || opts.DryRun {
+		return report, err
+	}
+	if opts.Mode == storage.CONFLICT_FAIL && report.Conflicts() > 0 {
+		return report, fmt.Errorf("restore aborted: %d conflicts", report.Conflicts())
+	}
+	return readArchive(store, b, file, opts, mode, true)
+}
+
+func readArchive(store storage.MyStore, b storage.Backupable, file string, opts RestoreOptions, mode string, apply bool) (*Report, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: %w", err)
+	}
+	defer gz.Close()
+
+	r := &restoring{
+		opts: opts, home: config.GetWorkHome(), apply: apply,
+		report: &Report{
+			DryRun: !apply, Mode: opts.Mode, Files: &FileReport{},
+		},
+	}
+	tr := tar.NewReader(gz)
+	header, err := tr.Next()
+	if err != nil || header.Name != MANIFEST {
+		return nil, fmt.Errorf("invalid archive: missing %s", MANIFEST)
+	}
+	if err = r.readManifest(store, tr); err != nil {
+		return nil, err
+	}
+	if r.restorer, err = b.Backup().Begin(mode); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if r.restorer != nil {
+			r.restorer.Rollback()
+		}
+	}()
+
+	for {
+		header, err = tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read archive: %w", err)
+		}
+		switch {
+		case strings.HasPrefix(header.Name, DB_DIR):
+			table := strings.TrimSuffix(strings.TrimPrefix(header.Name, DB_DIR), ".jsonl")
+			err = r.readTable(table, tr)
+		case strings.HasPrefix(header.Name, FILE_DIR):
+			// 文件在数据库之后，写文件前先提交数据库
+			if err = r.finishDB(); err == nil {
+				err = r.readFile(header, tr)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = r.finishDB(); err != nil {
+		return nil, err
+	}
+	if apply {
+		log.Printf("[BACKUP] restored from %s", file)
+	}
+	return r.report, nil
+}
+
+func (r *restoring) readManifest(store storage.MyStore, tr io.Reader) error {
+	manifest := &Manifest{}
+	if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+		return fmt.Errorf("invalid manifest: %w", err)
+	}
+	if manifest.Format > FORMAT {
+		return fmt.Errorf("unsupported backup format: %d", manifest.Format)
+	}
+	r.report.Manifest = manifest
+	if m, ok := store.(storage.Migratable); ok {
+		current, err := m.Migrator().Current()
+		if err != nil {
+			return err
+		}
+		if manifest.Schema > current {
+			return fmt.Errorf("backup schema %d is newer than %d, run `-m migrate up` first", manifest.Schema, current)
+		}
+	}
+	if manifest.Secret == nil {
+		return nil
+	}
+
+	// 口令备份先校验口令，再将密文转为本机密钥加密
+	if manifest.Secret.Salt != "" {
+		if r.opts.Passphrase == "" {
+			return fmt.Errorf("backup is protected, passphrase required")
+		}
+		salt, err := base64.StdEncoding.DecodeString(manifest.Secret.Salt)
+		if err != nil {
+			return fmt.Errorf("invalid manifest salt: %w", err)
+		}
+		if r.key, err = secret.Derive(r.opts.Passphrase, salt); err != nil {
+			return err
+		}
+		if fp, _ := secret.Fingerprint(r.key); fp != manifest.Secret.Fingerprint {
+			return fmt.Errorf("wrong backup passphrase")
+		}
+		return nil
+	}
+	current, err := secret.Fingerprint(nil)
+	if err != nil {
+		return err
+	}
+	if current != manifest.Secret.Fingerprint {
+		r.strip = true
+		r.report.Warnings = append(r.report.Warnings,
+			"secrets were encrypted with another key and will be cleared, back up with a passphrase to keep them",
+		)
+	}
+	return nil
+}
+
+func (r *restoring) readTable(table string, tr io.Reader) error {
+	decoder := json.NewDecoder(tr)
+	batch, stripped := []map[string]json.RawMessage{}, 0
+	for {
+		row := map[string]json.RawMessage{}
+		err := decoder.Decode(&row)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("decode %s: %w", table, err)
+		}
+		count, err := r.convert(table, row)
+		if err != nil {
+			return err
+		}
+		stripped += count
+		if batch = append(batch, row); len(batch) >= 200 {
+			if err := r.restorer.Load(table, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if stripped > 0 {
+		r.report.Warnings = append(r.report.Warnings,
+			fmt.Sprintf("%s: %d secrets cleared", table, stripped),
+		)
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return r.restorer.Load(table, batch)
+}
+
+// convert 改写密文与 home 路径，返回清除的密文数
+func (r *restoring) convert(table string, row map[string]json.RawMessage) (int, error) {
+	count := 0
+	if raw, ok := row["data"]; ok && table == cfgTable && (r.key != nil || r.strip) {
+		data := map[string]any{}
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return 0, fmt.Errorf("decode %s data: %w", table, err)
+		}
+		if r.strip {
+			data, count = secret.Strip(data)
+		} else {
+			rekeyed, err := secret.Rekey(data, r.key, nil)
+			if err != nil {
+				return 0, fmt.Errorf("rekey %s: %w", table, err)
+			}
+			data = rekeyed
+		}
+		row["data"], _ = json.Marshal(data)
+	}
+	if raw, ok := row["home"]; ok && slices.Contains(homeTables, table) {
+		var home string
+		if json.Unmarshal(raw, &home) == nil {
+			home = rebase(home, r.report.Manifest.Home, r.home)
+			row["home"], _ = json.Marshal(home)
+		}
+	}
+	return count, nil
+}
+
+// finishDB 数据库部分结束：演练时回滚，否则提交
+func (r *restoring) finishDB() error {
+	if r.restorer == nil {
+		return nil
+	}
+	restorer := r.restorer
+	r.restorer, r.report.Tables = nil, restorer.Tables
+	if !r.apply {
+		return restorer.Rollback()
+	}
+	return restorer.Commit()
+}
+
+func (r *restoring) readFile(header *tar.Header, tr io.Reader) error {
+	if header.Typeflag != tar.TypeReg {
+		return nil
+	}
+	rel := strings.TrimPrefix(header.Name, FILE_DIR)
+	// 拒绝绝对路径与 .. 等越出工作目录的条目
+	clean := path.Clean("/" + rel)
+	if clean != "/"+rel || strings.Contains(rel, "\\") {
+		return fmt.Errorf("invalid file path: %s", header.Name)
+	}
+	report := r.report.Files
+	report.Total++
+	target := filepath.Join(r.home, filepath.FromSlash(rel))
+	if _, err := os.Stat(target); err == nil {
+		if len(report.Conflicts) < 20 {
+			report.Conflicts = append(report.Conflicts, rel)
+		}
+		if r.opts.Mode != storage.CONFLICT_OVERWRITE {
+			report.Skip++
+			return nil
+		}
+		report.Overwrite++
+	} else {
+		report.Insert++
+	}
+	if !r.apply {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(file, tr)
+	return err
+}
@@ -102,6 +102,20 @@ func GetAuthGate() string {
 	return GetStr("AUTH_GATE", "https://auth.swiflow.cc")
 }
 
+// MCP 目录来源，可配置为镜像地址或本地文件路径
+func GetMcpRegistry() string {
+	return GetStr("MCP_REGISTRY", "https://swiflow.cc/servers.json")
+}
+
+// 官方 MCP 目录的签名公钥
+const REGISTRY_KEY = "/R8gt7ybpdFuu/25oOfSClWLLFFwecQ9FTYXQE2Xj5w="
+
+// 校验 MCP 目录签名的 ed25519 公钥（base64），默认为官方目录的公钥
+// 设为 no 时不校验签名，仅用于自建的未签名镜像
+func GetRegistryKey() string {
+	return GetStr("MCP_REGISTRY_KEY", REGISTRY_KEY)
+}
+
 func NotifyLock() (*os.File, error) {
 	return GetLogFile("notify.lock")
 }
@@ -143,23 +157,6 @@ func EpigraphInfo() map[string]any {
 	return result
 }
 
-func NeedUpgrade() bool {
-	version := GetDataPath("version")
-	val, err := os.ReadFile(version)
-	if os.IsNotExist(err) {
-		os.WriteFile(version, []byte(GetVersion()), 0644)
-		log.Println("[MIGRATION] need initial~")
-		return true
-	}
-
-	if string(val) != GetVersion() {
-		os.WriteFile(version, []byte(GetVersion()), 0644)
-		log.Println("[MIGRATION] need upgrade~", string(val))
-		return true
-	}
-	return false
-}
-
 func GetShellName() (string, string) {
 	switch runtime.GOOS {
 	case "windows":
@@ -24,6 +24,31 @@ func MySQLDSN() any {
 	}
 	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", user, pass, host, port, name)
 }
+
+func PostgresDSN() any {
+	var host, port, name, user, pass string
+	if host = Get("POSTGRES_HOST"); host == "" {
+		return fmt.Errorf("%w: %s", errors.ErrorConfig, "loss host")
+	}
+	if port = GetStr("POSTGRES_PORT", "5432"); port == "" {
+		return fmt.Errorf("%w: %s", errors.ErrorConfig, "loss db port")
+	}
+	if name = Get("POSTGRES_NAME"); name == "" {
+		return fmt.Errorf("%w: %s", errors.ErrorConfig, "loss db name")
+	}
+	if user = Get("POSTGRES_USER"); user == "" {
+		return fmt.Errorf("%w: %s", errors.ErrorConfig, "loss username")
+	}
+	if pass = Get("POSTGRES_PASS"); pass == "" {
+		return fmt.Errorf("%w: %s", errors.ErrorConfig, "loss password")
+	}
+	sslmode := GetStr("POSTGRES_SSLMODE", "disable")
+	return fmt.Sprintf(
+		"host=%s port=%s dbname=%s user=%s password=%s sslmode=%s",
+		host, port, name, user, pass, sslmode,
+	)
+}
+
 func SQLiteFile() string {
 	return GetDataPath("swiflow.db")
 }
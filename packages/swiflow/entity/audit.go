@@ -0,0 +1,38 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuditEntity 记录一次 MCP 工具调用或资源读取
+type AuditEntity struct {
+	ID uint `gorm:"primarykey"`
+
+	Task   string `json:"task" gorm:"column:task;size:16;index"`
+	Bot    string `json:"bot" gorm:"column:bot;size:16;index"`
+	Kind   string `json:"kind" gorm:"column:kind;size:10"`
+	Server string `json:"server" gorm:"column:server;size:50;index"`
+	Tool   string `json:"tool" gorm:"column:tool;size:200"`
+	// 参数的 sha256，避免落库敏感内容
+	Args    string `json:"args" gorm:"column:args;size:64"`
+	Latency int64  `json:"latency" gorm:"column:latency"`
+	Size    int    `json:"size" gorm:"column:size"`
+	Error   string `json:"error" gorm:"column:error;type:text"`
+
+	gorm.Model `json:"-"`
+}
+
+func (m *AuditEntity) TableName() string {
+	return "llm_audit"
+}
+
+func (m *AuditEntity) ToMap() map[string]any {
+	return map[string]any{
+		"id": m.ID, "task": m.Task, "bot": m.Bot,
+		"kind": m.Kind, "server": m.Server, "tool": m.Tool,
+		"args": m.Args, "latency": m.Latency, "size": m.Size,
+		"error": m.Error, "time": m.CreatedAt.Format(time.DateTime),
+	}
+}
@@ -1,6 +1,9 @@
 package entity
 
 import (
+	"maps"
+	"swiflow/secret"
+
 	"gorm.io/gorm"
 )
 
@@ -16,11 +19,19 @@ type BotEntity struct {
 	Emoji  string   `json:"emoji" gorm:"column:emoji;size:50"`
 	Tools  []string `json:"tools" gorm:"tools;serializer:json;"`
 	Leader string   `json:"leader" gorm:"column:leader;size:16"`
+	// 作为子任务完成时 complete 内容需满足的 JSON Schema
+	OutputSchema map[string]any `json:"outputSchema,omitempty" gorm:"column:output_schema;serializer:json"`
+	// type 为 remote 时，通过 A2A 协议调用的远程 agent
+	Remote *RemoteAgent `json:"remote,omitempty" gorm:"column:remote;serializer:json"`
 
 	UsePrompt string `json:"usePrompt" gorm:"column:use_prompt"`
 	SysPrompt string `json:"sysPrompt" gorm:"column:sys_prompt"`
 
 	Provider string `json:"provider" gorm:"provider;size:50"`
+	// 当前定义对应的版本号，见 BotVersion
+	Version int `json:"version" gorm:"column:version;default:0"`
+	// 本次修改的作者，仅用于记录版本
+	Author string `json:"-" gorm:"-"`
 	// Endpoint  string `json:"endpoint" gorm:"endpoint;size:200"`
 	// ApiSecret string `json:"apiSecret" gorm:"api_secret;size:50"`
 	// ModelName string `json:"modelName" gorm:"model_name;size:50"`
@@ -43,5 +54,63 @@ func (r *BotEntity) ToMap() map[string]any {
 		"uuid": r.UUID, "type": r.Type, "name": r.Name,
 		"home": r.Home, "tools": r.Tools, "emoji": r.Emoji,
 		"leader": r.Leader, "provider": r.Provider, "desc": r.Desc,
+		"outputSchema": r.OutputSchema, "remote": r.Remote.Redacted(),
+		"version": r.Version,
+	}
+}
+
+// RemoteAgent 远程 agent 的地址与认证信息
+type RemoteAgent struct {
+	Url   string `json:"url"`
+	Token string `json:"token,omitempty"` // Bearer token
+	// 其他认证方式，如 X-API-Key
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Redacted 返回 token 与敏感 header 替换为掩码的副本，用于接口返回
+func (r *RemoteAgent) Redacted() *RemoteAgent {
+	if r == nil {
+		return nil
+	}
+	result := r.Clone()
+	result.Token = secret.Mask(r.Token)
+	for key, val := range result.Headers {
+		if secret.IsSecret(key) {
+			result.Headers[key] = secret.Mask(val)
+		}
+	}
+	return result
+}
+
+// Restore 将仍为掩码的 token 与 header 还原为 old 中的值
+func (r *RemoteAgent) Restore(old *RemoteAgent) {
+	if r == nil {
+		return
+	}
+	prev := &RemoteAgent{}
+	if old != nil {
+		prev = old
+	}
+	if r.Token == secret.MASK {
+		r.Token = prev.Token
+	}
+	for key, val := range r.Headers {
+		if val != secret.MASK {
+			continue
+		}
+		if value, ok := prev.Headers[key]; ok {
+			r.Headers[key] = value
+		} else {
+			delete(r.Headers, key)
+		}
+	}
+}
+
+func (r *RemoteAgent) Clone() *RemoteAgent {
+	if r == nil {
+		return nil
 	}
+	result := *r
+	result.Headers = maps.Clone(r.Headers)
+	return &result
 }
@@ -0,0 +1,36 @@
+package entity
+
+import (
+	"swiflow/secret"
+	"testing"
+)
+
+func TestRemoteAgent_Redacted(t *testing.T) {
+	remote := &RemoteAgent{
+		Url: "https://agent.example.com", Token: "tk-1",
+		Headers: map[string]string{"X-API-Key": "key-1", "X-Team": "dev"},
+	}
+	bot := &BotEntity{UUID: "bot-1", Remote: remote}
+	masked, _ := bot.ToMap()["remote"].(*RemoteAgent)
+	if masked == nil || masked.Token != secret.MASK || masked.Headers["X-API-Key"] != secret.MASK {
+		t.Fatalf("token 与敏感 header 应替换为掩码: %+v", masked)
+	}
+	if masked.Headers["X-Team"] != "dev" || remote.Token != "tk-1" {
+		t.Errorf("普通 header 不应掩码，原值不应修改: %+v %+v", masked, remote)
+	}
+
+	// 提交掩码时还原为已保存的值，新值直接使用
+	masked.Url, masked.Headers["X-Team"] = "https://new.example.com", "ops"
+	masked.Restore(remote)
+	if masked.Token != "tk-1" || masked.Headers["X-API-Key"] != "key-1" {
+		t.Errorf("掩码未还原: %+v", masked)
+	}
+	submit := &RemoteAgent{Token: "tk-2", Headers: map[string]string{"Authorization": secret.MASK}}
+	submit.Restore(remote)
+	if submit.Token != "tk-2" {
+		t.Errorf("新 token 不应被覆盖: %s", submit.Token)
+	}
+	if _, ok := submit.Headers["Authorization"]; ok {
+		t.Errorf("原先不存在的掩码 header 应移除: %v", submit.Headers)
+	}
+}
@@ -3,7 +3,9 @@ package entity
 import (
 	"database/sql"
 	"fmt"
+	"log"
 	"swiflow/errors"
+	"swiflow/secret"
 	"time"
 
 	"gorm.io/gorm"
@@ -19,12 +21,58 @@ type CfgEntity struct {
 	Data object `json:"data" gorm:"type:text;serializer:json;not null"`
 
 	gorm.Model `json:"-"`
+
+	// 保存期间暂存明文，保存后还原
+	plain object
 }
 
 func (m *CfgEntity) TableName() string {
 	return "llm_cfg"
 }
 
+// BeforeSave 加密敏感字段后入库，调用方的 Data 不受影响
+func (m *CfgEntity) BeforeSave(tx *gorm.DB) error {
+	data, err := secret.Seal(m.Data, m.Type == KEY_SECRET)
+	if err != nil {
+		return fmt.Errorf("seal cfg %s: %w", m.Name, err)
+	}
+	m.plain, m.Data = m.Data, data
+	return nil
+}
+
+func (m *CfgEntity) AfterSave(tx *gorm.DB) error {
+	if m.plain != nil {
+		m.Data, m.plain = m.plain, nil
+	}
+	return nil
+}
+
+// AfterFind 解密敏感字段，失败时保留密文并记录日志
+func (m *CfgEntity) AfterFind(tx *gorm.DB) error {
+	data, err := secret.Open(m.Data)
+	if err != nil {
+		log.Printf("[SECRET] open cfg %s/%s: %v", m.Type, m.Name, err)
+	}
+	m.Data = data
+	return nil
+}
+
+// Redacted 敏感字段替换为掩码，用于接口返回
+func (m *CfgEntity) Redacted() map[string]any {
+	result := m.ToMap()
+	if m.Type == KEY_SECRET {
+		result["data"] = object{"value": secret.Mask(m.GetStr("value"))}
+	} else {
+		result["data"] = secret.Redact(m.Data)
+	}
+	return result
+}
+
+func (m *CfgEntity) GetStr(key string) string {
+	val, _ := m.Data[key].(string)
+	return val
+}
+
 func (m *CfgEntity) ToMap() map[string]any {
 	return map[string]any{
 		"type": m.Type,
@@ -0,0 +1,46 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EvalEntity 一次评测中某个目标配置运行某个用例的结果
+type EvalEntity struct {
+	ID uint `gorm:"primarykey"`
+
+	Run    string `json:"run" gorm:"column:run;size:16;index"`
+	Suite  string `json:"suite" gorm:"column:suite;size:100"`
+	Target string `json:"target" gorm:"column:target;size:100"`
+	Case   string `json:"case" gorm:"column:case;size:100"`
+	Task   string `json:"task" gorm:"column:task;size:36"`
+
+	Bot      string `json:"bot" gorm:"column:bot;size:16"`
+	BotVer   int    `json:"botver" gorm:"column:botver"`
+	Provider string `json:"provider" gorm:"column:provider;size:50"`
+
+	Passed   bool     `json:"passed" gorm:"column:passed"`
+	Failures []string `json:"failures" gorm:"column:failures;serializer:json"`
+	State    string   `json:"state" gorm:"column:state;size:10"`
+	Turns    int      `json:"turns" gorm:"column:turns"`
+	Tokens   int      `json:"tokens" gorm:"column:tokens"`
+	Latency  int64    `json:"latency" gorm:"column:latency"`
+
+	gorm.Model `json:"-"`
+}
+
+func (m *EvalEntity) TableName() string {
+	return "llm_eval"
+}
+
+func (m *EvalEntity) ToMap() map[string]any {
+	return map[string]any{
+		"run": m.Run, "suite": m.Suite, "target": m.Target,
+		"case": m.Case, "task": m.Task, "bot": m.Bot,
+		"botver": m.BotVer, "provider": m.Provider,
+		"passed": m.Passed, "failures": m.Failures, "state": m.State,
+		"turns": m.Turns, "tokens": m.Tokens, "latency": m.Latency,
+		"time": m.CreatedAt.Format(time.DateTime),
+	}
+}
@@ -9,7 +9,10 @@ const (
 	KEY_APP_SETUP = "app-setup"
 
 	KEY_MCP_SERVER = "mcp-server"
+	KEY_MCP_OAUTH  = "mcp-oauth"
 	KEY_USE_WORKER = "use-worker"
 	KEY_LOGIN_USER = "login-user"
 	KEY_INTENT_MSG = "intent-msg"
+	// 命名密钥，data.value 整体加密
+	KEY_SECRET = "secret"
 )
@@ -1,7 +1,28 @@
 package entity
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	MEM_LOW    = 1
+	MEM_NORMAL = 2
+	MEM_HIGH   = 3
+)
+
+// 记忆的作用范围，优先级 task > project > bot > global
+const (
+	SCOPE_GLOBAL  = "global"  // 所有 bot 共享
+	SCOPE_BOT     = "bot"     // 仅写入它的 bot
+	SCOPE_PROJECT = "project" // 同一工作目录下共享
+	SCOPE_TASK    = "task"    // 仅当前任务
 )
 
 type MemEntity struct {
@@ -10,19 +31,144 @@ type MemEntity struct {
 	Bot  string `gorm:"column:bot;size:16;"`
 	Type string `gorm:"column:type;size:10;"`
 
+	Scope string `gorm:"column:scope;size:10;default:bot"`
+	// project 为工作目录，task 为任务 uuid
+	Target string `gorm:"column:target;size:200"`
+
 	Subject string `gorm:"column:subject;not null;size:200"`
 	Content string `gorm:"column:content;not null;longtext"`
 
+	// 重要程度：1 低、2 普通、3 高
+	Importance int        `gorm:"column:importance;default:2"`
+	ExpireAt   *time.Time `gorm:"column:expire_at"`
+	UsedAt     *time.Time `gorm:"column:used_at"`
+	UsedCount  int        `gorm:"column:used_count"`
+
+	// 语义检索用的向量及生成它的模型，内容变化后需重新生成
+	Vector   Vector `json:"-" gorm:"column:vector"`
+	Embedder string `json:"-" gorm:"column:embedder;size:100"`
+
 	gorm.Model `json:"-"`
 }
 
+// Vector 以 JSON 数组保存的向量
+type Vector []float32
+
+func (v Vector) Value() (driver.Value, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+func (Vector) GormDataType() string {
+	return "text"
+}
+
+// GormDBDataType 向量较长，mysql 需使用 longtext，postgres 使用 jsonb
+func (Vector) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	switch db.Dialector.Name() {
+	case "mysql":
+		return "longtext"
+	case "postgres":
+		return "jsonb"
+	}
+	return "text"
+}
+
+func (v *Vector) Scan(src any) error {
+	switch data := src.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(data), v)
+	case []byte:
+		return json.Unmarshal(data, v)
+	}
+	return fmt.Errorf("unsupported vector type %T", src)
+}
+
 func (m *MemEntity) TableName() string {
 	return "llm_mem"
 }
 
+// EmbedText 用于生成向量和检索的文本
+func (m *MemEntity) EmbedText() string {
+	return m.Subject + "\n" + m.Content
+}
+
+// IsExpired 记忆已过期，不再注入上下文
+func (m *MemEntity) IsExpired() bool {
+	return m.ExpireAt != nil && m.ExpireAt.Before(time.Now())
+}
+
+// GetScope 旧数据没有 scope，视为 bot
+func (m *MemEntity) GetScope() string {
+	if m.Scope == "" {
+		return SCOPE_BOT
+	}
+	return m.Scope
+}
+
+// Applies 记忆是否适用于 bot 在 home 目录下执行的 task
+func (m *MemEntity) Applies(bot, home, task string) bool {
+	switch m.GetScope() {
+	case SCOPE_GLOBAL:
+		return true
+	case SCOPE_PROJECT:
+		return home != "" && m.Target == home
+	case SCOPE_TASK:
+		return task != "" && m.Target == task
+	default:
+		return m.Bot == bot
+	}
+}
+
+// SameScope 两条记忆属于同一作用范围，重复检测和合并只在同一范围内进行
+func (m *MemEntity) SameScope(other *MemEntity) bool {
+	if m.GetScope() != other.GetScope() {
+		return false
+	}
+	switch m.GetScope() {
+	case SCOPE_GLOBAL:
+		return true
+	case SCOPE_BOT:
+		return m.Bot == other.Bot
+	default:
+		return m.Target == other.Target
+	}
+}
+
+// ParseScope 解析 global/bot/project/task，默认 bot
+func ParseScope(value string) string {
+	switch scope := strings.ToLower(strings.TrimSpace(value)); scope {
+	case SCOPE_GLOBAL, SCOPE_PROJECT, SCOPE_TASK:
+		return scope
+	default:
+		return SCOPE_BOT
+	}
+}
+
+// ParseImportance 解析 low/normal/high 或 1-3
+func ParseImportance(value string) int {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "low", "1":
+		return MEM_LOW
+	case "high", "3":
+		return MEM_HIGH
+	default:
+		return MEM_NORMAL
+	}
+}
+
 func (m *MemEntity) ToMap() map[string]any {
 	return map[string]any{
 		"id": m.ID, "bot": m.Bot, "type": m.Type,
+		"scope": m.GetScope(), "target": m.Target,
 		"subject": m.Subject, "content": m.Content,
+		"importance": m.Importance, "expireAt": m.ExpireAt,
+		"usedAt": m.UsedAt, "usedCount": m.UsedCount,
 	}
 }
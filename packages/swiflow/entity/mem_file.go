@@ -0,0 +1,190 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 导出的 Markdown 中每条记忆前的标记，记录 subject 以外的属性
+const MEM_MARKER = "<!-- memory"
+
+// memMeta Markdown 标记及 JSON 中的记忆属性
+type memMeta struct {
+	Subject    string `json:"subject,omitempty"`
+	Content    string `json:"content,omitempty"`
+	Scope      string `json:"scope,omitempty"`
+	Target     string `json:"target,omitempty"`
+	Importance int    `json:"importance,omitempty"`
+	Expire     string `json:"expire,omitempty"`
+}
+
+// MemFile 导出的 JSON 文件
+type MemFile struct {
+	Bot      string     `json:"bot"`
+	Time     string     `json:"time"`
+	Memories []*memMeta `json:"memories"`
+}
+
+func toMeta(mem *MemEntity) *memMeta {
+	meta := &memMeta{
+		Subject: mem.Subject, Content: mem.Content,
+		Target: mem.Target, Importance: mem.Importance,
+	}
+	if scope := mem.GetScope(); scope != SCOPE_BOT {
+		meta.Scope = scope
+	}
+	if meta.Importance == MEM_NORMAL {
+		meta.Importance = 0
+	}
+	if mem.ExpireAt != nil {
+		meta.Expire = mem.ExpireAt.Format(time.DateTime)
+	}
+	return meta
+}
+
+func (meta *memMeta) toEntity() *MemEntity {
+	mem := &MemEntity{
+		Type:    "chat",
+		Subject: strings.TrimSpace(meta.Subject),
+		Content: strings.TrimSpace(meta.Content),
+		Scope:   ParseScope(meta.Scope), Target: meta.Target,
+		Importance: MEM_NORMAL,
+	}
+	if meta.Importance >= MEM_LOW && meta.Importance <= MEM_HIGH {
+		mem.Importance = meta.Importance
+	}
+	if expire, err := time.ParseInLocation(time.DateTime, meta.Expire, time.Local); err == nil {
+		mem.ExpireAt = &expire
+	}
+	// project/task 记忆缺少目标时退化为 bot 记忆
+	if mem.Scope != SCOPE_GLOBAL && mem.Target == "" {
+		mem.Scope = SCOPE_BOT
+	}
+	return mem
+}
+
+// sortMems 按 subject 排序，便于在 git 中比较
+func sortMems(mems []*MemEntity) []*MemEntity {
+	list := append([]*MemEntity{}, mems...)
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Subject != list[j].Subject {
+			return list[i].Subject < list[j].Subject
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// MemToJson 导出为 JSON
+func MemToJson(bot string, mems []*MemEntity) ([]byte, error) {
+	file := &MemFile{Bot: bot, Time: time.Now().Format(time.DateTime)}
+	for _, mem := range sortMems(mems) {
+		file.Memories = append(file.Memories, toMeta(mem))
+	}
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// MemFromJson 解析导出的 JSON，也支持直接的记忆数组
+func MemFromJson(data []byte) ([]*MemEntity, error) {
+	file := new(MemFile)
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(data, &file.Memories); err != nil {
+			return nil, fmt.Errorf("invalid memory json: %w", err)
+		}
+	} else if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("invalid memory json: %w", err)
+	}
+	result := []*MemEntity{}
+	for _, meta := range file.Memories {
+		if mem := meta.toEntity(); mem.Content != "" {
+			result = append(result, mem)
+		}
+	}
+	return result, nil
+}
+
+// MemToMarkdown 导出为 Markdown，每个 subject 一节，没有 subject 的记忆放在第一节之前
+func MemToMarkdown(title string, mems []*MemEntity) string {
+	var s strings.Builder
+	s.WriteString("# " + title + "\n")
+	subject := ""
+	for _, mem := range sortMems(mems) {
+		if mem.Subject != subject {
+			subject = mem.Subject
+			s.WriteString("\n## " + escapeLine(subject) + "\n")
+		}
+		meta := toMeta(mem)
+		meta.Subject, meta.Content = "", ""
+		s.WriteString("\n" + MEM_MARKER)
+		if data, _ := json.Marshal(meta); string(data) != "{}" {
+			s.WriteString(" " + string(data))
+		}
+		s.WriteString(" -->\n")
+		for _, line := range strings.Split(strings.TrimSpace(mem.Content), "\n") {
+			s.WriteString(escapeLine(line) + "\n")
+		}
+	}
+	return s.String()
+}
+
+// MemFromMarkdown 解析 Markdown：二级标题为 subject，
+// 没有记忆标记的节整体作为一条记忆，便于直接导入手写的文档
+func MemFromMarkdown(text string) ([]*MemEntity, error) {
+	result := []*MemEntity{}
+	var subject string
+	var current *memMeta
+	var lines []string
+	flush := func() {
+		if current != nil {
+			current.Subject = subject
+			current.Content = strings.Join(lines, "\n")
+			if mem := current.toEntity(); mem.Content != "" {
+				result = append(result, mem)
+			}
+		}
+		current, lines = nil, nil
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "# ") && current == nil && len(result) == 0 && subject == "":
+			// 文档标题
+		case strings.HasPrefix(line, "## "):
+			flush()
+			subject = unescapeLine(strings.TrimSpace(line[3:]))
+		case strings.HasPrefix(line, MEM_MARKER):
+			flush()
+			current = new(memMeta)
+			data := strings.TrimSuffix(strings.TrimSpace(line[len(MEM_MARKER):]), "-->")
+			if data = strings.TrimSpace(data); data != "" {
+				if err := json.Unmarshal([]byte(data), current); err != nil {
+					return nil, fmt.Errorf("invalid memory marker %q: %w", line, err)
+				}
+			}
+		default:
+			if current == nil {
+				current = new(memMeta)
+			}
+			lines = append(lines, unescapeLine(line))
+		}
+	}
+	flush()
+	return result, nil
+}
+
+// escapeLine 避免内容中的标题或标记被当作新的记忆
+func escapeLine(line string) string {
+	if strings.HasPrefix(line, "#") || strings.HasPrefix(line, MEM_MARKER) {
+		return "\\" + line
+	}
+	return line
+}
+
+func unescapeLine(line string) string {
+	if strings.HasPrefix(line, "\\#") || strings.HasPrefix(line, "\\"+MEM_MARKER) {
+		return line[1:]
+	}
+	return line
+}
@@ -0,0 +1,71 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemFile_RoundTrip(t *testing.T) {
+	expire := time.Date(2030, 1, 2, 0, 0, 0, 0, time.Local)
+	mems := []*MemEntity{
+		{ID: 1, Subject: "构建", Content: "使用 make build\n# 不是标题", Scope: SCOPE_PROJECT, Target: "/proj"},
+		{ID: 2, Subject: "构建", Content: "发布前执行 make test", Importance: MEM_HIGH},
+		{ID: 3, Subject: "", Content: "回答时使用中文", Scope: SCOPE_GLOBAL},
+		{ID: 4, Subject: "周报", Content: "周五提交周报", ExpireAt: &expire},
+	}
+
+	markdown := MemToMarkdown("coder", mems)
+	if !strings.Contains(markdown, "## 构建") || strings.Count(markdown, "## 构建") != 1 {
+		t.Errorf("同一主题应只有一节:\n%s", markdown)
+	}
+	fromMd, err := MemFromMarkdown(markdown)
+	if err != nil {
+		t.Fatalf("解析 Markdown 失败: %v", err)
+	}
+	data, _ := MemToJson("coder", mems)
+	fromJson, err := MemFromJson(data)
+	if err != nil {
+		t.Fatalf("解析 JSON 失败: %v", err)
+	}
+
+	for name, list := range map[string][]*MemEntity{"markdown": fromMd, "json": fromJson} {
+		if len(list) != len(mems) {
+			t.Fatalf("%s 记忆数量应为 %d，实际 %d", name, len(mems), len(list))
+		}
+		found := map[string]*MemEntity{}
+		for _, mem := range list {
+			found[mem.Content] = mem
+		}
+		for _, want := range mems {
+			got := found[want.Content]
+			if got == nil {
+				t.Errorf("%s 缺少记忆: %q", name, want.Content)
+				continue
+			}
+			if got.Subject != want.Subject || got.GetScope() != want.GetScope() || got.Target != want.Target {
+				t.Errorf("%s 属性不一致: %+v", name, got)
+			}
+			if want.Importance == MEM_HIGH && got.Importance != MEM_HIGH {
+				t.Errorf("%s 重要程度丢失", name)
+			}
+			if want.ExpireAt != nil && (got.ExpireAt == nil || !got.ExpireAt.Equal(expire)) {
+				t.Errorf("%s 有效期丢失: %v", name, got.ExpireAt)
+			}
+		}
+	}
+}
+
+func TestMemFromMarkdown_Handwritten(t *testing.T) {
+	text := "# 团队知识\n\n## 代码风格\n\n- 提交前执行 gofmt\n- 错误信息使用英文\n\n## 部署\n\n使用 docker compose\n"
+	list, err := MemFromMarkdown(text)
+	if err != nil || len(list) != 2 {
+		t.Fatalf("手写文档应解析为 2 条记忆: %d %v", len(list), err)
+	}
+	if list[0].Subject != "代码风格" || !strings.Contains(list[0].Content, "错误信息使用英文") {
+		t.Errorf("解析错误: %+v", list[0])
+	}
+	if list[1].GetScope() != SCOPE_BOT || list[1].Importance != MEM_NORMAL {
+		t.Errorf("默认属性错误: %+v", list[1])
+	}
+}
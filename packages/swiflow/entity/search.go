@@ -0,0 +1,48 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	SEARCH_TASK = "task"
+	SEARCH_MSG  = "msg"
+	SEARCH_MEM  = "mem"
+)
+
+// SearchHit 全文检索命中的任务、消息或记忆
+type SearchHit struct {
+	Kind    string  `json:"kind"`
+	Task    string  `json:"task"`   // 任务 uuid
+	UniqId  string  `json:"uniqId"` // 消息 UniqId
+	MemId   uint    `json:"memId"`
+	Bot     string  `json:"bot"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"`
+	Score   float64 `json:"score"`
+
+	Time time.Time `json:"time"`
+}
+
+// Link 跳转到命中的任务或消息
+func (m *SearchHit) Link() string {
+	switch m.Kind {
+	case SEARCH_TASK:
+		return fmt.Sprintf("/api/task?uuid=%s", m.Task)
+	case SEARCH_MSG:
+		return fmt.Sprintf("/api/msgs?task=%s#%s", m.Task, m.UniqId)
+	case SEARCH_MEM:
+		return fmt.Sprintf("/api/mem?act=get-mem&bot=%s#%d", m.Bot, m.MemId)
+	}
+	return ""
+}
+
+func (m *SearchHit) ToMap() map[string]any {
+	return map[string]any{
+		"kind": m.Kind, "task": m.Task, "uniqId": m.UniqId,
+		"memId": m.MemId, "bot": m.Bot, "title": m.Title,
+		"snippet": m.Snippet, "score": m.Score, "link": m.Link(),
+		"time": m.Time.Format(time.DateTime),
+	}
+}
@@ -13,6 +13,8 @@ type TaskEntity struct {
 	Desc  string `json:"desc" gorm:"column:desc;size:200"`
 	Group string `json:"group" gorm:"column:group;size:36"`
 	BotId string `json:"botid" gorm:"column:botid;size:36"`
+	// 执行任务时 bot 的版本号
+	BotVer int `json:"botver" gorm:"column:botver;default:0"`
 	// 任务状态 (process, running, completed, failed, canceled)
 	State string `json:"state" gorm:"column:state;size:10"`
 	// session, from feishu or another bot
@@ -35,7 +37,7 @@ func (m *TaskEntity) TableName() string {
 func (m *TaskEntity) ToMap() map[string]any {
 	return map[string]any{
 		"uuid": m.UUID, "name": m.Name, "home": m.Home,
-		"botid": m.BotId, "group": m.Group, "state": m.State,
+		"botid": m.BotId, "botver": m.BotVer, "group": m.Group, "state": m.State,
 		"sessid": m.SessID, "source": m.Source, "desc": m.Desc,
 		"context": m.Context, "command": m.Command, "process": m.Process,
 	}
@@ -0,0 +1,134 @@
+package entity
+
+import (
+	"reflect"
+	"slices"
+	"swiflow/support"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BotVersion 记录 bot 每次变更后的定义，用于对比与回滚
+type BotVersion struct {
+	ID uint `gorm:"primarykey"`
+
+	Bot     string `json:"bot" gorm:"column:bot;size:16;index;uniqueIndex:idx_bot_version"`
+	Version int    `json:"version" gorm:"column:version;not null;uniqueIndex:idx_bot_version"`
+	Author  string `json:"author" gorm:"column:author;size:50"`
+
+	Name     string `json:"name" gorm:"column:name;size:50"`
+	Type     string `json:"type" gorm:"column:type;size:16"`
+	Desc     string `json:"desc" gorm:"column:desc;size:200"`
+	Emoji    string `json:"emoji" gorm:"column:emoji;size:50"`
+	Leader   string `json:"leader" gorm:"column:leader;size:16"`
+	Provider string `json:"provider" gorm:"column:provider;size:50"`
+
+	Tools        []string       `json:"tools" gorm:"column:tools;serializer:json"`
+	UsePrompt    string         `json:"usePrompt" gorm:"column:use_prompt"`
+	SysPrompt    string         `json:"sysPrompt" gorm:"column:sys_prompt"`
+	OutputSchema map[string]any `json:"outputSchema,omitempty" gorm:"column:output_schema;serializer:json"`
+
+	gorm.Model `json:"-"`
+}
+
+func (m *BotVersion) TableName() string {
+	return "llm_bot_version"
+}
+
+// NewBotVersion 从 bot 当前定义生成快照，不含 home 与远程认证信息
+func NewBotVersion(bot *BotEntity) *BotVersion {
+	return &BotVersion{
+		Bot: bot.UUID, Author: bot.Author,
+		Name: bot.Name, Type: bot.Type, Desc: bot.Desc,
+		Emoji: bot.Emoji, Leader: bot.Leader, Provider: bot.Provider,
+		Tools: slices.Clone(bot.Tools), OutputSchema: bot.OutputSchema,
+		UsePrompt: bot.UsePrompt, SysPrompt: bot.SysPrompt,
+	}
+}
+
+// Same 定义是否一致，忽略版本号与作者
+func (m *BotVersion) Same(other *BotVersion) bool {
+	if other == nil {
+		return false
+	}
+	a, b := *m, *other
+	a.ID, a.Version, a.Author, a.Model = 0, 0, "", gorm.Model{}
+	b.ID, b.Version, b.Author, b.Model = 0, 0, "", gorm.Model{}
+	if len(a.Tools) == 0 && len(b.Tools) == 0 {
+		a.Tools, b.Tools = nil, nil
+	}
+	if len(a.OutputSchema) == 0 && len(b.OutputSchema) == 0 {
+		a.OutputSchema, b.OutputSchema = nil, nil
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Apply 回滚：把快照中的定义写回 bot
+func (m *BotVersion) Apply(bot *BotEntity) {
+	bot.Name, bot.Type, bot.Desc = m.Name, m.Type, m.Desc
+	bot.Emoji, bot.Leader, bot.Provider = m.Emoji, m.Leader, m.Provider
+	bot.Tools, bot.OutputSchema = slices.Clone(m.Tools), m.OutputSchema
+	bot.UsePrompt, bot.SysPrompt = m.UsePrompt, m.SysPrompt
+}
+
+func (m *BotVersion) ToMap() map[string]any {
+	return map[string]any{
+		"bot": m.Bot, "version": m.Version, "author": m.Author,
+		"name": m.Name, "type": m.Type, "desc": m.Desc,
+		"emoji": m.Emoji, "leader": m.Leader, "provider": m.Provider,
+		"tools": m.Tools, "outputSchema": m.OutputSchema,
+		"usePrompt": m.UsePrompt, "sysPrompt": m.SysPrompt,
+		"time": m.CreatedAt.Format(time.DateTime),
+	}
+}
+
+// DiffVersion 对比两个版本的提示词、工具与其他字段
+// 提示词为逐行差异，工具为增删列表，其他字段为前后取值
+func DiffVersion(from, to *BotVersion) map[string]any {
+	result := map[string]any{
+		"from": from.Version, "to": to.Version,
+	}
+	prompts := map[string]any{}
+	if from.UsePrompt != to.UsePrompt {
+		prompts["usePrompt"] = support.LineDiff(from.UsePrompt, to.UsePrompt)
+	}
+	if from.SysPrompt != to.SysPrompt {
+		prompts["sysPrompt"] = support.LineDiff(from.SysPrompt, to.SysPrompt)
+	}
+	result["prompts"] = prompts
+
+	added, removed := []string{}, []string{}
+	for _, tool := range to.Tools {
+		if !slices.Contains(from.Tools, tool) {
+			added = append(added, tool)
+		}
+	}
+	for _, tool := range from.Tools {
+		if !slices.Contains(to.Tools, tool) {
+			removed = append(removed, tool)
+		}
+	}
+	result["tools"] = map[string]any{
+		"added": added, "removed": removed,
+	}
+
+	fields := map[string]any{}
+	pairs := map[string][2]string{
+		"name": {from.Name, to.Name}, "type": {from.Type, to.Type},
+		"desc": {from.Desc, to.Desc}, "emoji": {from.Emoji, to.Emoji},
+		"leader": {from.Leader, to.Leader}, "provider": {from.Provider, to.Provider},
+	}
+	for key, pair := range pairs {
+		if pair[0] != pair[1] {
+			fields[key] = map[string]string{"from": pair[0], "to": pair[1]}
+		}
+	}
+	if !reflect.DeepEqual(from.OutputSchema, to.OutputSchema) {
+		fields["outputSchema"] = map[string]any{
+			"from": from.OutputSchema, "to": to.OutputSchema,
+		}
+	}
+	result["fields"] = fields
+	return result
+}
@@ -0,0 +1,74 @@
+package entry
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"swiflow/backup"
+	"swiflow/config"
+	"swiflow/storage"
+)
+
+// StartBackup 备份或恢复数据，口令也可通过 BACKUP_PASSPHRASE 传入
+//
+//	-m backup create [-workspace] [-passphrase x] [file]
+//	-m backup restore [-mode skip|overwrite|fail] [-dry-run] [-passphrase x] file
+func StartBackup(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: backup create|restore [options] file")
+	}
+	store, err := storage.GetStorage()
+	if err != nil {
+		return err
+	}
+
+	cmd := flag.NewFlagSet("backup "+args[0], flag.ContinueOnError)
+	passphrase := cmd.String("passphrase", config.Get("BACKUP_PASSPHRASE"), "encrypt secrets with passphrase")
+	switch args[0] {
+	case "create":
+		workspace := cmd.Bool("workspace", false, "include task workspaces")
+		if err := cmd.Parse(args[1:]); err != nil {
+			return err
+		}
+		name := cmd.Arg(0)
+		if name == "" {
+			name = time.Now().Format("swiflow-backup-20060102-150405.tar.gz")
+		}
+		file, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		manifest, err := backup.Create(store, file, backup.Options{
+			Workspace: *workspace, Passphrase: *passphrase,
+		})
+		if err != nil {
+			os.Remove(name)
+			return err
+		}
+		fmt.Printf("backup %s: %d tables, %d files\n", name, len(manifest.Tables), manifest.Files)
+		return nil
+	case "restore":
+		mode := cmd.String("mode", storage.CONFLICT_SKIP, "conflict mode: skip, overwrite or fail")
+		dryRun := cmd.Bool("dry-run", false, "report without writing")
+		if err := cmd.Parse(args[1:]); err != nil {
+			return err
+		}
+		if cmd.Arg(0) == "" {
+			return fmt.Errorf("backup file required")
+		}
+		report, err := backup.Restore(store, cmd.Arg(0), backup.RestoreOptions{
+			Mode: *mode, DryRun: *dryRun, Passphrase: *passphrase,
+		})
+		if report != nil {
+			data, _ := json.MarshalIndent(report, "", "  ")
+			fmt.Println(string(data))
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown backup command: %s", args[0])
+	}
+}
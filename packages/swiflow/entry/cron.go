@@ -3,10 +3,7 @@ package entry
 import (
 	"context"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	"os"
 	"swiflow/action"
 	"swiflow/amcp"
 	"swiflow/config"
@@ -60,8 +57,18 @@ func StartSchedule(ctx context.Context) error {
 		log.Printf("[CRON] start create job: %s", job.ID())
 	}
 
+	// 每天整理记忆：清理过期记忆、合并相关记忆，off 表示关闭
+	if spec := config.GetStr("MEMORY_CONSOLIDATE", "0 3 * * *"); spec != "off" {
+		desc = gocron.CronJob(spec, false)
+		task = gocron.NewTask(consolidateMemory, "MEMORY")
+		if job, err := scheduler.NewJob(desc, task); err != nil {
+			log.Printf("[MEMORY] fail create job: %v", err)
+		} else {
+			log.Printf("[MEMORY] start create job: %s", job.ID())
+		}
+	}
+
 	support.Listen("wait-todo", handleNewTodo)
-	support.Listen("mcp-reboot", rebootMcpServers)
 
 	// start
 	scheduler.Start()
@@ -145,6 +152,15 @@ func execThisTodo(todo *entity.TodoEntity) {
 	log.Printf("[%s] finish exec todo", todo.UUID)
 }
 
+func consolidateMemory(name string) {
+	if manager == nil {
+		return
+	}
+	log.Printf("[%s] start consolidate", name)
+	manager.ConsolidateMemory()
+	log.Printf("[%s] finish consolidate", name)
+}
+
 func fetchSystemEnv(_ string) {
 	store, _ := storage.GetStorage()
 	cfg := &entity.CfgEntity{
@@ -157,32 +173,23 @@ func fetchSystemEnv(_ string) {
 	}
 }
 
-// 拉取并保存 servers.json
+// 拉取、校验并保存 servers.json
 func fetchMcpServers(name string) {
-	host := "https://swiflow.cc"
-	url := host + "/servers.json"
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Printf("[%s] get servers.json fail: %v", name, err)
-		return
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		log.Printf("[%s] get servers error: %v", name, resp.Status)
-		return
-	}
-	data, err := io.ReadAll(resp.Body)
+	registry := amcp.NewMcpRegistry()
+	catalog, err := registry.Sync()
 	if err != nil {
-		log.Printf("[%s] read servers fail: %v", name, err)
+		log.Printf("[%s] sync servers fail: %v", name, err)
 		return
 	}
-	path := config.GetWorkPath("servers.json")
-	err = os.WriteFile(path, data, 0644)
-	if err != nil {
-		log.Printf("[%s] save servers fail: %v", name, err)
-		return
+	store, _ := storage.GetStorage()
+	installed := amcp.GetMcpService(store).ListServers()
+	for _, diff := range catalog.Diff(installed) {
+		if diff.Status == amcp.CATALOG_UPGRADE {
+			log.Printf("[%s] %s upgrade %s -> %s", name,
+				diff.UUID, diff.Current, diff.Latest)
+		}
 	}
-	log.Printf("[%s] save servers succ", name)
+	log.Printf("[%s] save servers succ: %s", name, catalog.Version)
 }
 
 // 启动所有MCP服务器
@@ -206,54 +213,3 @@ func startMcpServers(_ string) {
 		}
 	}
 }
-
-func rebootMcpServers(name string, data any) {
-	// bot, _ := data.(*entity.BotEntity)
-	// if bot == nil || bot.Home == "" {
-	// 	return
-	// }
-
-	store, err := storage.GetStorage()
-	if err != nil || store == nil {
-		log.Println("[MCP] 获取store失败", err)
-		return
-	}
-
-	mcpSrv := amcp.GetMcpService(store)
-	servers := mcpSrv.ListServers()
-	for _, server := range servers {
-		if !server.Status.Enable {
-			continue
-		}
-		if !server.Status.Active {
-			continue
-		}
-
-		var needReboot = false
-		for _, val := range server.Env {
-			if val == "$CURRENT_HOME" {
-				needReboot = true
-				break
-			}
-		}
-		if !needReboot {
-			continue
-		}
-		go func() {
-			if mcpSrv.ServerClose(server) == nil {
-				log.Printf("[MCP] Stop Server %s Success", server.Name)
-			}
-			// check env and package ready
-			if err := server.Preload(); err != nil {
-				log.Printf("[MCP] Preload %s err: %v", server.Name, err)
-				return
-			}
-			if err := mcpSrv.ServerStatus(server); err == nil {
-				log.Printf("[MCP] Start Server %s Success", server.Name)
-				log.Printf("[MCP] 启动服务器 %s 失败: %v", server.Name, err)
-			} else {
-				log.Printf("[MCP] Start Server %s Fail: %v", server.Name, err)
-			}
-		}()
-	}
-}
@@ -0,0 +1,77 @@
+package entry
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"swiflow/storage"
+)
+
+// StartMigrate 查看或执行数据库迁移
+//
+//	-m migrate status     列出全部迁移
+//	-m migrate up [ver]   迁移到指定版本，默认最新
+//	-m migrate down [ver] 回滚到指定版本，默认回滚一个
+func StartMigrate(args []string) error {
+	store, err := storage.OpenStorage()
+	if err != nil {
+		return err
+	}
+	m, ok := store.(storage.Migratable)
+	if !ok {
+		return fmt.Errorf("storage not support migration")
+	}
+	migrator := m.Migrator()
+
+	cmd, target := "status", -1
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	if len(args) > 1 {
+		if target, err = strconv.Atoi(args[1]); err != nil || target < 0 {
+			return fmt.Errorf("invalid version: %s", args[1])
+		}
+	}
+
+	var done []*storage.Migration
+	switch cmd {
+	case "status":
+		return printMigration(migrator)
+	case "up":
+		done, err = migrator.Up(max(target, 0))
+	case "down":
+		if target < 0 {
+			current, err := migrator.Current()
+			if err != nil {
+				return err
+			}
+			target = max(current-1, 0)
+		}
+		done, err = migrator.Down(target)
+	default:
+		return fmt.Errorf("unknown migrate command: %s", cmd)
+	}
+	for _, item := range done {
+		fmt.Printf("%s %04d %s\n", cmd, item.Version, item.Name)
+	}
+	if err == nil && len(done) == 0 {
+		fmt.Println("nothing to migrate")
+	}
+	return err
+}
+
+func printMigration(migrator *storage.Migrator) error {
+	list, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	for _, item := range list {
+		applied := "pending"
+		if item.Applied {
+			applied = item.AppliedAt.Format(time.DateTime)
+		}
+		fmt.Printf("%04d  %-40s %s\n", item.Version, item.Name, applied)
+	}
+	return nil
+}
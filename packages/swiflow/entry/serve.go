@@ -32,9 +32,11 @@ func StartWebServer(address string) (err error) {
 	manager = agent.NewManager()
 	handler := httpd.NewHttpHandler(manager)
 	setting := httpd.NewSettingHandle(manager)
+	remote := httpd.NewA2AHandler(manager)
 	mux.HandleFunc("/", handler.Static)
 	mux.HandleFunc("/socket", startSocket)
 	mux.HandleFunc("/api/bot", setting.BotSet)
+	mux.HandleFunc("/api/eval", setting.EvalSet)
 	mux.HandleFunc("/api/mem", setting.MemSet)
 	mux.HandleFunc("/api/mcp", setting.McpSet)
 	mux.HandleFunc("/api/task", setting.TaskSet)
@@ -42,6 +44,9 @@ func StartWebServer(address string) (err error) {
 	mux.HandleFunc("/api/tool", setting.ToolSet)
 	mux.HandleFunc("/api/msgs", setting.GetMsgs)
 	mux.HandleFunc("/api/tasks", setting.GetTasks)
+	mux.HandleFunc("/api/search", setting.Search)
+	mux.HandleFunc("/api/secret", setting.SecretSet)
+	mux.HandleFunc("/api/backup", setting.Backup)
 
 	mux.HandleFunc("/api/start", handler.Start)
 	mux.HandleFunc("/api/intent", handler.Intent)
@@ -56,6 +61,7 @@ func StartWebServer(address string) (err error) {
 	mux.HandleFunc("/api/setting", handler.Setting)
 	mux.HandleFunc("/api/sign-in", handler.SignIn)
 	mux.HandleFunc("/api/sign-out", handler.SignOut)
+	mux.HandleFunc("/api/a2a/", remote.Serve)
 
 	if host := config.Get("SWIFLOW_ADDRESS"); host != "" {
 		address, allows = "0.0.0.0:11235", append(allows, host)
@@ -82,6 +88,8 @@ func middle(next http.Handler) http.Handler {
 				w.Header().Set("Access-Control-Allow-Origin", origin)
 				w.Header().Set("Access-Control-Allow-Credentials", "true")
 			}
+			// 列表接口的分页游标
+			w.Header().Set("Access-Control-Expose-Headers", "X-Next-Cursor")
 		}
 		if r.Method == "OPTIONS" {
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
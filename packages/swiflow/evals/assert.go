@@ -0,0 +1,91 @@
+package evals
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Outcome 一次运行的结果，供断言检查
+type Outcome struct {
+	State    string
+	Home     string
+	Complete string
+	// 调用过的工具：名称 => XML
+	Tools []Call
+}
+
+type Call struct {
+	Name string
+	Body string
+}
+
+// Check 返回断言失败的原因，通过时返回 nil
+func (a *Assert) Check(out *Outcome) error {
+	var found bool
+	var text, what string
+	switch a.Kind {
+	case ASSERT_COMPLETE:
+		what = "complete"
+		found, text = out.Complete != "", out.Complete
+	case ASSERT_FILE:
+		what = "file " + a.Path
+		data, err := os.ReadFile(filepath.Join(out.Home, a.Path))
+		found, text = err == nil, string(data)
+	case ASSERT_TOOL:
+		what = "tool " + a.Tool
+		bodies := []string{}
+		for _, call := range out.Tools {
+			if call.Name == a.Tool {
+				bodies = append(bodies, call.Body)
+			}
+		}
+		found, text = len(bodies) > 0, strings.Join(bodies, "\n")
+	default:
+		return fmt.Errorf("unknown assert kind %q", a.Kind)
+	}
+
+	matched, err := a.match(text)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !a.Not && !found:
+		return fmt.Errorf("%s not found", what)
+	case !a.Not && !matched:
+		return fmt.Errorf("%s not match %s", what, a.expect())
+	case a.Not && found && matched:
+		if a.expect() == "" {
+			return fmt.Errorf("%s should not exist", what)
+		}
+		return fmt.Errorf("%s should not match %s", what, a.expect())
+	}
+	return nil
+}
+
+func (a *Assert) match(text string) (bool, error) {
+	if a.Contains != "" && !strings.Contains(text, a.Contains) {
+		return false, nil
+	}
+	if a.Regex != "" {
+		re, err := regexp.Compile(a.Regex)
+		if err != nil {
+			return false, fmt.Errorf("invalid regex %q: %w", a.Regex, err)
+		}
+		return re.MatchString(text), nil
+	}
+	return true, nil
+}
+
+func (a *Assert) expect() string {
+	parts := []string{}
+	if a.Contains != "" {
+		parts = append(parts, fmt.Sprintf("contains %q", a.Contains))
+	}
+	if a.Regex != "" {
+		parts = append(parts, fmt.Sprintf("regex %q", a.Regex))
+	}
+	return strings.Join(parts, " and ")
+}
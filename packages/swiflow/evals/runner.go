@@ -0,0 +1,221 @@
+package evals
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"swiflow/action"
+	"swiflow/agent"
+	"swiflow/entity"
+	"swiflow/model"
+	"swiflow/storage"
+	"swiflow/support"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+)
+
+type Runner struct {
+	manager *agent.Manager
+	store   storage.MyStore
+}
+
+func NewRunner(manager *agent.Manager, store storage.MyStore) *Runner {
+	return &Runner{manager: manager, store: store}
+}
+
+// Run 在每个目标上运行所有用例，结果按 run 保存
+func (r *Runner) Run(run string, suite *Suite) []*entity.EvalEntity {
+	results := []*entity.EvalEntity{}
+	for _, target := range suite.Targets {
+		worker, err := r.resolve(target)
+		for _, item := range suite.Cases {
+			result := &entity.EvalEntity{
+				Run: run, Suite: suite.Name,
+				Target: target.Name, Case: item.Name,
+				Bot: target.Bot, BotVer: target.Version,
+				Provider: target.Provider,
+			}
+			if err != nil {
+				result.Failures = []string{err.Error()}
+			} else {
+				r.runCase(result, worker, target, item)
+			}
+			if err := r.store.SaveEval(result); err != nil {
+				log.Println("[EVAL] save result error", err)
+			}
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// resolve 按目标配置得到一份独立的 worker，不影响正在使用的 bot
+func (r *Runner) resolve(target *Target) (*agent.Worker, error) {
+	worker, err := r.manager.GetWorker(target.Bot)
+	if err != nil || worker == nil {
+		return nil, fmt.Errorf("bot %s not found", target.Bot)
+	}
+	worker = convertor.DeepClone(worker)
+	if target.Version > 0 {
+		list, err := r.store.LoadVersion(
+			"bot = ? AND version = ?", target.Bot, target.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		var found *entity.BotVersion
+		for _, item := range list {
+			if item.Bot == target.Bot && item.Version == target.Version {
+				found = item
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("version %d of %s not found", target.Version, target.Bot)
+		}
+		found.Apply(worker)
+	}
+	if target.Provider != "" && target.Provider != PROVIDER_SCRIPTED {
+		worker.Provider = target.Provider
+	}
+	return worker, nil
+}
+
+func (r *Runner) runCase(result *entity.EvalEntity, worker *agent.Worker, target *Target, item *Case) {
+	home, err := os.MkdirTemp("", "swiflow-eval-*")
+	if err != nil {
+		result.Failures = []string{err.Error()}
+		return
+	}
+	defer os.RemoveAll(home)
+	for path, content := range item.Fixture {
+		file := filepath.Join(home, path)
+		os.MkdirAll(filepath.Dir(file), 0755)
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			result.Failures = []string{err.Error()}
+			return
+		}
+	}
+
+	uuid, _ := support.UniqueID()
+	task := &agent.MyTask{
+		UUID: "eval-" + uuid, BotId: worker.UUID,
+		Name: support.Substring(item.Input, 80), Home: home,
+		SessID: result.Run, Source: "eval",
+	}
+	if err := r.store.InitTask(task); err != nil {
+		result.Failures = []string{err.Error()}
+		return
+	}
+	result.Task = task.UUID
+
+	// 每个用例使用独立的 worker 和记忆、待办存储，用例之间互不影响
+	worker = convertor.DeepClone(worker)
+	executor := r.manager.GetExecutor(task, worker)
+	executor.UseStore(newIsolated(r.store))
+	if target.Provider == PROVIDER_SCRIPTED {
+		executor.UseModel(NewScriptedModel(item.Script))
+	}
+	if executor.Model() == nil {
+		result.Failures = []string{"no model avalible"}
+		return
+	}
+
+	start := time.Now()
+	result.State = executor.Run(&action.UserInput{Content: item.Input})
+	result.Latency = time.Since(start).Milliseconds()
+
+	msgs, _ := r.store.LoadMsg(task)
+	out := r.outcome(result, msgs)
+	out.Home = home
+	if counter, ok := executor.Model().(model.UsageCounter); ok {
+		result.Tokens = counter.Usage().TotalTokens
+	}
+	if result.Tokens == 0 {
+		// 模型未返回用量时按消息长度估算
+		for _, msg := range msgs {
+			result.Tokens += estimate(msg.Request) + estimate(msg.Respond)
+		}
+	}
+
+	result.Failures = []string{}
+	for _, assert := range item.Asserts {
+		if err := assert.Check(out); err != nil {
+			result.Failures = append(result.Failures, err.Error())
+		}
+	}
+	result.Passed = len(result.Failures) == 0
+}
+
+// outcome 从任务消息中解析出工具调用和 complete 内容
+func (r *Runner) outcome(result *entity.EvalEntity, msgs []*agent.MyMsg) *Outcome {
+	out := &Outcome{State: result.State}
+	for _, msg := range msgs {
+		if msg.Respond == "" {
+			continue
+		}
+		result.Turns += 1
+		for _, tool := range action.Parse(msg.Respond).UseTools {
+			body := support.ToXML(tool, nil)
+			name, _, _ := strings.Cut(body, "\n")
+			out.Tools = append(out.Tools, Call{
+				Name: strings.Trim(name, "<>"), Body: body,
+			})
+			if act, ok := tool.(*action.Complete); ok {
+				out.Complete = act.Content
+			}
+		}
+	}
+	return out
+}
+
+// Summary 单个目标的汇总结果
+type Summary struct {
+	Target   string `json:"target"`
+	Bot      string `json:"bot"`
+	BotVer   int    `json:"botver"`
+	Provider string `json:"provider"`
+
+	Cases    int     `json:"cases"`
+	Passed   int     `json:"passed"`
+	PassRate float64 `json:"passRate"`
+
+	AvgTurns   float64 `json:"avgTurns"`
+	AvgTokens  int     `json:"avgTokens"`
+	AvgLatency int64   `json:"avgLatency"`
+}
+
+// Summarize 按目标汇总通过率、平均 token 与耗时
+func Summarize(results []*entity.EvalEntity) []*Summary {
+	summary := []*Summary{}
+	index := map[string]*Summary{}
+	turns := map[string]int{}
+	for _, item := range results {
+		data, ok := index[item.Target]
+		if !ok {
+			data = &Summary{
+				Target: item.Target, Bot: item.Bot,
+				BotVer: item.BotVer, Provider: item.Provider,
+			}
+			index[item.Target] = data
+			summary = append(summary, data)
+		}
+		data.Cases += 1
+		if item.Passed {
+			data.Passed += 1
+		}
+		turns[item.Target] += item.Turns
+		data.AvgTokens += item.Tokens
+		data.AvgLatency += item.Latency
+	}
+	for _, data := range summary {
+		cases := data.Cases
+		data.PassRate = float64(data.Passed) / float64(cases)
+		data.AvgTurns = float64(turns[data.Target]) / float64(cases)
+		data.AvgTokens = data.AvgTokens / cases
+		data.AvgLatency = data.AvgLatency / int64(cases)
+	}
+	return summary
+}
@@ -0,0 +1,90 @@
+package evals
+
+import (
+	"swiflow/agent"
+	"swiflow/entity"
+	"swiflow/storage"
+	"testing"
+)
+
+func TestRunner_Scripted(t *testing.T) {
+	store := storage.NewMockStore()
+	store.SetBots([]*entity.BotEntity{{
+		UUID: "tester", Name: "tester",
+		Type: agent.AGENT_BASIC, SysPrompt: "you are a tester",
+	}})
+	manager := agent.NewManager()
+	if err := manager.Initial(store); err != nil {
+		t.Fatalf("初始化失败: %v", err)
+	}
+
+	suite := &Suite{
+		Name: "smoke",
+		Cases: []*Case{{
+			Name:    "write",
+			Input:   "write hello to out.txt",
+			Fixture: map[string]string{"docs/readme.md": "fixture"},
+			Script: []string{
+				"<memorize>\n<subject>eval</subject>\n<content>eval only</content>\n</memorize>\n" +
+					"<file-put-content>\n<path>out.txt</path>\n<data>hello eval</data>\n</file-put-content>",
+				"<complete>\n<content>done: out.txt</content>\n</complete>",
+			},
+			Asserts: []*Assert{
+				{Kind: ASSERT_TOOL, Tool: "file-put-content", Contains: "out.txt"},
+				{Kind: ASSERT_FILE, Path: "out.txt", Contains: "hello eval"},
+				{Kind: ASSERT_FILE, Path: "docs/readme.md", Regex: "^fixture$"},
+				{Kind: ASSERT_COMPLETE, Contains: "done"},
+				{Kind: ASSERT_COMPLETE, Contains: "error", Not: true},
+				{Kind: ASSERT_TOOL, Tool: "execute-command", Not: true},
+			},
+		}},
+		Targets: []*Target{
+			{Name: "current", Bot: "tester", Provider: PROVIDER_SCRIPTED},
+			{Name: "missing", Bot: "tester", Version: 9, Provider: PROVIDER_SCRIPTED},
+		},
+	}
+	if err := suite.Validate(); err != nil {
+		t.Fatalf("用例校验失败: %v", err)
+	}
+
+	results := NewRunner(manager, store).Run("run-1", suite)
+	if len(results) != 2 {
+		t.Fatalf("结果数量应为 2，实际 %d", len(results))
+	}
+	if first := results[0]; !first.Passed {
+		t.Errorf("脚本用例应通过: %v", first.Failures)
+	} else if first.Turns != 2 || first.Tokens == 0 {
+		t.Errorf("轮次或 token 统计错误: turns=%d tokens=%d", first.Turns, first.Tokens)
+	}
+	if results[1].Passed || len(results[1].Failures) == 0 {
+		t.Errorf("不存在的版本应失败")
+	}
+	// 用例中的记忆不写入正在使用的 bot
+	if mems, _ := store.LoadMem("bot = ?", "tester"); len(mems) != 0 {
+		t.Errorf("评测不应写入 bot 的记忆: %d", len(mems))
+	}
+	if worker, _ := manager.GetWorker("tester"); worker == nil || len(worker.Memories) != 0 {
+		t.Errorf("评测不应修改 bot 的记忆缓存")
+	}
+	if saved, _ := store.LoadEval(); len(saved) != 2 {
+		t.Errorf("结果未保存: %d", len(saved))
+	}
+
+	summary := Summarize(results)
+	if len(summary) != 2 || summary[0].PassRate != 1 || summary[1].PassRate != 0 {
+		t.Errorf("汇总错误: %+v", summary)
+	}
+}
+
+func TestSuite_Validate(t *testing.T) {
+	cases := map[string]*Suite{
+		"无用例":  {Targets: []*Target{{Bot: "a"}}},
+		"越界路径": {Cases: []*Case{{Input: "x", Fixture: map[string]string{"../a": ""}}}, Targets: []*Target{{Bot: "a"}}},
+		"未知断言": {Cases: []*Case{{Input: "x", Asserts: []*Assert{{Kind: "what"}}}}, Targets: []*Target{{Bot: "a"}}},
+	}
+	for name, suite := range cases {
+		if suite.Validate() == nil {
+			t.Errorf("%s 应校验失败", name)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package evals
+
+import (
+	"fmt"
+	"swiflow/model"
+	"sync"
+)
+
+// ScriptedModel 按顺序返回预设回复，用于不依赖真实模型的评测
+type ScriptedModel struct {
+	mu      sync.Mutex
+	index   int
+	replies []string
+	usage   model.Usage
+}
+
+func NewScriptedModel(replies []string) *ScriptedModel {
+	return &ScriptedModel{replies: replies}
+}
+
+func (m *ScriptedModel) Cancel(string) error {
+	return nil
+}
+
+func (m *ScriptedModel) Stream(group string, msgs []model.Message, handle model.Handle) error {
+	choices, err := m.Respond(group, msgs)
+	if err != nil {
+		return err
+	}
+	handle(choices)
+	return nil
+}
+
+func (m *ScriptedModel) Respond(group string, msgs []model.Message) ([]model.Choice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.index >= len(m.replies) {
+		return nil, fmt.Errorf("script exhausted after %d replies", len(m.replies))
+	}
+	reply := m.replies[m.index]
+	m.index += 1
+
+	// 按 4 个字符 1 个 token 估算
+	prompt := 0
+	for _, msg := range msgs {
+		prompt += estimate(msg.Content)
+	}
+	m.usage.PromptTokens += prompt
+	m.usage.CompletionTokens += estimate(reply)
+	m.usage.TotalTokens += prompt + estimate(reply)
+
+	choice := model.Choice{}
+	choice.Message.Role = "assistant"
+	choice.Message.Content = reply
+	return []model.Choice{choice}, nil
+}
+
+func (m *ScriptedModel) Usage() model.Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage
+}
+
+func estimate(text string) int {
+	return (len(text) + 3) / 4
+}
@@ -0,0 +1,38 @@
+package evals
+
+import "swiflow/storage"
+
+// isolated 任务与消息写入真实存储，记忆与待办写入内存，
+// 用例中的 memorize、wait-todo 不影响正在使用的 bot 和后续用例
+type isolated struct {
+	storage.MyStore
+	mock *storage.MockStore
+}
+
+func newIsolated(store storage.MyStore) *isolated {
+	return &isolated{MyStore: store, mock: storage.NewMockStore()}
+}
+
+func (s *isolated) FindMem(mem *storage.MemEntity) error {
+	return s.mock.FindMem(mem)
+}
+
+func (s *isolated) SaveMem(mem *storage.MemEntity) error {
+	return s.mock.SaveMem(mem)
+}
+
+func (s *isolated) LoadMem(query ...any) ([]*storage.MemEntity, error) {
+	return s.mock.LoadMem(query...)
+}
+
+func (s *isolated) FindTodo(todo *storage.TodoEntity) error {
+	return s.mock.FindTodo(todo)
+}
+
+func (s *isolated) SaveTodo(todo *storage.TodoEntity) error {
+	return s.mock.SaveTodo(todo)
+}
+
+func (s *isolated) LoadTodo(query ...any) ([]*storage.TodoEntity, error) {
+	return s.mock.LoadTodo(query...)
+}
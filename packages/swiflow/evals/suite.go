@@ -0,0 +1,113 @@
+package evals
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ASSERT_COMPLETE = "complete" // 检查 complete 的内容
+	ASSERT_FILE     = "file"     // 检查工作区中生成的文件
+	ASSERT_TOOL     = "tool"     // 检查调用过的工具
+
+	// 使用用例中的脚本回复代替真实模型
+	PROVIDER_SCRIPTED = "scripted"
+)
+
+// Suite 一组评测用例，依次在每个目标配置上运行
+type Suite struct {
+	Name    string    `json:"name"`
+	Cases   []*Case   `json:"cases"`
+	Targets []*Target `json:"targets"`
+}
+
+// Case 单个用例：输入、工作区初始文件与断言
+type Case struct {
+	Name  string `json:"name"`
+	Input string `json:"input"`
+	// 相对路径 => 文件内容，运行前写入临时工作区
+	Fixture map[string]string `json:"fixture"`
+	Asserts []*Assert         `json:"asserts"`
+	// scripted 模型依次返回的回复
+	Script []string `json:"script"`
+}
+
+// Target 参与比较的 bot 配置
+type Target struct {
+	Name string `json:"name"`
+	Bot  string `json:"bot"`
+	// bot 的历史版本，0 表示当前定义
+	Version int `json:"version"`
+	// 覆盖 bot 的 provider，scripted 表示使用脚本回复
+	Provider string `json:"provider"`
+}
+
+// Assert 对一次运行结果的断言
+type Assert struct {
+	Kind     string `json:"kind"`
+	Path     string `json:"path"`
+	Tool     string `json:"tool"`
+	Contains string `json:"contains"`
+	Regex    string `json:"regex"`
+	// 取反：内容不应出现
+	Not bool `json:"not"`
+}
+
+func (s *Suite) Validate() error {
+	if len(s.Cases) == 0 {
+		return fmt.Errorf("suite has no cases")
+	}
+	if len(s.Targets) == 0 {
+		return fmt.Errorf("suite has no targets")
+	}
+	for i, target := range s.Targets {
+		if target.Bot == "" {
+			return fmt.Errorf("target %d: empty bot", i)
+		}
+		if target.Name == "" {
+			target.Name = fmt.Sprintf("%s@%d", target.Bot, target.Version)
+		}
+	}
+	for i, item := range s.Cases {
+		if strings.TrimSpace(item.Input) == "" {
+			return fmt.Errorf("case %d: empty input", i)
+		}
+		if item.Name == "" {
+			item.Name = fmt.Sprintf("case-%d", i+1)
+		}
+		for path := range item.Fixture {
+			if !isLocal(path) {
+				return fmt.Errorf("case %s: invalid fixture %s", item.Name, path)
+			}
+		}
+		for _, assert := range item.Asserts {
+			if err := assert.Validate(); err != nil {
+				return fmt.Errorf("case %s: %w", item.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (a *Assert) Validate() error {
+	switch a.Kind {
+	case ASSERT_COMPLETE:
+	case ASSERT_FILE:
+		if !isLocal(a.Path) {
+			return fmt.Errorf("invalid file path %q", a.Path)
+		}
+	case ASSERT_TOOL:
+		if a.Tool == "" {
+			return fmt.Errorf("tool assert without tool")
+		}
+	default:
+		return fmt.Errorf("unknown assert kind %q", a.Kind)
+	}
+	return nil
+}
+
+// isLocal 路径必须位于工作区内
+func isLocal(path string) bool {
+	return path != "" && filepath.IsLocal(path)
+}
@@ -0,0 +1,379 @@
+package httpd
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"swiflow/a2a"
+	"swiflow/action"
+	"swiflow/agent"
+	"swiflow/config"
+	"swiflow/storage"
+	"swiflow/support"
+	"sync"
+)
+
+// A2AHandler 以 A2A 协议对外提供 agent，供其他实例作为远程 worker 调用
+// 路由：/api/a2a/{bot}，描述文件：/api/a2a/{bot}/.well-known/agent.json
+type A2AHandler struct {
+	manager *agent.Manager
+
+	lock sync.Mutex
+	runs map[string]*a2aRun
+}
+
+// a2aRun 正在执行的任务，事件按任务转发给请求方
+type a2aRun struct {
+	task      *agent.MyTask
+	events    chan *a2a.Event
+	contextId string
+	lastErr   string
+}
+
+func NewA2AHandler(m *agent.Manager) *A2AHandler {
+	h := &A2AHandler{manager: m, runs: map[string]*a2aRun{}}
+	support.Once("stream", h.onStream)
+	support.Once("errors", h.onErrors)
+	support.Once("control", h.onControl)
+	support.Once("complete", h.onComplete)
+	return h
+}
+
+func (h *A2AHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		JsonResp(w, fmt.Errorf("unauthorized"))
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/api/a2a/")
+	uuid, suffix, _ := strings.Cut(path, "/")
+	worker, err := h.manager.GetWorker(support.Or(uuid, config.GetStr("USE_WORKER", "")))
+	if err != nil || worker == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method == http.MethodGet || suffix != "" {
+		JsonResp(w, h.agentCard(r, worker))
+		return
+	}
+
+	var req a2a.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, nil, -32700, "parse error")
+		return
+	}
+	var params struct {
+		Id      string       `json:"id"`
+		Message *a2a.Message `json:"message"`
+	}
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			h.writeError(w, req.Id, -32602, "invalid params")
+			return
+		}
+	}
+	switch req.Method {
+	case a2a.METHOD_SEND, a2a.METHOD_STREAM:
+		if params.Message == nil || params.Message.Text() == "" {
+			h.writeError(w, req.Id, -32602, "message is required")
+			return
+		}
+		run, err := h.startTask(params.Message, worker)
+		if err != nil {
+			h.writeError(w, req.Id, -32603, err.Error())
+			return
+		}
+		if req.Method == a2a.METHOD_STREAM {
+			h.writeStream(w, r, req.Id, run)
+		} else {
+			h.waitTask(w, r, req.Id, run)
+		}
+	case a2a.METHOD_GET:
+		task := h.findTask(params.Id, worker)
+		if task == nil {
+			h.writeError(w, req.Id, -32001, "task not found")
+			return
+		}
+		h.writeResult(w, req.Id, &a2a.Event{
+			Kind: a2a.KIND_TASK, Id: task.UUID,
+			Status: &a2a.TaskStatus{State: toA2AState(task.State)},
+		})
+	case a2a.METHOD_CANCEL:
+		if h.findTask(params.Id, worker) == nil {
+			h.writeError(w, req.Id, -32001, "task not found")
+			return
+		}
+		executor, err := h.manager.FindExecutor(params.Id)
+		if err != nil || executor == nil {
+			h.writeError(w, req.Id, -32001, "task not found")
+			return
+		}
+		executor.Terminate()
+		h.writeResult(w, req.Id, &a2a.Event{
+			Kind: a2a.KIND_TASK, Id: params.Id,
+			Status: &a2a.TaskStatus{State: a2a.STATE_CANCELED},
+		})
+	default:
+		h.writeError(w, req.Id, -32601, "method not found")
+	}
+}
+
+// authorized 配置了 A2A_TOKEN 时校验 Bearer token，否则仅允许本机直连；
+// X-Forwarded-For 可伪造，经代理转发的请求必须使用 token
+func (h *A2AHandler) authorized(r *http.Request) bool {
+	token := config.GetStr("A2A_TOKEN", "")
+	if token == "" {
+		if r.Header.Get("X-Forwarded-For") != "" {
+			return false
+		}
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		ip := net.ParseIP(host)
+		return err == nil && ip != nil && ip.IsLoopback()
+	}
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(auth), []byte(token)) == 1
+}
+
+func (h *A2AHandler) agentCard(r *http.Request, worker *agent.Worker) *a2a.AgentCard {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return &a2a.AgentCard{
+		Name: worker.Name, Description: worker.Desc,
+		Url:          fmt.Sprintf("%s://%s/api/a2a/%s", scheme, r.Host, worker.UUID),
+		Version:      config.GetVersion(),
+		Capabilities: map[string]any{"streaming": true},
+		Skills: []map[string]any{{
+			"id": worker.UUID, "name": worker.Name,
+			"description": worker.Desc,
+		}},
+	}
+}
+
+// findTask 只返回由 A2A 创建且属于该 agent 的任务，本地任务对外不可见
+func (h *A2AHandler) findTask(tid string, worker *agent.Worker) *agent.MyTask {
+	if run := h.getRun(tid); run != nil && run.task.BotId == worker.UUID {
+		return run.task
+	}
+	task, err := h.manager.QueryTask(tid)
+	if err != nil || task == nil {
+		return nil
+	}
+	if task.Source != "a2a" || task.BotId != worker.UUID {
+		log.Println("[A2A] reject task", tid, task.Source)
+		return nil
+	}
+	return task
+}
+
+func (h *A2AHandler) startTask(msg *a2a.Message, worker *agent.Worker) (*a2aRun, error) {
+	var err error
+	var task *agent.MyTask
+	if msg.TaskId != "" {
+		if task = h.findTask(msg.TaskId, worker); task == nil {
+			return nil, fmt.Errorf("task not found: %s", msg.TaskId)
+		}
+	} else {
+		task, err = h.manager.InitTask(msg.Text(), "")
+	}
+	if task == nil || err != nil {
+		return nil, fmt.Errorf("init task error: %v", err)
+	}
+	task.BotId, task.Source = worker.UUID, "a2a"
+	if worker.Home == "" {
+		task.Home = config.CurrentHome()
+	}
+	if store, _ := storage.GetStorage(); store != nil {
+		store.SaveTask(task)
+	}
+
+	run := &a2aRun{
+		task: task, contextId: support.Or(msg.ContextId, task.UUID),
+		events: make(chan *a2a.Event, 256),
+	}
+	h.lock.Lock()
+	h.runs[task.UUID] = run
+	h.lock.Unlock()
+
+	log.Println("[A2A] start task", task.UUID, worker.UUID)
+	input := &action.UserInput{Content: msg.Text()}
+	go h.manager.Start(input, task, worker)
+	return run, nil
+}
+
+// waitTask message/send 等待任务结束后返回
+func (h *A2AHandler) waitTask(w http.ResponseWriter, r *http.Request, id any, run *a2aRun) {
+	defer h.finish(run)
+	result := run.toTask(a2a.STATE_SUBMITTED)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-run.events:
+			if event.Kind == a2a.KIND_ARTIFACT {
+				result.Artifacts = append(result.Artifacts, *event.Artifact)
+			}
+			if event.Status != nil {
+				result.Status = event.Status
+			}
+			if event.IsFinal() {
+				h.writeResult(w, id, result)
+				return
+			}
+		}
+	}
+}
+
+// writeStream message/stream 以 SSE 推送任务事件
+func (h *A2AHandler) writeStream(w http.ResponseWriter, r *http.Request, id any, run *a2aRun) {
+	defer h.finish(run)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := w.(http.Flusher)
+	write := func(event *a2a.Event) {
+		data, _ := json.Marshal(&a2a.Response{
+			JsonRPC: "2.0", Id: id, Result: event,
+		})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	write(run.toTask(a2a.STATE_SUBMITTED))
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-run.events:
+			write(event)
+			if event.IsFinal() {
+				return
+			}
+		}
+	}
+}
+
+func (h *A2AHandler) writeResult(w http.ResponseWriter, id any, result any) {
+	JsonResp(w, &a2a.Response{JsonRPC: "2.0", Id: id, Result: result})
+}
+
+func (h *A2AHandler) writeError(w http.ResponseWriter, id any, code int, msg string) {
+	JsonResp(w, &a2a.Response{
+		JsonRPC: "2.0", Id: id,
+		Error: &a2a.RpcError{Code: code, Message: msg},
+	})
+}
+
+func (h *A2AHandler) getRun(tid string) *a2aRun {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.runs[tid]
+}
+
+func (h *A2AHandler) finish(run *a2aRun) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	delete(h.runs, run.task.UUID)
+}
+
+func (h *A2AHandler) onStream(tid string, data any) {
+	run := h.getRun(tid)
+	if run == nil {
+		return
+	}
+	var stream struct {
+		Idx uint32 `json:"idx"`
+		Str string `json:"str"`
+	}
+	// 第一条是消息头，不转发
+	json.Unmarshal([]byte(support.ToJson(data)), &stream)
+	if stream.Idx == 0 || stream.Str == "" {
+		return
+	}
+	run.push(run.toStatus(a2a.STATE_WORKING, stream.Str, false))
+}
+
+func (h *A2AHandler) onErrors(tid string, data any) {
+	if run := h.getRun(tid); run != nil {
+		run.lastErr = fmt.Sprint(data)
+	}
+}
+
+func (h *A2AHandler) onComplete(tid string, data any) {
+	run := h.getRun(tid)
+	act, _ := data.(*action.Complete)
+	if run == nil || act == nil {
+		return
+	}
+	run.push(&a2a.Event{
+		Kind: a2a.KIND_ARTIFACT, TaskId: tid, ContextId: run.contextId,
+		Artifact: &a2a.Artifact{
+			ArtifactId: tid, Name: "result",
+			Parts: []a2a.Part{{Kind: "text", Text: act.Content}},
+		},
+	})
+	run.push(run.toStatus(a2a.STATE_COMPLETED, "", true))
+}
+
+// onControl 失败、取消或需要人工介入时结束流
+func (h *A2AHandler) onControl(tid string, data any) {
+	run := h.getRun(tid)
+	state, _ := data.(string)
+	if run == nil {
+		return
+	}
+	switch state {
+	case agent.STATE_FAILED, agent.STATE_CANCELED, agent.STATE_SEEK_HELP:
+		run.push(run.toStatus(toA2AState(state), run.lastErr, true))
+	}
+}
+
+func (run *a2aRun) push(event *a2a.Event) {
+	select {
+	case run.events <- event:
+	default:
+		log.Println("[A2A] drop event", run.task.UUID, event.Kind)
+	}
+}
+
+func (run *a2aRun) toTask(state string) *a2a.Event {
+	return &a2a.Event{
+		Kind: a2a.KIND_TASK, Id: run.task.UUID, ContextId: run.contextId,
+		Status: &a2a.TaskStatus{State: state},
+	}
+}
+
+func (run *a2aRun) toStatus(state, text string, final bool) *a2a.Event {
+	status := &a2a.TaskStatus{State: state}
+	if text != "" {
+		status.Message = &a2a.Message{
+			Kind: a2a.KIND_MESSAGE, Role: "agent",
+			Parts: []a2a.Part{{Kind: "text", Text: text}},
+		}
+	}
+	return &a2a.Event{
+		Kind: a2a.KIND_STATUS, TaskId: run.task.UUID,
+		ContextId: run.contextId, Status: status, Final: final,
+	}
+}
+
+func toA2AState(state string) string {
+	switch state {
+	case agent.STATE_COMPLETED:
+		return a2a.STATE_COMPLETED
+	case agent.STATE_FAILED:
+		return a2a.STATE_FAILED
+	case agent.STATE_CANCELED:
+		return a2a.STATE_CANCELED
+	case agent.STATE_SEEK_HELP:
+		return a2a.STATE_INPUT_REQUIRED
+	case agent.STATE_RUNNING, agent.STATE_WAITING:
+		return a2a.STATE_WORKING
+	}
+	return a2a.STATE_UNKNOWN
+}
@@ -339,7 +339,7 @@ func (h *HttpHandler) Setting(w http.ResponseWriter, r *http.Request) {
 		if err := h.service.SaveUseModel(cfg); err == nil {
 			h.service.SaveProvider(cfg)
 			h.manager.InitConfig()
-			JsonResp(w, cfg.ToMap())
+			JsonResp(w, cfg.Redacted())
 		} else {
 			JsonResp(w, err)
 		}
@@ -349,7 +349,7 @@ func (h *HttpHandler) Setting(w http.ResponseWriter, r *http.Request) {
 		cfg.Data, _ = data.(map[string]any)
 		if err := h.service.SaveProvider(cfg); err == nil {
 			h.manager.InitConfig()
-			JsonResp(w, cfg.ToMap())
+			JsonResp(w, cfg.Redacted())
 		} else {
 			JsonResp(w, err)
 		}
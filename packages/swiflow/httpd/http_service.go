@@ -21,6 +21,7 @@ import (
 	"swiflow/entity"
 	"swiflow/initial"
 	"swiflow/model"
+	"swiflow/secret"
 	"swiflow/storage"
 	"swiflow/support"
 	"sync"
@@ -320,7 +321,7 @@ func (h *HttpServie) LoadGlobal() map[string]any {
 		for _, item := range list {
 			switch item.Type {
 			case entity.KEY_LOGIN_USER:
-				result["login"] = item.Data
+				result["login"] = secret.Redact(item.Data)
 			case entity.KEY_APP_SETUP:
 				result["setup"] = item.Data
 			case entity.KEY_USE_WORKER:
@@ -410,6 +411,7 @@ func (h *HttpServie) SaveProvider(data *entity.CfgEntity) error {
 	if !support.Bool(data.Data) {
 		return fmt.Errorf("invalid data")
 	}
+	h.restoreProvider(data.Data)
 	// 同步更新 provider 配置
 	cfg := &entity.CfgEntity{Data: data.Data}
 	cfg.Name, _ = cfg.Data["provider"].(string)
@@ -447,19 +449,33 @@ func (h *HttpServie) LoadModelCfg() any {
 		for _, item := range list {
 			switch item.Type {
 			case entity.KEY_USE_MODEL:
-				result["useModel"] = item.Data
+				result["useModel"] = secret.Redact(item.Data)
 			case entity.KEY_PROVIDER:
-				models[item.Name] = item.Data
+				models[item.Name] = secret.Redact(item.Data)
 			}
 		}
 	}
 	return result
 }
 
+// restoreProvider 前端回传的掩码 apiKey 还原为已保存的值
+func (h *HttpServie) restoreProvider(data map[string]any) {
+	name, _ := data["provider"].(string)
+	if name == "" {
+		return
+	}
+	cfg := &entity.CfgEntity{
+		Name: name, Type: entity.KEY_PROVIDER,
+	}
+	_ = h.store.FindCfg(cfg)
+	secret.Restore(data, cfg.Data)
+}
+
 func (h *HttpServie) SaveUseModel(cfg *entity.CfgEntity) error {
 	if !support.Bool(cfg.Data) {
 		return fmt.Errorf("invalid")
 	}
+	h.restoreProvider(cfg.Data)
 	cfg.Name = entity.KEY_USE_MODEL
 	cfg.Type = entity.KEY_USE_MODEL
 	return h.store.SaveCfg(cfg)
@@ -510,6 +526,40 @@ func (h *HttpServie) SaveBot(bot *entity.BotEntity) error {
 	return h.store.SaveBot(bot)
 }
 
+// GetAuthor 当前登录用户，用于记录 bot 版本的作者
+func (h *HttpServie) GetAuthor() string {
+	cfg := &entity.CfgEntity{
+		Name: entity.KEY_LOGIN_USER,
+		Type: entity.KEY_LOGIN_USER,
+	}
+	if err := h.store.FindCfg(cfg); err == nil {
+		for _, key := range []string{"username", "email"} {
+			if name, _ := cfg.Data[key].(string); name != "" {
+				return name
+			}
+		}
+	}
+	return "local"
+}
+
+// FindVersion 查询 bot 的指定版本，version 为 0 时返回最新版本
+func (h *HttpServie) FindVersion(uuid string, version int) (*entity.BotVersion, error) {
+	query := []any{"bot = ?", uuid}
+	if version > 0 {
+		query = []any{"bot = ? AND version = ?", uuid, version}
+	}
+	list, err := h.store.LoadVersion(query...)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range list {
+		if item.Bot == uuid && (version == 0 || item.Version == version) {
+			return item, nil
+		}
+	}
+	return nil, fmt.Errorf("version %d of %s not found", version, uuid)
+}
+
 func (h *HttpServie) UseBot(bot *entity.BotEntity) error {
 	cfg := &entity.CfgEntity{
 		Name: entity.KEY_USE_WORKER,
@@ -520,11 +570,93 @@ func (h *HttpServie) UseBot(bot *entity.BotEntity) error {
 	return h.store.SaveCfg(cfg)
 }
 
-func (h *HttpServie) LoadMem() []*entity.MemEntity {
-	list, _ := h.store.LoadMem() // Call without parameters to maintain existing behavior
+func (h *HttpServie) LoadMem(query ...any) []*entity.MemEntity {
+	list, _ := h.store.LoadMem(query...)
 	return list
 }
 
+// MemQuery 按 scope、target、bot 过滤记忆，参数为空时不过滤
+func (h *HttpServie) MemQuery(scope, target, bot string) []any {
+	conds, args := []string{}, []any{}
+	switch scope {
+	case "":
+	case entity.SCOPE_BOT: // 旧数据没有 scope
+		conds = append(conds, "(scope = ? OR scope = '' OR scope IS NULL)")
+		args = append(args, scope)
+	default:
+		conds = append(conds, "scope = ?")
+		args = append(args, scope)
+	}
+	if target != "" {
+		conds = append(conds, "target = ?")
+		args = append(args, target)
+	}
+	if bot != "" {
+		conds = append(conds, "bot = ?")
+		args = append(args, bot)
+	}
+	if len(conds) == 0 {
+		return nil
+	}
+	return append([]any{strings.Join(conds, " AND ")}, args...)
+}
+
+// ImportMem 导入 bot 的记忆
+// overwrite 替换 bot 已有的记忆，merge 跳过范围、主题和内容都相同的记忆
+func (h *HttpServie) ImportMem(bot string, mems []*entity.MemEntity, mode string) (map[string]int, error) {
+	result := map[string]int{"created": 0, "skipped": 0, "removed": 0}
+	list, err := h.store.LoadMem("bot = ?", bot)
+	if err != nil {
+		return nil, err
+	}
+	exists, olds := map[string]bool{}, []*entity.MemEntity{}
+	for _, mem := range list {
+		if mem.Bot != bot {
+			continue
+		}
+		if mode == "overwrite" {
+			olds = append(olds, mem)
+			continue
+		}
+		exists[memKey(mem)] = true
+	}
+	// 先写入新记忆，全部成功后再删除旧记忆，中途失败时撤销已写入的，保留原有记忆
+	created := []*entity.MemEntity{}
+	for _, mem := range mems {
+		mem.ID, mem.Bot = 0, bot
+		if exists[memKey(mem)] {
+			result["skipped"] += 1
+			continue
+		}
+		if err := h.store.SaveMem(mem); err != nil {
+			for _, item := range created {
+				item.DeletedAt.Time = time.Now()
+				h.store.SaveMem(item)
+			}
+			return nil, err
+		}
+		created = append(created, mem)
+		exists[memKey(mem)] = true
+		result["created"] += 1
+	}
+	for _, mem := range olds {
+		mem.DeletedAt.Time = time.Now()
+		if err := h.store.SaveMem(mem); err != nil {
+			return result, err
+		}
+		result["removed"] += 1
+	}
+	return result, nil
+}
+
+func memKey(mem *entity.MemEntity) string {
+	return strings.Join([]string{
+		mem.GetScope(), mem.Target,
+		strings.TrimSpace(mem.Subject),
+		strings.TrimSpace(mem.Content),
+	}, "\x00")
+}
+
 func (h *HttpServie) FindMem(id uint) *entity.MemEntity {
 	mem := &entity.MemEntity{ID: id}
 	if h.store.FindMem(mem) == nil {
@@ -550,6 +682,38 @@ func (h *HttpServie) LoadMsg(task *entity.TaskEntity, msgid string) *storage.Msg
 	return nil
 }
 
+// LoadSecrets 列出命名密钥，值已解密，返回前需脱敏
+func (h *HttpServie) LoadSecrets() ([]*entity.CfgEntity, error) {
+	query := storage.NewQuery("name", 0)
+	query.Where("type", storage.OP_EQ, entity.KEY_SECRET)
+	return h.store.LoadCfg(query)
+}
+
+func (h *HttpServie) SaveSecret(name, value string) error {
+	if !secret.ValidName(name) {
+		return fmt.Errorf("invalid secret name: %s", name)
+	}
+	if value == "" || value == secret.MASK || secret.IsRef(value) {
+		return fmt.Errorf("invalid secret value")
+	}
+	cfg := &entity.CfgEntity{
+		Name: name, Type: entity.KEY_SECRET,
+		Data: map[string]any{"value": value},
+	}
+	return h.store.SaveCfg(cfg)
+}
+
+func (h *HttpServie) DeleteSecret(name string) error {
+	cfg := &entity.CfgEntity{
+		Name: name, Type: entity.KEY_SECRET,
+	}
+	if err := h.store.FindCfg(cfg); err != nil {
+		return fmt.Errorf("secret not found: %s", name)
+	}
+	cfg.DeletedAt.Time = time.Now()
+	return h.store.SaveCfg(cfg)
+}
+
 func (h *HttpServie) LoadCache(key string) map[string]any {
 	list, err := h.store.LoadCfg() // Call without parameters to maintain existing behavior
 	if err != nil || len(list) == 0 {
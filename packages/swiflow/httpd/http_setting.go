@@ -1,22 +1,28 @@
 package httpd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"net/http"
 	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"swiflow/a2a"
 	"swiflow/action"
 	"swiflow/agent"
 	"swiflow/amcp"
+	"swiflow/backup"
 	"swiflow/builtin"
 	"swiflow/config"
 	"swiflow/entity"
+	"swiflow/evals"
 	"swiflow/model"
+	"swiflow/secret"
 	"swiflow/storage"
 	"swiflow/support"
 	"time"
@@ -38,25 +44,39 @@ func NewSettingHandle(m *agent.Manager) *SettingHandler {
 	return &SettingHandler{s, m}
 }
 
+// GetTasks 分页列出顶层任务，下一页游标见 X-Next-Cursor
 func (h *SettingHandler) GetTasks(w http.ResponseWriter, r *http.Request) {
 	tasks := []map[string]any{}
 	store, _ := storage.GetStorage()
-	query := "(`group`='' or `group`=`uuid`)"
-	if config.Get("USE_SUBAGENT") != "yes" {
-		// filter task not by leader
-		query = "(`group`='' or `group`!=`uuid`)"
+	query := listQuery(r, "-id", 100, "state", "botid")
+	if name := r.URL.Query().Get("name"); name != "" {
+		query.Where("name", storage.OP_LIKE, "%"+name+"%")
 	}
-	if list, err := store.LoadTask(query); err == nil {
-		if len(list) == 0 {
-			list, _ = store.LoadTask()
-		}
-		for _, item := range list {
-			tasks = append(tasks, item.ToMap())
-		}
+	// 评测任务的目录在运行后即删除，只在评测报告中查看
+	query.Where("source", storage.OP_NE, "eval")
+	// filter task not by leader
+	group := storage.Filter{Field: "group", Op: storage.OP_NE, Value: storage.Column("uuid")}
+	if config.Get("USE_SUBAGENT") == "yes" {
+		group.Op = storage.OP_EQ
+	}
+	query.Any(storage.Filter{Field: "group", Op: storage.OP_EQ, Value: ""}, group)
+	list, err := store.LoadTask(query)
+	if err != nil {
+		JsonResp(w, err)
+		return
+	}
+	if len(list) == 0 && query.Cursor == "" {
+		query.Filters = query.Filters[:len(query.Filters)-1]
+		list, _ = store.LoadTask(query)
+	}
+	for _, item := range list {
+		tasks = append(tasks, item.ToMap())
 	}
+	w.Header().Set("X-Next-Cursor", query.Next)
 	JsonResp(w, tasks)
 }
 
+// GetMsgs 列出任务及其子任务的消息，可按 limit、cursor 分页
 func (h *SettingHandler) GetMsgs(w http.ResponseWriter, r *http.Request) {
 	uuid := r.URL.Query().Get("task")
 	store, _ := storage.GetStorage()
@@ -67,13 +87,32 @@ func (h *SettingHandler) GetMsgs(w http.ResponseWriter, r *http.Request) {
 	}
 	context := agent.Context{}
 	result := []*action.SuperAction{}
+	if len(tasks) == 0 {
+		JsonResp(w, result)
+		return
+	}
+
+	uuids := []string{}
 	for _, task := range tasks {
-		msgs, err := store.LoadMsg(task)
-		if err != nil || len(msgs) == 0 {
+		uuids = append(uuids, task.UUID)
+	}
+	query := listQuery(r, "id", 0)
+	query.Where("task_id", storage.OP_IN, uuids)
+	msgs, err := store.LoadMsg(nil, query)
+	if err != nil {
+		JsonResp(w, err)
+		return
+	}
+	groups := map[string][]*entity.MsgEntity{}
+	for _, msg := range msgs {
+		groups[msg.TaskId] = append(groups[msg.TaskId], msg)
+	}
+	for _, task := range tasks {
+		if len(groups[task.UUID]) == 0 {
 			continue
 		}
 
-		acts := context.ParseMsgs(msgs)
+		acts := context.ParseMsgs(groups[task.UUID])
 		for _, act := range acts {
 			if act.WorkerID == "" {
 				act.WorkerID = task.BotId
@@ -87,11 +126,58 @@ func (h *SettingHandler) GetMsgs(w http.ResponseWriter, r *http.Request) {
 	})
 
 	// SuperAction now has custom MarshalJSON method that uses ToMap format
+	w.Header().Set("X-Next-Cursor", query.Next)
 	if err := JsonResp(w, result); err != nil {
 		log.Println("[HTTP] resp error", err)
 	}
 }
 
+// listQuery 读取列表接口的 sort、limit、cursor 参数，fields 中的参数作为等值过滤
+func listQuery(r *http.Request, sort string, limit int, fields ...string) *storage.Query {
+	params := r.URL.Query()
+	query := storage.NewQuery(support.Or(params.Get("sort"), sort), limit)
+	if size, err := strconv.Atoi(params.Get("limit")); err == nil && size > 0 {
+		query.Limit = size
+	}
+	query.Cursor = params.Get("cursor")
+	for _, field := range fields {
+		if value := params.Get(field); value != "" {
+			query.Where(field, storage.OP_EQ, value)
+		}
+	}
+	return query
+}
+
+// Search 全文检索任务、消息和记忆，kind 以逗号分隔
+func (h *SettingHandler) Search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		JsonResp(w, fmt.Errorf("query required"))
+		return
+	}
+	kinds := []string{}
+	for _, kind := range strings.Split(r.URL.Query().Get("kind"), ",") {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			kinds = append(kinds, kind)
+		}
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	store, _ := storage.GetStorage()
+	hits, err := store.Search(query, kinds, limit)
+	if err != nil {
+		JsonResp(w, err)
+		return
+	}
+	result := []map[string]any{}
+	for _, hit := range hits {
+		result = append(result, hit.ToMap())
+	}
+	JsonResp(w, result)
+}
+
 func (h *SettingHandler) TaskSet(w http.ResponseWriter, r *http.Request) {
 	act := r.URL.Query().Get("act")
 	uuid := r.URL.Query().Get("uuid")
@@ -177,11 +263,75 @@ func (h *SettingHandler) BotSet(w http.ResponseWriter, r *http.Request) {
 	if uuid != "" && bot == nil {
 		http.NotFound(w, r)
 		return
+	} else if bot != nil {
+		bot.Author = h.service.GetAuthor()
 	}
 	switch act {
+	case "versions":
+		list, err := h.service.store.LoadVersion("bot = ?", uuid)
+		if err != nil {
+			JsonResp(w, err)
+			return
+		}
+		data := []map[string]any{}
+		for _, item := range list {
+			data = append(data, map[string]any{
+				"version": item.Version, "author": item.Author,
+				"time": item.CreatedAt.Format(time.DateTime),
+			})
+		}
+		JsonResp(w, data)
+		return
+	case "version":
+		ver, _ := strconv.Atoi(r.URL.Query().Get("version"))
+		if version, err := h.service.FindVersion(uuid, ver); err != nil {
+			JsonResp(w, err)
+		} else {
+			JsonResp(w, version.ToMap())
+		}
+		return
+	case "diff":
+		// to 为空时与当前版本对比
+		from, _ := strconv.Atoi(r.URL.Query().Get("from"))
+		to, _ := strconv.Atoi(r.URL.Query().Get("to"))
+		fromVer, err := h.service.FindVersion(uuid, from)
+		if err != nil {
+			JsonResp(w, err)
+			return
+		}
+		toVer, err := h.service.FindVersion(uuid, to)
+		if err != nil {
+			JsonResp(w, err)
+			return
+		}
+		JsonResp(w, entity.DiffVersion(fromVer, toVer))
+		return
+	case "rollback":
+		// 回滚会生成一个与目标版本内容相同的新版本
+		ver, _ := strconv.Atoi(r.URL.Query().Get("version"))
+		if ver <= 0 {
+			JsonResp(w, fmt.Errorf("version required"))
+			return
+		}
+		version, err := h.service.FindVersion(uuid, ver)
+		if err != nil {
+			JsonResp(w, err)
+			return
+		}
+		version.Apply(bot)
+		if err := h.service.SaveBot(bot); err != nil {
+			JsonResp(w, fmt.Errorf("rollback: %w", err))
+			return
+		}
 	case "get-bot":
 		// here need return usePrompt and sysPrompt
-		if err := JsonResp(w, bot); err != nil {
+		if bot == nil {
+			http.NotFound(w, r)
+			return
+		}
+		resp := *bot
+		resp.Remote = bot.Remote.Redacted()
+		if err := JsonResp(w, &resp); err != nil {
 			log.Println("resp error", err)
 		}
 		return
@@ -251,16 +401,36 @@ func (h *SettingHandler) BotSet(w http.ResponseWriter, r *http.Request) {
 			JsonResp(w, bot.ToMap())
 			return
 		}
+	case "remote-card":
+		if bot.Remote == nil || bot.Remote.Url == "" {
+			JsonResp(w, fmt.Errorf("no remote config of %s", bot.UUID))
+			return
+		}
+		remote := bot.Remote
+		client := a2a.NewClient(remote.Url, remote.Token, remote.Headers)
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		if card, err := client.Card(ctx); err != nil {
+			JsonResp(w, fmt.Errorf("get agent card: %w", err))
+		} else {
+			JsonResp(w, card)
+		}
+		return
 	case "set-bot":
 		if uuid == "" {
 			bot = new(entity.BotEntity)
+			bot.Author = h.service.GetAuthor()
 		}
+		// 复制一份再解析，提交的掩码还原为已保存的值
+		old := bot.Remote
+		bot.Remote = old.Clone()
 		data, _ := io.ReadAll(r.Body)
 		err := json.Unmarshal(data, bot)
 		if err != nil || bot.Name == "" {
 			JsonResp(w, fmt.Errorf("error input"))
 			return
 		}
+		bot.Remote.Restore(old)
 		if bot.UUID == "" {
 			uuid, _ := support.UniqueID(8)
 			bot.UUID = "bot-" + uuid
@@ -282,6 +452,12 @@ func (h *SettingHandler) NewMcp(w http.ResponseWriter, r *http.Request) {
 		JsonResp(w, err)
 		return
 	}
+	// 前端回传的掩码值还原为已保存的 env/header
+	for _, item := range service.ListServers() {
+		if item.UUID == server.UUID {
+			server.Restore(item)
+		}
+	}
 	switch act {
 	case "set-new":
 		server.Name = support.Or(server.Name, server.UUID)
@@ -295,7 +471,7 @@ func (h *SettingHandler) NewMcp(w http.ResponseWriter, r *http.Request) {
 		}
 		if err := service.ServerStatus(server); err == nil {
 			_ = service.EnableServer(server)
-			err = JsonResp(w, server)
+			err = JsonResp(w, server.Redacted())
 		} else {
 			err = JsonResp(w, err)
 		}
@@ -344,19 +520,91 @@ func (h *SettingHandler) GetMcp(w http.ResponseWriter, r *http.Request) {
 		builtinServer.Status.Enable = true
 		builtinServer.Status.Active = true
 		builtinServer.Status.McpTools = mcpTools
-		mcpList = append(prependList, mcpList...)
-		if err := JsonResp(w, mcpList); err != nil {
+		for _, item := range mcpList {
+			prependList = append(prependList, item.Redacted())
+		}
+		if err := JsonResp(w, prependList); err != nil {
 			log.Println("resp error", err)
 		}
 		return
 	case "servers":
-		w.Header().Set("Content-Type", "application/json")
-		path := config.GetWorkPath("servers.json")
-		data, _ := os.ReadFile(path)
-		_, _ = w.Write(data)
+		store, _ := storage.GetStorage()
+		service := amcp.GetMcpService(store)
+		registry := amcp.NewMcpRegistry()
+		if r.URL.Query().Get("sync") != "" {
+			if _, err := registry.Sync(); err != nil {
+				JsonResp(w, fmt.Errorf("sync: %v", err))
+				return
+			}
+		}
+		catalog, err := registry.Local()
+		if err != nil {
+			JsonResp(w, fmt.Errorf("no catalog: %v", err))
+			return
+		}
+		installed := service.ListServers()
+		if err := JsonResp(w, catalog.ToList(installed)); err != nil {
+			log.Println("resp error", err)
+		}
+	}
+}
+
+// ImportMcp 导入 Claude Desktop / Cursor / VS Code 的 MCP 配置
+func (h *SettingHandler) ImportMcp(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil || len(data) == 0 {
+		JsonResp(w, fmt.Errorf("empty config"))
+		return
+	}
+	store, _ := storage.GetStorage()
+	service := amcp.GetMcpService(store)
+	flag := r.URL.Query().Get("overwrite")
+	overwrite := slice.Contain([]string{"1", "true", "yes"}, flag)
+	report, err := service.ImportServers(data, overwrite)
+	if err != nil {
+		JsonResp(w, err)
+		return
+	}
+	if err := JsonResp(w, report); err != nil {
+		log.Println("import mcp error", err)
 	}
 }
 
+// McpAudit 查询 MCP 调用审计，可按 uuid/task/bot/tool 过滤
+func (h *SettingHandler) McpAudit(w http.ResponseWriter, r *http.Request) {
+	where, args := []string{}, []any{}
+	query := r.URL.Query()
+	for param, column := range map[string]string{
+		"uuid": "server", "task": "task", "bot": "bot", "tool": "tool",
+	} {
+		if val := query.Get(param); val != "" {
+			where = append(where, column+" = ?")
+			args = append(args, val)
+		}
+	}
+	if errs := query.Get("errors"); slice.Contain([]string{"1", "true"}, errs) {
+		where = append(where, "error <> ''")
+	}
+	store, _ := storage.GetStorage()
+	var err error
+	var list []*entity.AuditEntity
+	if len(where) == 0 {
+		list, err = store.LoadAudit()
+	} else {
+		cond := []any{strings.Join(where, " AND ")}
+		list, err = store.LoadAudit(append(cond, args...)...)
+	}
+	if err != nil {
+		JsonResp(w, err)
+		return
+	}
+	result := []map[string]any{}
+	for _, item := range list {
+		result = append(result, item.ToMap())
+	}
+	JsonResp(w, result)
+}
+
 func (h *SettingHandler) McpSet(w http.ResponseWriter, r *http.Request) {
 	act := r.URL.Query().Get("act")
 	list := []string{"set-new", "test-mcp"}
@@ -369,6 +617,14 @@ func (h *SettingHandler) McpSet(w http.ResponseWriter, r *http.Request) {
 		h.GetMcp(w, r)
 		return
 	}
+	if act == "import" {
+		h.ImportMcp(w, r)
+		return
+	}
+	if act == "audit" {
+		h.McpAudit(w, r)
+		return
+	}
 
 	var found *amcp.McpServer
 	store, _ := storage.GetStorage()
@@ -420,8 +676,8 @@ func (h *SettingHandler) McpSet(w http.ResponseWriter, r *http.Request) {
 		client := service.GetMcpClient(found)
 		args, _ := data.(map[string]any)
 		res, err := client.Execute(tool, args)
-		if err == nil && res != "" {
-			err = JsonResp(w, res)
+		if err == nil && res != nil {
+			err = JsonResp(w, res.String())
 			return
 		}
 		if e := JsonResp(w, err); e != nil {
@@ -453,10 +709,14 @@ func (h *SettingHandler) McpSet(w http.ResponseWriter, r *http.Request) {
 			log.Println("server disable error", e)
 		}
 	case "set-mcp":
+		saved := &amcp.McpServer{
+			Env: maps.Clone(found.Env), Headers: maps.Clone(found.Headers),
+		}
 		if err := h.service.ReadTo(r.Body, found); err != nil {
 			JsonResp(w, err)
 			return
 		} else {
+			found.Restore(saved)
 			service.ServerClose(found)
 			service.ClearDebugMsgs(found)
 		}
@@ -464,10 +724,71 @@ func (h *SettingHandler) McpSet(w http.ResponseWriter, r *http.Request) {
 			JsonResp(w, err)
 			return
 		}
-		if err := JsonResp(w, found); err != nil {
+		if err := JsonResp(w, found.Redacted()); err != nil {
 			_ = service.EnableServer(found)
 			log.Println("upsert server error", err)
 		}
+	case "oauth-login":
+		// 授权完成前请求会一直等待
+		auth := found.OAuth()
+		if _, err := auth.Token(r.Context()); err != nil {
+			JsonResp(w, fmt.Errorf("oauth: %v", err))
+			return
+		}
+		_ = service.ServerClose(found)
+		if err := service.ServerStatus(found); err == nil {
+			_ = service.EnableServer(found)
+			err = JsonResp(w, found.Status)
+		} else if e := JsonResp(w, err); e != nil {
+			log.Println("oauth login error", e)
+		}
+	case "oauth-logout":
+		_ = service.ServerClose(found)
+		if err := found.OAuth().Revoke(); err != nil {
+			JsonResp(w, err)
+			return
+		}
+		JsonResp(w, "success")
+	case "package":
+		pkg, err := found.Package()
+		if err != nil {
+			JsonResp(w, err)
+			return
+		}
+		JsonResp(w, map[string]any{
+			"package": pkg, "lock": amcp.LoadLock().Get(uuid),
+		})
+	case "upgrade", "pin", "unpin":
+		var err error
+		var entry *amcp.LockEntry
+		version := r.URL.Query().Get("version")
+		_ = service.ServerClose(found)
+		switch act {
+		case "upgrade":
+			entry, err = found.Upgrade(version)
+		case "pin":
+			entry, err = found.Pin(version)
+		case "unpin":
+			err = found.Unpin()
+		}
+		if err != nil {
+			JsonResp(w, err)
+			return
+		}
+		// 版本号可能已写入命令参数
+		if err = service.UpsertServer(found); err != nil {
+			JsonResp(w, err)
+			return
+		}
+		JsonResp(w, map[string]any{"server": found.Redacted(), "lock": entry})
+	case "uninstall":
+		_ = service.ServerClose(found)
+		_ = service.DisableServer(found)
+		if err := found.Uninstall(); err != nil {
+			JsonResp(w, err)
+			return
+		}
+		JsonResp(w, found)
 	case "del-mcp":
 		_ = service.ServerClose(found)
 		resp := service.RemoveServer(found)
@@ -569,7 +890,10 @@ func (h *SettingHandler) ToolSet(w http.ResponseWriter, r *http.Request) {
 func (h *SettingHandler) MemSet(w http.ResponseWriter, r *http.Request) {
 	act := r.URL.Query().Get("act")
 	if act == "get-mem" {
-		list := h.service.LoadMem()
+		query := r.URL.Query()
+		list := h.service.LoadMem(h.service.MemQuery(
+			query.Get("scope"), query.Get("target"), query.Get("bot"),
+		)...)
 		mems := []map[string]any{}
 		for _, r := range list {
 			mems = append(mems, r.ToMap())
@@ -583,6 +907,12 @@ func (h *SettingHandler) MemSet(w http.ResponseWriter, r *http.Request) {
 	uuid := r.URL.Query().Get("uuid")
 
 	switch act {
+	case "export":
+		h.exportMem(w, r)
+		return
+	case "import":
+		h.importMem(w, r)
+		return
 	case "set-mem":
 		data, _ := io.ReadAll(r.Body)
 		var mem entity.MemEntity
@@ -596,6 +926,14 @@ func (h *SettingHandler) MemSet(w http.ResponseWriter, r *http.Request) {
 			JsonResp(w, fmt.Errorf("bot field is required"))
 			return
 		}
+		// project/task 记忆需指定工作目录或任务
+		switch mem.Scope = entity.ParseScope(mem.Scope); mem.Scope {
+		case entity.SCOPE_PROJECT, entity.SCOPE_TASK:
+			if mem.Target == "" {
+				JsonResp(w, fmt.Errorf("target is required for %s scope", mem.Scope))
+				return
+			}
+		}
 
 		// 如果是更新现有记录
 		if uuid != "" {
@@ -609,11 +947,19 @@ func (h *SettingHandler) MemSet(w http.ResponseWriter, r *http.Request) {
 				http.NotFound(w, r)
 				return
 			}
+			// 内容变化后需要重新生成向量
+			if find.Subject != mem.Subject || find.Content != mem.Content {
+				find.Vector, find.Embedder = nil, ""
+			}
 			// 更新现有记录
 			find.Bot = mem.Bot
 			find.Type = mem.Type
 			find.Subject = mem.Subject
 			find.Content = mem.Content
+			find.Importance = support.Or(mem.Importance, find.Importance)
+			find.ExpireAt = mem.ExpireAt
+			find.Scope = mem.Scope
+			find.Target = mem.Target
 			h.service.SaveMem(find)
 			if err := JsonResp(w, find.ToMap()); err != nil {
 				log.Println("resp error", err)
@@ -650,3 +996,300 @@ func (h *SettingHandler) MemSet(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// SecretSet 管理命名密钥，值只写不读
+// 模型 apiKey 与 MCP env/headers 可填写 secret://NAME 引用
+func (h *SettingHandler) SecretSet(w http.ResponseWriter, r *http.Request) {
+	act := r.URL.Query().Get("act")
+	name := r.URL.Query().Get("name")
+	switch act {
+	case "get-secret", "":
+		list, err := h.service.LoadSecrets()
+		if err != nil {
+			JsonResp(w, err)
+			return
+		}
+		secrets := []map[string]any{}
+		for _, item := range list {
+			data := item.Redacted()
+			data["ref"] = secret.Ref(item.Name)
+			secrets = append(secrets, data)
+		}
+		if err := JsonResp(w, secrets); err != nil {
+			log.Println("resp error", err)
+		}
+	case "set-secret":
+		var data struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		}
+		if err := h.service.ReadTo(r.Body, &data); err != nil {
+			JsonResp(w, err)
+			return
+		}
+		name = support.Or(data.Name, name)
+		if err := h.service.SaveSecret(name, data.Value); err != nil {
+			JsonResp(w, err)
+			return
+		}
+		JsonResp(w, map[string]any{"name": name, "ref": secret.Ref(name)})
+	case "del-secret":
+		if err := h.service.DeleteSecret(name); err != nil {
+			JsonResp(w, err)
+			return
+		}
+		JsonResp(w, "success")
+	default:
+		JsonResp(w, fmt.Errorf("unknown act: %s", act))
+	}
+}
+
+// exportMem 导出 bot 的记忆，format 为 md 或 json
+func (h *SettingHandler) exportMem(w http.ResponseWriter, r *http.Request) {
+	uuid := r.URL.Query().Get("bot")
+	bot, err := h.manager.QueryWorker(uuid)
+	if bot == nil || err != nil {
+		JsonResp(w, fmt.Errorf("bot not found: %s", uuid))
+		return
+	}
+	mems := []*entity.MemEntity{}
+	for _, mem := range h.service.LoadMem("bot = ?", uuid) {
+		if mem.Bot == uuid && !mem.IsExpired() {
+			mems = append(mems, mem)
+		}
+	}
+
+	var data []byte
+	name := support.Or(bot.Name, bot.UUID) + "-memories"
+	switch format := r.URL.Query().Get("format"); format {
+	case "json":
+		if data, err = entity.MemToJson(uuid, mems); err != nil {
+			JsonResp(w, err)
+			return
+		}
+		name += ".json"
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	case "md", "":
+		data = []byte(entity.MemToMarkdown(support.Or(bot.Name, bot.UUID), mems))
+		name += ".md"
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	default:
+		JsonResp(w, fmt.Errorf("unknown format: %s", format))
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(
+		"attachment; filename=%q", name,
+	))
+	w.Write(data)
+}
+
+// importMem 导入 Markdown 或 JSON 格式的记忆，支持上传文件或直接提交内容
+// mode 为 merge（默认）或 overwrite
+func (h *SettingHandler) importMem(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	uuid, format := query.Get("bot"), query.Get("format")
+	if bot, err := h.manager.QueryWorker(uuid); bot == nil || err != nil {
+		JsonResp(w, fmt.Errorf("bot not found: %s", uuid))
+		return
+	}
+	mode := support.Or(query.Get("mode"), "merge")
+	if mode != "merge" && mode != "overwrite" {
+		JsonResp(w, fmt.Errorf("unknown mode: %s", mode))
+		return
+	}
+
+	var data []byte
+	var err error
+	r.ParseMultipartForm(32 << 20)
+	if r.MultipartForm != nil && len(r.MultipartForm.File["files"]) > 0 {
+		header := r.MultipartForm.File["files"][0]
+		if format == "" && strings.HasSuffix(strings.ToLower(header.Filename), ".json") {
+			format = "json"
+		}
+		if file, e := header.Open(); e != nil {
+			err = e
+		} else {
+			data, err = io.ReadAll(file)
+			file.Close()
+		}
+	} else {
+		data, err = io.ReadAll(r.Body)
+	}
+	if err != nil {
+		JsonResp(w, fmt.Errorf("read memories error: %w", err))
+		return
+	}
+
+	var mems []*entity.MemEntity
+	if format == "json" || (format == "" && json.Valid(data)) {
+		mems, err = entity.MemFromJson(data)
+	} else {
+		mems, err = entity.MemFromMarkdown(string(data))
+	}
+	if err != nil {
+		JsonResp(w, err)
+		return
+	}
+	result, err := h.service.ImportMem(uuid, mems, mode)
+	if err != nil {
+		JsonResp(w, fmt.Errorf("import memories error: %w", err))
+		return
+	}
+	if err := h.manager.ReloadMemory(uuid); err != nil {
+		log.Println("[MEM] reload memory error", err)
+	}
+	JsonResp(w, result)
+}
+
+// EvalSet 运行评测用例并查询结果，用于比较 bot 版本或模型
+func (h *SettingHandler) EvalSet(w http.ResponseWriter, r *http.Request) {
+	act := r.URL.Query().Get("act")
+	store, _ := storage.GetStorage()
+	switch act {
+	case "run":
+		suite := new(evals.Suite)
+		if err := json.NewDecoder(r.Body).Decode(suite); err != nil {
+			JsonResp(w, fmt.Errorf("decode suite error: %w", err))
+			return
+		}
+		if err := suite.Validate(); err != nil {
+			JsonResp(w, fmt.Errorf("invalid suite: %w", err))
+			return
+		}
+		run, _ := support.UniqueID()
+		runner := evals.NewRunner(h.manager, store)
+		go runner.Run(run, suite)
+		JsonResp(w, map[string]any{"run": run})
+	case "runs":
+		runs, index := []map[string]any{}, map[string]map[string]any{}
+		list, _ := store.LoadEval()
+		for _, item := range list {
+			if data, ok := index[item.Run]; ok {
+				data["results"] = data["results"].(int) + 1
+				continue
+			}
+			index[item.Run] = map[string]any{
+				"run": item.Run, "suite": item.Suite, "results": 1,
+				"time": item.CreatedAt.Format(time.DateTime),
+			}
+			runs = append(runs, index[item.Run])
+		}
+		JsonResp(w, runs)
+	case "results", "":
+		run := r.URL.Query().Get("run")
+		list, err := store.LoadEval("run = ?", run)
+		if err != nil {
+			JsonResp(w, fmt.Errorf("load eval error: %w", err))
+			return
+		}
+		results := []map[string]any{}
+		for _, item := range list {
+			results = append(results, item.ToMap())
+		}
+		JsonResp(w, map[string]any{
+			"run": run, "results": results,
+			"summary": evals.Summarize(list),
+		})
+	default:
+		JsonResp(w, fmt.Errorf("unknown act: %s", act))
+	}
+}
+
+// Backup 备份与恢复整个数据目录
+//
+//	act=create  body {workspace, passphrase}，返回 tar.gz
+//	act=restore 上传 files，参数 mode、dry-run、passphrase，返回恢复报告
+func (h *SettingHandler) Backup(w http.ResponseWriter, r *http.Request) {
+	store, err := storage.GetStorage()
+	if err != nil {
+		JsonResp(w, err)
+		return
+	}
+	yes := []string{"1", "true", "yes"}
+	switch act := r.URL.Query().Get("act"); act {
+	case "create":
+		var opts struct {
+			Workspace  bool   `json:"workspace"`
+			Passphrase string `json:"passphrase"`
+		}
+		if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+			if err := json.Unmarshal(data, &opts); err != nil {
+				JsonResp(w, fmt.Errorf("error input"))
+				return
+			}
+		}
+		opts.Workspace = opts.Workspace || slice.Contain(yes, r.URL.Query().Get("workspace"))
+		file, err := os.CreateTemp("", "swiflow-backup-*.tar.gz")
+		if err != nil {
+			JsonResp(w, err)
+			return
+		}
+		defer os.Remove(file.Name())
+		defer file.Close()
+		_, err = backup.Create(store, file, backup.Options{
+			Workspace: opts.Workspace, Passphrase: opts.Passphrase,
+		})
+		if err != nil {
+			log.Println("[BACKUP] create error", err)
+			JsonResp(w, err)
+			return
+		}
+		name := time.Now().Format("swiflow-backup-20060102-150405.tar.gz")
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(
+			"attachment; filename=%q", name,
+		))
+		http.ServeContent(w, r, name, time.Now(), file)
+	case "restore":
+		path, err := saveUpload(r)
+		if err != nil {
+			JsonResp(w, err)
+			return
+		}
+		defer os.Remove(path)
+		report, err := backup.Restore(store, path, backup.RestoreOptions{
+			Mode:       r.FormValue("mode"),
+			DryRun:     slice.Contain(yes, r.FormValue("dry-run")),
+			Passphrase: r.FormValue("passphrase"),
+		})
+		if err != nil {
+			log.Println("[BACKUP] restore error", err)
+			JsonResp(w, map[string]any{"errmsg": err.Error(), "report": report})
+			return
+		}
+		if !report.DryRun {
+			_ = h.manager.Initial(nil)
+		}
+		JsonResp(w, report)
+	default:
+		JsonResp(w, fmt.Errorf("unknown act: %s", act))
+	}
+}
+
+// saveUpload 将上传的 files 或请求体保存为临时文件
+func saveUpload(r *http.Request) (string, error) {
+	var src io.Reader = r.Body
+	r.ParseMultipartForm(32 << 20)
+	if r.MultipartForm != nil && len(r.MultipartForm.File["files"]) > 0 {
+		file, err := r.MultipartForm.File["files"][0].Open()
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		src = file
+	}
+	file, err := os.CreateTemp("", "swiflow-restore-*.tar.gz")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	size, err := io.Copy(file, src)
+	if err == nil && size == 0 {
+		err = fmt.Errorf("empty backup file")
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
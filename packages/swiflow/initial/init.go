@@ -40,6 +40,16 @@ var worker = []string{
 	"mcp-tool-module",
 }
 
+// manager 是多级团队中的中层，既执行任务也可再分派
+var manager = []string{
+	"use-basic-tools",
+	"use-file-system",
+
+	"builtin-module",
+	"mcp-tool-module",
+	"subagent-module",
+}
+
 // debug 拥有mcp能力
 var debug = []string{
 	"mcp-tool-module",
@@ -129,6 +139,8 @@ func UsePrompt(kind string) string {
 		ability = append(ability, leader...)
 	case "worker":
 		ability = append(ability, worker...)
+	case "manager":
+		ability = append(ability, manager...)
 	case "debug":
 		ability = append(ability, debug...)
 	default:
@@ -152,6 +164,9 @@ func UsePrompt(kind string) string {
 	}
 
 	var prompt strings.Builder
+	if kind == "manager" { // 复用 worker 提示词
+		kind = "worker"
+	}
 	path := fmt.Sprintf("tools/0.%s-prompt.md", kind)
 	if data, _ := fs.ReadFile(path); len(data) != 0 {
 		prompt.WriteString(strings.ReplaceAll(
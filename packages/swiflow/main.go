@@ -33,6 +33,22 @@ func main() {
 			log.Println("load env fail:", err)
 		}
 		entry.StartChat(context.Background())
+	case "migrate":
+		if err := config.LoadEnv(); err != nil {
+			log.Println("load env fail:", err)
+		}
+		if err := entry.StartMigrate(flag.Args()); err != nil {
+			log.Println("migrate fail:", err)
+			os.Exit(1)
+		}
+	case "backup":
+		if err := config.LoadEnv(); err != nil {
+			log.Println("load env fail:", err)
+		}
+		if err := entry.StartBackup(flag.Args()); err != nil {
+			log.Println("backup fail:", err)
+			os.Exit(1)
+		}
 	case "test":
 		var s = new(httpd.HttpServie)
 		// resp := s.InitMcpEnvAsync("uvx-py", "mainland")
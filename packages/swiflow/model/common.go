@@ -15,6 +15,9 @@ type CommonModel struct {
 	mu   sync.Mutex
 	reqs sync.Map
 
+	// 累计 token 用量
+	usage Usage
+
 	fn func() ClientInterface
 }
 
@@ -34,6 +37,20 @@ func (m *CommonModel) client() ClientInterface {
 	return openai.NewClientWithConfig(config)
 }
 
+func (m *CommonModel) Usage() Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage
+}
+
+func (m *CommonModel) addUsage(usage Usage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usage.PromptTokens += usage.PromptTokens
+	m.usage.CompletionTokens += usage.CompletionTokens
+	m.usage.TotalTokens += usage.TotalTokens
+}
+
 func (m *CommonModel) logInfo() {
 	log.Println("[LLM] provider:", m.cfg.Provider, "model:", m.cfg.UseModel)
 	log.Println("[LLM] task-id:", m.cfg.TaskId, "api:", m.cfg.ApiUrl)
@@ -77,6 +94,9 @@ func (m *CommonModel) Stream(group string, msgs []Message, handle Handle) error
 	result := []Choice{{}}
 	for {
 		if resp, err := stream.Recv(); err == nil {
+			if resp.Usage != nil {
+				m.addUsage(*resp.Usage)
+			}
 			if len(resp.Choices) == 0 {
 				continue
 			}
@@ -119,5 +139,6 @@ func (m *CommonModel) Respond(group string, msgs []Message) ([]Choice, error) {
 		return []Choice{}, fmt.Errorf("LLM API ERROR: %v", err)
 	}
 
+	m.addUsage(resp.Usage)
 	return resp.Choices, nil
 }
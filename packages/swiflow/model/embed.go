@@ -0,0 +1,53 @@
+package model
+
+import (
+	"context"
+	"fmt"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Embedder 把文本转换为向量，用于记忆的语义检索
+type Embedder interface {
+	Name() string
+	Embed(texts []string) ([][]float32, error)
+}
+
+// EmbedModel 兼容 OpenAI embeddings 接口的向量模型
+type EmbedModel struct {
+	cfg LLMConfig
+}
+
+func NewEmbedModel(cfg LLMConfig) *EmbedModel {
+	return &EmbedModel{cfg: cfg}
+}
+
+func (m *EmbedModel) Name() string {
+	return m.cfg.Provider + "/" + m.cfg.UseModel
+}
+
+func (m *EmbedModel) Embed(texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	config := openai.DefaultConfig(m.cfg.ApiKey)
+	config.BaseURL = m.cfg.ApiUrl
+	config.HTTPClient = NewProxyHttpClient(nil)
+	client := openai.NewClientWithConfig(config)
+	resp, err := client.CreateEmbeddings(context.Background(), openai.EmbeddingRequest{
+		Input: texts, Model: openai.EmbeddingModel(m.cfg.UseModel),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("EMBED API ERROR: %v", err)
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("EMBED API ERROR: got %d of %d", len(resp.Data), len(texts))
+	}
+	result := make([][]float32, len(texts))
+	for _, item := range resp.Data {
+		if item.Index >= 0 && item.Index < len(result) {
+			result[item.Index] = item.Embedding
+		}
+	}
+	return result, nil
+}
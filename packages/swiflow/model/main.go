@@ -22,6 +22,7 @@ type Choice = openai.ChatCompletionChoice
 type Message = openai.ChatCompletionMessage
 type Request = openai.ChatCompletionRequest
 type Response = openai.ChatCompletionResponse
+type Usage = openai.Usage
 
 type requestContext struct {
 	ctx    context.Context
@@ -39,6 +40,11 @@ type LLMClient interface {
 	Respond(string, []Message) ([]Choice, error)
 }
 
+// UsageCounter 可统计累计 token 用量的客户端
+type UsageCounter interface {
+	Usage() Usage
+}
+
 type LLMConfig struct {
 	TaskId   string `json:"taskId"`
 	ApiKey   string `json:"apiKey"`
@@ -0,0 +1,230 @@
+package secret
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"swiflow/config"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	keyringService = "swiflow"
+	keyringAccount = "master-key"
+)
+
+var master []byte
+
+// errNoKeyring 钥匙串中没有主密钥条目，只有这种情况才允许新建
+var errNoKeyring = errors.New("keyring entry not found")
+
+// 钥匙串读写，测试时可替换
+var (
+	useKeyring = keyringEnabled
+	keyringGet = readKeyring
+	keyringSet = writeKeyring
+)
+
+// masterKey 按顺序获取主密钥：
+// SECRET_PASSPHRASE 派生 > 已存在的密钥文件 > 系统钥匙串 > 新建密钥文件
+func masterKey() ([]byte, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if master != nil {
+		return master, nil
+	}
+	key, err := loadKey()
+	if err != nil {
+		return nil, err
+	}
+	master = key
+	return master, nil
+}
+
+func loadKey() ([]byte, error) {
+	if pass := config.Get("SECRET_PASSPHRASE"); pass != "" {
+		salt, err := loadSalt()
+		if err != nil {
+			return nil, err
+		}
+		return Derive(pass, salt)
+	}
+	if data, err := os.ReadFile(keyFile()); err == nil {
+		return decodeKey(string(data))
+	}
+	if useKeyring() {
+		key, err := loadKeyring()
+		if key != nil || err != nil {
+			return key, err
+		}
+	}
+	// 无钥匙串时退回到仅当前用户可读的密钥文件
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	data := []byte(hex.EncodeToString(key))
+	if err := os.WriteFile(keyFile(), data, 0600); err != nil {
+		return nil, fmt.Errorf("save secret key: %w", err)
+	}
+	log.Printf("[SECRET] master key saved to %s", keyFile())
+	return key, nil
+}
+
+// loadKeyring 读取钥匙串中的主密钥，条目不存在时才新建；
+// 钥匙串被锁定、拒绝访问等其他错误直接返回，避免生成新密钥后旧密文无法解密。
+// 返回 nil, nil 表示钥匙串不可写，由调用方退回到密钥文件
+func loadKeyring() ([]byte, error) {
+	data, err := keyringGet()
+	if err == nil {
+		return decodeKey(data)
+	}
+	if !errors.Is(err, errNoKeyring) {
+		return nil, fmt.Errorf("read keyring: %w", err)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err = keyringSet(hex.EncodeToString(key)); err == nil {
+		return key, nil
+	}
+	// 写入失败可能是其他进程刚刚创建了条目，以已存在的为准
+	if data, err := keyringGet(); err == nil {
+		return decodeKey(data)
+	}
+	log.Printf("[SECRET] keyring unavailable: %v", err)
+	return nil, nil
+}
+
+// Derive 由口令和盐派生密钥，也用于加密备份中的密钥
+func Derive(pass string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(pass), salt, 1<<15, 8, 1, 32)
+}
+
+// Fingerprint 密钥摘要前缀，用于判断两端是否使用同一密钥，nil 表示主密钥
+func Fingerprint(key []byte) (string, error) {
+	if key == nil {
+		var err error
+		if key, err = masterKey(); err != nil {
+			return "", err
+		}
+	}
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+func decodeKey(data string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(data))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("invalid secret key")
+	}
+	return key, nil
+}
+
+// loadSalt 口令派生使用的随机盐，首次使用时生成
+func loadSalt() ([]byte, error) {
+	path := config.GetDataPath("secret.salt")
+	if data, err := os.ReadFile(path); err == nil && len(data) > 0 {
+		return data, nil
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, salt, 0600); err != nil {
+		return nil, fmt.Errorf("save secret salt: %w", err)
+	}
+	return salt, nil
+}
+
+func keyFile() string {
+	return config.GetDataPath("secret.key")
+}
+
+// keyringEnabled SECRET_KEYRING=no 时不使用系统钥匙串
+// Windows 暂无钥匙串支持，使用密钥文件，文件权限无法限制其他用户读取，建议设置 SECRET_PASSPHRASE
+func keyringEnabled() bool {
+	if config.Get("SECRET_KEYRING") == "no" {
+		return false
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		_, err := exec.LookPath("security")
+		return err == nil
+	case "linux":
+		_, err := exec.LookPath("secret-tool")
+		return err == nil
+	}
+	return false
+}
+
+// readKeyring 条目不存在时返回 errNoKeyring：
+// security 以 44 (errSecItemNotFound) 退出，secret-tool 以 1 退出且没有错误输出
+func readKeyring() (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password",
+			"-s", keyringService, "-a", keyringAccount, "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup",
+			"service", keyringService, "account", keyringAccount)
+	default:
+		return "", fmt.Errorf("unsupported os: %s", runtime.GOOS)
+	}
+	out, err := cmd.Output()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		stderr := strings.TrimSpace(string(exit.Stderr))
+		switch {
+		case runtime.GOOS == "darwin" && exit.ExitCode() == 44:
+			return "", errNoKeyring
+		case runtime.GOOS == "linux" && exit.ExitCode() == 1 && stderr == "":
+			return "", errNoKeyring
+		}
+		return "", fmt.Errorf("%v: %s", err, stderr)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// writeKeyring 仅在条目不存在时调用，security 不带 -U，已存在时失败而不是覆盖
+// 密钥经 stdin 传入，不出现在命令行参数中（ps 可见）
+func writeKeyring(value string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		// security -i 从 stdin 读取命令，出错时不一定以非零状态退出，写入后需读回确认
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = bytes.NewBufferString(fmt.Sprintf(
+			"add-generic-password -s %s -a %s -w %s\n",
+			keyringService, keyringAccount, value,
+		))
+	case "linux":
+		cmd = exec.Command("secret-tool", "store", "--label=Swiflow",
+			"service", keyringService, "account", keyringAccount)
+		cmd.Stdin = bytes.NewBufferString(value)
+	default:
+		return fmt.Errorf("unsupported os: %s", runtime.GOOS)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	if stored, err := readKeyring(); err != nil || stored != value {
+		return fmt.Errorf("keyring not saved: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// 接口返回时替代敏感值的掩码，保存时遇到掩码保留原值
+	MASK = "******"
+	// 加密后的值前缀，无前缀的视为旧版明文
+	PREFIX = "enc:v1:"
+	// 引用已命名的密钥，如 secret://OPENAI_KEY
+	SCHEME = "secret://"
+)
+
+var (
+	lock   sync.Mutex
+	lookup func(name string) (string, error)
+
+	nameRule = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,80}$`)
+	keyRule  = regexp.MustCompile(`(?i)(api[_-]?key|secret|token|passwd|password|^pass$|^authorization$|^key$)`)
+)
+
+// SetLookup 注册按名称读取密钥的方法，由 storage 初始化时设置
+func SetLookup(fn func(name string) (string, error)) {
+	lock.Lock()
+	defer lock.Unlock()
+	lookup = fn
+}
+
+// ValidName 密钥名仅允许字母、数字及 _ . -
+func ValidName(name string) bool {
+	return nameRule.MatchString(name)
+}
+
+// IsSecret 按字段名判断是否为敏感值，如 apiKey、API_TOKEN、password
+func IsSecret(key string) bool {
+	return keyRule.MatchString(key)
+}
+
+// IsRef 值是否为 secret://NAME 形式的引用
+func IsRef(val string) bool {
+	return strings.HasPrefix(val, SCHEME)
+}
+
+// Ref 生成密钥引用
+func Ref(name string) string {
+	return SCHEME + name
+}
+
+// Resolve 将 secret://NAME 引用替换为密钥明文，普通值原样返回
+func Resolve(val string) (string, error) {
+	if !IsRef(val) {
+		return val, nil
+	}
+	name := strings.TrimPrefix(val, SCHEME)
+	lock.Lock()
+	fn := lookup
+	lock.Unlock()
+	if fn == nil {
+		return "", fmt.Errorf("secret store not ready: %s", name)
+	}
+	value, err := fn(name)
+	if err != nil {
+		return "", fmt.Errorf("secret %s: %w", name, err)
+	}
+	return value, nil
+}
+
+// Encrypt 使用主密钥 AES-GCM 加密，空值、引用和已加密的值不处理
+func Encrypt(val string) (string, error) {
+	return encryptWith(nil, val)
+}
+
+// Decrypt 解密 Encrypt 的结果，无前缀的明文原样返回
+func Decrypt(val string) (string, error) {
+	return decryptWith(nil, val)
+}
+
+func encryptWith(key []byte, val string) (string, error) {
+	if val == "" || val == MASK || IsRef(val) {
+		return val, nil
+	}
+	if strings.HasPrefix(val, PREFIX) {
+		return val, nil
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	data := gcm.Seal(nonce, nonce, []byte(val), nil)
+	return PREFIX + base64.RawStdEncoding.EncodeToString(data), nil
+}
+
+func decryptWith(key []byte, val string) (string, error) {
+	if !strings.HasPrefix(val, PREFIX) {
+		return val, nil
+	}
+	data, err := base64.RawStdEncoding.DecodeString(
+		strings.TrimPrefix(val, PREFIX),
+	)
+	if err != nil {
+		return "", fmt.Errorf("decode secret: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("decode secret: too short")
+	}
+	size := gcm.NonceSize()
+	plain, err := gcm.Open(nil, data[:size], data[size:], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret: %w", err)
+	}
+	return string(plain), nil
+}
+
+// newGCM key 为 nil 时使用主密钥
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if key == nil {
+		var err error
+		if key, err = masterKey(); err != nil {
+			return nil, err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal 返回加密敏感字段后的副本，all 为 true 时加密全部字符串
+func Seal(data map[string]any, all bool) (map[string]any, error) {
+	var failed error
+	result := walk(data, all, func(val string) string {
+		enc, err := Encrypt(val)
+		if err != nil && failed == nil {
+			failed = err
+		}
+		return enc
+	})
+	return result, failed
+}
+
+// Open 返回解密后的副本，解密失败的字段保留密文
+func Open(data map[string]any) (map[string]any, error) {
+	var failed error
+	result := walk(data, true, func(val string) string {
+		plain, err := Decrypt(val)
+		if err != nil {
+			if failed == nil {
+				failed = err
+			}
+			return val
+		}
+		return plain
+	})
+	return result, failed
+}
+
+// Rekey 将 from 密钥加密的值改用 to 密钥加密，nil 表示主密钥
+func Rekey(data map[string]any, from, to []byte) (map[string]any, error) {
+	var failed error
+	result := walk(data, true, func(val string) string {
+		if !strings.HasPrefix(val, PREFIX) || failed != nil {
+			return val
+		}
+		plain, err := decryptWith(from, val)
+		if err == nil {
+			val, err = encryptWith(to, plain)
+		}
+		failed = err
+		return val
+	})
+	return result, failed
+}
+
+// Strip 清空无法使用的密文，返回清空的数量
+func Strip(data map[string]any) (map[string]any, int) {
+	count := 0
+	result := walk(data, true, func(val string) string {
+		if strings.HasPrefix(val, PREFIX) {
+			count++
+			return ""
+		}
+		return val
+	})
+	return result, count
+}
+
+// Redact 返回敏感字段替换为掩码的副本，引用保持可见
+func Redact(data map[string]any) map[string]any {
+	result := walk(data, false, func(val string) string {
+		return Mask(val)
+	})
+	return result
+}
+
+// Mask 非空且非引用的值替换为掩码
+func Mask(val string) string {
+	if val == "" || IsRef(val) {
+		return val
+	}
+	return MASK
+}
+
+// Restore 将 data 中仍为掩码的字段还原为 old 中的值
+func Restore(data, old map[string]any) {
+	for key, val := range data {
+		switch val := val.(type) {
+		case string:
+			if val != MASK {
+				continue
+			}
+			if prev, ok := old[key].(string); ok {
+				data[key] = prev
+			} else {
+				delete(data, key)
+			}
+		case map[string]any:
+			prev, _ := old[key].(map[string]any)
+			Restore(val, prev)
+		}
+	}
+}
+
+// walk 复制 map，并对敏感字段（或 all 时全部字符串）执行 fn
+func walk(data map[string]any, all bool, fn func(string) string) map[string]any {
+	if data == nil {
+		return nil
+	}
+	result := make(map[string]any, len(data))
+	for key, val := range data {
+		switch val := val.(type) {
+		case string:
+			if all || IsSecret(key) {
+				result[key] = fn(val)
+			} else {
+				result[key] = val
+			}
+		case map[string]any:
+			result[key] = walk(val, all || IsSecret(key), fn)
+		case []any:
+			list := make([]any, len(val))
+			for i, item := range val {
+				if item, ok := item.(map[string]any); ok {
+					list[i] = walk(item, all, fn)
+				} else {
+					list[i] = item
+				}
+			}
+			result[key] = list
+		default:
+			result[key] = val
+		}
+	}
+	return result
+}
@@ -0,0 +1,194 @@
+package secret
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func setupKey(t *testing.T, pass string) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("SECRET_PASSPHRASE", pass)
+	t.Setenv("SECRET_KEYRING", "no")
+	master = nil
+	t.Cleanup(func() { master = nil })
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	setupKey(t, "correct horse")
+	enc, err := Encrypt("sk-123456")
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+	if !strings.HasPrefix(enc, PREFIX) || strings.Contains(enc, "sk-123456") {
+		t.Fatalf("密文格式不正确: %s", enc)
+	}
+	if again, _ := Encrypt(enc); again != enc {
+		t.Errorf("已加密的值不应重复加密")
+	}
+	if plain, err := Decrypt(enc); err != nil || plain != "sk-123456" {
+		t.Fatalf("解密结果不正确: %q, %v", plain, err)
+	}
+	// 旧版明文原样返回
+	if plain, _ := Decrypt("sk-plain"); plain != "sk-plain" {
+		t.Errorf("明文应原样返回: %s", plain)
+	}
+	// 引用不加密
+	if ref, _ := Encrypt(Ref("OPENAI")); ref != "secret://OPENAI" {
+		t.Errorf("引用不应加密: %s", ref)
+	}
+
+	// 口令错误时无法解密
+	master = nil
+	t.Setenv("SECRET_PASSPHRASE", "wrong horse")
+	if _, err := Decrypt(enc); err == nil {
+		t.Errorf("错误口令应解密失败")
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	setupKey(t, "")
+	enc, err := Encrypt("token")
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+	// 重新加载时应读取同一个密钥文件
+	master = nil
+	if plain, err := Decrypt(enc); err != nil || plain != "token" {
+		t.Fatalf("密钥文件未复用: %q, %v", plain, err)
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	setupKey(t, "seal")
+	data := map[string]any{
+		"provider": "openai", "apiKey": "sk-abc", "maxTokens": float64(100),
+		"env": map[string]any{
+			"GITHUB_TOKEN": "ghp-xyz", "HOME_DIR": "$CURRENT_HOME",
+		},
+		"headers": map[string]any{"Authorization": "Bearer abc"},
+	}
+	sealed, err := Seal(data, false)
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+	if data["apiKey"] != "sk-abc" {
+		t.Errorf("Seal 不应修改原数据")
+	}
+	env := sealed["env"].(map[string]any)
+	if !strings.HasPrefix(sealed["apiKey"].(string), PREFIX) ||
+		!strings.HasPrefix(env["GITHUB_TOKEN"].(string), PREFIX) {
+		t.Errorf("敏感字段应被加密: %v", sealed)
+	}
+	if sealed["provider"] != "openai" || env["HOME_DIR"] != "$CURRENT_HOME" {
+		t.Errorf("普通字段不应加密: %v", sealed)
+	}
+	opened, err := Open(sealed)
+	if err != nil {
+		t.Fatalf("解密失败: %v", err)
+	}
+	if fmt.Sprint(opened) != fmt.Sprint(data) {
+		t.Errorf("解密后应与原数据一致: %v", opened)
+	}
+
+	all, _ := Seal(map[string]any{"value": "v"}, true)
+	if !strings.HasPrefix(all["value"].(string), PREFIX) {
+		t.Errorf("all 模式应加密全部字符串: %v", all)
+	}
+}
+
+func TestRedactRestore(t *testing.T) {
+	data := map[string]any{
+		"apiKey": "sk-abc", "provider": "openai",
+		"env": map[string]any{"API_KEY": Ref("KEY"), "DB_PASSWORD": "pw"},
+	}
+	redacted := Redact(data)
+	env := redacted["env"].(map[string]any)
+	if redacted["apiKey"] != MASK || env["DB_PASSWORD"] != MASK {
+		t.Errorf("敏感字段应替换为掩码: %v", redacted)
+	}
+	if env["API_KEY"] != "secret://KEY" || redacted["provider"] != "openai" {
+		t.Errorf("引用与普通字段应保留: %v", redacted)
+	}
+
+	// 未修改的掩码还原为原值，修改过的保留新值
+	input := map[string]any{
+		"apiKey": MASK, "provider": "openai",
+		"env": map[string]any{"DB_PASSWORD": "new", "NEW_TOKEN": MASK},
+	}
+	Restore(input, data)
+	if input["apiKey"] != "sk-abc" {
+		t.Errorf("掩码应还原: %v", input)
+	}
+	env = input["env"].(map[string]any)
+	if env["DB_PASSWORD"] != "new" {
+		t.Errorf("新值不应被覆盖: %v", env)
+	}
+	if _, ok := env["NEW_TOKEN"]; ok {
+		t.Errorf("无原值的掩码应删除: %v", env)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	defer SetLookup(nil)
+	if val, err := Resolve("plain"); err != nil || val != "plain" {
+		t.Errorf("普通值应原样返回: %q, %v", val, err)
+	}
+	if _, err := Resolve(Ref("KEY")); err == nil {
+		t.Errorf("未注册查询时应返回错误")
+	}
+	SetLookup(func(name string) (string, error) {
+		if name == "KEY" {
+			return "sk-ref", nil
+		}
+		return "", fmt.Errorf("not found")
+	})
+	if val, err := Resolve(Ref("KEY")); err != nil || val != "sk-ref" {
+		t.Errorf("引用解析不正确: %q, %v", val, err)
+	}
+	if _, err := Resolve(Ref("MISSING")); err == nil {
+		t.Errorf("不存在的密钥应返回错误")
+	}
+	if ValidName("a b") || !ValidName("OPENAI_KEY.v1") {
+		t.Errorf("密钥名校验不正确")
+	}
+	if IsSecret("auth") || IsSecret("provider") || !IsSecret("apiKey") || !IsSecret("X-API-KEY") {
+		t.Errorf("敏感字段判断不正确")
+	}
+}
+
+func TestKeyringLoad(t *testing.T) {
+	setupKey(t, "")
+	stored, sets := "", 0
+	readErr := errNoKeyring
+	useKeyring = func() bool { return true }
+	keyringGet = func() (string, error) {
+		if stored != "" {
+			return stored, nil
+		}
+		return "", readErr
+	}
+	keyringSet = func(value string) error {
+		sets++
+		stored = value
+		return nil
+	}
+	t.Cleanup(func() {
+		useKeyring, keyringGet, keyringSet = keyringEnabled, readKeyring, writeKeyring
+	})
+
+	// 条目不存在时新建
+	key, err := loadKey()
+	if err != nil || sets != 1 || len(key) != 32 {
+		t.Fatalf("条目不存在时应新建密钥: %v, %d", err, sets)
+	}
+	// 已存在时直接读取
+	if again, err := loadKey(); err != nil || string(again) != string(key) || sets != 1 {
+		t.Fatalf("应复用钥匙串中的密钥: %v, %d", err, sets)
+	}
+	// 钥匙串被锁定等错误时不得生成新密钥覆盖原条目
+	stored, readErr = "", fmt.Errorf("keychain locked")
+	if _, err := loadKey(); err == nil || sets != 1 {
+		t.Fatalf("读取失败时应返回错误且不写入: %v, %d", err, sets)
+	}
+}
@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// 恢复时遇到已存在的记录：跳过、覆盖或中止
+	CONFLICT_SKIP      = "skip"
+	CONFLICT_OVERWRITE = "overwrite"
+	CONFLICT_FAIL      = "fail"
+)
+
+// backupSpec 参与备份的表及判断重复的业务主键，自增 id 在不同安装间没有意义
+type backupSpec struct {
+	model any
+	keys  []string
+}
+
+var backupSpecs = []*backupSpec{
+	{new(TaskEntity), []string{"uuid"}},
+	{new(MsgEntity), []string{"uniq_id"}},
+	{new(BotEntity), []string{"uuid"}},
+	{new(BotVersion), []string{"bot", "version"}},
+	{new(CfgEntity), []string{"type", "name"}},
+	{new(ToolEntity), []string{"uuid"}},
+	{new(MemEntity), []string{"bot", "scope", "target", "subject"}},
+	{new(TodoEntity), []string{"uuid"}},
+	{new(AuditEntity), []string{"task", "server", "tool", "args", "latency"}},
+	{new(EvalEntity), []string{"run", "target", "case"}},
+}
+
+// Backupable 支持整库导出与恢复的存储
+type Backupable interface {
+	Backup() *Backup
+}
+
+type Backup struct {
+	db      *gorm.DB
+	dialect string
+}
+
+// TableReport 单表的恢复结果
+type TableReport struct {
+	Table     string   `json:"table"`
+	Total     int      `json:"total"`
+	Insert    int      `json:"insert"`
+	Overwrite int      `json:"overwrite"`
+	Skip      int      `json:"skip"`
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// Restorer 在同一事务中恢复多张表，dry-run 时回滚
+type Restorer struct {
+	tx   *gorm.DB
+	mode string
+	// 各表恢复前的最大 id，只与此前已存在的记录比较冲突，
+	// 业务主键不唯一的表（如同一主题的多条记忆）不会与本次刚插入的记录冲突
+	base map[string]uint
+
+	Tables []*TableReport
+}
+
+func NewBackup(db *gorm.DB, dialect string) *Backup {
+	return &Backup{db: db, dialect: dialect}
+}
+
+func (b *Backup) Dialect() string {
+	return b.dialect
+}
+
+// Tables 参与备份的表名
+func (b *Backup) Tables() []string {
+	result := []string{}
+	for _, spec := range backupSpecs {
+		if sch, err := backupSchema(spec.model); err == nil {
+			result = append(result, sch.Table)
+		}
+	}
+	return result
+}
+
+// Dump 逐行导出全部表（含软删除的记录），不经过 AfterFind，密文保持原样
+// sqlite 先 VACUUM INTO 得到一致快照，其余数据库在只读的可重复读事务中导出
+func (b *Backup) Dump(fn func(table string, row map[string]any) error) error {
+	if b.dialect == "sqlite" {
+		dir, err := os.MkdirTemp("", "swiflow-backup")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "snapshot.db")
+		if err := b.db.Exec("VACUUM INTO ?", path).Error; err != nil {
+			log.Printf("[BACKUP] sqlite snapshot: %v", err)
+			return fmt.Errorf("sqlite snapshot: %w", err)
+		}
+		snap, err := gorm.Open(sqlite.Open(path), &gorm.Config{
+			Logger: b.db.Logger,
+		})
+		if err != nil {
+			return fmt.Errorf("open snapshot: %w", err)
+		}
+		if db, err := snap.DB(); err == nil {
+			defer db.Close()
+		}
+		return dumpTables(snap, fn)
+	}
+	return b.db.Transaction(func(tx *gorm.DB) error {
+		return dumpTables(tx, fn)
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+}
+
+func dumpTables(db *gorm.DB, fn func(string, map[string]any) error) error {
+	db = db.Unscoped().Session(&gorm.Session{SkipHooks: true})
+	for _, spec := range backupSpecs {
+		sch, err := backupSchema(spec.model)
+		if err != nil {
+			return err
+		}
+		rows := reflect.New(reflect.SliceOf(reflect.PointerTo(sch.ModelType)))
+		result := db.Model(spec.model).FindInBatches(rows.Interface(), 500, func(tx *gorm.DB, batch int) error {
+			list := rows.Elem()
+			for i := 0; i < list.Len(); i++ {
+				if err := fn(sch.Table, encodeRow(tx, sch, list.Index(i))); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if result.Error != nil {
+			log.Printf("[BACKUP] dump %s: %v", sch.Table, result.Error)
+			return fmt.Errorf("dump %s: %w", sch.Table, result.Error)
+		}
+	}
+	return nil
+}
+
+// encodeRow 按列名导出字段值，直接取结构体字段，serializer 字段保持 Go 值
+func encodeRow(db *gorm.DB, sch *schema.Schema, val reflect.Value) map[string]any {
+	row := map[string]any{}
+	for _, name := range sch.DBNames {
+		if name == "id" {
+			continue
+		}
+		field := sch.FieldsByDBName[name]
+		row[name] = field.ReflectValueOf(db.Statement.Context, val).Interface()
+	}
+	return row
+}
+
+// Begin 开启恢复事务，mode 为 CONFLICT_* 之一
+func (b *Backup) Begin(mode string) (*Restorer, error) {
+	mode = strings.ToLower(mode)
+	if mode == "" {
+		mode = CONFLICT_SKIP
+	}
+	modes := []string{CONFLICT_SKIP, CONFLICT_OVERWRITE, CONFLICT_FAIL}
+	if !slices.Contains(modes, mode) {
+		return nil, fmt.Errorf("unknown conflict mode: %s", mode)
+	}
+	tx := b.db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &Restorer{
+		tx: tx, mode: mode, base: map[string]uint{},
+		Tables: []*TableReport{},
+	}, nil
+}
+
+// Load 恢复一批记录，结果累计到对应表的报告中
+func (r *Restorer) Load(table string, rows []map[string]json.RawMessage) error {
+	spec, sch := findSpec(table)
+	if spec == nil {
+		return fmt.Errorf("unknown table: %s", table)
+	}
+	report := r.report(table)
+	db := r.tx.Unscoped().Session(&gorm.Session{SkipHooks: true})
+	base, ok := r.base[table]
+	if !ok {
+		err := db.Model(spec.model).Select("COALESCE(MAX(id), 0)").Scan(&base).Error
+		if err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+		r.base[table] = base
+	}
+	for _, row := range rows {
+		report.Total++
+		obj, err := decodeRow(db, sch, row)
+		if err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+		where, key := clause.AndConditions{}, []string{}
+		where.Exprs = append(where.Exprs, clause.Lte{
+			Column: clause.Column{Name: "id"}, Value: base,
+		})
+		for _, name := range spec.keys {
+			value, _ := sch.FieldsByDBName[name].ValueOf(db.Statement.Context, obj.Elem())
+			where.Exprs = append(where.Exprs, clause.Eq{
+				Column: clause.Column{Name: name}, Value: value,
+			})
+			key = append(key, fmt.Sprint(value))
+		}
+
+		var count int64
+		if err := db.Model(spec.model).Where(where).Count(&count).Error; err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+		if count > 0 {
+			if len(report.Conflicts) < 20 {
+				report.Conflicts = append(report.Conflicts, strings.Join(key, "/"))
+			}
+			switch r.mode {
+			case CONFLICT_SKIP:
+				report.Skip++
+				continue
+			case CONFLICT_FAIL:
+				return fmt.Errorf("%s: record exists: %s", table, strings.Join(key, "/"))
+			}
+			if err := db.Where(where).Delete(spec.model).Error; err != nil {
+				return fmt.Errorf("%s: %w", table, err)
+			}
+			report.Overwrite++
+		} else {
+			report.Insert++
+		}
+		if err := db.Create(obj.Interface()).Error; err != nil {
+			log.Printf("[BACKUP] restore %s: %v", table, err)
+			return fmt.Errorf("%s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+func (r *Restorer) Commit() error {
+	return r.tx.Commit().Error
+}
+
+func (r *Restorer) Rollback() error {
+	return r.tx.Rollback().Error
+}
+
+func (r *Restorer) report(table string) *TableReport {
+	for _, item := range r.Tables {
+		if item.Table == table {
+			return item
+		}
+	}
+	report := &TableReport{Table: table}
+	r.Tables = append(r.Tables, report)
+	return report
+}
+
+// decodeRow 按字段类型解析 JSON 值，忽略 id 与未知列
+func decodeRow(db *gorm.DB, sch *schema.Schema, row map[string]json.RawMessage) (reflect.Value, error) {
+	obj := reflect.New(sch.ModelType)
+	for _, name := range sch.DBNames {
+		raw, ok := row[name]
+		if !ok || name == "id" {
+			continue
+		}
+		field := sch.FieldsByDBName[name]
+		ptr := reflect.New(field.FieldType)
+		if err := json.Unmarshal(raw, ptr.Interface()); err != nil {
+			return obj, fmt.Errorf("column %s: %w", name, err)
+		}
+		field.ReflectValueOf(db.Statement.Context, obj.Elem()).Set(ptr.Elem())
+	}
+	return obj, nil
+}
+
+func findSpec(table string) (*backupSpec, *schema.Schema) {
+	for _, spec := range backupSpecs {
+		sch, err := backupSchema(spec.model)
+		if err == nil && sch.Table == table {
+			return spec, sch
+		}
+	}
+	return nil, nil
+}
+
+func backupSchema(model any) (*schema.Schema, error) {
+	return schema.Parse(model, &querySchemas, schema.NamingStrategy{})
+}
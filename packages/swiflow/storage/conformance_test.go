@@ -0,0 +1,366 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"swiflow/entity"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 所有 MyStore 实现都需通过同一组测试
+// mysql 与 postgres 需设置 MYSQL_TEST_DSN、POSTGRES_TEST_DSN 才会运行
+func conformanceStores(t *testing.T) map[string]func(t *testing.T) MyStore {
+	return map[string]func(t *testing.T) MyStore{
+		"mock": func(t *testing.T) MyStore {
+			return NewMockStore()
+		},
+		"sqlite": func(t *testing.T) MyStore {
+			path := filepath.Join(t.TempDir(), "test.db")
+			store, err := NewStorage("sqlite", map[string]any{"path": path})
+			if err != nil {
+				t.Fatalf("创建存储失败: %v", err)
+			}
+			return store
+		},
+		"mysql": func(t *testing.T) MyStore {
+			return openTestStore(t, "mysql", "MYSQL_TEST_DSN")
+		},
+		"postgres": func(t *testing.T) MyStore {
+			return openTestStore(t, "postgres", "POSTGRES_TEST_DSN")
+		},
+	}
+}
+
+func openTestStore(t *testing.T, kind, env string) MyStore {
+	dsn := os.Getenv(env)
+	if dsn == "" {
+		t.Skipf("%s 未设置", env)
+	}
+	store, err := NewStorage(kind, map[string]any{"dsn": dsn})
+	if err != nil {
+		t.Fatalf("连接 %s 失败: %v", kind, err)
+	}
+	return store
+}
+
+func TestStorage_Conformance(t *testing.T) {
+	cases := map[string]func(*testing.T, MyStore, string){
+		"task": conformTask, "msg": conformMsg, "bot": conformBot,
+		"cfg": conformCfg, "mem": conformMem, "tool": conformTool,
+		"todo": conformTodo, "audit": conformAudit, "search": conformSearch,
+		"query": conformQuery,
+	}
+	for kind, open := range conformanceStores(t) {
+		t.Run(kind, func(t *testing.T) {
+			store := open(t)
+			if err := store.AutoMigrate(); err != nil {
+				t.Fatalf("迁移失败: %v", err)
+			}
+			// 共享数据库时避免与上次运行冲突
+			run := fmt.Sprintf("%06x", time.Now().UnixNano()&0xffffff)
+			for name, test := range cases {
+				t.Run(name, func(t *testing.T) { test(t, store, run) })
+			}
+		})
+	}
+}
+
+func conformTask(t *testing.T, store MyStore, run string) {
+	task := &TaskEntity{UUID: "task-" + run, Name: "first", BotId: "bot-" + run}
+	if err := store.SaveTask(task); err != nil {
+		t.Fatalf("保存任务失败: %v", err)
+	}
+	task = &TaskEntity{UUID: "task-" + run, Name: "second", BotId: "bot-" + run}
+	if err := store.SaveTask(task); err != nil {
+		t.Fatalf("更新任务失败: %v", err)
+	}
+	found := &TaskEntity{UUID: "task-" + run}
+	if err := store.FindTask(found); err != nil || found.Name != "second" {
+		t.Errorf("应按 uuid 更新任务: %q %v", found.Name, err)
+	}
+	if list, _ := store.LoadTask("uuid = ?", task.UUID); len(list) != 1 {
+		t.Errorf("同一 uuid 只应有一条任务: %d", len(list))
+	}
+	err := store.FindTask(&TaskEntity{UUID: "none-" + run})
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("不存在的任务应返回 ErrRecordNotFound: %v", err)
+	}
+	task.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	store.SaveTask(task)
+	if err := store.FindTask(&TaskEntity{UUID: task.UUID}); err == nil {
+		t.Errorf("删除后不应找到任务")
+	}
+}
+
+func conformMsg(t *testing.T, store MyStore, run string) {
+	task := &TaskEntity{UUID: "msg-task-" + run}
+	send := &MsgEntity{
+		TaskId: task.UUID, UniqId: "msg-" + run, OpType: "user",
+		Request: "hello", IsSend: true,
+	}
+	if err := store.SaveMsg(send); err != nil {
+		t.Fatalf("保存消息失败: %v", err)
+	}
+	// 收到回复时只更新 respond，不覆盖 request
+	recv := &MsgEntity{
+		TaskId: task.UUID, UniqId: send.UniqId, OpType: "user",
+		Respond: "world",
+	}
+	if err := store.SaveMsg(recv); err != nil {
+		t.Fatalf("更新消息失败: %v", err)
+	}
+	found := &MsgEntity{UniqId: send.UniqId}
+	if err := store.FindMsg(found); err != nil {
+		t.Fatalf("查询消息失败: %v", err)
+	}
+	if found.Request != "hello" || found.Respond != "world" {
+		t.Errorf("消息部分更新错误: %q %q", found.Request, found.Respond)
+	}
+	store.SaveMsg(&MsgEntity{TaskId: task.UUID, UniqId: "msg2-" + run, OpType: "user"})
+	list, _ := store.LoadMsg(task)
+	if len(list) != 2 || list[0].UniqId != send.UniqId {
+		t.Errorf("消息应按写入顺序返回: %d", len(list))
+	}
+	err := store.FindMsg(&MsgEntity{UniqId: "none-" + run})
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("不存在的消息应返回 ErrRecordNotFound: %v", err)
+	}
+}
+
+func conformBot(t *testing.T, store MyStore, run string) {
+	bot := &BotEntity{
+		UUID: "bot-" + run, Name: "coder", Type: "worker",
+		Tools:        []string{"command", "browser"},
+		OutputSchema: map[string]any{"type": "object"},
+		Remote:       &entity.RemoteAgent{Url: "http://127.0.0.1"},
+	}
+	if err := store.SaveBot(bot); err != nil || bot.Version != 1 {
+		t.Fatalf("首次保存应为版本1: %d %v", bot.Version, err)
+	}
+	found := &BotEntity{UUID: bot.UUID}
+	if err := store.FindBot(found); err != nil {
+		t.Fatalf("查询 bot 失败: %v", err)
+	}
+	if !slices.Equal(found.Tools, bot.Tools) || found.OutputSchema["type"] != "object" {
+		t.Errorf("json 字段未正确保存: %v %v", found.Tools, found.OutputSchema)
+	}
+	if found.Remote == nil || found.Remote.Url != "http://127.0.0.1" {
+		t.Errorf("remote 未正确保存: %+v", found.Remote)
+	}
+	if store.SaveBot(bot); bot.Version != 1 {
+		t.Errorf("内容未变化不应产生新版本: %d", bot.Version)
+	}
+	bot.Tools = []string{"command"}
+	if store.SaveBot(bot); bot.Version != 2 {
+		t.Errorf("修改后应为版本2: %d", bot.Version)
+	}
+	versions, _ := store.LoadVersion("bot = ?", bot.UUID)
+	if len(versions) != 2 || versions[0].Version != 2 {
+		t.Errorf("版本应按新到旧返回: %d", len(versions))
+	}
+	if list, _ := store.LoadBot("uuid = ?", bot.UUID); len(list) != 1 {
+		t.Errorf("同一 uuid 只应有一个 bot: %d", len(list))
+	}
+	bot.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	store.SaveBot(bot)
+	if err := store.FindBot(&BotEntity{UUID: bot.UUID}); err == nil {
+		t.Errorf("删除后不应找到 bot")
+	}
+}
+
+func conformCfg(t *testing.T, store MyStore, run string) {
+	cfg := &CfgEntity{Type: "test-" + run, Name: "conform", Data: map[string]any{"a": "1"}}
+	if err := store.SaveCfg(cfg); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+	cfg = &CfgEntity{Type: cfg.Type, Name: cfg.Name, Data: map[string]any{"a": "2"}}
+	if err := store.SaveCfg(cfg); err != nil {
+		t.Fatalf("更新配置失败: %v", err)
+	}
+	if list, _ := store.LoadCfg("type = ?", cfg.Type); len(list) != 1 {
+		t.Errorf("type+name 相同应更新原配置: %d", len(list))
+	}
+	found := &CfgEntity{Type: cfg.Type, Name: cfg.Name}
+	if err := store.FindCfg(found); err != nil || found.Data["a"] != "2" {
+		t.Errorf("配置未更新: %v %v", found.Data, err)
+	}
+	cfg.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	store.SaveCfg(cfg)
+	err := store.FindCfg(&CfgEntity{Type: cfg.Type, Name: cfg.Name})
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("删除后应返回 ErrRecordNotFound: %v", err)
+	}
+}
+
+func conformMem(t *testing.T, store MyStore, run string) {
+	bot := "mem-" + run
+	first := &MemEntity{Bot: bot, Type: "note", Subject: "a", Content: "first"}
+	second := &MemEntity{Bot: bot, Type: "note", Subject: "b", Content: "second"}
+	store.SaveMem(first)
+	store.SaveMem(second)
+	if first.ID == 0 || first.ID == second.ID {
+		t.Fatalf("新记忆应分配不同 ID: %d %d", first.ID, second.ID)
+	}
+	first.Content, first.Vector = "updated", []float32{0.5, 0.25}
+	if err := store.SaveMem(first); err != nil {
+		t.Fatalf("更新记忆失败: %v", err)
+	}
+	found := &MemEntity{ID: first.ID}
+	if err := store.FindMem(found); err != nil || found.Content != "updated" {
+		t.Errorf("记忆未更新: %q %v", found.Content, err)
+	}
+	if len(found.Vector) != 2 || found.Vector[1] != 0.25 {
+		t.Errorf("向量未正确保存: %v", found.Vector)
+	}
+	list, _ := store.LoadMem("bot = ?", bot)
+	if len(list) != 2 || list[0].ID != second.ID {
+		t.Errorf("记忆应按新到旧返回: %d", len(list))
+	}
+	second.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	store.SaveMem(second)
+	if list, _ = store.LoadMem("bot = ?", bot); len(list) != 1 {
+		t.Errorf("删除后应剩一条记忆: %d", len(list))
+	}
+}
+
+func conformTool(t *testing.T, store MyStore, run string) {
+	tool := &ToolEntity{UUID: "tool-" + run, Type: "py3", Name: "a", Data: map[string]any{"k": "v"}}
+	store.SaveTool(tool)
+	tool = &ToolEntity{UUID: tool.UUID, Type: "py3", Name: "b", Data: map[string]any{"k": "w"}}
+	if err := store.SaveTool(tool); err != nil {
+		t.Fatalf("保存工具失败: %v", err)
+	}
+	found := &ToolEntity{UUID: tool.UUID}
+	if err := store.FindTool(found); err != nil || found.Name != "b" || found.Data["k"] != "w" {
+		t.Errorf("工具未按 uuid 更新: %q %v %v", found.Name, found.Data, err)
+	}
+	if list, _ := store.LoadTool("uuid = ?", tool.UUID); len(list) != 1 {
+		t.Errorf("同一 uuid 只应有一个工具: %d", len(list))
+	}
+}
+
+func conformTodo(t *testing.T, store MyStore, run string) {
+	task := "todo-" + run
+	store.SaveTodo(&TodoEntity{UUID: "t1-" + run, Task: task, Time: "now", Todo: "a"})
+	store.SaveTodo(&TodoEntity{UUID: "t2-" + run, Task: task, Time: "now", Todo: "b", Done: 1})
+	undone, _ := store.LoadTodo()
+	if !slices.ContainsFunc(undone, func(t *TodoEntity) bool { return t.UUID == "t1-"+run }) {
+		t.Errorf("默认应返回未完成的待办")
+	}
+	if slices.ContainsFunc(undone, func(t *TodoEntity) bool { return t.UUID == "t2-"+run }) {
+		t.Errorf("默认不应返回已完成的待办")
+	}
+	done, _ := store.LoadTodo("done = ? AND task = ?", 1, task)
+	if len(done) != 1 || done[0].Todo != "b" {
+		t.Errorf("已完成的待办查询错误: %d", len(done))
+	}
+}
+
+func conformAudit(t *testing.T, store MyStore, run string) {
+	for _, tool := range []string{"a", "b"} {
+		store.SaveAudit(&AuditEntity{Task: "audit-" + run, Tool: tool})
+	}
+	list, _ := store.LoadAudit("task = ?", "audit-"+run)
+	if len(list) != 2 || list[0].Tool != "b" {
+		t.Errorf("审计应按新到旧返回: %d", len(list))
+	}
+	for _, name := range []string{"x", "y"} {
+		store.SaveEval(&EvalEntity{Run: "eval-" + run, Case: name, Passed: true})
+	}
+	evals, _ := store.LoadEval("run = ?", "eval-"+run)
+	if len(evals) != 2 || evals[0].Case != "x" || !evals[0].Passed {
+		t.Errorf("评测结果应按写入顺序返回: %d", len(evals))
+	}
+}
+
+func conformSearch(t *testing.T, store MyStore, run string) {
+	keyword := "zebra" + run
+	store.SaveMem(&MemEntity{Bot: "search-" + run, Subject: "habit", Content: "likes " + keyword})
+	hits, err := store.Search(keyword, nil, 10)
+	if err != nil {
+		t.Fatalf("检索失败: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Kind != "mem" || !strings.Contains(hits[0].Snippet, "<mark>") {
+		t.Errorf("检索结果错误: %+v", hits)
+	}
+}
+
+func conformQuery(t *testing.T, store MyStore, run string) {
+	bot := "query-" + run
+	names := []string{"b", "a", "c", "a", "d"}
+	for i, name := range names {
+		task := &TaskEntity{
+			UUID: fmt.Sprintf("q%d-%s", i, run), Name: name, BotId: bot,
+			// 第一个任务作为组长，其余为子任务
+			Group: fmt.Sprintf("q0-%s", run),
+		}
+		if i%2 == 0 {
+			task.State = "done"
+		}
+		store.SaveTask(task)
+	}
+
+	// 按 id 倒序翻页
+	seen, query := []string{}, NewQuery("-id", 2).Where("botid", OP_EQ, bot)
+	for page := 0; page < 5; page++ {
+		list, err := store.LoadTask(query)
+		if err != nil {
+			t.Fatalf("分页查询失败: %v", err)
+		}
+		for _, task := range list {
+			seen = append(seen, task.UUID)
+		}
+		if query.Next == "" {
+			break
+		}
+		query.Cursor = query.Next
+	}
+	want := []string{}
+	for i := len(names) - 1; i >= 0; i-- {
+		want = append(want, fmt.Sprintf("q%d-%s", i, run))
+	}
+	if !slices.Equal(seen, want) {
+		t.Errorf("翻页结果错误: %v", seen)
+	}
+
+	// 按名称排序，相同时按 id
+	query = NewQuery("name", 3).Where("botid", OP_EQ, bot)
+	first, _ := store.LoadTask(query)
+	query.Cursor = query.Next
+	second, _ := store.LoadTask(query)
+	order := []string{}
+	for _, task := range append(first, second...) {
+		order = append(order, task.Name+task.UUID[:2])
+	}
+	if !slices.Equal(order, []string{"aq1", "aq3", "bq0", "cq2", "dq4"}) || query.Next != "" {
+		t.Errorf("按名称排序错误: %v %q", order, query.Next)
+	}
+
+	// in、like、or 以及列比较
+	query = NewQuery("id", 0).Where("botid", OP_EQ, bot).
+		Where("name", OP_IN, []string{"a", "d"})
+	if list, _ := store.LoadTask(query); len(list) != 3 {
+		t.Errorf("in 过滤错误: %d", len(list))
+	}
+	query = NewQuery("id", 0).Where("botid", OP_EQ, bot).Any(
+		Filter{"group", OP_EQ, Column("uuid")},
+		Filter{"state", OP_LIKE, "%on%"},
+	)
+	if list, _ := store.LoadTask(query); len(list) != 3 {
+		t.Errorf("or 过滤错误: %d", len(list))
+	}
+	if _, err := store.LoadTask(NewQuery("password", 1)); err == nil {
+		t.Errorf("未知的排序字段应返回错误")
+	}
+	query = NewQuery("id", 0).Where("task_id", OP_EQ, "none")
+	if _, err := store.LoadMsg(nil, query); err != nil {
+		t.Errorf("消息查询失败: %v", err)
+	}
+}
@@ -11,3 +11,7 @@ type MemEntity = entity.MemEntity
 type TaskEntity = entity.TaskEntity
 type ToolEntity = entity.ToolEntity
 type TodoEntity = entity.TodoEntity
+type AuditEntity = entity.AuditEntity
+type BotVersion = entity.BotVersion
+type EvalEntity = entity.EvalEntity
+type SearchHit = entity.SearchHit
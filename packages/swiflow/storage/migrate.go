@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration 编号递增的结构或数据迁移，Down 为空表示不可回滚
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB, dialect string) error
+	Down    func(tx *gorm.DB, dialect string) error
+}
+
+// MigrationState 迁移的执行状态
+type MigrationState struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt"`
+}
+
+// schemaVersion 记录已执行的迁移
+type schemaVersion struct {
+	Version   int       `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name;size:100"`
+	AppliedAt time.Time `gorm:"column:applied_at"`
+}
+
+func (m *schemaVersion) TableName() string {
+	return "schema_version"
+}
+
+// Migratable 支持版本化迁移的存储
+type Migratable interface {
+	Migrator() *Migrator
+}
+
+// Migrator 按版本执行迁移，适用于 sqlite 和 mysql
+type Migrator struct {
+	db      *gorm.DB
+	dialect string
+	list    []*Migration
+}
+
+func NewMigrator(db *gorm.DB, dialect string) *Migrator {
+	list := slices.Clone(migrations)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+	return &Migrator{db: db, dialect: dialect, list: list}
+}
+
+// Latest 最新的迁移版本
+func (m *Migrator) Latest() int {
+	if len(m.list) == 0 {
+		return 0
+	}
+	return m.list[len(m.list)-1].Version
+}
+
+// applied 已执行的迁移
+func (m *Migrator) applied() (map[int]*schemaVersion, error) {
+	if err := m.db.AutoMigrate(new(schemaVersion)); err != nil {
+		return nil, fmt.Errorf("failed to create schema_version: %w", err)
+	}
+	rows := []*schemaVersion{}
+	if err := m.db.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load schema_version: %w", err)
+	}
+	result := map[int]*schemaVersion{}
+	for _, row := range rows {
+		result[row.Version] = row
+	}
+	return result, nil
+}
+
+// Current 当前版本，即已执行的最大版本号
+func (m *Migrator) Current() (int, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+	current := 0
+	for version := range applied {
+		current = max(current, version)
+	}
+	return current, nil
+}
+
+// Status 列出全部迁移及执行状态
+func (m *Migrator) Status() ([]*MigrationState, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	result := []*MigrationState{}
+	for _, item := range m.list {
+		state := &MigrationState{Version: item.Version, Name: item.Name}
+		if row, ok := applied[item.Version]; ok {
+			state.Applied, state.AppliedAt = true, &row.AppliedAt
+		}
+		result = append(result, state)
+	}
+	return result, nil
+}
+
+// Pending 尚未执行的迁移
+func (m *Migrator) Pending() ([]*Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	result := []*Migration{}
+	for _, item := range m.list {
+		if _, ok := applied[item.Version]; !ok {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// Up 执行版本号不大于 target 的待执行迁移，target 为 0 时迁移到最新
+func (m *Migrator) Up(target int) ([]*Migration, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+	if target <= 0 {
+		target = m.Latest()
+	}
+	done := []*Migration{}
+	for _, item := range pending {
+		if item.Version > target {
+			break
+		}
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := item.Up(tx, m.dialect); err != nil {
+				return err
+			}
+			return tx.Create(&schemaVersion{
+				Version: item.Version, Name: item.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			log.Printf("[MIGRATION] up %d %s failed: %v", item.Version, item.Name, err)
+			return done, fmt.Errorf("migration %d %s: %w", item.Version, item.Name, err)
+		}
+		log.Printf("[MIGRATION] up %d %s", item.Version, item.Name)
+		done = append(done, item)
+	}
+	return done, nil
+}
+
+// Down 按倒序回滚版本号大于 target 的已执行迁移
+func (m *Migrator) Down(target int) ([]*Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	done := []*Migration{}
+	for _, item := range slices.Backward(m.list) {
+		if item.Version <= target {
+			break
+		}
+		if _, ok := applied[item.Version]; !ok {
+			continue
+		}
+		if item.Down == nil {
+			return done, fmt.Errorf("migration %d %s is irreversible", item.Version, item.Name)
+		}
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := item.Down(tx, m.dialect); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaVersion{}, item.Version).Error
+		})
+		if err != nil {
+			log.Printf("[MIGRATION] down %d %s failed: %v", item.Version, item.Name, err)
+			return done, fmt.Errorf("migration %d %s: %w", item.Version, item.Name, err)
+		}
+		log.Printf("[MIGRATION] down %d %s", item.Version, item.Name)
+		done = append(done, item)
+	}
+	return done, nil
+}
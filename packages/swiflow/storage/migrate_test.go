@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"swiflow/entity"
+	"testing"
+)
+
+func TestMigrator_UpDown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewSQLiteStorage(map[string]any{"path": path})
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	// 早期版本的表结构
+	store.gormDB.Exec("CREATE TABLE llm_msg (id integer PRIMARY KEY, op_type text NOT NULL, msg_id text, task_id text)")
+	store.gormDB.Exec("INSERT INTO llm_msg (id, op_type, msg_id, task_id) VALUES (1, 'user', 'msg-1', 'task-1')")
+
+	migrator := store.Migrator()
+	if done, err := migrator.Up(1); err != nil || len(done) != 1 {
+		t.Fatalf("迁移到版本1失败: %d %v", len(done), err)
+	}
+	msg := &MsgEntity{UniqId: "msg-1"}
+	if err = store.FindMsg(msg); err != nil || msg.TaskId != "task-1" {
+		t.Errorf("旧列名未重命名: %v", err)
+	}
+
+	// 补全旧数据
+	store.gormDB.Exec("INSERT INTO llm_mem (bot, subject, content, scope, importance) VALUES ('bot-1', 's', 'c', '', 0)")
+	if err = store.AutoMigrate(); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	if current, _ := migrator.Current(); current != migrator.Latest() {
+		t.Errorf("应迁移到最新版本: %d", current)
+	}
+	mems, _ := store.LoadMem("bot = ?", "bot-1")
+	if len(mems) != 1 || mems[0].Scope != entity.SCOPE_BOT || mems[0].Importance != entity.MEM_NORMAL {
+		t.Errorf("记忆未补全: %+v", mems)
+	}
+
+	// 回滚后全文索引被删除，再次迁移可恢复
+	if _, err = migrator.Down(1); err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	if store.gormDB.Migrator().HasTable("llm_msg_fts") {
+		t.Errorf("回滚后不应存在全文索引")
+	}
+	list, _ := migrator.Status()
+	if len(list) != len(migrations) || !list[0].Applied || list[1].Applied {
+		t.Errorf("迁移状态错误: %+v", list)
+	}
+	if _, err = migrator.Down(0); err == nil {
+		t.Errorf("初始迁移不可回滚")
+	}
+	if _, err = migrator.Up(0); err != nil {
+		t.Fatalf("再次迁移失败: %v", err)
+	}
+	if !store.gormDB.Migrator().HasTable("llm_msg_fts") {
+		t.Errorf("再次迁移后应存在全文索引")
+	}
+}
+
+// 实体的列与 testdata/schema.json 不一致时，需要追加迁移并更新快照：
+// UPDATE_SCHEMA=1 go test ./storage -run TestMigrations_Schema
+func TestMigrations_Schema(t *testing.T) {
+	type snapshot struct {
+		Version int                 `json:"version"`
+		Tables  map[string][]string `json:"tables"`
+		Indexes map[string][]string `json:"indexes"`
+	}
+	latest := migrations[len(migrations)-1].Version
+	current := snapshot{
+		Version: latest, Tables: map[string][]string{},
+		Indexes: map[string][]string{},
+	}
+	for _, spec := range backupSpecs {
+		sch, err := backupSchema(spec.model)
+		if err != nil {
+			t.Fatalf("解析实体失败: %v", err)
+		}
+		columns := slices.Clone(sch.DBNames)
+		slices.Sort(columns)
+		current.Tables[sch.Table] = columns
+		indexes := []string{}
+		for _, index := range sch.ParseIndexes() {
+			indexes = append(indexes, index.Name)
+		}
+		slices.Sort(indexes)
+		current.Indexes[sch.Table] = indexes
+	}
+
+	path := filepath.Join("testdata", "schema.json")
+	if os.Getenv("UPDATE_SCHEMA") != "" {
+		data, _ := json.MarshalIndent(current, "", "  ")
+		os.MkdirAll("testdata", 0755)
+		if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			t.Fatalf("写入快照失败: %v", err)
+		}
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取快照失败: %v", err)
+	}
+	saved := snapshot{}
+	if err = json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("解析快照失败: %v", err)
+	}
+	if reflect.DeepEqual(saved.Tables, current.Tables) &&
+		reflect.DeepEqual(saved.Indexes, current.Indexes) {
+		return
+	}
+	if saved.Version >= latest {
+		t.Fatalf("实体的列或索引已变更，已有数据库不会重新执行版本 1，请追加迁移（如 addColumns）后更新快照")
+	}
+	t.Fatalf("已追加迁移 %d，请用 UPDATE_SCHEMA=1 更新快照", latest)
+}
+
+func TestMigrations_AddColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewSQLiteStorage(map[string]any{"path": path})
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	db := store.gormDB
+	db.Exec("CREATE TABLE llm_todo (id integer PRIMARY KEY, uuid text)")
+	// 已存在的列跳过，可重复执行
+	for i := 0; i < 2; i++ {
+		if err = addColumns(db, new(TodoEntity), "uuid", "Done", "CreatedAt"); err != nil {
+			t.Fatalf("添加列失败: %v", err)
+		}
+	}
+	if !db.Migrator().HasColumn(new(TodoEntity), "done") {
+		t.Errorf("新增列未添加")
+	}
+}
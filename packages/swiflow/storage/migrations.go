@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"swiflow/entity"
+
+	"gorm.io/gorm"
+)
+
+// migrations 按版本号递增追加，已发布的迁移不要修改
+// 版本 1 按当前的实体结构建表，只对新安装生效；已记录版本 1 的数据库不会再执行它，
+// 所以实体的每次列变更（新增列、改类型、加索引）都要追加新的迁移，
+// 新增列用 addColumns，对已按最新结构建表的新安装也能安全执行。
+// testdata/schema.json 记录最新版本对应的列和索引，变更而未追加迁移时测试失败
+var migrations = []*Migration{
+	{
+		Version: 1, Name: "initial tables",
+		Up: func(tx *gorm.DB, dialect string) error {
+			// 兼容早期版本的列名
+			var msg = new(MsgEntity)
+			var migrator = tx.Migrator()
+			renames := map[string]string{
+				"msg_id": "uniq_id", "pre_msg": "prev_id",
+			}
+			if dialect == "sqlite" {
+				renames["bot_id"] = "group"
+			}
+			for from, to := range renames {
+				if migrator.HasTable(msg) && migrator.HasColumn(msg, from) {
+					if err := migrator.RenameColumn(msg, from, to); err != nil {
+						return err
+					}
+				}
+			}
+			return tx.AutoMigrate(
+				new(TaskEntity), new(BotEntity), msg,
+				new(CfgEntity), new(ToolEntity),
+				new(MemEntity), new(TodoEntity), new(AuditEntity),
+				new(BotVersion), new(EvalEntity),
+			)
+		},
+	},
+	{
+		Version: 2, Name: "full-text search",
+		Up: func(tx *gorm.DB, dialect string) error {
+			switch dialect {
+			case "sqlite":
+				return createFTS(tx)
+			case "mysql":
+				return createFullText(tx)
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB, dialect string) error {
+			switch dialect {
+			case "sqlite":
+				return dropFTS(tx)
+			case "mysql":
+				return dropFullText(tx)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 3, Name: "backfill memory scope and importance",
+		Up: func(tx *gorm.DB, dialect string) error {
+			err := tx.Model(new(MemEntity)).
+				Where("scope IS NULL OR scope = ''").
+				Update("scope", entity.SCOPE_BOT).Error
+			if err != nil {
+				return err
+			}
+			return tx.Model(new(MemEntity)).
+				Where("importance IS NULL OR importance = 0").
+				Update("importance", entity.MEM_NORMAL).Error
+		},
+		// 仅补全数据，回滚无需处理
+		Down: func(tx *gorm.DB, dialect string) error {
+			return nil
+		},
+	},
+	{
+		Version: 4, Name: "postgres jsonb columns",
+		Up: func(tx *gorm.DB, dialect string) error {
+			return alterJson(tx, dialect, "jsonb", "NULLIF(%s::text, '')::jsonb")
+		},
+		Down: func(tx *gorm.DB, dialect string) error {
+			return alterJson(tx, dialect, "text", "%s::text")
+		},
+	},
+	{
+		Version: 5, Name: "encrypt config secrets",
+		Up: func(tx *gorm.DB, dialect string) error {
+			// 读取时已解密，重新保存即由 BeforeSave 加密
+			var list []*CfgEntity
+			if err := tx.Unscoped().Find(&list).Error; err != nil {
+				return err
+			}
+			for _, item := range list {
+				if err := tx.Unscoped().Select("data").Save(item).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB, dialect string) error {
+			var list []*CfgEntity
+			if err := tx.Unscoped().Find(&list).Error; err != nil {
+				return err
+			}
+			for _, item := range list {
+				data, err := json.Marshal(item.Data)
+				if err != nil {
+					return err
+				}
+				// UpdateColumn 跳过钩子，按明文写回
+				err = tx.Unscoped().Model(item).UpdateColumn("data", string(data)).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 6, Name: "unique bot version",
+		Up: func(tx *gorm.DB, dialect string) error {
+			// 并发保存可能已产生重复的版本号，重复的记录顺延到最新版本之后
+			var dups []struct {
+				Bot     string
+				Version int
+			}
+			err := tx.Unscoped().Model(new(BotVersion)).Select("bot, version").
+				Group("bot, version").Having("COUNT(*) > 1").Scan(&dups).Error
+			if err != nil {
+				return err
+			}
+			for _, dup := range dups {
+				var rows []*BotVersion
+				err = tx.Unscoped().Where("bot = ? AND version = ?", dup.Bot, dup.Version).
+					Order("id").Find(&rows).Error
+				if err != nil {
+					return err
+				}
+				for _, row := range rows[1:] {
+					var last int
+					err = tx.Unscoped().Model(new(BotVersion)).Where("bot = ?", dup.Bot).
+						Select("COALESCE(MAX(version), 0)").Scan(&last).Error
+					if err != nil {
+						return err
+					}
+					if err = tx.Unscoped().Model(row).UpdateColumn("version", last+1).Error; err != nil {
+						return err
+					}
+				}
+			}
+			if migrator := tx.Migrator(); !migrator.HasIndex(new(BotVersion), "idx_bot_version") {
+				return migrator.CreateIndex(new(BotVersion), "idx_bot_version")
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB, dialect string) error {
+			if migrator := tx.Migrator(); migrator.HasIndex(new(BotVersion), "idx_bot_version") {
+				return migrator.DropIndex(new(BotVersion), "idx_bot_version")
+			}
+			return nil
+		},
+	},
+}
+
+// addColumns 添加实体上新增的列，已存在的列跳过
+func addColumns(tx *gorm.DB, model any, fields ...string) error {
+	migrator := tx.Migrator()
+	for _, field := range fields {
+		if migrator.HasColumn(model, field) {
+			continue
+		}
+		if err := migrator.AddColumn(model, field); err != nil {
+			return fmt.Errorf("add column %s: %w", field, err)
+		}
+	}
+	return nil
+}
+
+// alterJson 修改 serializer:json 字段的列类型，仅 postgres 需要
+func alterJson(tx *gorm.DB, dialect, kind, using string) error {
+	if dialect != "postgres" {
+		return nil
+	}
+	models := []any{
+		new(BotEntity), new(BotVersion), new(CfgEntity),
+		new(ToolEntity), new(EvalEntity),
+	}
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.Serializer == nil || field.DBName == "" {
+				continue
+			}
+			column := tx.Statement.Quote(field.DBName)
+			sql := fmt.Sprintf(
+				"ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s",
+				tx.Statement.Quote(stmt.Schema.Table), column,
+				kind, fmt.Sprintf(using, column),
+			)
+			if err := tx.Exec(sql).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
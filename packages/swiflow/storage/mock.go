@@ -1,14 +1,34 @@
 package storage
 
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"swiflow/entity"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
 // MockStore 是一个模拟的存储实现，用于测试
 type MockStore struct {
-	bots  []*BotEntity
-	msgs  []*MsgEntity
-	cfgs  []*CfgEntity
-	mems  []*MemEntity
-	tools []*ToolEntity
-	tasks []*TaskEntity
-	todos []*TodoEntity
+	bots     []*BotEntity
+	msgs     []*MsgEntity
+	cfgs     []*CfgEntity
+	mems     []*MemEntity
+	tools    []*ToolEntity
+	tasks    []*TaskEntity
+	todos    []*TodoEntity
+	audit    []*AuditEntity
+	versions []*BotVersion
+	evals    []*EvalEntity
+
+	serial uint // 自增 ID
 }
 
 // NewMockStore 创建一个新的 MockStore 实例
@@ -21,6 +41,7 @@ func NewMockStore() *MockStore {
 		tools: make([]*ToolEntity, 0),
 		tasks: make([]*TaskEntity, 0),
 		todos: make([]*TodoEntity, 0),
+		audit: make([]*AuditEntity, 0),
 	}
 }
 
@@ -64,41 +85,77 @@ func (m *MockStore) GetTools() []*ToolEntity {
 	return m.tools
 }
 
-// 实现 MyStore 接口的所有方法
+// 实现 MyStore 接口的所有方法，行为与 sql 存储保持一致，见 conformance_test.go
 
 func (m *MockStore) AutoMigrate() error {
 	return nil
 }
 
-func (m *MockStore) InitTask(tool *TaskEntity) error {
+// stamp 新记录分配自增 ID，并更新时间
+func (m *MockStore) stamp(id *uint, model *gorm.Model) {
+	if *id == 0 {
+		m.serial++
+		*id = m.serial
+	}
+	now := time.Now()
+	if model.CreatedAt.IsZero() {
+		model.CreatedAt = now
+	}
+	model.UpdatedAt = now
+}
+
+func notFound(name string) error {
+	return fmt.Errorf("failed to load %s: %w", name, gorm.ErrRecordNotFound)
+}
+
+func (m *MockStore) InitTask(task *TaskEntity) error {
+	if task.UUID == "" {
+		return fmt.Errorf("task uuid empty")
+	}
+	for _, t := range m.tasks {
+		if t.UUID == task.UUID {
+			return fmt.Errorf("failed to init task: duplicated %s", task.UUID)
+		}
+	}
+	m.stamp(&task.ID, &task.Model)
+	m.tasks = append(m.tasks, task)
 	return nil
 }
 
-func (m *MockStore) FindTask(tool *TaskEntity) error {
-	for _, j := range m.tasks {
-		if j.ID == tool.ID || j.Name == tool.Name {
-			*tool = *j
+func (m *MockStore) FindTask(task *TaskEntity) error {
+	for _, t := range m.tasks {
+		if t.UUID == task.UUID {
+			*task = *t
 			return nil
 		}
 	}
-	return nil
+	return notFound("task")
 }
 
-func (m *MockStore) SaveTask(tool *TaskEntity) error {
-	for i, j := range m.tasks {
-		if j.ID == tool.ID || j.Name == tool.Name {
-			m.tasks[i] = tool
+func (m *MockStore) SaveTask(task *TaskEntity) error {
+	for i, t := range m.tasks {
+		if t.UUID != task.UUID {
+			continue
+		}
+		if !task.DeletedAt.Time.IsZero() {
+			m.tasks = slices.Delete(m.tasks, i, i+1)
 			return nil
 		}
+		task.ID, task.CreatedAt = t.ID, t.CreatedAt
+		m.stamp(&task.ID, &task.Model)
+		m.tasks[i] = task
+		return nil
+	}
+	if task.DeletedAt.Time.IsZero() {
+		m.stamp(&task.ID, &task.Model)
+		m.tasks = append(m.tasks, task)
 	}
-	m.tasks = append(m.tasks, tool)
 	return nil
 }
 
+// LoadTask loads tasks with simple query parameters, newest first
 func (m *MockStore) LoadTask(query ...any) ([]*TaskEntity, error) {
-	// Mock implementation simply returns all tasks
-	// In a real implementation, you would filter based on query parameters
-	return m.tasks, nil
+	return mockLoad(m.tasks, query, "-id")
 }
 
 func (m *MockStore) FindMsg(msg *MsgEntity) error {
@@ -108,35 +165,42 @@ func (m *MockStore) FindMsg(msg *MsgEntity) error {
 			return nil
 		}
 	}
-	return nil
+	return notFound("msg")
 }
 
+// SaveMsg 已存在时与 sql 存储一样只更新部分字段
 func (m *MockStore) SaveMsg(msg *MsgEntity) error {
-	for i, mmsg := range m.msgs {
-		if mmsg.UniqId == msg.UniqId {
-			m.msgs[i] = msg
-			return nil
+	for _, mmsg := range m.msgs {
+		if mmsg.UniqId != msg.UniqId {
+			continue
+		}
+		mmsg.OpType, mmsg.TaskId = msg.OpType, msg.TaskId
+		mmsg.PrevId, mmsg.Group = msg.PrevId, msg.Group
+		if msg.IsSend {
+			mmsg.Request, mmsg.SendAt = msg.Request, msg.SendAt
+		} else if msg.Context == "" {
+			mmsg.Respond, mmsg.RecvAt = msg.Respond, msg.RecvAt
+		} else {
+			mmsg.Respond, mmsg.Context = msg.Respond, msg.Context
 		}
+		m.stamp(&mmsg.ID, &mmsg.Model)
+		msg.ID = mmsg.ID
+		return nil
 	}
+	m.stamp(&msg.ID, &msg.Model)
 	m.msgs = append(m.msgs, msg)
 	return nil
 }
 
-func (m *MockStore) LoadMsg(task *TaskEntity) ([]*MsgEntity, error) {
+func (m *MockStore) LoadMsg(task *TaskEntity, query ...any) ([]*MsgEntity, error) {
 	var result = []*MsgEntity{}
-	if len(m.msgs) == 0 {
-		return result, nil
-	}
-	if task == nil || task.UUID == "" {
-		return result, nil
-	}
 	for _, msg := range m.msgs {
-		if msg.TaskId != task.UUID {
+		if task != nil && msg.TaskId != task.UUID {
 			continue
 		}
 		result = append(result, msg)
 	}
-	return result, nil
+	return mockLoad(result, query, "id")
 }
 func (m *MockStore) ClearMsg(task *TaskEntity) error {
 	if len(m.msgs) == 0 {
@@ -158,30 +222,66 @@ func (m *MockStore) ClearMsg(task *TaskEntity) error {
 
 func (m *MockStore) FindBot(bot *BotEntity) error {
 	for _, b := range m.bots {
-		if b.ID == bot.ID || b.Name == bot.Name {
+		if b.UUID == bot.UUID {
 			*bot = *b
 			return nil
 		}
 	}
-	return nil
+	return notFound("bot")
 }
 
 func (m *MockStore) SaveBot(bot *BotEntity) error {
+	if !bot.DeletedAt.Time.IsZero() {
+		m.bots = slices.DeleteFunc(m.bots, func(b *BotEntity) bool {
+			return b.UUID == bot.UUID
+		})
+		return nil
+	}
+	m.saveVersion(bot)
 	for i, b := range m.bots {
-		if b.ID == bot.ID || b.Name == bot.Name {
+		if b.UUID == bot.UUID {
+			bot.ID, bot.CreatedAt = b.ID, b.CreatedAt
+			m.stamp(&bot.ID, &bot.Model)
 			m.bots[i] = bot
 			return nil
 		}
 	}
+	m.stamp(&bot.ID, &bot.Model)
 	m.bots = append(m.bots, bot)
 	return nil
 }
 
-// LoadBot loads bots with optional query parameters (mock implementation returns all bots)
+// saveVersion 定义有变化时记录新版本
+func (m *MockStore) saveVersion(bot *BotEntity) {
+	var last *BotVersion
+	for _, v := range m.versions {
+		if v.Bot == bot.UUID && (last == nil || v.Version > last.Version) {
+			last = v
+		}
+	}
+	version := entity.NewBotVersion(bot)
+	if last != nil && last.Same(version) {
+		bot.Version = last.Version
+		return
+	}
+	if last != nil {
+		version.Version = last.Version + 1
+	} else {
+		version.Version = 1
+	}
+	m.stamp(&version.ID, &version.Model)
+	bot.Version = version.Version
+	m.versions = append(m.versions, version)
+}
+
+// LoadVersion returns versions newest first
+func (m *MockStore) LoadVersion(query ...any) ([]*BotVersion, error) {
+	return mockLoad(m.versions, query, "-version")
+}
+
+// LoadBot loads bots with simple query parameters, newest first
 func (m *MockStore) LoadBot(query ...any) ([]*BotEntity, error) {
-	// Note: Mock implementation returns all bots regardless of query parameters
-	// In a real implementation, query parameters would be used to filter results
-	return m.bots, nil
+	return mockLoad(m.bots, query, "-id")
 }
 
 func (m *MockStore) FindCfg(cfg *CfgEntity) error {
@@ -192,136 +292,264 @@ func (m *MockStore) FindCfg(cfg *CfgEntity) error {
 			return nil
 		}
 	}
-	cfg.Data = nil
-	return nil
+	return notFound("cfg")
 }
 
 func (m *MockStore) SaveCfg(cfg *CfgEntity) error {
 	for i, c := range m.cfgs {
-		if c.Type == cfg.Type && c.Name == cfg.Name {
-			m.cfgs[i] = cfg
+		if c.Type != cfg.Type || c.Name != cfg.Name {
+			continue
+		}
+		if !cfg.DeletedAt.Time.IsZero() {
+			m.cfgs = slices.Delete(m.cfgs, i, i+1)
 			return nil
 		}
+		cfg.ID, cfg.CreatedAt = c.ID, c.CreatedAt
+		m.stamp(&cfg.ID, &cfg.Model)
+		m.cfgs[i] = cfg
+		return nil
+	}
+	if cfg.DeletedAt.Time.IsZero() {
+		m.stamp(&cfg.ID, &cfg.Model)
+		m.cfgs = append(m.cfgs, cfg)
 	}
-	m.cfgs = append(m.cfgs, cfg)
 	return nil
 }
 
-// LoadCfg loads configurations with optional query parameters (mock implementation returns all configs)
+// LoadCfg loads configurations with simple query parameters
 func (m *MockStore) LoadCfg(query ...any) ([]*CfgEntity, error) {
-	// Note: Mock implementation returns all configs regardless of query parameters
-	// In a real implementation, query parameters would be used to filter results
-	return m.cfgs, nil
+	return mockLoad(m.cfgs, query, "id")
 }
 
 func (m *MockStore) FindMem(mem *MemEntity) error {
 	for _, mm := range m.mems {
-		if mm.ID == mem.ID || (mm.Bot == mem.Bot && mm.Type == mem.Type) {
+		if mm.ID == mem.ID {
 			*mem = *mm
 			return nil
 		}
 	}
-	return nil
+	return notFound("mem")
 }
 
 func (m *MockStore) SaveMem(mem *MemEntity) error {
 	for i, mm := range m.mems {
-		if mm.ID == mem.ID || (mm.Bot == mem.Bot && mm.Type == mem.Type) {
-			m.mems[i] = mem
+		if mem.ID == 0 || mm.ID != mem.ID {
+			continue
+		}
+		if !mem.DeletedAt.Time.IsZero() {
+			m.mems = slices.Delete(m.mems, i, i+1)
 			return nil
 		}
+		mem.CreatedAt = mm.CreatedAt
+		m.stamp(&mem.ID, &mem.Model)
+		m.mems[i] = mem
+		return nil
+	}
+	if mem.ID == 0 && mem.DeletedAt.Time.IsZero() {
+		m.stamp(&mem.ID, &mem.Model)
+		m.mems = append(m.mems, mem)
 	}
-	m.mems = append(m.mems, mem)
 	return nil
 }
 
-// LoadMem loads memories with optional query parameters (mock implementation returns all memories)
+// LoadMem loads memories with simple query parameters, newest first
 func (m *MockStore) LoadMem(query ...any) ([]*MemEntity, error) {
-	// Note: Mock implementation returns all memories regardless of query parameters
-	// In a real implementation, query parameters would be used to filter results
-	return m.mems, nil
+	return mockLoad(m.mems, query, "-id")
 }
 
 func (m *MockStore) FindTool(tool *ToolEntity) error {
 	for _, t := range m.tools {
-		if t.ID == tool.ID || t.Name == tool.Name {
+		if t.UUID == tool.UUID {
 			*tool = *t
 			return nil
 		}
 	}
-	return nil
+	return notFound("tool")
 }
 
 func (m *MockStore) SaveTool(tool *ToolEntity) error {
 	for i, t := range m.tools {
-		if t.ID == tool.ID || t.Name == tool.Name {
-			m.tools[i] = tool
+		if t.UUID != tool.UUID {
+			continue
+		}
+		if !tool.DeletedAt.Time.IsZero() {
+			m.tools = slices.Delete(m.tools, i, i+1)
 			return nil
 		}
+		tool.ID, tool.CreatedAt = t.ID, t.CreatedAt
+		m.stamp(&tool.ID, &tool.Model)
+		m.tools[i] = tool
+		return nil
+	}
+	if tool.DeletedAt.Time.IsZero() {
+		m.stamp(&tool.ID, &tool.Model)
+		m.tools = append(m.tools, tool)
 	}
-	m.tools = append(m.tools, tool)
 	return nil
 }
 
-// LoadTool loads tools with optional query parameters (mock implementation returns all tools)
+// LoadTool loads tools with simple query parameters, newest first
 func (m *MockStore) LoadTool(query ...any) ([]*ToolEntity, error) {
-	// Note: Mock implementation returns all tools regardless of query parameters
-	// In a real implementation, query parameters would be used to filter results
-	return m.tools, nil
+	return mockLoad(m.tools, query, "-id")
 }
 
 func (m *MockStore) FindTodo(todo *TodoEntity) error {
 	for _, t := range m.todos {
-		if t.ID == todo.ID || t.UUID == todo.UUID {
+		if t.UUID == todo.UUID {
 			*todo = *t
 			return nil
 		}
 	}
-	return nil
+	return notFound("todo")
 }
 
 func (m *MockStore) SaveTodo(todo *TodoEntity) error {
 	for i, t := range m.todos {
-		if t.ID == todo.ID || t.UUID == todo.UUID {
+		if t.UUID == todo.UUID {
+			todo.ID, todo.CreatedAt = t.ID, t.CreatedAt
+			m.stamp(&todo.ID, &todo.Model)
 			m.todos[i] = todo
 			return nil
 		}
 	}
+	m.stamp(&todo.ID, &todo.Model)
 	m.todos = append(m.todos, todo)
 	return nil
 }
 
-// LoadTodo loads todos with optional query parameters (mock implementation returns all todos)
+// LoadTodo loads todos with simple query parameters, undone todos by default
 func (m *MockStore) LoadTodo(query ...any) ([]*TodoEntity, error) {
-	var result []*TodoEntity
+	if len(query) == 0 {
+		query = []any{"done = ?", 0}
+	}
+	return mockLoad(m.todos, query, "-id")
+}
+
+func (m *MockStore) SaveAudit(audit *AuditEntity) error {
+	m.stamp(&audit.ID, &audit.Model)
+	m.audit = append(m.audit, audit)
+	return nil
+}
+
+// LoadAudit returns audits newest first
+func (m *MockStore) LoadAudit(query ...any) ([]*AuditEntity, error) {
+	return mockLoad(m.audit, query, "-id")
+}
+
+func (m *MockStore) SaveEval(eval *EvalEntity) error {
+	m.stamp(&eval.ID, &eval.Model)
+	m.evals = append(m.evals, eval)
+	return nil
+}
+
+// LoadEval returns evals in insertion order
+func (m *MockStore) LoadEval(query ...any) ([]*EvalEntity, error) {
+	return mockLoad(m.evals, query, "id")
+}
+
+var mockSchemas sync.Map
+var mockAnd = regexp.MustCompile(`(?i)\s+and\s+`)
+
+// mockLoad 过滤并排序记录，sort 为 "-" 开头时倒序
+func mockLoad[T any](items []*T, query []any, sort string) ([]*T, error) {
+	if q := toQuery(query); q != nil {
+		return queryList(q, items, sort)
+	}
+	result := []*T{}
+	for _, item := range items {
+		if mockMatch(item, query) {
+			result = append(result, item)
+		}
+	}
+	if strings.HasPrefix(sort, "-") {
+		slices.Reverse(result)
+	}
+	return result, nil
+}
 
-	// If no query parameters provided, default to undone todos (backward compatibility)
+// mockMatch 支持 "col = ?"、"a = ? AND b = ?"、"col", val 和 map 形式的条件
+// 无法解析的条件视为匹配全部
+func mockMatch(item any, query []any) bool {
 	if len(query) == 0 {
-		for _, t := range m.todos {
-			if t.Done == 0 {
-				result = append(result, t)
+		return true
+	}
+	conds := map[string]any{}
+	switch where := query[0].(type) {
+	case map[string]any:
+		conds = where
+	case string:
+		parts := mockAnd.Split(where, -1)
+		if len(parts) != len(query)-1 {
+			return true
+		}
+		for i, part := range parts {
+			col := strings.TrimSuffix(strings.TrimSpace(part), "?")
+			col = strings.TrimSuffix(strings.TrimSpace(col), "=")
+			col = strings.Trim(strings.TrimSpace(col), "`\"")
+			if strings.ContainsAny(col, " ()<>!") {
+				return true
 			}
+			conds[col] = query[i+1]
 		}
-	} else {
-		// For mock implementation, we support basic done status filtering
-		// First parameter should be "done = ?" and second should be the value (0 or 1)
-		if len(query) >= 2 {
-			if query[0] == "done = ?" {
-				doneValue := query[1]
-				for _, t := range m.todos {
-					if (doneValue == 0 && t.Done == 0) || (doneValue == 1 && t.Done != 0) {
-						result = append(result, t)
-					}
+	default:
+		return true
+	}
+	parsed, err := schema.Parse(item, &mockSchemas, schema.NamingStrategy{})
+	if err != nil {
+		return true
+	}
+	value := reflect.Indirect(reflect.ValueOf(item))
+	for col, want := range conds {
+		field := parsed.LookUpField(col)
+		if field == nil {
+			return false
+		}
+		got, _ := field.ValueOf(context.Background(), value)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			return false
+		}
+	}
+	return true
+}
+
+// Search matches terms by substring (mock implementation ignores ranking)
+func (m *MockStore) Search(query string, kinds []string, limit int) ([]*SearchHit, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []*SearchHit{}, nil
+	}
+	matches := func(text string) bool {
+		for _, term := range terms {
+			if !strings.Contains(strings.ToLower(text), strings.ToLower(term)) {
+				return false
+			}
+		}
+		return true
+	}
+	hits := []*SearchHit{}
+	for _, spec := range searchKinds(kinds) {
+		rows := []*searchRow{}
+		switch spec.kind {
+		case entity.SEARCH_TASK:
+			for _, t := range m.tasks {
+				if matches(t.Name + "\n" + t.Context) {
+					rows = append(rows, &searchRow{Task: t.UUID, Bot: t.BotId, Title: t.Name, A: t.Name, B: t.Context, Time: t.UpdatedAt})
+				}
+			}
+		case entity.SEARCH_MSG:
+			for _, msg := range m.msgs {
+				if matches(msg.Request + "\n" + msg.Respond) {
+					rows = append(rows, &searchRow{Task: msg.TaskId, UniqId: msg.UniqId, A: msg.Request, B: msg.Respond, Time: msg.UpdatedAt})
+				}
+			}
+		case entity.SEARCH_MEM:
+			for _, mem := range m.mems {
+				if matches(mem.Subject + "\n" + mem.Content) {
+					rows = append(rows, &searchRow{MemId: mem.ID, Bot: mem.Bot, Title: mem.Subject, A: mem.Subject, B: mem.Content, Time: mem.UpdatedAt})
 				}
-			} else {
-				// For other query types, return all todos
-				result = m.todos
 			}
-		} else {
-			// Fallback to all todos
-			result = m.todos
 		}
+		hits = append(hits, toHits(spec.kind, rows, terms)...)
 	}
-
-	return result, nil
+	return sortHits(hits, limit), nil
 }
@@ -52,29 +52,18 @@ func NewMySQLStorage(config map[string]any) (*MySQLStorage, error) {
 	}
 }
 
-func (s *MySQLStorage) AutoMigrate() error {
-	var msg = new(MsgEntity)
-	var migrator = s.gormDB.Migrator()
-	if migrator.HasColumn(msg, "msg_id") {
-		migrator.RenameColumn(msg, "msg_id", "uniq_id")
-	}
-	if migrator.HasColumn(msg, "pre_msg") {
-		migrator.RenameColumn(msg, "pre_msg", "prev_id")
-	}
-	task, bot := new(TaskEntity), new(BotEntity)
-	if err := s.gormDB.AutoMigrate(task, bot, msg); err != nil {
-		log.Printf("[MYSQL]failed to migrate tables: %v", err)
-		return fmt.Errorf("failed to migrate tables: %w", err)
-	}
+// Migrator 版本化迁移
+func (s *MySQLStorage) Migrator() *Migrator {
+	return NewMigrator(s.gormDB, "mysql")
+}
 
-	cfg, tool := new(CfgEntity), new(ToolEntity)
-	if err := s.gormDB.AutoMigrate(cfg, tool); err != nil {
-		log.Printf("[MYSQL]failed to migrate tables: %v", err)
-		return fmt.Errorf("failed to migrate tables: %w", err)
-	}
+func (s *MySQLStorage) Backup() *Backup {
+	return NewBackup(s.gormDB, "mysql")
+}
 
-	mem, todo := new(MemEntity), new(TodoEntity)
-	if err := s.gormDB.AutoMigrate(mem, todo); err != nil {
+// AutoMigrate 执行全部待执行的迁移
+func (s *MySQLStorage) AutoMigrate() error {
+	if _, err := s.Migrator().Up(0); err != nil {
 		log.Printf("[MYSQL]failed to migrate tables: %v", err)
 		return fmt.Errorf("failed to migrate tables: %w", err)
 	}
@@ -98,22 +87,20 @@ func (s *MySQLStorage) InitTask(task *TaskEntity) error {
 // LoadTask lists tasks with optional query parameters
 func (s *MySQLStorage) LoadTask(query ...any) ([]*TaskEntity, error) {
 	var models []*TaskEntity
-	threeMonthsAgo := time.Now().AddDate(0, -3, 0)
-
-	// Start with base query for time filter
-	db := s.gormDB.Where("updated_at >= ?", threeMonthsAgo)
-
-	// Apply additional query conditions if provided
-	if len(query) > 0 {
-		db = db.Where(query[0], query[1:]...)
+	db := s.gormDB.Model(&TaskEntity{})
+	// 未分页时只列出最近三个月的任务
+	if toQuery(query) == nil {
+		db = db.Where("updated_at >= ?", time.Now().AddDate(0, -3, 0))
 	}
-
-	if result := db.Order("id DESC").Find(&models); result.Error != nil {
-		log.Printf("[MYSQL]failed to list tasks: %v", result.Error)
-		return nil, fmt.Errorf("failed to list tasks: %w", result.Error)
+	db, q, err := withQuery(db, &TaskEntity{}, query, "-id", 0)
+	if err != nil {
+		return nil, err
 	}
-
-	return models, nil
+	if r := db.Find(&models); r.Error != nil {
+		log.Printf("[MYSQL]failed to list tasks: %v", r.Error)
+		return nil, fmt.Errorf("failed to list tasks: %w", r.Error)
+	}
+	return pageOf(q, models), nil
 }
 
 func (s *MySQLStorage) FindTask(task *TaskEntity) error {
@@ -141,6 +128,7 @@ func (s *MySQLStorage) SaveTask(task *TaskEntity) error {
 	update := map[string]any{
 		"uuid": task.UUID, "name": task.Name, "home": task.Home,
 		"group": task.Group, "botid": task.BotId, "state": task.State,
+		"botver": task.BotVer,
 		"sessid": task.SessID, "source": task.Source, "desc": task.Desc,
 		"context": task.Context, "command": task.Command, "process": task.Process,
 	}
@@ -202,17 +190,21 @@ func (s *MySQLStorage) SaveMsg(msg *MsgEntity) error {
 }
 
 // LoadMsg 加载消息数据
-func (s *MySQLStorage) LoadMsg(task *TaskEntity) ([]*MsgEntity, error) {
-	// var msgs []*Msg
+func (s *MySQLStorage) LoadMsg(task *TaskEntity, query ...any) ([]*MsgEntity, error) {
 	var result []*MsgEntity
-
-	// 使用GORM查询消息
-	query := s.gormDB.Where("task_id = ?", task.UUID).Order("id ASC")
-	if err := query.Find(&result).Error; err != nil {
-		log.Printf("[MYSQL]failed to query msgs: %v", err)
-		return result, fmt.Errorf("failed to query msgs: %w", err)
+	db := s.gormDB.Model(&MsgEntity{})
+	if task != nil {
+		db = db.Where("task_id = ?", task.UUID)
+	}
+	db, q, err := withQuery(db, &MsgEntity{}, query, "id", 0)
+	if err != nil {
+		return nil, err
+	}
+	if r := db.Find(&result); r.Error != nil {
+		log.Printf("[MYSQL]failed to query msgs: %v", r.Error)
+		return result, fmt.Errorf("failed to query msgs: %w", r.Error)
 	}
-	return result, nil
+	return pageOf(q, result), nil
 }
 
 func (s *MySQLStorage) FindBot(bot *BotEntity) error {
@@ -243,35 +235,52 @@ func (s *MySQLStorage) SaveBot(bot *BotEntity) error {
 		"emoji": bot.Emoji, "tools": bot.Tools, "deleted_at": nil,
 		"sys_prompt": bot.SysPrompt, "use_prompt": bot.UsePrompt,
 		"leader": bot.Leader, "home": bot.Home, "provider": bot.Provider,
+		"output_schema": bot.OutputSchema, "remote": bot.Remote,
+	}
+	// 定义有变化时记录新版本，与 bot 在同一事务中保存
+	err := saveVersioned(s.gormDB, bot, func(tx *gorm.DB) error {
+		updates["version"] = bot.Version
+		clauses := clause.OnConflict{
+			Columns:   []clause.Column{{Name: "uuid"}},
+			DoUpdates: clause.AssignmentColumns(maputil.Keys(updates)),
+		}
+		return tx.Model(bot).Clauses(clauses).Assign(updates).Create(bot).Error
+	})
+	if err != nil {
+		log.Printf("[MYSQL]failed to save bot: %v", err)
+		return fmt.Errorf("failed to save bot: %w", err)
 	}
+	return nil
+}
 
-	clauses := clause.OnConflict{
-		Columns:   []clause.Column{{Name: "uuid"}},
-		DoUpdates: clause.AssignmentColumns(maputil.Keys(updates)),
+// LoadVersion loads bot versions, newest first
+func (s *MySQLStorage) LoadVersion(query ...any) ([]*BotVersion, error) {
+	var result []*BotVersion
+	db := s.gormDB.Model(&BotVersion{})
+	db, q, err := withQuery(db, &BotVersion{}, query, "-version", 500)
+	if err != nil {
+		return nil, err
 	}
-	query = s.gormDB.Model(bot).Clauses(clauses).Assign(updates)
-	if r := query.Create(bot); r.Error != nil {
-		log.Printf("[MYSQL]failed to save bot: %v", r.Error)
-		return fmt.Errorf("failed to save bot: %w", r.Error)
+	if r := db.Find(&result); r.Error != nil {
+		log.Printf("[MYSQL]failed to query versions: %v", r.Error)
+		return nil, fmt.Errorf("failed to query versions: %w", r.Error)
 	}
-	return nil
+	return pageOf(q, result), nil
 }
 
 // LoadBot loads bots with optional query parameters
 func (s *MySQLStorage) LoadBot(query ...any) ([]*BotEntity, error) {
 	var result []*BotEntity
 	db := s.gormDB.Model(&BotEntity{})
-
-	// Apply additional query conditions if provided
-	if len(query) > 0 {
-		db = db.Where(query[0], query[1:]...)
+	db, q, err := withQuery(db, &BotEntity{}, query, "-id", 0)
+	if err != nil {
+		return nil, err
 	}
-
-	if r := db.Order("id desc").Preload("Memories").Find(&result); r.Error != nil {
+	if r := db.Preload("Memories").Find(&result); r.Error != nil {
 		log.Printf("[MYSQL]failed to query bots: %v", r.Error)
 		return nil, fmt.Errorf("failed to query bots: %w", r.Error)
 	}
-	return result, nil
+	return pageOf(q, result), nil
 }
 
 func (s *MySQLStorage) FindCfg(cfg *CfgEntity) error {
@@ -317,17 +326,15 @@ func (s *MySQLStorage) SaveCfg(cfg *CfgEntity) error {
 func (s *MySQLStorage) LoadCfg(query ...any) ([]*CfgEntity, error) {
 	var result []*CfgEntity
 	db := s.gormDB.Model(&CfgEntity{})
-
-	// Apply additional query conditions if provided
-	if len(query) > 0 {
-		db = db.Where(query[0], query[1:]...)
+	db, q, err := withQuery(db, &CfgEntity{}, query, "id", 0)
+	if err != nil {
+		return nil, err
 	}
-
-	if r := db.Order("id ASC").Find(&result); r.Error != nil {
+	if r := db.Find(&result); r.Error != nil {
 		log.Printf("[MYSQL]failed to query cfg: %v", r.Error)
 		return nil, fmt.Errorf("failed to query cfg: %w", r.Error)
 	}
-	return result, nil
+	return pageOf(q, result), nil
 }
 
 func (s *MySQLStorage) FindMem(mem *MemEntity) error {
@@ -358,6 +365,10 @@ func (s *MySQLStorage) SaveMem(mem *MemEntity) error {
 		updates := map[string]any{
 			"type": mem.Type, "subject": mem.Subject,
 			"bot": mem.Bot, "content": mem.Content,
+			"scope": mem.Scope, "target": mem.Target,
+			"vector": mem.Vector, "embedder": mem.Embedder,
+			"importance": mem.Importance, "expire_at": mem.ExpireAt,
+			"used_at": mem.UsedAt, "used_count": mem.UsedCount,
 		}
 		if r := s.gormDB.Model(mem).Where("id = ?", mem.ID).Updates(updates); r.Error != nil {
 			log.Printf("[MYSQL]failed to update mem: %v", r.Error)
@@ -378,17 +389,15 @@ func (s *MySQLStorage) SaveMem(mem *MemEntity) error {
 func (s *MySQLStorage) LoadMem(query ...any) ([]*MemEntity, error) {
 	var result []*MemEntity
 	db := s.gormDB.Model(&MemEntity{})
-
-	// Apply additional query conditions if provided
-	if len(query) > 0 {
-		db = db.Where(query[0], query[1:]...)
+	db, q, err := withQuery(db, &MemEntity{}, query, "-id", 0)
+	if err != nil {
+		return nil, err
 	}
-
-	if r := db.Order("id desc").Find(&result); r.Error != nil {
+	if r := db.Find(&result); r.Error != nil {
 		log.Printf("[MYSQL]failed to query mem: %v", r.Error)
 		return nil, fmt.Errorf("failed to query mem: %w", r.Error)
 	}
-	return result, nil
+	return pageOf(q, result), nil
 }
 
 func (s *MySQLStorage) FindTool(tool *ToolEntity) error {
@@ -437,17 +446,15 @@ func (s *MySQLStorage) SaveTool(tool *ToolEntity) error {
 func (s *MySQLStorage) LoadTool(query ...any) ([]*ToolEntity, error) {
 	var result []*ToolEntity
 	db := s.gormDB.Model(&ToolEntity{})
-
-	// Apply additional query conditions if provided
-	if len(query) > 0 {
-		db = db.Where(query[0], query[1:]...)
+	db, q, err := withQuery(db, &ToolEntity{}, query, "-id", 0)
+	if err != nil {
+		return nil, err
 	}
-
-	if r := db.Order("id desc").Find(&result); r.Error != nil {
+	if r := db.Find(&result); r.Error != nil {
 		log.Printf("[MYSQL]failed to query tools: %v", r.Error)
 		return nil, fmt.Errorf("failed to query tools: %w", r.Error)
 	}
-	return result, nil
+	return pageOf(q, result), nil
 }
 
 // TodoEntity 相关方法
@@ -485,18 +492,64 @@ func (s *MySQLStorage) SaveTodo(todo *TodoEntity) error {
 func (s *MySQLStorage) LoadTodo(query ...any) ([]*TodoEntity, error) {
 	var result []*TodoEntity
 	db := s.gormDB.Model(&TodoEntity{})
-
 	// If no query parameters provided, default to undone todos (backward compatibility)
 	if len(query) == 0 {
-		db = db.Where("done = ?", 0)
-	} else {
-		// Apply query conditions - first parameter can be used to specify done status
-		db = db.Where(query[0], query[1:]...)
+		query = []any{"done = ?", 0}
 	}
-
-	if r := db.Order("id DESC").Find(&result); r.Error != nil {
+	db, q, err := withQuery(db, &TodoEntity{}, query, "-id", 0)
+	if err != nil {
+		return nil, err
+	}
+	if r := db.Find(&result); r.Error != nil {
 		log.Printf("[MYSQL]failed to query todos: %v", r.Error)
 		return nil, fmt.Errorf("failed to query todos: %w", r.Error)
 	}
-	return result, nil
+	return pageOf(q, result), nil
+}
+
+// AuditEntity 相关方法
+func (s *MySQLStorage) SaveAudit(audit *AuditEntity) error {
+	if r := s.gormDB.Create(audit); r.Error != nil {
+		log.Printf("[MYSQL]failed to save audit: %v", r.Error)
+		return fmt.Errorf("failed to save audit: %w", r.Error)
+	}
+	return nil
+}
+
+// LoadAudit loads recent audits with optional query parameters
+func (s *MySQLStorage) LoadAudit(query ...any) ([]*AuditEntity, error) {
+	var result []*AuditEntity
+	db := s.gormDB.Model(&AuditEntity{})
+	db, q, err := withQuery(db, &AuditEntity{}, query, "-id", 500)
+	if err != nil {
+		return nil, err
+	}
+	if r := db.Find(&result); r.Error != nil {
+		log.Printf("[MYSQL]failed to query audits: %v", r.Error)
+		return nil, fmt.Errorf("failed to query audits: %w", r.Error)
+	}
+	return pageOf(q, result), nil
+}
+
+func (s *MySQLStorage) SaveEval(eval *EvalEntity) error {
+	if r := s.gormDB.Create(eval); r.Error != nil {
+		log.Printf("[MYSQL]failed to save eval: %v", r.Error)
+		return fmt.Errorf("failed to save eval: %w", r.Error)
+	}
+	return nil
+}
+
+// LoadEval loads eval results with optional query parameters
+func (s *MySQLStorage) LoadEval(query ...any) ([]*EvalEntity, error) {
+	var result []*EvalEntity
+	db := s.gormDB.Model(&EvalEntity{})
+	db, q, err := withQuery(db, &EvalEntity{}, query, "id", 2000)
+	if err != nil {
+		return nil, err
+	}
+	if r := db.Find(&result); r.Error != nil {
+		log.Printf("[MYSQL]failed to query evals: %v", r.Error)
+		return nil, fmt.Errorf("failed to query evals: %w", r.Error)
+	}
+	return pageOf(q, result), nil
 }
@@ -0,0 +1,563 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/duke-git/lancet/v2/maputil"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// PostgresStorage PostgreSQL存储实现
+type PostgresStorage struct {
+	baseDb *sql.DB
+	gormDB *gorm.DB
+}
+
+// NewPostgresStorage 创建PostgreSQL存储实例
+func NewPostgresStorage(config map[string]any) (*PostgresStorage, error) {
+	dsn, ok := config["dsn"].(string)
+	if !ok {
+		log.Printf("[POSTGRES]postgres dsn not exists")
+		return nil, fmt.Errorf("postgres dsn not exists")
+	}
+	// 使用GORM连接数据库
+	gormLogger := logger.Default.LogMode(logger.Warn)
+	gormDB, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: gormLogger,
+	})
+	if err != nil {
+		log.Printf("[POSTGRES]postgres connect err %v", err)
+		return nil, fmt.Errorf("postgres connect err %w", err)
+	}
+
+	if baseDB, err := gormDB.DB(); err != nil {
+		log.Printf("[POSTGRES]failed to get sql.DB: %v", err)
+		return nil, fmt.Errorf("failed to get sql.DB: %w", err)
+	} else {
+		// 设置连接池参数
+		baseDB.SetMaxIdleConns(10)
+		baseDB.SetMaxOpenConns(100)
+		baseDB.SetConnMaxLifetime(time.Hour)
+		return &PostgresStorage{baseDB, gormDB}, nil
+	}
+}
+
+// pgQuery 调用方按 mysql 习惯使用反引号，postgres 需改为双引号
+func pgQuery(query []any) []any {
+	if len(query) > 0 {
+		if str, ok := query[0].(string); ok {
+			query = append([]any{strings.ReplaceAll(str, "`", `"`)}, query[1:]...)
+		}
+	}
+	return query
+}
+
+// Migrator 版本化迁移
+func (s *PostgresStorage) Migrator() *Migrator {
+	return NewMigrator(s.gormDB, "postgres")
+}
+
+func (s *PostgresStorage) Backup() *Backup {
+	return NewBackup(s.gormDB, "postgres")
+}
+
+// AutoMigrate 执行全部待执行的迁移
+func (s *PostgresStorage) AutoMigrate() error {
+	if _, err := s.Migrator().Up(0); err != nil {
+		log.Printf("[POSTGRES]failed to migrate tables: %v", err)
+		return fmt.Errorf("failed to migrate tables: %w", err)
+	}
+	return nil
+}
+
+// InitTask 初始化存储
+func (s *PostgresStorage) InitTask(task *TaskEntity) error {
+	if task.UUID == "" {
+		return fmt.Errorf("task uuid empty")
+	}
+
+	// 使用FirstOrCreate创建记录
+	if result := s.gormDB.Create(task); result.Error != nil {
+		log.Printf("[POSTGRES]failed to init task: %v", result.Error)
+		return fmt.Errorf("failed to init task: %w", result.Error)
+	}
+	return nil
+}
+
+// LoadTask lists tasks with optional query parameters
+func (s *PostgresStorage) LoadTask(query ...any) ([]*TaskEntity, error) {
+	var models []*TaskEntity
+	db := s.gormDB.Model(&TaskEntity{})
+	// 未分页时只列出最近三个月的任务
+	if toQuery(query) == nil {
+		db = db.Where("updated_at >= ?", time.Now().AddDate(0, -3, 0))
+	}
+	db, q, err := withQuery(db, &TaskEntity{}, pgQuery(query), "-id", 0)
+	if err != nil {
+		return nil, err
+	}
+	if r := db.Find(&models); r.Error != nil {
+		log.Printf("[POSTGRES]failed to list tasks: %v", r.Error)
+		return nil, fmt.Errorf("failed to list tasks: %w", r.Error)
+	}
+	return pageOf(q, models), nil
+}
+
+func (s *PostgresStorage) FindTask(task *TaskEntity) error {
+	query := s.gormDB.Where("uuid = ?", task.UUID)
+	if result := query.First(&task); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load task: %w", result.Error)
+		} else {
+			log.Printf("[POSTGRES]failed to load task: %v", result.Error)
+			return fmt.Errorf("failed to load task: %w", result.Error)
+		}
+	}
+	return nil
+}
+
+func (s *PostgresStorage) SaveTask(task *TaskEntity) error {
+	query := s.gormDB.Where("uuid = ?", task.UUID)
+	if !task.DeletedAt.Time.IsZero() {
+		if r := query.Delete(task); r.Error != nil {
+			log.Printf("[POSTGRES]failed to delete task: %v", r.Error)
+			return fmt.Errorf("failed to delete task: %w", r.Error)
+		}
+	}
+
+	update := map[string]any{
+		"uuid": task.UUID, "name": task.Name, "home": task.Home,
+		"group": task.Group, "botid": task.BotId, "state": task.State,
+		"botver": task.BotVer,
+		"sessid": task.SessID, "source": task.Source, "desc": task.Desc,
+		"context": task.Context, "command": task.Command, "process": task.Process,
+	}
+
+	clauses := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "uuid"}},
+		DoUpdates: clause.AssignmentColumns(maputil.Keys(update)),
+	}
+	query = s.gormDB.Model(task).Clauses(clauses).Assign(update)
+	if result := query.Create(&task); result.Error != nil {
+		log.Printf("[POSTGRES]failed to save task: %v", result.Error)
+		return fmt.Errorf("failed to save task: %w", result.Error)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) FindMsg(msg *MsgEntity) error {
+	query := s.gormDB.Where("uniq_id = ?", msg.UniqId)
+	if result := query.First(&msg); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load msg: %w", result.Error)
+		} else {
+			log.Printf("[POSTGRES]failed to load msg: %v", result.Error)
+			return fmt.Errorf("failed to load msg: %w", result.Error)
+		}
+	}
+	return nil
+}
+
+func (s *PostgresStorage) SaveMsg(msg *MsgEntity) error {
+	// 构建更新数据
+	updates := map[string]any{
+		"op_type": msg.OpType,
+		"task_id": msg.TaskId,
+		"prev_id": msg.PrevId,
+		"group":   msg.Group,
+	}
+	if msg.IsSend {
+		updates["request"] = msg.Request
+		updates["send_at"] = msg.SendAt
+	} else if msg.Context == "" {
+		updates["recv_at"] = msg.RecvAt
+		updates["respond"] = msg.Respond
+	} else {
+		updates["respond"] = msg.Respond
+		updates["context"] = msg.Context
+	}
+
+	clauses := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "uniq_id"}},
+		DoUpdates: clause.AssignmentColumns(maputil.Keys(updates)),
+	}
+	query := s.gormDB.Model(msg).Clauses(clauses).Assign(updates)
+	if result := query.Create(msg); result.Error != nil {
+		log.Printf("[POSTGRES]failed to save msg: %v", result.Error)
+		return fmt.Errorf("failed to save msg: %w", result.Error)
+	}
+	return nil
+}
+
+// LoadMsg 加载消息数据
+func (s *PostgresStorage) LoadMsg(task *TaskEntity, query ...any) ([]*MsgEntity, error) {
+	var result []*MsgEntity
+	db := s.gormDB.Model(&MsgEntity{})
+	if task != nil {
+		db = db.Where("task_id = ?", task.UUID)
+	}
+	db, q, err := withQuery(db, &MsgEntity{}, pgQuery(query), "id", 0)
+	if err != nil {
+		return nil, err
+	}
+	if r := db.Find(&result); r.Error != nil {
+		log.Printf("[POSTGRES]failed to query msgs: %v", r.Error)
+		return result, fmt.Errorf("failed to query msgs: %w", r.Error)
+	}
+	return pageOf(q, result), nil
+}
+
+func (s *PostgresStorage) FindBot(bot *BotEntity) error {
+	query := s.gormDB.Where("uuid = ?", bot.UUID)
+	if result := query.First(&bot); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load bot: %w", result.Error)
+		} else {
+			log.Printf("[POSTGRES]failed to load bot: %v", result.Error)
+			return fmt.Errorf("failed to load bot: %w", result.Error)
+		}
+	}
+	return nil
+}
+
+func (s *PostgresStorage) SaveBot(bot *BotEntity) error {
+	query := s.gormDB.Model(bot).Where("uuid = ?", bot.UUID)
+	if !bot.DeletedAt.Time.IsZero() {
+		if r := query.Delete(bot); r.Error != nil {
+			log.Printf("[POSTGRES]failed to delete bot: %v", r.Error)
+			return fmt.Errorf("failed to delete bot: %w", r.Error)
+		}
+		return nil
+	}
+
+	updates := map[string]any{
+		"name": bot.Name, "type": bot.Type, "desc": bot.Desc,
+		"emoji": bot.Emoji, "tools": bot.Tools, "deleted_at": nil,
+		"sys_prompt": bot.SysPrompt, "use_prompt": bot.UsePrompt,
+		"leader": bot.Leader, "home": bot.Home, "provider": bot.Provider,
+		"output_schema": bot.OutputSchema, "remote": bot.Remote,
+	}
+	// 定义有变化时记录新版本，与 bot 在同一事务中保存
+	err := saveVersioned(s.gormDB, bot, func(tx *gorm.DB) error {
+		updates["version"] = bot.Version
+		clauses := clause.OnConflict{
+			Columns:   []clause.Column{{Name: "uuid"}},
+			DoUpdates: clause.AssignmentColumns(maputil.Keys(updates)),
+		}
+		return tx.Model(bot).Clauses(clauses).Assign(updates).Create(bot).Error
+	})
+	if err != nil {
+		log.Printf("[POSTGRES]failed to save bot: %v", err)
+		return fmt.Errorf("failed to save bot: %w", err)
+	}
+	return nil
+}
+
+// LoadVersion loads bot versions, newest first
+func (s *PostgresStorage) LoadVersion(query ...any) ([]*BotVersion, error) {
+	var result []*BotVersion
+	db := s.gormDB.Model(&BotVersion{})
+	db, q, err := withQuery(db, &BotVersion{}, pgQuery(query), "-version", 500)
+	if err != nil {
+		return nil, err
+	}
+	if r := db.Find(&result); r.Error != nil {
+		log.Printf("[POSTGRES]failed to query versions: %v", r.Error)
+		return nil, fmt.Errorf("failed to query versions: %w", r.Error)
+	}
+	return pageOf(q, result), nil
+}
+
+// LoadBot loads bots with optional query parameters
+func (s *PostgresStorage) LoadBot(query ...any) ([]*BotEntity, error) {
+	var result []*BotEntity
+	db := s.gormDB.Model(&BotEntity{})
+	db, q, err := withQuery(db, &BotEntity{}, pgQuery(query), "-id", 0)
+	if err != nil {
+		return nil, err
+	}
+	if r := db.Preload("Memories").Find(&result); r.Error != nil {
+		log.Printf("[POSTGRES]failed to query bots: %v", r.Error)
+		return nil, fmt.Errorf("failed to query bots: %w", r.Error)
+	}
+	return pageOf(q, result), nil
+}
+
+func (s *PostgresStorage) FindCfg(cfg *CfgEntity) error {
+	query := s.gormDB.Where("type = ? AND name = ?", cfg.Type, cfg.Name)
+	if result := query.First(&cfg); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load cfg: %w", result.Error)
+		} else {
+			log.Printf("[POSTGRES]failed to load cfg: %v", result.Error)
+			return fmt.Errorf("failed to load cfg: %w", result.Error)
+		}
+	}
+	return nil
+}
+
+func (s *PostgresStorage) SaveCfg(cfg *CfgEntity) error {
+	if !cfg.DeletedAt.Time.IsZero() {
+		where := map[string]any{"type": cfg.Type, "name": cfg.Name}
+		if r := s.gormDB.Where(where).Delete(cfg); r.Error != nil {
+			log.Printf("[POSTGRES]failed to delete cfg: %v", r.Error)
+			return fmt.Errorf("failed to delete cfg: %w", r.Error)
+		}
+		return nil
+	}
+
+	update := map[string]any{
+		"type": cfg.Type, "name": cfg.Name,
+		"data": cfg.Data, "deleted_at": nil,
+	}
+	clauses := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "type"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns(maputil.Keys(update)),
+	}
+	query := s.gormDB.Model(cfg).Clauses(clauses).Assign(update)
+	if r := query.Create(cfg); r.Error != nil {
+		log.Printf("[POSTGRES]failed to save config: %v", r.Error)
+		return fmt.Errorf("failed to save config: %w", r.Error)
+	}
+	return nil
+}
+
+// LoadCfg loads configurations with optional query parameters
+func (s *PostgresStorage) LoadCfg(query ...any) ([]*CfgEntity, error) {
+	var result []*CfgEntity
+	db := s.gormDB.Model(&CfgEntity{})
+	db, q, err := withQuery(db, &CfgEntity{}, pgQuery(query), "id", 0)
+	if err != nil {
+		return nil, err
+	}
+	if r := db.Find(&result); r.Error != nil {
+		log.Printf("[POSTGRES]failed to query cfg: %v", r.Error)
+		return nil, fmt.Errorf("failed to query cfg: %w", r.Error)
+	}
+	return pageOf(q, result), nil
+}
+
+func (s *PostgresStorage) FindMem(mem *MemEntity) error {
+	query := s.gormDB.Where("id = ?", mem.ID)
+	if result := query.First(&mem); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load mem: %w", result.Error)
+		} else {
+			log.Printf("[POSTGRES]failed to load mem: %v", result.Error)
+			return fmt.Errorf("failed to load mem: %w", result.Error)
+		}
+	}
+	return nil
+}
+
+func (s *PostgresStorage) SaveMem(mem *MemEntity) error {
+	// 如果是删除操作
+	if !mem.DeletedAt.Time.IsZero() {
+		if r := s.gormDB.Model(mem).Where("id = ?", mem.ID).Delete(mem); r.Error != nil {
+			log.Printf("[POSTGRES]failed to delete mem: %v", r.Error)
+			return fmt.Errorf("failed to delete mem: %w", r.Error)
+		}
+		return nil
+	}
+
+	// 如果是更新操作（ID不为0）
+	if mem.ID != 0 {
+		updates := map[string]any{
+			"type": mem.Type, "subject": mem.Subject,
+			"bot": mem.Bot, "content": mem.Content,
+			"scope": mem.Scope, "target": mem.Target,
+			"vector": mem.Vector, "embedder": mem.Embedder,
+			"importance": mem.Importance, "expire_at": mem.ExpireAt,
+			"used_at": mem.UsedAt, "used_count": mem.UsedCount,
+		}
+		if r := s.gormDB.Model(mem).Where("id = ?", mem.ID).Updates(updates); r.Error != nil {
+			log.Printf("[POSTGRES]failed to update mem: %v", r.Error)
+			return fmt.Errorf("failed to update mem: %w", r.Error)
+		}
+		return nil
+	}
+
+	// 如果是创建新记录
+	if r := s.gormDB.Model(mem).Create(mem); r.Error != nil {
+		log.Printf("[POSTGRES]failed to create mem: %v", r.Error)
+		return fmt.Errorf("failed to create mem: %w", r.Error)
+	}
+	return nil
+}
+
+// LoadMem loads memories with optional query parameters
+func (s *PostgresStorage) LoadMem(query ...any) ([]*MemEntity, error) {
+	var result []*MemEntity
+	db := s.gormDB.Model(&MemEntity{})
+	db, q, err := withQuery(db, &MemEntity{}, pgQuery(query), "-id", 0)
+	if err != nil {
+		return nil, err
+	}
+	if r := db.Find(&result); r.Error != nil {
+		log.Printf("[POSTGRES]failed to query mem: %v", r.Error)
+		return nil, fmt.Errorf("failed to query mem: %w", r.Error)
+	}
+	return pageOf(q, result), nil
+}
+
+func (s *PostgresStorage) FindTool(tool *ToolEntity) error {
+	query := s.gormDB.Where("uuid = ?", tool.UUID)
+	if result := query.First(&tool); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load tool: %w", result.Error)
+		} else {
+			log.Printf("[POSTGRES]failed to load tool: %v", result.Error)
+			return fmt.Errorf("failed to load tool: %w", result.Error)
+		}
+	}
+	return nil
+}
+
+func (s *PostgresStorage) SaveTool(tool *ToolEntity) error {
+	// 如果是删除操作
+	if !tool.DeletedAt.Time.IsZero() {
+		if r := s.gormDB.Model(tool).Where("uuid = ?", tool.UUID).Delete(tool); r.Error != nil {
+			log.Printf("[POSTGRES]failed to delete tool: %v", r.Error)
+			return fmt.Errorf("failed to delete tool: %w", r.Error)
+		}
+		return nil
+	}
+
+	// 构建更新数据
+	updates := map[string]any{
+		"uuid": tool.UUID, "type": tool.Type,
+		"desc": tool.Desc, "text": tool.Text,
+		"name": tool.Name, "data": tool.Data,
+	}
+
+	clauses := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "uuid"}},
+		DoUpdates: clause.AssignmentColumns(maputil.Keys(updates)),
+	}
+	query := s.gormDB.Model(tool).Clauses(clauses).Assign(updates)
+	if r := query.Create(tool); r.Error != nil {
+		log.Printf("[POSTGRES]failed to save tool: %v", r.Error)
+		return fmt.Errorf("failed to save tool: %w", r.Error)
+	}
+	return nil
+}
+
+// LoadTool loads tools with optional query parameters
+func (s *PostgresStorage) LoadTool(query ...any) ([]*ToolEntity, error) {
+	var result []*ToolEntity
+	db := s.gormDB.Model(&ToolEntity{})
+	db, q, err := withQuery(db, &ToolEntity{}, pgQuery(query), "-id", 0)
+	if err != nil {
+		return nil, err
+	}
+	if r := db.Find(&result); r.Error != nil {
+		log.Printf("[POSTGRES]failed to query tools: %v", r.Error)
+		return nil, fmt.Errorf("failed to query tools: %w", r.Error)
+	}
+	return pageOf(q, result), nil
+}
+
+// TodoEntity 相关方法
+func (s *PostgresStorage) FindTodo(todo *TodoEntity) error {
+	query := s.gormDB.Where("uuid = ?", todo.UUID)
+	if result := query.First(&todo); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load todo: %w", result.Error)
+		}
+		log.Printf("[POSTGRES]failed to load todo: %v", result.Error)
+		return fmt.Errorf("failed to load todo: %w", result.Error)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) SaveTodo(todo *TodoEntity) error {
+	updates := map[string]any{
+		"uuid": todo.UUID, "task": todo.Task,
+		"time": todo.Time, "todo": todo.Todo,
+		"done": todo.Done,
+	}
+	clauses := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "uuid"}},
+		DoUpdates: clause.AssignmentColumns(maputil.Keys(updates)),
+	}
+	query := s.gormDB.Model(todo).Clauses(clauses).Assign(updates)
+	if r := query.Create(todo); r.Error != nil {
+		log.Printf("[POSTGRES]failed to save todo: %v", r.Error)
+		return fmt.Errorf("failed to save todo: %w", r.Error)
+	}
+	return nil
+}
+
+// LoadTodo loads todos with optional query parameters
+func (s *PostgresStorage) LoadTodo(query ...any) ([]*TodoEntity, error) {
+	var result []*TodoEntity
+	db := s.gormDB.Model(&TodoEntity{})
+	// If no query parameters provided, default to undone todos (backward compatibility)
+	if len(query) == 0 {
+		query = []any{"done = ?", 0}
+	}
+	db, q, err := withQuery(db, &TodoEntity{}, pgQuery(query), "-id", 0)
+	if err != nil {
+		return nil, err
+	}
+	if r := db.Find(&result); r.Error != nil {
+		log.Printf("[POSTGRES]failed to query todos: %v", r.Error)
+		return nil, fmt.Errorf("failed to query todos: %w", r.Error)
+	}
+	return pageOf(q, result), nil
+}
+
+// AuditEntity 相关方法
+func (s *PostgresStorage) SaveAudit(audit *AuditEntity) error {
+	if r := s.gormDB.Create(audit); r.Error != nil {
+		log.Printf("[POSTGRES]failed to save audit: %v", r.Error)
+		return fmt.Errorf("failed to save audit: %w", r.Error)
+	}
+	return nil
+}
+
+// LoadAudit loads recent audits with optional query parameters
+func (s *PostgresStorage) LoadAudit(query ...any) ([]*AuditEntity, error) {
+	var result []*AuditEntity
+	db := s.gormDB.Model(&AuditEntity{})
+	db, q, err := withQuery(db, &AuditEntity{}, pgQuery(query), "-id", 500)
+	if err != nil {
+		return nil, err
+	}
+	if r := db.Find(&result); r.Error != nil {
+		log.Printf("[POSTGRES]failed to query audits: %v", r.Error)
+		return nil, fmt.Errorf("failed to query audits: %w", r.Error)
+	}
+	return pageOf(q, result), nil
+}
+
+func (s *PostgresStorage) SaveEval(eval *EvalEntity) error {
+	if r := s.gormDB.Create(eval); r.Error != nil {
+		log.Printf("[POSTGRES]failed to save eval: %v", r.Error)
+		return fmt.Errorf("failed to save eval: %w", r.Error)
+	}
+	return nil
+}
+
+// LoadEval loads eval results with optional query parameters
+func (s *PostgresStorage) LoadEval(query ...any) ([]*EvalEntity, error) {
+	var result []*EvalEntity
+	db := s.gormDB.Model(&EvalEntity{})
+	db, q, err := withQuery(db, &EvalEntity{}, pgQuery(query), "id", 2000)
+	if err != nil {
+		return nil, err
+	}
+	if r := db.Find(&result); r.Error != nil {
+		log.Printf("[POSTGRES]failed to query evals: %v", r.Error)
+		return nil, fmt.Errorf("failed to query evals: %w", r.Error)
+	}
+	return pageOf(q, result), nil
+}
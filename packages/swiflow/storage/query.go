@@ -0,0 +1,444 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	OP_EQ   = "="
+	OP_NE   = "<>"
+	OP_GT   = ">"
+	OP_GTE  = ">="
+	OP_LT   = "<"
+	OP_LTE  = "<="
+	OP_IN   = "in"
+	OP_LIKE = "like"
+	// Value 为 []Filter，任一条件满足即可
+	OP_ANY = "any"
+)
+
+// 单页最多返回的条数
+const MAX_LIMIT = 1000
+
+// Query 列表查询条件，可作为 Load* 方法的唯一参数
+//
+//	query := storage.NewQuery("-id", 50).Where("state", storage.OP_EQ, "running")
+//	list, err := store.LoadTask(query)
+//	next := query.Next // 下一页游标，为空表示没有更多
+type Query struct {
+	Filters []Filter
+	// 排序字段，"-" 前缀表示倒序，相同时按 id 排序
+	Sort   string
+	Cursor string
+	Limit  int
+
+	// 查询后回写下一页的游标
+	Next string
+
+	schema *schema.Schema
+	field  *schema.Field
+	desc   bool
+}
+
+type Filter struct {
+	Field string
+	Op    string
+	Value any
+}
+
+// Column 与另一列比较，如 Filter{"group", OP_EQ, Column("uuid")}
+type Column string
+
+// cursor 上一页最后一条记录的排序值和 id
+type cursor struct {
+	Value any  `json:"v"`
+	ID    uint `json:"id"`
+}
+
+var querySchemas sync.Map
+
+func NewQuery(sort string, limit int) *Query {
+	return &Query{Sort: sort, Limit: limit}
+}
+
+func (q *Query) Where(field, op string, value any) *Query {
+	q.Filters = append(q.Filters, Filter{field, op, value})
+	return q
+}
+
+// Any 添加一组 or 条件
+func (q *Query) Any(filters ...Filter) *Query {
+	q.Filters = append(q.Filters, Filter{Op: OP_ANY, Value: filters})
+	return q
+}
+
+// toQuery Load* 的参数为 *Query 时返回它
+func toQuery(query []any) *Query {
+	if len(query) == 1 {
+		if q, ok := query[0].(*Query); ok && q != nil {
+			return q
+		}
+	}
+	return nil
+}
+
+// prepare 校验过滤和排序字段，避免拼接任意列名
+func (q *Query) prepare(model any, sort string) error {
+	parsed, err := schema.Parse(model, &querySchemas, schema.NamingStrategy{})
+	if err != nil {
+		return err
+	}
+	q.schema, q.Sort = parsed, strings.TrimSpace(q.Sort)
+	if q.Sort == "" {
+		q.Sort = sort
+	}
+	q.desc = strings.HasPrefix(q.Sort, "-")
+	if q.field = q.lookup(strings.TrimPrefix(q.Sort, "-")); q.field == nil {
+		return fmt.Errorf("unknown sort field: %s", q.Sort)
+	}
+	if err := q.check(q.Filters); err != nil {
+		return err
+	}
+	q.Limit = min(q.Limit, MAX_LIMIT)
+	return nil
+}
+
+func (q *Query) check(filters []Filter) error {
+	for _, filter := range filters {
+		if filter.Op == OP_ANY {
+			group, ok := filter.Value.([]Filter)
+			if !ok {
+				return fmt.Errorf("filter any requires []Filter")
+			}
+			if err := q.check(group); err != nil {
+				return err
+			}
+			continue
+		}
+		if q.lookup(filter.Field) == nil {
+			return fmt.Errorf("unknown filter field: %s", filter.Field)
+		}
+		if col, ok := filter.Value.(Column); ok && q.lookup(string(col)) == nil {
+			return fmt.Errorf("unknown filter field: %s", col)
+		}
+		switch filter.Op {
+		case OP_IN:
+			if reflect.ValueOf(filter.Value).Kind() != reflect.Slice {
+				return fmt.Errorf("filter %s in requires a list", filter.Field)
+			}
+		case OP_EQ, OP_NE, OP_GT, OP_GTE, OP_LT, OP_LTE, OP_LIKE:
+		default:
+			return fmt.Errorf("unknown filter op: %s", filter.Op)
+		}
+	}
+	return nil
+}
+
+func (q *Query) lookup(name string) *schema.Field {
+	field := q.schema.LookUpField(name)
+	if field == nil || field.DBName == "" {
+		return nil
+	}
+	return field
+}
+
+// decode 解析游标，时间字段需转回 time.Time
+func (q *Query) decode() (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	result := new(cursor)
+	if err = json.Unmarshal(data, result); err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if str, ok := result.Value.(string); ok && q.field.DataType == schema.Time {
+		if result.Value, err = time.Parse(time.RFC3339Nano, str); err != nil {
+			return nil, fmt.Errorf("invalid cursor: %w", err)
+		}
+	}
+	return result, nil
+}
+
+func (q *Query) encode(item any) string {
+	value := reflect.Indirect(reflect.ValueOf(item))
+	sorted, _ := q.field.ValueOf(context.Background(), value)
+	id, _ := q.schema.PrioritizedPrimaryField.ValueOf(context.Background(), value)
+	if t, ok := sorted.(time.Time); ok {
+		sorted = t.Format(time.RFC3339Nano)
+	}
+	uid, _ := id.(uint)
+	data, _ := json.Marshal(cursor{sorted, uid})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// expr 将过滤条件转为 sql 表达式，字段已在 prepare 中校验
+func (q *Query) expr(filter Filter) clause.Expression {
+	if filter.Op == OP_ANY {
+		exprs := []clause.Expression{}
+		for _, item := range filter.Value.([]Filter) {
+			exprs = append(exprs, q.expr(item))
+		}
+		return clause.Or(exprs...)
+	}
+	column := clause.Column{Name: q.lookup(filter.Field).DBName}
+	value := filter.Value
+	if col, ok := value.(Column); ok {
+		value = clause.Column{Name: q.lookup(string(col)).DBName}
+	}
+	switch filter.Op {
+	case OP_IN:
+		values := []any{}
+		list := reflect.ValueOf(value)
+		for i := 0; list.Kind() == reflect.Slice && i < list.Len(); i++ {
+			values = append(values, list.Index(i).Interface())
+		}
+		return clause.IN{Column: column, Values: values}
+	case OP_LIKE:
+		return clause.Like{Column: column, Value: value}
+	}
+	return clause.Expr{SQL: "? " + filter.Op + " ?", Vars: []any{column, value}}
+}
+
+// apply 在 db 上应用过滤、游标、排序，并多取一条用于判断是否有下一页
+func (q *Query) apply(db *gorm.DB) (*gorm.DB, error) {
+	for _, filter := range q.Filters {
+		db = db.Where(q.expr(filter))
+	}
+
+	id := clause.Column{Name: q.schema.PrioritizedPrimaryField.DBName}
+	sort := clause.Column{Name: q.field.DBName}
+	if q.Cursor != "" {
+		last, err := q.decode()
+		if err != nil {
+			return nil, err
+		}
+		op := ">"
+		if q.desc {
+			op = "<"
+		}
+		if q.field.DBName == id.Name {
+			db = db.Where(clause.Expr{SQL: "? " + op + " ?", Vars: []any{id, last.ID}})
+		} else {
+			db = db.Where(clause.Expr{
+				SQL:  fmt.Sprintf("(? %s ? OR (? = ? AND ? %s ?))", op, op),
+				Vars: []any{sort, last.Value, sort, last.Value, id, last.ID},
+			})
+		}
+	}
+	db = db.Order(clause.OrderByColumn{Column: sort, Desc: q.desc})
+	if sort.Name != id.Name {
+		db = db.Order(clause.OrderByColumn{Column: id, Desc: q.desc})
+	}
+	if q.Limit > 0 {
+		db = db.Limit(q.Limit + 1)
+	}
+	return db, nil
+}
+
+// withQuery 应用 Load* 的参数，支持 *Query 或 gorm 风格的 where 条件
+// 使用 where 条件时按 sort 排序，limit 为 0 表示不限
+func withQuery(db *gorm.DB, model any, query []any, sort string, limit int) (*gorm.DB, *Query, error) {
+	if q := toQuery(query); q != nil {
+		if err := q.prepare(model, sort); err != nil {
+			return nil, nil, err
+		}
+		db, err := q.apply(db)
+		return db, q, err
+	}
+	if len(query) > 0 {
+		db = db.Where(query[0], query[1:]...)
+	}
+	column := clause.Column{Name: strings.TrimPrefix(sort, "-")}
+	db = db.Order(clause.OrderByColumn{Column: column, Desc: strings.HasPrefix(sort, "-")})
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	return db, nil, nil
+}
+
+// pageOf 去掉多取的一条并生成下一页游标
+func pageOf[T any](q *Query, list []*T) []*T {
+	if q == nil {
+		return list
+	}
+	q.Next = ""
+	if q.Limit <= 0 || len(list) <= q.Limit {
+		return list
+	}
+	list = list[:q.Limit]
+	q.Next = q.encode(list[len(list)-1])
+	return list
+}
+
+// queryList 在内存中执行查询，供 MockStore 使用
+func queryList[T any](q *Query, items []*T, sort string) ([]*T, error) {
+	if err := q.prepare(new(T), sort); err != nil {
+		return nil, err
+	}
+	var last *cursor
+	if q.Cursor != "" {
+		var err error
+		if last, err = q.decode(); err != nil {
+			return nil, err
+		}
+	}
+	result := []*T{}
+	for _, item := range items {
+		if q.match(item, last) {
+			result = append(result, item)
+		}
+	}
+	slices.SortStableFunc(result, func(a, b *T) int {
+		sortA, idA := q.values(a)
+		sortB, idB := q.values(b)
+		order := cmp.Or(compareValue(sortA, sortB), compareValue(idA, idB))
+		if q.desc {
+			return -order
+		}
+		return order
+	})
+	if q.Limit > 0 && len(result) > q.Limit+1 {
+		result = result[:q.Limit+1]
+	}
+	return pageOf(q, result), nil
+}
+
+func (q *Query) values(item any) (any, any) {
+	value := reflect.Indirect(reflect.ValueOf(item))
+	sorted, _ := q.field.ValueOf(context.Background(), value)
+	id, _ := q.schema.PrioritizedPrimaryField.ValueOf(context.Background(), value)
+	return sorted, id
+}
+
+// match 记录满足全部过滤条件，且位于游标之后
+func (q *Query) match(item any, last *cursor) bool {
+	value := reflect.Indirect(reflect.ValueOf(item))
+	for _, filter := range q.Filters {
+		if !q.matchFilter(value, filter) {
+			return false
+		}
+	}
+	if last == nil {
+		return true
+	}
+	sorted, id := q.values(item)
+	order := compareValue(sorted, last.Value)
+	if q.field == q.schema.PrioritizedPrimaryField {
+		order = compareValue(id, last.ID)
+	} else if order == 0 {
+		order = compareValue(id, last.ID)
+	}
+	if q.desc {
+		return order < 0
+	}
+	return order > 0
+}
+
+func (q *Query) matchFilter(value reflect.Value, filter Filter) bool {
+	if filter.Op == OP_ANY {
+		for _, item := range filter.Value.([]Filter) {
+			if q.matchFilter(value, item) {
+				return true
+			}
+		}
+		return false
+	}
+	got, _ := q.lookup(filter.Field).ValueOf(context.Background(), value)
+	if col, ok := filter.Value.(Column); ok {
+		filter.Value, _ = q.lookup(string(col)).ValueOf(context.Background(), value)
+	}
+	switch filter.Op {
+	case OP_IN:
+		list := reflect.ValueOf(filter.Value)
+		for i := 0; list.Kind() == reflect.Slice && i < list.Len(); i++ {
+			if compareValue(got, list.Index(i).Interface()) == 0 {
+				return true
+			}
+		}
+		return false
+	case OP_LIKE:
+		pattern := regexp.QuoteMeta(fmt.Sprint(filter.Value))
+		pattern = strings.NewReplacer("%", ".*", "_", ".").Replace(pattern)
+		matched, _ := regexp.MatchString("(?is)^"+pattern+"$", fmt.Sprint(got))
+		return matched
+	}
+	order := compareValue(got, filter.Value)
+	switch filter.Op {
+	case OP_EQ:
+		return order == 0
+	case OP_NE:
+		return order != 0
+	case OP_GT:
+		return order > 0
+	case OP_GTE:
+		return order >= 0
+	case OP_LT:
+		return order < 0
+	case OP_LTE:
+		return order <= 0
+	}
+	return false
+}
+
+// compareValue 比较数字、时间和字符串，其它类型按字符串比较
+func compareValue(a, b any) int {
+	a, b = derefValue(a), derefValue(b)
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return ta.Compare(tb)
+		}
+	}
+	if na, ok := toFloat(a); ok {
+		if nb, ok := toFloat(b); ok {
+			return cmp.Compare(na, nb)
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func derefValue(v any) any {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if !value.IsValid() {
+		return nil
+	}
+	return value.Interface()
+}
+
+func toFloat(v any) (float64, bool) {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	case reflect.Bool:
+		if value.Bool() {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
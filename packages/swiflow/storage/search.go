@@ -0,0 +1,333 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"swiflow/entity"
+	"swiflow/support"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// searchSpec 参与全文检索的表，每个表索引两列
+type searchSpec struct {
+	kind   string
+	table  string
+	cols   [2]string
+	fields string // 命中结果的标识字段
+}
+
+var searchSpecs = []searchSpec{
+	{
+		kind: entity.SEARCH_TASK, table: "llm_task",
+		cols:   [2]string{"name", "context"},
+		fields: "t.uuid AS task, '' AS uniq_id, 0 AS mem_id, t.botid AS bot, t.name AS title",
+	},
+	{
+		kind: entity.SEARCH_MSG, table: "llm_msg",
+		cols:   [2]string{"request", "respond"},
+		fields: "t.task_id AS task, t.uniq_id AS uniq_id, 0 AS mem_id, '' AS bot, '' AS title",
+	},
+	{
+		kind: entity.SEARCH_MEM, table: "llm_mem",
+		cols:   [2]string{"subject", "content"},
+		fields: "'' AS task, '' AS uniq_id, t.id AS mem_id, t.bot AS bot, t.subject AS title",
+	},
+}
+
+// searchRow 查询结果，snippet 为空时由 A、B 两列生成
+type searchRow struct {
+	Task    string
+	UniqId  string
+	MemId   uint
+	Bot     string
+	Title   string
+	A, B    string
+	Snippet string
+	Score   float64
+	Time    time.Time
+}
+
+// 片段前后保留的字数
+const snippetWidth = 40
+
+// searchTerms 按空白切分检索词，去掉引号避免破坏检索语法
+func searchTerms(query string) []string {
+	terms := []string{}
+	for _, term := range strings.Fields(query) {
+		term = strings.Trim(strings.ReplaceAll(term, `"`, ""), "*+-()")
+		if term != "" && !slices.Contains(terms, term) {
+			terms = append(terms, term)
+		}
+	}
+	return terms[:min(len(terms), 8)]
+}
+
+// shortest 最短检索词的字数
+func shortest(terms []string) int {
+	size := 0
+	for i, term := range terms {
+		if n := utf8.RuneCountInString(term); i == 0 || n < size {
+			size = n
+		}
+	}
+	return size
+}
+
+func searchKinds(kinds []string) []searchSpec {
+	if len(kinds) == 0 {
+		return searchSpecs
+	}
+	specs := []searchSpec{}
+	for _, spec := range searchSpecs {
+		if slices.Contains(kinds, spec.kind) {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// toHits 生成片段并按分数排序
+func toHits(kind string, rows []*searchRow, terms []string) []*SearchHit {
+	hits := []*SearchHit{}
+	for _, row := range rows {
+		snippet := row.Snippet
+		if snippet == "" {
+			text := strings.TrimSpace(row.A + "\n" + row.B)
+			snippet = support.Snippet(text, terms, snippetWidth)
+		}
+		hits = append(hits, &SearchHit{
+			Kind: kind, Task: row.Task, UniqId: row.UniqId,
+			MemId: row.MemId, Bot: row.Bot, Title: row.Title,
+			Snippet: snippet, Score: row.Score, Time: row.Time,
+		})
+	}
+	return hits
+}
+
+func sortHits(hits []*SearchHit, limit int) []*SearchHit {
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Time.After(hits[j].Time)
+	})
+	return hits[:min(len(hits), limit)]
+}
+
+// likeSearch 检索词过短无法使用全文索引时，退化为 LIKE 匹配
+func likeSearch(db *gorm.DB, spec searchSpec, terms []string, limit int) ([]*searchRow, error) {
+	a, b := spec.cols[0], spec.cols[1]
+	query := db.Table(spec.table + " AS t").Select(fmt.Sprintf(
+		"%s, t.%s AS a, t.%s AS b, t.updated_at AS time", spec.fields, a, b,
+	)).Where("t.deleted_at IS NULL")
+	// postgres 的 LIKE 区分大小写
+	op := support.If(db.Dialector.Name() == "postgres", "ILIKE", "LIKE")
+	for _, term := range terms {
+		like := "%" + term + "%"
+		query = query.Where(fmt.Sprintf("(t.%s %s ? OR t.%s %s ?)", a, op, b, op), like, like)
+	}
+	rows := []*searchRow{}
+	if err := query.Order("t.id DESC").Limit(limit).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	// 按命中次数计分
+	for _, row := range rows {
+		text := strings.ToLower(row.A + "\n" + row.B)
+		for _, term := range terms {
+			row.Score += float64(strings.Count(text, strings.ToLower(term)))
+		}
+	}
+	return rows, nil
+}
+
+// createFTS 为 sqlite 创建 FTS5 索引及同步触发器，使用 trigram 分词以支持中文
+func createFTS(db *gorm.DB) error {
+	for _, spec := range searchSpecs {
+		fts, a, b := spec.table+"_fts", spec.cols[0], spec.cols[1]
+		var count int64
+		db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", fts).Scan(&count)
+		stmts := []string{
+			fmt.Sprintf("CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(%s, %s, content='%s', content_rowid='id', tokenize='trigram')", fts, a, b, spec.table),
+			fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s_ai AFTER INSERT ON %s BEGIN INSERT INTO %s(rowid, %s, %s) VALUES (new.id, new.%s, new.%s); END", fts, spec.table, fts, a, b, a, b),
+			fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s_ad AFTER DELETE ON %s BEGIN INSERT INTO %s(%s, rowid, %s, %s) VALUES ('delete', old.id, old.%s, old.%s); END", fts, spec.table, fts, fts, a, b, a, b),
+			fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s_au AFTER UPDATE ON %s BEGIN INSERT INTO %s(%s, rowid, %s, %s) VALUES ('delete', old.id, old.%s, old.%s); INSERT INTO %s(rowid, %s, %s) VALUES (new.id, new.%s, new.%s); END", fts, spec.table, fts, fts, a, b, a, b, fts, a, b, a, b),
+		}
+		// 新建索引时导入已有数据
+		if count == 0 {
+			stmts = append(stmts, fmt.Sprintf("INSERT INTO %s(%s) VALUES ('rebuild')", fts, fts))
+		}
+		for _, stmt := range stmts {
+			if err := db.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("failed to create %s: %w", fts, err)
+			}
+		}
+	}
+	return nil
+}
+
+func dropFTS(db *gorm.DB) error {
+	for _, spec := range searchSpecs {
+		fts := spec.table + "_fts"
+		for _, stmt := range []string{
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s_ai", fts),
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s_ad", fts),
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s_au", fts),
+			fmt.Sprintf("DROP TABLE IF EXISTS %s", fts),
+		} {
+			if err := db.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("failed to drop %s: %w", fts, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Search 全文检索任务、消息和记忆，kinds 为空时检索全部
+func (s *SQLiteStorage) Search(query string, kinds []string, limit int) ([]*SearchHit, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []*SearchHit{}, nil
+	}
+	// trigram 至少需要 3 个字符
+	match := ""
+	if shortest(terms) >= 3 {
+		quoted := []string{}
+		for _, term := range terms {
+			quoted = append(quoted, `"`+term+`"`)
+		}
+		match = strings.Join(quoted, " ")
+	}
+
+	hits := []*SearchHit{}
+	for _, spec := range searchKinds(kinds) {
+		var err error
+		rows := []*searchRow{}
+		if match == "" {
+			rows, err = likeSearch(s.gormDB, spec, terms, limit)
+		} else {
+			fts := spec.table + "_fts"
+			err = s.gormDB.Raw(fmt.Sprintf(
+				`SELECT %s, snippet(%s, -1, '<mark>', '</mark>', '…', 24) AS snippet,
+				-bm25(%s) AS score, t.updated_at AS time
+				FROM %s JOIN %s AS t ON t.id = %s.rowid
+				WHERE %s MATCH ? AND t.deleted_at IS NULL
+				ORDER BY score DESC LIMIT ?`,
+				spec.fields, fts, fts, fts, spec.table, fts, fts,
+			), match, limit).Scan(&rows).Error
+		}
+		if err != nil {
+			log.Printf("[SQLITE]failed to search %s: %v", spec.table, err)
+			return nil, fmt.Errorf("failed to search %s: %w", spec.table, err)
+		}
+		hits = append(hits, toHits(spec.kind, rows, terms)...)
+	}
+	return sortHits(hits, limit), nil
+}
+
+// hasIndex mysql 中索引是否存在
+func hasIndex(db *gorm.DB, table, index string) bool {
+	var count int64
+	db.Raw(
+		`SELECT COUNT(*) FROM information_schema.statistics
+		WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?`,
+		table, index,
+	).Scan(&count)
+	return count > 0
+}
+
+// createFullText 为 mysql 创建 FULLTEXT 索引，使用 ngram 分词以支持中文
+func createFullText(db *gorm.DB) error {
+	for _, spec := range searchSpecs {
+		index := "ft_" + spec.table
+		if hasIndex(db, spec.table, index) {
+			continue
+		}
+		stmt := fmt.Sprintf(
+			"ALTER TABLE %s ADD FULLTEXT INDEX %s (%s, %s) WITH PARSER ngram",
+			spec.table, index, spec.cols[0], spec.cols[1],
+		)
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to create %s: %w", index, err)
+		}
+	}
+	return nil
+}
+
+func dropFullText(db *gorm.DB) error {
+	for _, spec := range searchSpecs {
+		index := "ft_" + spec.table
+		if !hasIndex(db, spec.table, index) {
+			continue
+		}
+		stmt := fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", spec.table, index)
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to drop %s: %w", index, err)
+		}
+	}
+	return nil
+}
+
+// Search 全文检索任务、消息和记忆，kinds 为空时检索全部
+func (s *MySQLStorage) Search(query string, kinds []string, limit int) ([]*SearchHit, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []*SearchHit{}, nil
+	}
+	// ngram 默认按 2 个字符切分
+	match := ""
+	if shortest(terms) >= 2 {
+		quoted := []string{}
+		for _, term := range terms {
+			quoted = append(quoted, `+"`+term+`"`)
+		}
+		match = strings.Join(quoted, " ")
+	}
+
+	hits := []*SearchHit{}
+	for _, spec := range searchKinds(kinds) {
+		var err error
+		rows := []*searchRow{}
+		if match == "" {
+			rows, err = likeSearch(s.gormDB, spec, terms, limit)
+		} else {
+			a, b := spec.cols[0], spec.cols[1]
+			against := fmt.Sprintf("MATCH(t.%s, t.%s) AGAINST(? IN BOOLEAN MODE)", a, b)
+			err = s.gormDB.Raw(fmt.Sprintf(
+				`SELECT %s, t.%s AS a, t.%s AS b, %s AS score, t.updated_at AS time
+				FROM %s AS t WHERE %s AND t.deleted_at IS NULL
+				ORDER BY score DESC LIMIT ?`,
+				spec.fields, a, b, against, spec.table, against,
+			), match, match, limit).Scan(&rows).Error
+		}
+		if err != nil {
+			log.Printf("[MYSQL]failed to search %s: %v", spec.table, err)
+			return nil, fmt.Errorf("failed to search %s: %w", spec.table, err)
+		}
+		hits = append(hits, toHits(spec.kind, rows, terms)...)
+	}
+	return sortHits(hits, limit), nil
+}
+
+// Search postgres 的内置分词不支持中文，统一使用 ILIKE 匹配
+func (s *PostgresStorage) Search(query string, kinds []string, limit int) ([]*SearchHit, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []*SearchHit{}, nil
+	}
+	hits := []*SearchHit{}
+	for _, spec := range searchKinds(kinds) {
+		rows, err := likeSearch(s.gormDB, spec, terms, limit)
+		if err != nil {
+			log.Printf("[POSTGRES]failed to search %s: %v", spec.table, err)
+			return nil, fmt.Errorf("failed to search %s: %w", spec.table, err)
+		}
+		hits = append(hits, toHits(spec.kind, rows, terms)...)
+	}
+	return sortHits(hits, limit), nil
+}
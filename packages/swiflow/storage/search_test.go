@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"path/filepath"
+	"strings"
+	"swiflow/entity"
+	"testing"
+)
+
+func TestSQLiteStorage_Search(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewSQLiteStorage(map[string]any{"path": path})
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	// 迁移前已有的数据也应被索引
+	store.gormDB.AutoMigrate(new(TaskEntity))
+	store.gormDB.Create(&TaskEntity{UUID: "task-0", Name: "整理发票报销单据"})
+	if err = store.AutoMigrate(); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+
+	store.SaveTask(&TaskEntity{UUID: "task-1", Name: "deploy website", BotId: "bot-1"})
+	store.SaveMsg(&MsgEntity{
+		TaskId: "task-1", UniqId: "msg-1", OpType: "user",
+		Request: "please deploy the website to production server",
+	})
+	mem := &MemEntity{Bot: "bot-1", Subject: "偏好", Content: "用户喜欢使用简体中文回复"}
+	store.SaveMem(mem)
+
+	hits, err := store.Search("deploy website", nil, 10)
+	if err != nil {
+		t.Fatalf("检索失败: %v", err)
+	}
+	kinds := map[string]*SearchHit{}
+	for _, hit := range hits {
+		kinds[hit.Kind] = hit
+	}
+	if kinds[entity.SEARCH_TASK] == nil || kinds[entity.SEARCH_MSG] == nil {
+		t.Fatalf("应命中任务和消息: %v", hits)
+	}
+	if msg := kinds[entity.SEARCH_MSG]; msg.UniqId != "msg-1" || !strings.Contains(msg.Snippet, "<mark>deploy</mark>") {
+		t.Errorf("消息片段错误: %s %s", msg.UniqId, msg.Snippet)
+	}
+
+	// 中文检索
+	hits, _ = store.Search("简体中文", []string{entity.SEARCH_MEM}, 10)
+	if len(hits) != 1 || hits[0].MemId != mem.ID || !strings.Contains(hits[0].Snippet, "<mark>") {
+		t.Errorf("中文检索失败: %v", hits)
+	}
+	hits, _ = store.Search("发票报销", []string{entity.SEARCH_TASK}, 10)
+	if len(hits) != 1 || hits[0].Task != "task-0" {
+		t.Errorf("迁移前的数据未被索引: %v", hits)
+	}
+	// 短词退化为 LIKE 匹配
+	hits, _ = store.Search("偏好", []string{entity.SEARCH_MEM}, 10)
+	if len(hits) != 1 || !strings.Contains(hits[0].Snippet, "<mark>偏好</mark>") {
+		t.Errorf("短词检索失败: %v", hits)
+	}
+
+	// 更新和删除后同步索引
+	mem.Content = "用户喜欢英文"
+	store.SaveMem(mem)
+	if hits, _ = store.Search("简体中文", nil, 10); len(hits) != 0 {
+		t.Errorf("更新后不应再命中: %v", hits)
+	}
+	store.gormDB.Delete(&MsgEntity{}, "uniq_id = ?", "msg-1")
+	if hits, _ = store.Search("production", nil, 10); len(hits) != 0 {
+		t.Errorf("删除后不应再命中: %v", hits)
+	}
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"path/filepath"
+	"strings"
+	"swiflow/entity"
+	"swiflow/secret"
+	"testing"
+)
+
+func TestSQLiteStorage_Secret(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("SECRET_PASSPHRASE", "storage-test")
+	t.Setenv("SECRET_KEYRING", "no")
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewSQLiteStorage(map[string]any{"path": path})
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	// 迁移前写入的明文配置
+	store.gormDB.AutoMigrate(new(CfgEntity))
+	store.gormDB.Exec(
+		"INSERT INTO llm_cfg (type, name, data) VALUES (?, ?, ?)",
+		entity.KEY_PROVIDER, "openai", `{"provider":"openai","apiKey":"sk-legacy"}`,
+	)
+	if err = store.AutoMigrate(); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	rawData := func(kind, name string) string {
+		var raw string
+		store.gormDB.Raw(
+			"SELECT data FROM llm_cfg WHERE type = ? AND name = ?", kind, name,
+		).Scan(&raw)
+		return raw
+	}
+	if raw := rawData(entity.KEY_PROVIDER, "openai"); strings.Contains(raw, "sk-legacy") {
+		t.Fatalf("迁移后明文应被加密: %s", raw)
+	}
+
+	cfg := &CfgEntity{
+		Type: entity.KEY_PROVIDER, Name: "deepseek",
+		Data: map[string]any{"provider": "deepseek", "apiKey": "sk-new"},
+	}
+	if err = store.SaveCfg(cfg); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+	if cfg.Data["apiKey"] != "sk-new" {
+		t.Errorf("保存后调用方数据应为明文: %v", cfg.Data)
+	}
+	raw := rawData(entity.KEY_PROVIDER, "deepseek")
+	if strings.Contains(raw, "sk-new") || !strings.Contains(raw, secret.PREFIX) {
+		t.Fatalf("入库数据应为密文: %s", raw)
+	}
+	if !strings.Contains(raw, `"provider":"deepseek"`) {
+		t.Errorf("普通字段不应加密: %s", raw)
+	}
+
+	found := &CfgEntity{Type: entity.KEY_PROVIDER, Name: "openai"}
+	if err = store.FindCfg(found); err != nil || found.Data["apiKey"] != "sk-legacy" {
+		t.Fatalf("读取应自动解密: %v, %v", found.Data, err)
+	}
+	list, _ := store.LoadCfg()
+	for _, item := range list {
+		if key, _ := item.Data["apiKey"].(string); strings.HasPrefix(key, secret.PREFIX) {
+			t.Errorf("列表读取应自动解密: %v", item.Data)
+		}
+	}
+
+	// 命名密钥整体加密，可通过引用读取
+	store.SaveCfg(&CfgEntity{
+		Type: entity.KEY_SECRET, Name: "OPENAI",
+		Data: map[string]any{"value": "sk-named"},
+	})
+	if raw := rawData(entity.KEY_SECRET, "OPENAI"); strings.Contains(raw, "sk-named") {
+		t.Errorf("命名密钥应加密: %s", raw)
+	}
+	if val, err := FindSecret(store, "OPENAI"); err != nil || val != "sk-named" {
+		t.Errorf("读取命名密钥失败: %q, %v", val, err)
+	}
+
+	// 回滚后恢复为明文
+	if _, err = store.Migrator().Down(4); err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	if raw := rawData(entity.KEY_PROVIDER, "deepseek"); !strings.Contains(raw, "sk-new") {
+		t.Errorf("回滚后应为明文: %s", raw)
+	}
+}
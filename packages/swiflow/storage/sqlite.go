@@ -42,37 +42,21 @@ func NewSQLiteStorage(config map[string]any) (*SQLiteStorage, error) {
 	return &SQLiteStorage{gormDB: gormDB}, nil
 }
 
-// 自动迁移表结构
-func (s *SQLiteStorage) AutoMigrate() error {
-	var msg = new(MsgEntity)
-	var migrator = s.gormDB.Migrator()
-	if migrator.HasColumn(msg, "bot_id") {
-		migrator.RenameColumn(msg, "bot_id", "group")
-	}
-	if migrator.HasColumn(msg, "msg_id") {
-		migrator.RenameColumn(msg, "msg_id", "uniq_id")
-	}
-	if migrator.HasColumn(msg, "pre_msg") {
-		migrator.RenameColumn(msg, "pre_msg", "prev_id")
-	}
-	task, bot := new(TaskEntity), new(BotEntity)
-	if err := s.gormDB.AutoMigrate(task, bot, msg); err != nil {
-		log.Printf("[SQLITE]failed to migrate tables: %v", err)
-		return fmt.Errorf("failed to migrate tables: %w", err)
-	}
+// Migrator 版本化迁移
+func (s *SQLiteStorage) Migrator() *Migrator {
+	return NewMigrator(s.gormDB, "sqlite")
+}
 
-	cfg, tool := new(CfgEntity), new(ToolEntity)
-	if err := s.gormDB.AutoMigrate(cfg, tool); err != nil {
-		log.Printf("[SQLITE]failed to migrate tables: %v", err)
-		return fmt.Errorf("failed to migrate tables: %w", err)
-	}
+func (s *SQLiteStorage) Backup() *Backup {
+	return NewBackup(s.gormDB, "sqlite")
+}
 
-	mem, todo := new(MemEntity), new(TodoEntity)
-	if err := s.gormDB.AutoMigrate(mem, todo); err != nil {
+// AutoMigrate 执行全部待执行的迁移
+func (s *SQLiteStorage) AutoMigrate() error {
+	if _, err := s.Migrator().Up(0); err != nil {
 		log.Printf("[SQLITE]failed to migrate tables: %v", err)
 		return fmt.Errorf("failed to migrate tables: %w", err)
 	}
-
 	return nil
 }
 
@@ -93,22 +77,20 @@ func (s *SQLiteStorage) InitTask(task *TaskEntity) error {
 // LoadTask lists tasks with optional query parameters
 func (s *SQLiteStorage) LoadTask(query ...any) ([]*TaskEntity, error) {
 	var models []*TaskEntity
-	threeMonthsAgo := time.Now().AddDate(0, -3, 0)
-
-	// Start with base query for time filter
-	db := s.gormDB.Where("updated_at >= ?", threeMonthsAgo)
-
-	// Apply additional query conditions if provided
-	if len(query) > 0 {
-		db = db.Where(query[0], query[1:]...)
+	db := s.gormDB.Model(&TaskEntity{})
+	// 未分页时只列出最近三个月的任务
+	if toQuery(query) == nil {
+		db = db.Where("updated_at >= ?", time.Now().AddDate(0, -3, 0))
 	}
-
-	if result := db.Order("id DESC").Find(&models); result.Error != nil {
-		log.Printf("[SQLITE]failed to list task: %v", result.Error)
-		return nil, fmt.Errorf("failed to list task: %w", result.Error)
+	db, q, err := withQuery(db, &TaskEntity{}, query, "-id", 0)
+	if err != nil {
+		return nil, err
 	}
-
-	return models, nil
+	if r := db.Find(&models); r.Error != nil {
+		log.Printf("[SQLITE]failed to list task: %v", r.Error)
+		return nil, fmt.Errorf("failed to list task: %w", r.Error)
+	}
+	return pageOf(q, models), nil
 }
 
 func (s *SQLiteStorage) FindTask(task *TaskEntity) error {
@@ -135,6 +117,7 @@ func (s *SQLiteStorage) SaveTask(task *TaskEntity) error {
 	update := map[string]any{
 		"uuid": task.UUID, "name": task.Name, "home": task.Home,
 		"group": task.Group, "botid": task.BotId, "state": task.State,
+		"botver": task.BotVer,
 		"sessid": task.SessID, "source": task.Source, "desc": task.Desc,
 		"context": task.Context, "command": task.Command, "process": task.Process,
 	}
@@ -194,17 +177,21 @@ func (s *SQLiteStorage) SaveMsg(msg *MsgEntity) error {
 }
 
 // LoadMsg 加载消息数据
-func (s *SQLiteStorage) LoadMsg(task *TaskEntity) ([]*MsgEntity, error) {
-	// var msgs []*Msg
+func (s *SQLiteStorage) LoadMsg(task *TaskEntity, query ...any) ([]*MsgEntity, error) {
 	var result []*MsgEntity
-
-	// 使用GORM查询消息
-	query := s.gormDB.Where("task_id = ?", task.UUID).Order("id ASC")
-	if err := query.Find(&result).Error; err != nil {
-		log.Printf("[SQLITE]failed to query msgs: %v", err)
-		return result, fmt.Errorf("failed to query msgs: %w", err)
+	db := s.gormDB.Model(&MsgEntity{})
+	if task != nil {
+		db = db.Where("task_id = ?", task.UUID)
+	}
+	db, q, err := withQuery(db, &MsgEntity{}, query, "id", 0)
+	if err != nil {
+		return nil, err
 	}
-	return result, nil
+	if r := db.Find(&result); r.Error != nil {
+		log.Printf("[SQLITE]failed to query msgs: %v", r.Error)
+		return result, fmt.Errorf("failed to query msgs: %w", r.Error)
+	}
+	return pageOf(q, result), nil
 }
 
 func (s *SQLiteStorage) FindBot(bot *BotEntity) error {
@@ -235,35 +222,52 @@ func (s *SQLiteStorage) SaveBot(bot *BotEntity) error {
 		"emoji": bot.Emoji, "tools": bot.Tools, "deleted_at": nil,
 		"sys_prompt": bot.SysPrompt, "use_prompt": bot.UsePrompt,
 		"leader": bot.Leader, "home": bot.Home, "provider": bot.Provider,
+		"output_schema": bot.OutputSchema, "remote": bot.Remote,
+	}
+	// 定义有变化时记录新版本，与 bot 在同一事务中保存
+	err := saveVersioned(s.gormDB, bot, func(tx *gorm.DB) error {
+		updates["version"] = bot.Version
+		clauses := clause.OnConflict{
+			Columns:   []clause.Column{{Name: "uuid"}},
+			DoUpdates: clause.AssignmentColumns(maputil.Keys(updates)),
+		}
+		return tx.Model(bot).Clauses(clauses).Assign(updates).Create(bot).Error
+	})
+	if err != nil {
+		log.Printf("[SQLITE]failed to save bot: %v", err)
+		return fmt.Errorf("failed to save bot: %w", err)
 	}
+	return nil
+}
 
-	clauses := clause.OnConflict{
-		Columns:   []clause.Column{{Name: "uuid"}},
-		DoUpdates: clause.AssignmentColumns(maputil.Keys(updates)),
+// LoadVersion loads bot versions, newest first
+func (s *SQLiteStorage) LoadVersion(query ...any) ([]*BotVersion, error) {
+	var result []*BotVersion
+	db := s.gormDB.Model(&BotVersion{})
+	db, q, err := withQuery(db, &BotVersion{}, query, "-version", 500)
+	if err != nil {
+		return nil, err
 	}
-	query = s.gormDB.Model(bot).Clauses(clauses).Assign(updates)
-	if r := query.Create(bot); r.Error != nil {
-		log.Printf("[SQLITE]failed to save bot: %v", r.Error)
-		return fmt.Errorf("failed to save bot: %w", r.Error)
+	if r := db.Find(&result); r.Error != nil {
+		log.Printf("[SQLITE]failed to query versions: %v", r.Error)
+		return nil, fmt.Errorf("failed to query versions: %w", r.Error)
 	}
-	return nil
+	return pageOf(q, result), nil
 }
 
 // LoadBot loads bots with optional query parameters
 func (s *SQLiteStorage) LoadBot(query ...any) ([]*BotEntity, error) {
 	var result []*BotEntity
 	db := s.gormDB.Model(&BotEntity{})
-
-	// Apply additional query conditions if provided
-	if len(query) > 0 {
-		db = db.Where(query[0], query[1:]...)
+	db, q, err := withQuery(db, &BotEntity{}, query, "-id", 0)
+	if err != nil {
+		return nil, err
 	}
-
-	if r := db.Order("id desc").Preload("Memories").Find(&result); r.Error != nil {
+	if r := db.Preload("Memories").Find(&result); r.Error != nil {
 		log.Printf("[SQLITE]failed to query bot: %v", r.Error)
-		return result, fmt.Errorf("failed to query bot: %w", r.Error)
+		return nil, fmt.Errorf("failed to query bot: %w", r.Error)
 	}
-	return result, nil
+	return pageOf(q, result), nil
 }
 
 func (s *SQLiteStorage) FindCfg(cfg *CfgEntity) error {
@@ -310,17 +314,15 @@ func (s *SQLiteStorage) SaveCfg(cfg *CfgEntity) error {
 func (s *SQLiteStorage) LoadCfg(query ...any) ([]*CfgEntity, error) {
 	var result []*CfgEntity
 	db := s.gormDB.Model(&CfgEntity{})
-
-	// Apply additional query conditions if provided
-	if len(query) > 0 {
-		db = db.Where(query[0], query[1:]...)
+	db, q, err := withQuery(db, &CfgEntity{}, query, "id", 0)
+	if err != nil {
+		return nil, err
 	}
-
-	if r := db.Order("id ASC").Find(&result); r.Error != nil {
+	if r := db.Find(&result); r.Error != nil {
 		log.Printf("[SQLITE]failed to query config: %v", r.Error)
-		return result, fmt.Errorf("failed to query config: %w", r.Error)
+		return nil, fmt.Errorf("failed to query config: %w", r.Error)
 	}
-	return result, nil
+	return pageOf(q, result), nil
 }
 
 func (s *SQLiteStorage) FindMem(mem *MemEntity) error {
@@ -351,6 +353,10 @@ func (s *SQLiteStorage) SaveMem(mem *MemEntity) error {
 		updates := map[string]any{
 			"type": mem.Type, "subject": mem.Subject,
 			"bot": mem.Bot, "content": mem.Content,
+			"scope": mem.Scope, "target": mem.Target,
+			"vector": mem.Vector, "embedder": mem.Embedder,
+			"importance": mem.Importance, "expire_at": mem.ExpireAt,
+			"used_at": mem.UsedAt, "used_count": mem.UsedCount,
 		}
 		if r := s.gormDB.Model(mem).Where("id = ?", mem.ID).Updates(updates); r.Error != nil {
 			log.Printf("[SQLITE]failed to update mem: %v", r.Error)
@@ -371,17 +377,15 @@ func (s *SQLiteStorage) SaveMem(mem *MemEntity) error {
 func (s *SQLiteStorage) LoadMem(query ...any) ([]*MemEntity, error) {
 	var result []*MemEntity
 	db := s.gormDB.Model(&MemEntity{})
-
-	// Apply additional query conditions if provided
-	if len(query) > 0 {
-		db = db.Where(query[0], query[1:]...)
+	db, q, err := withQuery(db, &MemEntity{}, query, "-id", 0)
+	if err != nil {
+		return nil, err
 	}
-
-	if r := db.Order("id desc").Find(&result); r.Error != nil {
+	if r := db.Find(&result); r.Error != nil {
 		log.Printf("[SQLITE]failed to query mem: %v", r.Error)
 		return nil, fmt.Errorf("failed to query mem: %w", r.Error)
 	}
-	return result, nil
+	return pageOf(q, result), nil
 }
 
 func (s *SQLiteStorage) FindTool(tool *ToolEntity) error {
@@ -429,17 +433,15 @@ func (s *SQLiteStorage) SaveTool(tool *ToolEntity) error {
 func (s *SQLiteStorage) LoadTool(query ...any) ([]*ToolEntity, error) {
 	var result []*ToolEntity
 	db := s.gormDB.Model(&ToolEntity{})
-
-	// Apply additional query conditions if provided
-	if len(query) > 0 {
-		db = db.Where(query[0], query[1:]...)
+	db, q, err := withQuery(db, &ToolEntity{}, query, "-id", 0)
+	if err != nil {
+		return nil, err
 	}
-
-	if r := db.Order("id desc").Find(&result); r.Error != nil {
+	if r := db.Find(&result); r.Error != nil {
 		log.Printf("[SQLITE]failed to query tools: %v", r.Error)
 		return nil, fmt.Errorf("failed to query tools: %w", r.Error)
 	}
-	return result, nil
+	return pageOf(q, result), nil
 }
 
 // TodoEntity 相关方法
@@ -478,18 +480,64 @@ func (s *SQLiteStorage) SaveTodo(todo *TodoEntity) error {
 func (s *SQLiteStorage) LoadTodo(query ...any) ([]*TodoEntity, error) {
 	var result []*TodoEntity
 	db := s.gormDB.Model(&TodoEntity{})
-
 	// If no query parameters provided, default to undone todos (backward compatibility)
 	if len(query) == 0 {
-		db = db.Where("done = ?", 0)
-	} else {
-		// Apply query conditions - first parameter can be used to specify done status
-		db = db.Where(query[0], query[1:]...)
+		query = []any{"done = ?", 0}
 	}
-
-	if r := db.Order("id desc").Find(&result); r.Error != nil {
+	db, q, err := withQuery(db, &TodoEntity{}, query, "-id", 0)
+	if err != nil {
+		return nil, err
+	}
+	if r := db.Find(&result); r.Error != nil {
 		log.Printf("[SQLITE]failed to query todos: %v", r.Error)
 		return nil, fmt.Errorf("failed to query todos: %w", r.Error)
 	}
-	return result, nil
+	return pageOf(q, result), nil
+}
+
+// AuditEntity 相关方法
+func (s *SQLiteStorage) SaveAudit(audit *AuditEntity) error {
+	if r := s.gormDB.Create(audit); r.Error != nil {
+		log.Printf("[SQLITE]failed to save audit: %v", r.Error)
+		return fmt.Errorf("failed to save audit: %w", r.Error)
+	}
+	return nil
+}
+
+// LoadAudit loads recent audits with optional query parameters
+func (s *SQLiteStorage) LoadAudit(query ...any) ([]*AuditEntity, error) {
+	var result []*AuditEntity
+	db := s.gormDB.Model(&AuditEntity{})
+	db, q, err := withQuery(db, &AuditEntity{}, query, "-id", 500)
+	if err != nil {
+		return nil, err
+	}
+	if r := db.Find(&result); r.Error != nil {
+		log.Printf("[SQLITE]failed to query audits: %v", r.Error)
+		return nil, fmt.Errorf("failed to query audits: %w", r.Error)
+	}
+	return pageOf(q, result), nil
+}
+
+func (s *SQLiteStorage) SaveEval(eval *EvalEntity) error {
+	if r := s.gormDB.Create(eval); r.Error != nil {
+		log.Printf("[SQLITE]failed to save eval: %v", r.Error)
+		return fmt.Errorf("failed to save eval: %w", r.Error)
+	}
+	return nil
+}
+
+// LoadEval loads eval results with optional query parameters
+func (s *SQLiteStorage) LoadEval(query ...any) ([]*EvalEntity, error) {
+	var result []*EvalEntity
+	db := s.gormDB.Model(&EvalEntity{})
+	db, q, err := withQuery(db, &EvalEntity{}, query, "id", 2000)
+	if err != nil {
+		return nil, err
+	}
+	if r := db.Find(&result); r.Error != nil {
+		log.Printf("[SQLITE]failed to query evals: %v", r.Error)
+		return nil, fmt.Errorf("failed to query evals: %w", r.Error)
+	}
+	return pageOf(q, result), nil
 }
@@ -1,8 +1,11 @@
 package storage
 
 import (
+	"log"
 	"strings"
 	"swiflow/config"
+	"swiflow/entity"
+	"swiflow/secret"
 )
 
 type MyStore interface {
@@ -15,11 +18,12 @@ type MyStore interface {
 
 	FindMsg(*MsgEntity) error
 	SaveMsg(*MsgEntity) error
-	LoadMsg(*TaskEntity) ([]*MsgEntity, error)
+	LoadMsg(task *TaskEntity, query ...any) ([]*MsgEntity, error)
 
 	FindBot(*BotEntity) error
 	SaveBot(*BotEntity) error
 	LoadBot(query ...any) ([]*BotEntity, error)
+	LoadVersion(query ...any) ([]*BotVersion, error)
 
 	FindCfg(*CfgEntity) error
 	SaveCfg(*CfgEntity) error
@@ -36,6 +40,14 @@ type MyStore interface {
 	FindTodo(*TodoEntity) error
 	SaveTodo(*TodoEntity) error
 	LoadTodo(query ...any) ([]*TodoEntity, error)
+
+	SaveAudit(*AuditEntity) error
+	LoadAudit(query ...any) ([]*AuditEntity, error)
+
+	SaveEval(*EvalEntity) error
+	LoadEval(query ...any) ([]*EvalEntity, error)
+
+	Search(query string, kinds []string, limit int) ([]*SearchHit, error)
 }
 
 var mystore MyStore
@@ -44,7 +56,45 @@ func GetStorage() (MyStore, error) {
 	if mystore != nil {
 		return mystore, nil
 	}
-	needUpgrade := config.NeedUpgrade()
+	store, err := OpenStorage()
+	if store == nil || err != nil {
+		return store, err
+	}
+	// 启动时执行待执行的迁移，AUTO_MIGRATE=no 时需通过 -m migrate 手动执行
+	if m, ok := store.(Migratable); ok {
+		pending, err := m.Migrator().Pending()
+		if err != nil {
+			return nil, err
+		}
+		if len(pending) > 0 {
+			if config.GetStr("AUTO_MIGRATE", "yes") != "no" {
+				err = store.AutoMigrate()
+			} else {
+				log.Printf("[MIGRATION] %d pending migrations, run `-m migrate up`", len(pending))
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	mystore = store
+	secret.SetLookup(func(name string) (string, error) {
+		return FindSecret(store, name)
+	})
+	return store, nil
+}
+
+// FindSecret 读取命名密钥的明文，供 secret://NAME 引用解析
+func FindSecret(store MyStore, name string) (string, error) {
+	cfg := &CfgEntity{Type: entity.KEY_SECRET, Name: name}
+	if err := store.FindCfg(cfg); err != nil {
+		return "", err
+	}
+	return cfg.GetStr("value"), nil
+}
+
+// OpenStorage 按配置连接存储，不执行迁移
+func OpenStorage() (MyStore, error) {
 	kind := config.GetStr("STORAGE_TYPE", "sqlite")
 	cfg := map[string]any{"path": config.GetWorkHome()}
 	switch strings.ToLower(kind) {
@@ -58,26 +108,16 @@ func GetStorage() (MyStore, error) {
 		case error:
 			return nil, dsn
 		}
-	}
-	store, err := NewStorage(kind, cfg)
-	if store == nil || err != nil {
-		return store, err
-	}
-	// handle migrate
-	switch strings.ToLower(kind) {
-	case "sqlite", "mysql":
-		// Call without parameters to maintain existing behavior
-		if _, err = store.LoadCfg(); err != nil {
-			if strings.Contains(err.Error(), "no such table") {
-				needUpgrade = true
-			}
+	case "postgres":
+		dsn := config.PostgresDSN()
+		switch dsn := dsn.(type) {
+		case string:
+			cfg["dsn"] = dsn
+		case error:
+			return nil, dsn
 		}
 	}
-	if needUpgrade {
-		err = store.AutoMigrate()
-	}
-	mystore = store
-	return store, err
+	return NewStorage(kind, cfg)
 }
 
 func NewStorage(kind string, config map[string]any) (MyStore, error) {
@@ -86,6 +126,8 @@ func NewStorage(kind string, config map[string]any) (MyStore, error) {
 		return NewMockStore(), nil
 	case "mysql":
 		return NewMySQLStorage(config)
+	case "postgres":
+		return NewPostgresStorage(config)
 	case "sqlite":
 		return NewSQLiteStorage(config)
 	default:
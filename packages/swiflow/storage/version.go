@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"errors"
+	"swiflow/entity"
+
+	"gorm.io/gorm"
+)
+
+// 并发保存同一 bot 时版本号冲突的重试次数
+const versionRetries = 3
+
+// nextVersion 与最近一个版本对比，定义有变化时返回待保存的新版本
+// 同时把版本号写回 bot.Version
+func nextVersion(db *gorm.DB, bot *BotEntity) *BotVersion {
+	last := new(BotVersion)
+	db.Where("bot = ?", bot.UUID).Order("version desc").Limit(1).Find(last)
+	version := entity.NewBotVersion(bot)
+	if last.ID != 0 && last.Same(version) {
+		bot.Version = last.Version
+		return nil
+	}
+	version.Version = last.Version + 1
+	bot.Version = version.Version
+	return version
+}
+
+// saveVersioned 在同一事务中计算版本号、保存 bot 并记录新版本
+// (bot, version) 唯一索引冲突说明有并发保存，重新读取最新版本后重试
+func saveVersioned(db *gorm.DB, bot *BotEntity, save func(tx *gorm.DB) error) error {
+	id := bot.ID
+	var err error
+	for i := 0; i < versionRetries; i++ {
+		bot.ID = id
+		err = db.Transaction(func(tx *gorm.DB) error {
+			version := nextVersion(tx, bot)
+			if err := save(tx); err != nil {
+				return err
+			}
+			if version == nil {
+				return nil
+			}
+			return tx.Create(version).Error
+		})
+		if !isDuplicate(db, err) {
+			return err
+		}
+	}
+	return err
+}
+
+// isDuplicate 唯一索引冲突，各数据库的错误经方言转换后判断
+func isDuplicate(db *gorm.DB, err error) bool {
+	if err == nil {
+		return false
+	}
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
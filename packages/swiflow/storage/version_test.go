@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"path/filepath"
+	"slices"
+	"swiflow/entity"
+	"testing"
+)
+
+func TestSQLiteStorage_BotVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewSQLiteStorage(map[string]any{"path": path})
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	if err = store.AutoMigrate(); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+
+	bot := &BotEntity{
+		UUID: "bot-1", Name: "coder", Type: "worker",
+		UsePrompt: "line1\nline2", Tools: []string{"command"},
+		Author: "alice",
+	}
+	if err = store.SaveBot(bot); err != nil || bot.Version != 1 {
+		t.Fatalf("首次保存应为版本1: %d %v", bot.Version, err)
+	}
+	// 内容未变化不产生新版本
+	bot.Home = "/tmp"
+	if store.SaveBot(bot); bot.Version != 1 {
+		t.Errorf("内容未变化不应产生新版本: %d", bot.Version)
+	}
+	bot.UsePrompt, bot.Tools = "line1\nline3", []string{"command", "browser"}
+	bot.Author = "bob"
+	if store.SaveBot(bot); bot.Version != 2 {
+		t.Errorf("修改后应为版本2: %d", bot.Version)
+	}
+
+	list, _ := store.LoadVersion("bot = ?", bot.UUID)
+	if len(list) != 2 || list[0].Version != 2 || list[0].Author != "bob" {
+		t.Fatalf("版本列表错误: %v", list)
+	}
+	diff := entity.DiffVersion(list[1], list[0])
+	lines := diff["prompts"].(map[string]any)["usePrompt"].([]string)
+	if !slices.Contains(lines, "- line2") || !slices.Contains(lines, "+ line3") {
+		t.Errorf("提示词差异错误: %v", lines)
+	}
+	tools := diff["tools"].(map[string]any)
+	if added := tools["added"].([]string); len(added) != 1 || added[0] != "browser" {
+		t.Errorf("工具差异错误: %v", tools)
+	}
+
+	// 回滚生成内容相同的新版本
+	list[1].Apply(bot)
+	if store.SaveBot(bot); bot.Version != 3 || bot.UsePrompt != "line1\nline2" {
+		t.Errorf("回滚应生成版本3: %d", bot.Version)
+	}
+	found := &BotEntity{UUID: bot.UUID}
+	if store.FindBot(found); found.Version != 3 || len(found.Tools) != 1 {
+		t.Errorf("回滚后的定义未保存: %d %v", found.Version, found.Tools)
+	}
+}
+
+func TestSQLiteStorage_UniqueVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewSQLiteStorage(map[string]any{"path": path})
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	if err = store.AutoMigrate(); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	// 模拟唯一索引之前并发保存产生的重复版本号
+	migrator := store.Migrator()
+	if _, err = migrator.Down(5); err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	for _, name := range []string{"a", "b"} {
+		store.gormDB.Create(&BotVersion{Bot: "bot-1", Version: 1, Name: name})
+	}
+	if _, err = migrator.Up(0); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	list, _ := store.LoadVersion("bot = ?", "bot-1")
+	if len(list) != 2 || list[0].Version != 2 || list[1].Version != 1 {
+		t.Fatalf("重复版本号应顺延: %v", list)
+	}
+
+	err = store.gormDB.Create(&BotVersion{Bot: "bot-1", Version: 2}).Error
+	if !isDuplicate(store.gormDB, err) {
+		t.Errorf("重复版本号应被唯一索引拒绝: %v", err)
+	}
+	bot := &BotEntity{UUID: "bot-1", Name: "c", Type: "worker"}
+	if err = store.SaveBot(bot); err != nil || bot.Version != 3 {
+		t.Errorf("保存应生成版本3: %d %v", bot.Version, err)
+	}
+}
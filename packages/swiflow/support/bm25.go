@@ -0,0 +1,122 @@
+package support
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Tokenize 英文、数字按单词切分，中日韩文字按单字及相邻两字切分
+func Tokenize(text string) []string {
+	tokens := []string{}
+	var word []rune
+	var prev rune
+	flush := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flush()
+			tokens = append(tokens, string(r))
+			if prev != 0 {
+				tokens = append(tokens, string([]rune{prev, r}))
+			}
+			prev = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			word = append(word, r)
+		default:
+			flush()
+		}
+		prev = 0
+	}
+	flush()
+	return tokens
+}
+
+// BM25 计算每个文档与查询的相关度，分数越高越相关
+func BM25(query string, docs []string) []float64 {
+	const k1, b = 1.2, 0.75
+	scores := make([]float64, len(docs))
+	terms := Tokenize(query)
+	if len(docs) == 0 || len(terms) == 0 {
+		return scores
+	}
+
+	var total float64
+	freqs := make([]map[string]int, len(docs))
+	lengths := make([]float64, len(docs))
+	df := map[string]int{}
+	for i, doc := range docs {
+		freqs[i] = map[string]int{}
+		for _, token := range Tokenize(doc) {
+			freqs[i][token] += 1
+			lengths[i] += 1
+		}
+		for token := range freqs[i] {
+			df[token] += 1
+		}
+		total += lengths[i]
+	}
+	avg := math.Max(total/float64(len(docs)), 1)
+
+	seen := map[string]bool{}
+	for _, term := range terms {
+		if seen[term] || df[term] == 0 {
+			continue
+		}
+		seen[term] = true
+		n := float64(df[term])
+		idf := math.Log(1 + (float64(len(docs))-n+0.5)/(n+0.5))
+		for i := range docs {
+			tf := float64(freqs[i][term])
+			if tf == 0 {
+				continue
+			}
+			scores[i] += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*lengths[i]/avg))
+		}
+	}
+	return scores
+}
+
+// Cosine 计算两个向量的余弦相似度
+func Cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// Jaccard 计算两段文本分词集合的重合度
+func Jaccard(a, b string) float64 {
+	setA, setB := map[string]bool{}, map[string]bool{}
+	for _, token := range Tokenize(a) {
+		setA[token] = true
+	}
+	for _, token := range Tokenize(b) {
+		setB[token] = true
+	}
+	if len(setA) == 0 || len(setB) == 0 {
+		return 0
+	}
+	common := 0
+	for token := range setA {
+		if setB[token] {
+			common += 1
+		}
+	}
+	return float64(common) / float64(len(setA)+len(setB)-common)
+}
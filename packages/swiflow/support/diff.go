@@ -0,0 +1,55 @@
+package support
+
+import (
+	"strings"
+)
+
+// LineDiff 按行对比文本，返回带 "+ "/"- "/"  " 前缀的差异行
+// 基于最长公共子序列，适用于提示词这类较短文本
+func LineDiff(from, to string) []string {
+	a, b := splitLines(from), splitLines(to)
+	// lcs[i][j] 为 a[i:] 与 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	result := []string{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			result = append(result, "  "+a[i])
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, "- "+a[i])
+			i += 1
+		default:
+			result = append(result, "+ "+b[j])
+			j += 1
+		}
+	}
+	for ; i < len(a); i++ {
+		result = append(result, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		result = append(result, "+ "+b[j])
+	}
+	return result
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(text, "\n"), "\n")
+}
@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/duke-git/lancet/v2/strutil"
 	"github.com/google/jsonschema-go/jsonschema"
@@ -260,3 +261,55 @@ func MapToSchema(m map[string]any) (*jsonschema.Schema, error) {
 	}
 	return schema, nil
 }
+
+// Snippet 截取 text 中第一个命中 terms 的片段，命中的词用 <mark> 标记
+func Snippet(text string, terms []string, width int) string {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	// 找出所有命中的位置
+	marks := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		needle := []rune(strings.ToLower(term))
+		if len(needle) == 0 {
+			continue
+		}
+		for i := 0; i+len(needle) <= len(lower); i++ {
+			if string(lower[i:i+len(needle)]) != string(needle) {
+				continue
+			}
+			for j := i; j < i+len(needle); j++ {
+				marks[j] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+		}
+	}
+	start, end := 0, min(len(runes), width*2)
+	if first >= 0 {
+		start = max(0, first-width)
+		end = min(len(runes), first+width)
+	}
+
+	var s strings.Builder
+	if start > 0 {
+		s.WriteString("…")
+	}
+	for i := start; i < end; i++ {
+		if marks[i] && (i == start || !marks[i-1]) {
+			s.WriteString("<mark>")
+		}
+		s.WriteRune(runes[i])
+		if marks[i] && (i == end-1 || !marks[i+1]) {
+			s.WriteString("</mark>")
+		}
+	}
+	if end < len(runes) {
+		s.WriteString("…")
+	}
+	return strings.Join(strings.Fields(s.String()), " ")
+}